@@ -0,0 +1,73 @@
+package main
+
+import (
+	"database/sql"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"badminton-reservation-api/models"
+	"badminton-reservation-api/services/auth"
+	"badminton-reservation-api/utils"
+
+	"github.com/google/uuid"
+	"github.com/joho/godotenv"
+	_ "github.com/lib/pq"
+)
+
+// Creates an API account. Staff and admin accounts cannot self-register, so use this to
+// bootstrap them. Usage:
+//
+//	go run ./cmd/createuser -email admin@example.com -password secret123 -role admin -name "Admin"
+func main() {
+	_ = godotenv.Load()
+
+	var (
+		name     = flag.String("name", "", "display name")
+		email    = flag.String("email", "", "login email")
+		password = flag.String("password", "", "plaintext password (min 8 characters)")
+		role     = flag.String("role", models.RoleAdmin, "role: customer, staff or admin")
+	)
+	flag.Parse()
+
+	if *email == "" || *password == "" {
+		fmt.Println("-email and -password are required")
+		flag.Usage()
+		os.Exit(2)
+	}
+	if !models.IsValidRole(*role) {
+		fmt.Println("Invalid role:", *role)
+		os.Exit(2)
+	}
+	if len(*password) < 8 {
+		fmt.Println("Password must be at least 8 characters")
+		os.Exit(2)
+	}
+	if *name == "" {
+		*name = *email
+	}
+
+	db, err := sql.Open("postgres", utils.GetDataSource())
+	if err != nil {
+		fmt.Println("Failed to open DB:", err)
+		os.Exit(1)
+	}
+	defer db.Close()
+
+	hash, err := auth.HashPassword(*password)
+	if err != nil {
+		fmt.Println("Failed to hash password:", err)
+		os.Exit(1)
+	}
+
+	id := uuid.New().String()
+	_, err = db.Exec(`INSERT INTO users (id, name, email, password_hash, role, is_active, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, true, now(), now())`, id, *name, strings.ToLower(*email), hash, *role)
+	if err != nil {
+		fmt.Println("Failed to create user:", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("Created %s user %s (id %s)\n", *role, *email, id)
+}
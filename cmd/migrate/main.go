@@ -1,22 +1,133 @@
+// Applies the numbered SQL migrations in database/migrations and tracks them in
+// schema_migrations.
+//
+//	go run ./cmd/migrate [up]          apply every pending migration
+//	go run ./cmd/migrate down          revert the latest applied migration
+//	go run ./cmd/migrate status        list migrations and whether they are applied
+//	go run ./cmd/migrate to <version>  migrate up or down to version (0 reverts everything)
+//	go run ./cmd/migrate baseline <version>
+//	                                   record migrations up to version as applied without
+//	                                   running them, for a database created before tracking
 package main
 
 import (
+	"context"
+	"database/sql"
 	"fmt"
 	"os"
+	"strconv"
 
 	"badminton-reservation-api/database"
+	"badminton-reservation-api/database/migrations"
+	"badminton-reservation-api/utils"
 
 	"github.com/joho/godotenv"
+	_ "github.com/lib/pq"
 )
 
+func usage() {
+	fmt.Println("Usage: migrate [up | down | status | to <version> | baseline <version>]")
+	os.Exit(2)
+}
+
 func main() {
 	// load .env if present
 	_ = godotenv.Load()
 
-	fmt.Println("Running GORM migrations...")
-	if err := database.RunGormMigrations(); err != nil {
+	command := "up"
+	if len(os.Args) > 1 {
+		command = os.Args[1]
+	}
+	version := 0
+	if command == "to" || command == "baseline" {
+		if len(os.Args) != 3 {
+			usage()
+		}
+		v, err := strconv.Atoi(os.Args[2])
+		if err != nil || v < 0 {
+			fmt.Println("Invalid version:", os.Args[2])
+			os.Exit(2)
+		}
+		version = v
+	} else if len(os.Args) > 2 {
+		usage()
+	}
+
+	list, err := database.LoadMigrations(migrations.FS)
+	if err != nil {
+		fmt.Println("Failed to load migrations:", err)
+		os.Exit(1)
+	}
+
+	ds := utils.GetDataSource()
+	if ds == "" {
+		fmt.Println("No data source available. Set DB_URL or DB_* env vars.")
+		os.Exit(1)
+	}
+	db, err := sql.Open("postgres", ds)
+	if err != nil {
+		fmt.Println("Failed to open DB:", err)
+		os.Exit(1)
+	}
+	defer db.Close()
+
+	ctx := context.Background()
+	migrator := database.NewMigrator(db, list)
+	var done []*database.Migration
+	switch command {
+	case "up":
+		done, err = migrator.Up(ctx)
+	case "down":
+		done, err = migrator.Down(ctx)
+	case "to":
+		done, err = migrator.To(ctx, version)
+	case "baseline":
+		done, err = migrator.Baseline(ctx, version)
+	case "status":
+		printStatus(ctx, migrator)
+		return
+	default:
+		usage()
+	}
+
+	verb := map[string]string{"up": "Applied", "down": "Reverted", "to": "Migrated", "baseline": "Recorded"}[command]
+	for _, m := range done {
+		fmt.Printf("%s %03d_%s\n", verb, m.Version, m.Name)
+	}
+	if err != nil {
 		fmt.Println("Migration failed:", err)
 		os.Exit(1)
 	}
-	fmt.Println("Migrations applied successfully")
+	if len(done) == 0 {
+		fmt.Println("Nothing to do, database is up to date")
+		return
+	}
+	fmt.Println("Migrations completed successfully")
+}
+
+func printStatus(ctx context.Context, migrator *database.Migrator) {
+	statuses, err := migrator.Status(ctx)
+	if err != nil {
+		fmt.Println("Failed to read migration status:", err)
+		os.Exit(1)
+	}
+	broken := false
+	for _, s := range statuses {
+		state := "pending"
+		if s.Applied {
+			state = "applied " + s.AppliedAt.Format("2006-01-02 15:04:05")
+		}
+		switch {
+		case s.Missing:
+			state += "  (file missing)"
+			broken = true
+		case s.Modified:
+			state += "  (MODIFIED since applied)"
+			broken = true
+		}
+		fmt.Printf("%03d_%-45s %s\n", s.Version, s.Name, state)
+	}
+	if broken {
+		os.Exit(1)
+	}
 }
@@ -0,0 +1,67 @@
+// Compares the registered ORM models with the live database and reports missing tables and
+// columns, type mismatches, and missing primary keys, indexes, unique indexes and foreign
+// keys. Columns no model maps are printed as warnings.
+//
+//	go run ./cmd/schemacheck
+//
+// Exits 1 when the schema has drifted and 2 when the check could not run, so a release
+// pipeline can run it after `go run ./cmd/migrate up`.
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+
+	"badminton-reservation-api/database"
+	"badminton-reservation-api/models"
+	"badminton-reservation-api/utils"
+
+	"github.com/joho/godotenv"
+	_ "github.com/lib/pq"
+)
+
+func main() {
+	// load .env if present
+	_ = godotenv.Load()
+
+	expected, err := database.ModelSchema(models.RegisteredModels())
+	if err != nil {
+		fmt.Println("Failed to read models:", err)
+		os.Exit(2)
+	}
+
+	ds := utils.GetDataSource()
+	if ds == "" {
+		fmt.Println("No data source available. Set DB_URL or DB_* env vars.")
+		os.Exit(2)
+	}
+	db, err := sql.Open("postgres", ds)
+	if err != nil {
+		fmt.Println("Failed to open DB:", err)
+		os.Exit(2)
+	}
+	defer db.Close()
+
+	actual, err := database.LoadSchema(context.Background(), db)
+	if err != nil {
+		fmt.Println("Failed to read database schema:", err)
+		os.Exit(2)
+	}
+
+	drift := 0
+	for _, issue := range database.CompareSchema(expected, actual) {
+		if issue.Warning {
+			fmt.Println("warning", issue)
+			continue
+		}
+		drift++
+		fmt.Println("ERROR  ", issue)
+	}
+	if drift > 0 {
+		fmt.Printf("Schema drift: %d issue(s) across %d model table(s)\n", drift, len(expected))
+		os.Exit(1)
+	}
+	fmt.Printf("Schema matches the models (%d tables)\n", len(expected))
+}
@@ -0,0 +1,315 @@
+package controllers
+
+import (
+	"badminton-reservation-api/models"
+	"badminton-reservation-api/utils"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/beego/beego/v2/client/orm"
+	"github.com/beego/beego/v2/server/web"
+)
+
+// AdminCourtController manages court definitions. All routes require an admin token.
+type AdminCourtController struct {
+	web.Controller
+}
+
+type CreateCourtRequest struct {
+	Name         string       `json:"name"`
+	Description  string       `json:"description"`
+	PricePerHour models.Money `json:"price_per_hour"`
+	Status       string       `json:"status"`
+}
+
+type UpdateCourtRequest struct {
+	Name        *string `json:"name"`
+	Description *string `json:"description"`
+}
+
+type UpdateCourtPriceRequest struct {
+	PricePerHour models.Money `json:"price_per_hour"`
+}
+
+// CourtConflictReport lists bookings that block taking a court out of service
+type CourtConflictReport struct {
+	CourtId      int                   `json:"court_id"`
+	TargetStatus string                `json:"target_status"`
+	Reservations []*models.Reservation `json:"reservations"`
+}
+
+// maxCourtNameLength matches courts.name VARCHAR(100)
+const maxCourtNameLength = 100
+
+// maxPrice is the exclusive upper bound of a NUMERIC(10,2) column
+var maxPrice = models.Whole(100000000)
+
+// validatePrice checks a price is positive, fits NUMERIC(10,2) and is a whole amount, since
+// the gateways bill whole rupiah
+func validatePrice(price models.Money) bool {
+	if price.Amount <= 0 || price.Amount >= maxPrice.Amount {
+		return false
+	}
+	_, err := price.WholeUnits()
+	return err == nil
+}
+
+// loadCourt reads the :id court or writes the error response and returns nil
+func (c *AdminCourtController) loadCourt() *models.Court {
+	id, err := strconv.Atoi(c.Ctx.Input.Param(":id"))
+	if err != nil {
+		utils.SendBadRequest(&c.Controller, "Invalid court id", nil)
+		return nil
+	}
+	court, err := models.GetCourtById(id)
+	if errors.Is(err, orm.ErrNoRows) {
+		utils.SendNotFound(&c.Controller, "Court not found")
+		return nil
+	}
+	if err != nil {
+		utils.SendInternalError(&c.Controller, "Error retrieving court", err.Error())
+		return nil
+	}
+	return court
+}
+
+// ListCourts godoc
+// @Summary List all courts (admin)
+// @Description Returns all courts including inactive and maintenance ones
+// @Tags admin-courts
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} utils.Response
+// @Router /api/v1/admin/courts [get]
+func (c *AdminCourtController) ListCourts() {
+	courts, err := models.GetAllCourts()
+	if err != nil {
+		utils.SendInternalError(&c.Controller, "Error retrieving courts", err.Error())
+		return
+	}
+	utils.SendSuccess(&c.Controller, "Courts retrieved successfully", courts)
+}
+
+// GetCourt godoc
+// @Summary Get a court (admin)
+// @Tags admin-courts
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Court ID"
+// @Success 200 {object} utils.Response
+// @Router /api/v1/admin/courts/{id} [get]
+func (c *AdminCourtController) GetCourt() {
+	court := c.loadCourt()
+	if court == nil {
+		return
+	}
+	utils.SendSuccess(&c.Controller, "Court retrieved successfully", court)
+}
+
+// CreateCourt godoc
+// @Summary Create a court (admin)
+// @Tags admin-courts
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param court body CreateCourtRequest true "Court details"
+// @Success 201 {object} utils.Response
+// @Router /api/v1/admin/courts [post]
+func (c *AdminCourtController) CreateCourt() {
+	var req CreateCourtRequest
+	if err := json.Unmarshal(c.Ctx.Input.RequestBody, &req); err != nil {
+		utils.SendBadRequest(&c.Controller, "Invalid request body", err.Error())
+		return
+	}
+
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" || len(req.Name) > maxCourtNameLength {
+		utils.SendBadRequest(&c.Controller, "name is required and must be at most 100 characters", nil)
+		return
+	}
+	if !validatePrice(req.PricePerHour) {
+		utils.SendBadRequest(&c.Controller, "price_per_hour must be a positive whole amount below 100000000", nil)
+		return
+	}
+	if req.Status == "" {
+		req.Status = models.CourtActive
+	}
+	if !models.IsValidCourtStatus(req.Status) {
+		utils.SendBadRequest(&c.Controller, "status must be one of: active, inactive, maintenance", nil)
+		return
+	}
+
+	court := &models.Court{
+		Name:         req.Name,
+		Description:  req.Description,
+		PricePerHour: req.PricePerHour,
+		Status:       req.Status,
+	}
+	if err := models.CreateCourt(court); err != nil {
+		utils.SendInternalError(&c.Controller, "Error creating court", err.Error())
+		return
+	}
+
+	created, _ := models.GetCourtById(court.Id)
+	c.Ctx.Output.SetStatus(201)
+	utils.SendSuccess(&c.Controller, "Court created successfully", created)
+}
+
+// UpdateCourt godoc
+// @Summary Update court details (admin)
+// @Description Update name and/or description. Use the price and status endpoints for those fields.
+// @Tags admin-courts
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Court ID"
+// @Param court body UpdateCourtRequest true "Fields to update"
+// @Success 200 {object} utils.Response
+// @Router /api/v1/admin/courts/{id} [put]
+func (c *AdminCourtController) UpdateCourt() {
+	court := c.loadCourt()
+	if court == nil {
+		return
+	}
+
+	var req UpdateCourtRequest
+	if err := json.Unmarshal(c.Ctx.Input.RequestBody, &req); err != nil {
+		utils.SendBadRequest(&c.Controller, "Invalid request body", err.Error())
+		return
+	}
+
+	var cols []string
+	if req.Name != nil {
+		name := strings.TrimSpace(*req.Name)
+		if name == "" || len(name) > maxCourtNameLength {
+			utils.SendBadRequest(&c.Controller, "name must be non-empty and at most 100 characters", nil)
+			return
+		}
+		court.Name = name
+		cols = append(cols, "name")
+	}
+	if req.Description != nil {
+		court.Description = *req.Description
+		cols = append(cols, "description")
+	}
+	if len(cols) == 0 {
+		utils.SendBadRequest(&c.Controller, "Nothing to update", nil)
+		return
+	}
+
+	if err := models.UpdateCourt(court, append(cols, "updated_at")...); err != nil {
+		utils.SendInternalError(&c.Controller, "Error updating court", err.Error())
+		return
+	}
+	utils.SendSuccess(&c.Controller, "Court updated successfully", court)
+}
+
+// UpdatePrice godoc
+// @Summary Change court price (admin)
+// @Description Changes the hourly price. Existing reservations keep the price they were booked at.
+// @Tags admin-courts
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Court ID"
+// @Param body body UpdateCourtPriceRequest true "New price"
+// @Success 200 {object} utils.Response
+// @Router /api/v1/admin/courts/{id}/price [put]
+func (c *AdminCourtController) UpdatePrice() {
+	court := c.loadCourt()
+	if court == nil {
+		return
+	}
+
+	var req UpdateCourtPriceRequest
+	if err := json.Unmarshal(c.Ctx.Input.RequestBody, &req); err != nil {
+		utils.SendBadRequest(&c.Controller, "Invalid request body", err.Error())
+		return
+	}
+	if !validatePrice(req.PricePerHour) {
+		utils.SendBadRequest(&c.Controller, "price_per_hour must be a positive whole amount below 100000000", nil)
+		return
+	}
+
+	court.PricePerHour = req.PricePerHour
+	if err := models.UpdateCourt(court, "price_per_hour", "updated_at"); err != nil {
+		utils.SendInternalError(&c.Controller, "Error updating court price", err.Error())
+		return
+	}
+	utils.SendSuccess(&c.Controller, "Court price updated successfully", court)
+}
+
+// Activate godoc
+// @Summary Activate a court (admin)
+// @Tags admin-courts
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Court ID"
+// @Success 200 {object} utils.Response
+// @Router /api/v1/admin/courts/{id}/activate [post]
+func (c *AdminCourtController) Activate() {
+	c.changeStatus(models.CourtActive)
+}
+
+// Deactivate godoc
+// @Summary Deactivate a court (admin)
+// @Description Returns 409 with a conflict report when the court has future paid reservations, unless force=true
+// @Tags admin-courts
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Court ID"
+// @Param force query bool false "Proceed despite future paid reservations"
+// @Success 200 {object} utils.Response
+// @Router /api/v1/admin/courts/{id}/deactivate [post]
+func (c *AdminCourtController) Deactivate() {
+	c.changeStatus(models.CourtInactive)
+}
+
+// Maintenance godoc
+// @Summary Put a court into maintenance (admin)
+// @Description Returns 409 with a conflict report when the court has future paid reservations, unless force=true
+// @Tags admin-courts
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Court ID"
+// @Param force query bool false "Proceed despite future paid reservations"
+// @Success 200 {object} utils.Response
+// @Router /api/v1/admin/courts/{id}/maintenance [post]
+func (c *AdminCourtController) Maintenance() {
+	c.changeStatus(models.CourtMaintenance)
+}
+
+// changeStatus moves a court to status. Taking a court out of service is refused with a
+// conflict report while it still has future paid reservations, unless ?force=true.
+func (c *AdminCourtController) changeStatus(status string) {
+	court := c.loadCourt()
+	if court == nil {
+		return
+	}
+
+	if status != models.CourtActive {
+		conflicts, err := models.GetFuturePaidReservationsByCourt(court.Id)
+		if err != nil {
+			utils.SendInternalError(&c.Controller, "Error checking court reservations", err.Error())
+			return
+		}
+		force, _ := c.GetBool("force", false)
+		if len(conflicts) > 0 && !force {
+			utils.SendConflict(&c.Controller, "Court has future paid reservations", CourtConflictReport{
+				CourtId:      court.Id,
+				TargetStatus: status,
+				Reservations: conflicts,
+			})
+			return
+		}
+	}
+
+	court.Status = status
+	if err := models.UpdateCourt(court, "status", "updated_at"); err != nil {
+		utils.SendInternalError(&c.Controller, "Error updating court status", err.Error())
+		return
+	}
+	utils.SendSuccess(&c.Controller, "Court status updated successfully", court)
+}
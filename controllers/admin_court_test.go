@@ -0,0 +1,120 @@
+package controllers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"badminton-reservation-api/models"
+
+	"github.com/beego/beego/v2/client/orm"
+	"github.com/google/uuid"
+)
+
+func adminCourtRouter() http.Handler {
+	return newTestRouter(
+		testRoute{"/api/v1/admin/courts", &AdminCourtController{}, "get:ListCourts;post:CreateCourt"},
+		testRoute{"/api/v1/admin/courts/:id", &AdminCourtController{}, "get:GetCourt;put:UpdateCourt"},
+		testRoute{"/api/v1/admin/courts/:id/price", &AdminCourtController{}, "put:UpdatePrice"},
+		testRoute{"/api/v1/admin/courts/:id/activate", &AdminCourtController{}, "post:Activate"},
+		testRoute{"/api/v1/admin/courts/:id/deactivate", &AdminCourtController{}, "post:Deactivate"},
+		testRoute{"/api/v1/admin/courts/:id/maintenance", &AdminCourtController{}, "post:Maintenance"},
+	)
+}
+
+func TestCreateCourtValidation(t *testing.T) {
+	t.Setenv("CURRENCY", "IDR")
+	router := adminCourtRouter()
+	tests := []struct {
+		name string
+		body string
+	}{
+		{"malformed body", `{"name":`},
+		{"missing name", `{"name":"  ","price_per_hour":100000}`},
+		{"name too long", fmt.Sprintf(`{"name":"%0101d","price_per_hour":100000}`, 0)},
+		{"zero price", `{"name":"Court Z","price_per_hour":0}`},
+		{"negative price", `{"name":"Court Z","price_per_hour":-5000}`},
+		{"fractional price", `{"name":"Court Z","price_per_hour":100000.5}`},
+		{"price beyond NUMERIC(10,2)", `{"name":"Court Z","price_per_hour":100000000}`},
+		{"unknown status", `{"name":"Court Z","price_per_hour":100000,"status":"closed"}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if res := do(t, router, "POST", "/api/v1/admin/courts", tt.body); res.Status != http.StatusBadRequest {
+				t.Errorf("status %d (%s), want 400", res.Status, res.Message)
+			}
+		})
+	}
+}
+
+func TestCourtStatusConflictReport(t *testing.T) {
+	requireDB(t)
+	t.Setenv("CURRENCY", "IDR")
+	router := adminCourtRouter()
+
+	res := do(t, router, "POST", "/api/v1/admin/courts", `{"name":"Test court status","price_per_hour":120000}`)
+	if res.Status != http.StatusCreated {
+		t.Fatalf("create: %d %s", res.Status, res.Message)
+	}
+	var court models.Court
+	if err := json.Unmarshal(res.Data, &court); err != nil {
+		t.Fatal(err)
+	}
+	slot := &models.Timeslot{StartTime: "23:00:00", EndTime: "23:59:00"}
+	if err := models.CreateTimeslot(slot); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		o := orm.NewOrm()
+		o.Raw("DELETE FROM reservations WHERE id IN (SELECT reservation_id FROM reservation_items WHERE court_id = ?)", court.Id).Exec()
+		o.Raw("DELETE FROM timeslot_availabilities WHERE court_id = ?", court.Id).Exec()
+		o.Raw("DELETE FROM timeslots WHERE id = ?", slot.Id).Exec()
+		o.Raw("DELETE FROM courts WHERE id = ?", court.Id).Exec()
+	})
+
+	// A court without future paid bookings can be taken out of service straight away
+	path := fmt.Sprintf("/api/v1/admin/courts/%d", court.Id)
+	if res := do(t, router, "POST", path+"/maintenance", ""); res.Status != http.StatusOK {
+		t.Fatalf("maintenance: %d %s", res.Status, res.Message)
+	}
+	if res := do(t, router, "POST", path+"/activate", ""); res.Status != http.StatusOK {
+		t.Fatalf("activate: %d %s", res.Status, res.Message)
+	}
+
+	paid := &models.Reservation{
+		Id: uuid.New().String(), CourtId: court.Id, TimeslotId: slot.Id,
+		BookingDate:  time.Now().AddDate(0, 0, 2).Format("2006-01-02"),
+		CustomerName: "Status Tester", CustomerEmail: "status@example.com", CustomerPhone: "+6281234567890",
+		TotalPrice: court.PricePerHour, Status: models.ReservationPaid, ExpiredAt: time.Now().Add(time.Hour),
+	}
+	if err := models.CreateReservation(paid); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, action := range []string{"deactivate", "maintenance"} {
+		res := do(t, router, "POST", path+"/"+action, "")
+		if res.Status != http.StatusConflict {
+			t.Fatalf("%s with a future paid booking: %d %s, want 409", action, res.Status, res.Message)
+		}
+		var report CourtConflictReport
+		if err := json.Unmarshal(res.Error, &report); err != nil {
+			t.Fatal(err)
+		}
+		if report.CourtId != court.Id || len(report.Reservations) != 1 || report.Reservations[0].Id != paid.Id {
+			t.Errorf("%s conflict report is %s", action, res.Error)
+		}
+	}
+	if got, _ := models.GetCourtById(court.Id); got.Status != models.CourtActive {
+		t.Errorf("refused status change left the court %s", got.Status)
+	}
+
+	res = do(t, router, "POST", path+"/deactivate?force=true", "")
+	if res.Status != http.StatusOK {
+		t.Fatalf("forced deactivate: %d %s", res.Status, res.Message)
+	}
+	if got, _ := models.GetCourtById(court.Id); got.Status != models.CourtInactive {
+		t.Errorf("forced deactivate left the court %s", got.Status)
+	}
+}
@@ -0,0 +1,40 @@
+package controllers
+
+import (
+	"badminton-reservation-api/services/scheduler"
+	"badminton-reservation-api/utils"
+
+	"github.com/beego/beego/v2/server/web"
+)
+
+// AdminJobController reports the background jobs. All routes require an admin token.
+type AdminJobController struct {
+	web.Controller
+}
+
+// ListJobs godoc
+// @Summary List background jobs (admin)
+// @Description Lists the scheduled background jobs with their schedule, last run, duration, last error and next run. Runs are recorded by whichever instance holds the scheduler lock, so any instance reports them; leader tells whether the answering instance is that one.
+// @Tags admin-jobs
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} utils.Response
+// @Failure 503 {object} utils.Response
+// @Router /api/v1/admin/jobs [get]
+func (c *AdminJobController) ListJobs() {
+	s := scheduler.Current()
+	if s == nil {
+		utils.SendError(&c.Controller, 503, "Scheduler is not running on this instance", nil)
+		return
+	}
+	jobs, err := s.Status()
+	if err != nil {
+		utils.SendInternalError(&c.Controller, "Error retrieving jobs", err.Error())
+		return
+	}
+	utils.SendSuccess(&c.Controller, "Jobs retrieved successfully", map[string]interface{}{
+		"instance": s.Instance(),
+		"leader":   s.Leader(),
+		"jobs":     jobs,
+	})
+}
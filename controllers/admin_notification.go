@@ -0,0 +1,40 @@
+package controllers
+
+import (
+	"badminton-reservation-api/models"
+	"badminton-reservation-api/utils"
+
+	"github.com/beego/beego/v2/server/web"
+)
+
+// AdminNotificationController reports customer notifications. All routes require an admin token.
+type AdminNotificationController struct {
+	web.Controller
+}
+
+// ListNotifications godoc
+// @Summary List customer notification attempts (admin)
+// @Description Every attempt to notify a customer by email, SMS or WhatsApp (confirmation, payment reminder, receipt, cancellation, expiry, game reminder) with its outcome. A failed lifecycle notification is retried through the outbox; each kind is sent at most once per reservation and channel.
+// @Tags admin-notifications
+// @Produce json
+// @Security BearerAuth
+// @Param reservation_id query string false "Reservation ID"
+// @Param kind query string false "reservation_created, payment_reminder, reservation_paid, reservation_cancelled, reservation_expired or game_reminder"
+// @Param channel query string false "email, sms or whatsapp"
+// @Param status query string false "sent or failed"
+// @Param limit query int false "Maximum number of entries (default 50, max 500)"
+// @Success 200 {object} utils.Response
+// @Router /api/v1/admin/notifications [get]
+func (c *AdminNotificationController) ListNotifications() {
+	limit, err := c.GetInt("limit", 50)
+	if err != nil || limit < 1 || limit > 500 {
+		utils.SendBadRequest(&c.Controller, "limit must be between 1 and 500", nil)
+		return
+	}
+	list, err := models.GetNotificationLogs(c.GetString("reservation_id"), c.GetString("kind"), c.GetString("channel"), c.GetString("status"), limit)
+	if err != nil {
+		utils.SendInternalError(&c.Controller, "Error retrieving notifications", err.Error())
+		return
+	}
+	utils.SendSuccess(&c.Controller, "Notifications retrieved successfully", list)
+}
@@ -0,0 +1,78 @@
+package controllers
+
+import (
+	"badminton-reservation-api/models"
+	"badminton-reservation-api/services/outbox"
+	"badminton-reservation-api/utils"
+	"errors"
+	"strconv"
+
+	"github.com/beego/beego/v2/client/orm"
+	"github.com/beego/beego/v2/server/web"
+)
+
+// AdminOutboxController inspects and retries domain events. All routes require an admin token.
+type AdminOutboxController struct {
+	web.Controller
+}
+
+// ListEvents godoc
+// @Summary List domain events in the outbox (admin)
+// @Description Reservation, series, payment and refund events written with the change they describe and delivered to the subscribed handlers at least once. pending events wait for delivery or a retry, dead ones failed OUTBOX_MAX_ATTEMPTS times and need a retry.
+// @Tags admin-outbox
+// @Produce json
+// @Security BearerAuth
+// @Param status query string false "pending, delivered or dead"
+// @Param event_type query string false "Event type, e.g. ReservationCreated"
+// @Param aggregate_id query string false "Reservation, series, payment or refund ID"
+// @Param limit query int false "Maximum number of events (default 50, max 500)"
+// @Success 200 {object} utils.Response
+// @Router /api/v1/admin/outbox [get]
+func (c *AdminOutboxController) ListEvents() {
+	limit, err := c.GetInt("limit", 50)
+	if err != nil || limit < 1 || limit > 500 {
+		utils.SendBadRequest(&c.Controller, "limit must be between 1 and 500", nil)
+		return
+	}
+	events, err := models.GetOutboxEvents(c.GetString("status"), c.GetString("event_type"), c.GetString("aggregate_id"), limit)
+	if err != nil {
+		utils.SendInternalError(&c.Controller, "Error retrieving outbox events", err.Error())
+		return
+	}
+	utils.SendSuccess(&c.Controller, "Outbox events retrieved successfully", map[string]interface{}{
+		"handlers": outbox.Handlers(),
+		"events":   events,
+	})
+}
+
+// RetryEvent godoc
+// @Summary Retry a domain event (admin)
+// @Description Puts a dead or pending event back in line for delivery now with a fresh set of attempts. Handlers that already processed it are skipped.
+// @Tags admin-outbox
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Outbox event ID"
+// @Success 200 {object} utils.Response
+// @Failure 409 {object} utils.Response
+// @Router /api/v1/admin/outbox/{id}/retry [post]
+func (c *AdminOutboxController) RetryEvent() {
+	id, err := strconv.ParseInt(c.Ctx.Input.Param(":id"), 10, 64)
+	if err != nil {
+		utils.SendBadRequest(&c.Controller, "Invalid outbox event ID", nil)
+		return
+	}
+	event, err := models.RetryOutboxEvent(id)
+	if errors.Is(err, orm.ErrNoRows) {
+		utils.SendNotFound(&c.Controller, "Outbox event not found")
+		return
+	}
+	if errors.Is(err, models.ErrOutboxEventDelivered) {
+		utils.SendConflict(&c.Controller, err.Error(), event)
+		return
+	}
+	if err != nil {
+		utils.SendInternalError(&c.Controller, "Error retrying outbox event", err.Error())
+		return
+	}
+	utils.SendSuccess(&c.Controller, "Outbox event queued for delivery", event)
+}
@@ -0,0 +1,262 @@
+package controllers
+
+import (
+	"badminton-reservation-api/models"
+	"badminton-reservation-api/services/payment"
+	"badminton-reservation-api/utils"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/beego/beego/v2/client/orm"
+	"github.com/beego/beego/v2/server/web"
+)
+
+// AdminPaymentController issues and lists refunds. All routes require an admin token.
+type AdminPaymentController struct {
+	web.Controller
+}
+
+type RefundRequest struct {
+	// Amount to refund; omitted or 0 refunds everything still refundable
+	Amount models.Money `json:"amount"`
+	Reason string       `json:"reason"`
+	// ReservationId is the reservation refunded, e.g. one occurrence of a prepaid series;
+	// omitted means the payment's reservation
+	ReservationId string `json:"reservation_id"`
+}
+
+// loadPayment reads the :id payment or writes the error response and returns nil
+func (c *AdminPaymentController) loadPayment() *models.Payment {
+	p, err := models.GetPaymentById(c.Ctx.Input.Param(":id"))
+	if errors.Is(err, orm.ErrNoRows) {
+		utils.SendNotFound(&c.Controller, "Payment not found")
+		return nil
+	}
+	if err != nil {
+		utils.SendInternalError(&c.Controller, "Error retrieving payment", err.Error())
+		return nil
+	}
+	return p
+}
+
+// Reconcile godoc
+// @Summary Reconcile pending payments now (admin)
+// @Description Runs the payment reconciler immediately: pending payments older than RECONCILE_AFTER are checked at their gateway and expired checkouts are released.
+// @Tags admin-payments
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} utils.Response
+// @Router /api/v1/admin/payments/reconcile [post]
+func (c *AdminPaymentController) Reconcile() {
+	result, err := payment.ReconcilePendingPayments(c.Ctx.Request.Context())
+	if err != nil {
+		utils.SendInternalError(&c.Controller, "Error reconciling payments", err.Error())
+		return
+	}
+	utils.SendSuccess(&c.Controller, "Payments reconciled", result)
+}
+
+// ListRefunds godoc
+// @Summary List refunds of a payment (admin)
+// @Tags admin-payments
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Payment ID"
+// @Success 200 {object} utils.Response
+// @Router /api/v1/admin/payments/{id}/refunds [get]
+func (c *AdminPaymentController) ListRefunds() {
+	p := c.loadPayment()
+	if p == nil {
+		return
+	}
+	refunds, err := models.GetRefundsByPaymentId(p.Id)
+	if err != nil {
+		utils.SendInternalError(&c.Controller, "Error retrieving refunds", err.Error())
+		return
+	}
+	refundable, err := models.RefundableAmount(p.Id)
+	if err != nil {
+		utils.SendInternalError(&c.Controller, "Error retrieving refunds", err.Error())
+		return
+	}
+	utils.SendSuccess(&c.Controller, "Refunds retrieved successfully", map[string]interface{}{
+		"payment":    p,
+		"refundable": refundable,
+		"refunds":    refunds,
+	})
+}
+
+// Refund godoc
+// @Summary Refund a payment (admin)
+// @Description Refunds all or part of a settled payment through the payment gateway. The payment becomes partially_refunded or refunded and a fully refunded reservation moves to refunded. For a prepaid series payment, reservation_id names the occurrence the refund is for. A refund the gateway did not complete stays pending (202, or 502 when the gateway could not be reached); repeating the request with the same amount retries that refund under its original refund key instead of paying out again, and the reconcile_refunds job settles it otherwise.
+// @Tags admin-payments
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Payment ID"
+// @Param body body RefundRequest false "Refund amount and reason"
+// @Success 201 {object} utils.Response
+// @Router /api/v1/admin/payments/{id}/refund [post]
+func (c *AdminPaymentController) Refund() {
+	p := c.loadPayment()
+	if p == nil {
+		return
+	}
+
+	var req RefundRequest
+	if len(c.Ctx.Input.RequestBody) > 0 {
+		if err := json.Unmarshal(c.Ctx.Input.RequestBody, &req); err != nil {
+			utils.SendBadRequest(&c.Controller, "Invalid request body", err.Error())
+			return
+		}
+	}
+	if req.Amount.Amount < 0 {
+		utils.SendBadRequest(&c.Controller, "amount must be positive", nil)
+		return
+	}
+	if _, err := req.Amount.WholeUnits(); err != nil {
+		utils.SendBadRequest(&c.Controller, "amount must be a whole amount", nil)
+		return
+	}
+	if req.Amount.IsZero() {
+		refundable, err := models.RefundableAmount(p.Id)
+		if err != nil {
+			utils.SendInternalError(&c.Controller, "Error calculating refundable amount", err.Error())
+			return
+		}
+		req.Amount = refundable
+	}
+
+	refund, err := payment.IssueRefund(p, req.ReservationId, req.Amount, req.Reason)
+	switch {
+	case errors.Is(err, models.ErrPaymentNotRefundable):
+		utils.SendConflict(&c.Controller, err.Error(), map[string]string{"payment_status": p.Status})
+	case errors.Is(err, models.ErrRefundNotCovered):
+		utils.SendBadRequest(&c.Controller, err.Error(), nil)
+	case errors.Is(err, models.ErrRefundExceedsPayment):
+		refundable, _ := models.RefundableAmount(p.Id)
+		utils.SendBadRequest(&c.Controller, err.Error(), map[string]models.Money{"refundable": refundable})
+	case err != nil && refund != nil:
+		utils.SendError(&c.Controller, 502, "Payment gateway could not be reached; the refund stays pending and is retried", map[string]interface{}{"refund": refund, "error": err.Error()})
+	case err != nil:
+		utils.SendInternalError(&c.Controller, "Error refunding payment", err.Error())
+	case refund.Status == models.RefundPending:
+		c.Ctx.Output.SetStatus(202)
+		utils.SendSuccess(&c.Controller, "Refund accepted and pending at the payment gateway", refund)
+	case refund.Status != models.RefundSucceeded:
+		utils.SendError(&c.Controller, 502, "Payment gateway rejected the refund", refund)
+	default:
+		c.Ctx.Output.SetStatus(201)
+		utils.SendSuccess(&c.Controller, "Refund issued successfully", refund)
+	}
+}
+
+// ListEvents godoc
+// @Summary List received payment notifications (admin)
+// @Description Every verified gateway notification and reconciler result is logged with its outcome: applied, stale (older than the payment's current status), error, or suspicious (amount, currency or reservation did not match the payment; not applied and flagged for review).
+// @Tags admin-payments
+// @Produce json
+// @Security BearerAuth
+// @Param order_id query string false "Gateway order ID"
+// @Param outcome query string false "received, applied, stale, error or suspicious"
+// @Param needs_review query bool false "Only events flagged for review"
+// @Param limit query int false "Maximum number of events (default 50, max 500)"
+// @Success 200 {object} utils.Response
+// @Router /api/v1/admin/payment-events [get]
+func (c *AdminPaymentController) ListEvents() {
+	limit, err := c.GetInt("limit", 50)
+	if err != nil || limit < 1 || limit > 500 {
+		utils.SendBadRequest(&c.Controller, "limit must be between 1 and 500", nil)
+		return
+	}
+	needsReview, err := c.GetBool("needs_review", false)
+	if err != nil {
+		utils.SendBadRequest(&c.Controller, "needs_review must be true or false", nil)
+		return
+	}
+	events, err := models.GetPaymentEvents(c.GetString("order_id"), c.GetString("outcome"), needsReview, limit)
+	if err != nil {
+		utils.SendInternalError(&c.Controller, "Error retrieving payment events", err.Error())
+		return
+	}
+	utils.SendSuccess(&c.Controller, "Payment events retrieved successfully", events)
+}
+
+// ReplayEvent godoc
+// @Summary Replay a stored payment notification (admin)
+// @Description Applies a logged notification again, e.g. after an error outcome. The payment status still only moves forward, so replaying an old event does not roll a payment back.
+// @Tags admin-payments
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Payment event ID"
+// @Success 200 {object} utils.Response
+// @Router /api/v1/admin/payment-events/{id}/replay [post]
+func (c *AdminPaymentController) ReplayEvent() {
+	id, err := strconv.ParseInt(c.Ctx.Input.Param(":id"), 10, 64)
+	if err != nil {
+		utils.SendBadRequest(&c.Controller, "Invalid payment event ID", nil)
+		return
+	}
+	event, err := models.GetPaymentEventById(id)
+	if errors.Is(err, orm.ErrNoRows) {
+		utils.SendNotFound(&c.Controller, "Payment event not found")
+		return
+	}
+	if err != nil {
+		utils.SendInternalError(&c.Controller, "Error retrieving payment event", err.Error())
+		return
+	}
+
+	event, err = payment.ReplayEvent(event)
+	if err != nil && event == nil {
+		utils.SendInternalError(&c.Controller, "Error replaying payment event", err.Error())
+		return
+	}
+	utils.SendSuccess(&c.Controller, "Payment event replayed", event)
+}
+
+// ReviewEventRequest is the admin's conclusion about a flagged payment event
+type ReviewEventRequest struct {
+	Note string `json:"note"`
+}
+
+// ReviewEvent godoc
+// @Summary Mark a flagged payment event as reviewed (admin)
+// @Description Clears the review flag of a suspicious notification and stores the admin's note. The event itself is not applied; replay it once the payment has been corrected.
+// @Tags admin-payments
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Payment event ID"
+// @Param body body ReviewEventRequest true "Review note"
+// @Success 200 {object} utils.Response
+// @Router /api/v1/admin/payment-events/{id}/review [post]
+func (c *AdminPaymentController) ReviewEvent() {
+	id, err := strconv.ParseInt(c.Ctx.Input.Param(":id"), 10, 64)
+	if err != nil {
+		utils.SendBadRequest(&c.Controller, "Invalid payment event ID", nil)
+		return
+	}
+	var req ReviewEventRequest
+	if err := json.Unmarshal(c.Ctx.Input.RequestBody, &req); err != nil {
+		utils.SendBadRequest(&c.Controller, "Invalid request body", err.Error())
+		return
+	}
+	if strings.TrimSpace(req.Note) == "" {
+		utils.SendBadRequest(&c.Controller, "note is required", nil)
+		return
+	}
+
+	event, err := models.ReviewPaymentEvent(id, strings.TrimSpace(req.Note))
+	if errors.Is(err, orm.ErrNoRows) {
+		utils.SendNotFound(&c.Controller, "Payment event not found")
+		return
+	}
+	if err != nil {
+		utils.SendInternalError(&c.Controller, "Error reviewing payment event", err.Error())
+		return
+	}
+	utils.SendSuccess(&c.Controller, "Payment event reviewed", event)
+}
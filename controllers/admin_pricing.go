@@ -0,0 +1,177 @@
+package controllers
+
+import (
+	"badminton-reservation-api/models"
+	"badminton-reservation-api/services/pricing"
+	"badminton-reservation-api/utils"
+	"encoding/json"
+	"errors"
+	"strconv"
+
+	"github.com/beego/beego/v2/client/orm"
+	"github.com/beego/beego/v2/server/web"
+)
+
+// AdminPricingController manages pricing rules. All routes require an admin token.
+type AdminPricingController struct {
+	web.Controller
+}
+
+type PricingRuleRequest struct {
+	Name string `json:"name"`
+	// CourtId limits the rule to one court; omitted or null applies to every court
+	CourtId *int `json:"court_id"`
+	// Weekdays is a comma-separated list, 0 = Sunday .. 6 = Saturday (e.g. "0,6" for weekends)
+	Weekdays   string `json:"weekdays"`
+	StartTime  string `json:"start_time"`
+	EndTime    string `json:"end_time"`
+	StartDate  string `json:"start_date"`
+	EndDate    string `json:"end_date"`
+	Adjustment string `json:"adjustment"`
+	// Amount is the slot price (fixed) or the amount added (surcharge)
+	Amount models.Money `json:"amount"`
+	// Multiplier is the factor applied to the base price (multiplier), e.g. 1.5
+	Multiplier float64 `json:"multiplier"`
+	Priority   int     `json:"priority"`
+	IsActive   *bool   `json:"is_active"`
+}
+
+// loadRule reads the :id pricing rule or writes the error response and returns nil
+func (c *AdminPricingController) loadRule() *models.PricingRule {
+	id, err := strconv.Atoi(c.Ctx.Input.Param(":id"))
+	if err != nil {
+		utils.SendBadRequest(&c.Controller, "Invalid pricing rule id", nil)
+		return nil
+	}
+	rule, err := models.GetPricingRuleById(id)
+	if errors.Is(err, orm.ErrNoRows) {
+		utils.SendNotFound(&c.Controller, "Pricing rule not found")
+		return nil
+	}
+	if err != nil {
+		utils.SendInternalError(&c.Controller, "Error retrieving pricing rule", err.Error())
+		return nil
+	}
+	return rule
+}
+
+// applyRequest copies the request body onto rule and validates the result, writing a 400 on failure
+func (c *AdminPricingController) applyRequest(rule *models.PricingRule) bool {
+	var req PricingRuleRequest
+	if err := json.Unmarshal(c.Ctx.Input.RequestBody, &req); err != nil {
+		utils.SendBadRequest(&c.Controller, "Invalid request body", err.Error())
+		return false
+	}
+	if req.CourtId != nil {
+		if _, err := models.GetCourtById(*req.CourtId); err != nil {
+			utils.SendBadRequest(&c.Controller, "Court not found", nil)
+			return false
+		}
+	}
+
+	rule.Name = req.Name
+	rule.CourtId = req.CourtId
+	rule.Weekdays = req.Weekdays
+	rule.StartTime = req.StartTime
+	rule.EndTime = req.EndTime
+	rule.StartDate = req.StartDate
+	rule.EndDate = req.EndDate
+	rule.Adjustment = req.Adjustment
+	rule.Amount = req.Amount
+	rule.Multiplier = req.Multiplier
+	rule.Priority = req.Priority
+	if req.IsActive != nil {
+		rule.IsActive = *req.IsActive
+	}
+	if err := pricing.Validate(rule); err != nil {
+		utils.SendBadRequest(&c.Controller, err.Error(), nil)
+		return false
+	}
+	return true
+}
+
+// ListRules godoc
+// @Summary List pricing rules (admin)
+// @Tags admin-pricing
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} utils.Response
+// @Router /api/v1/admin/pricing-rules [get]
+func (c *AdminPricingController) ListRules() {
+	rules, err := models.GetAllPricingRules()
+	if err != nil {
+		utils.SendInternalError(&c.Controller, "Error retrieving pricing rules", err.Error())
+		return
+	}
+	utils.SendSuccess(&c.Controller, "Pricing rules retrieved successfully", rules)
+}
+
+// CreateRule godoc
+// @Summary Create a pricing rule (admin)
+// @Description A slot is priced by the matching active rule with the highest priority (court, weekdays, start time in [start_time, end_time), booking date in [start_date, end_date]; empty fields match everything). adjustment is fixed (amount is the slot price), multiplier (base × multiplier) or surcharge (base + amount).
+// @Tags admin-pricing
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param rule body PricingRuleRequest true "Pricing rule"
+// @Success 201 {object} utils.Response
+// @Router /api/v1/admin/pricing-rules [post]
+func (c *AdminPricingController) CreateRule() {
+	rule := &models.PricingRule{IsActive: true}
+	if !c.applyRequest(rule) {
+		return
+	}
+	if err := models.CreatePricingRule(rule); err != nil {
+		utils.SendInternalError(&c.Controller, "Error creating pricing rule", err.Error())
+		return
+	}
+
+	c.Ctx.Output.SetStatus(201)
+	utils.SendSuccess(&c.Controller, "Pricing rule created successfully", rule)
+}
+
+// UpdateRule godoc
+// @Summary Replace a pricing rule (admin)
+// @Description Existing reservations keep the price they were booked at.
+// @Tags admin-pricing
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Pricing rule ID"
+// @Param rule body PricingRuleRequest true "Pricing rule"
+// @Success 200 {object} utils.Response
+// @Router /api/v1/admin/pricing-rules/{id} [put]
+func (c *AdminPricingController) UpdateRule() {
+	rule := c.loadRule()
+	if rule == nil {
+		return
+	}
+	if !c.applyRequest(rule) {
+		return
+	}
+	if err := models.UpdatePricingRule(rule); err != nil {
+		utils.SendInternalError(&c.Controller, "Error updating pricing rule", err.Error())
+		return
+	}
+	utils.SendSuccess(&c.Controller, "Pricing rule updated successfully", rule)
+}
+
+// DeleteRule godoc
+// @Summary Delete a pricing rule (admin)
+// @Tags admin-pricing
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Pricing rule ID"
+// @Success 200 {object} utils.Response
+// @Router /api/v1/admin/pricing-rules/{id} [delete]
+func (c *AdminPricingController) DeleteRule() {
+	rule := c.loadRule()
+	if rule == nil {
+		return
+	}
+	if err := models.DeletePricingRule(rule.Id); err != nil {
+		utils.SendInternalError(&c.Controller, "Error deleting pricing rule", err.Error())
+		return
+	}
+	utils.SendSuccess(&c.Controller, "Pricing rule deleted successfully", map[string]int{"id": rule.Id})
+}
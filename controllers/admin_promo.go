@@ -0,0 +1,229 @@
+package controllers
+
+import (
+	"badminton-reservation-api/models"
+	"badminton-reservation-api/utils"
+	"encoding/json"
+	"errors"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/beego/beego/v2/client/orm"
+	"github.com/beego/beego/v2/server/web"
+)
+
+// AdminPromoController manages promo codes. All routes require an admin token.
+type AdminPromoController struct {
+	web.Controller
+}
+
+type PromoCodeRequest struct {
+	Code         string `json:"code"`
+	Description  string `json:"description"`
+	DiscountType string `json:"discount_type"`
+	// DiscountAmount is taken off by fixed codes, DiscountPercent (0-100] by percent codes
+	DiscountAmount  models.Money `json:"discount_amount"`
+	DiscountPercent float64      `json:"discount_percent"`
+	// MaxDiscount caps percent discounts; 0 = no cap
+	MaxDiscount models.Money `json:"max_discount"`
+	MinOrder    models.Money `json:"min_order"`
+	ValidFrom   *time.Time   `json:"valid_from"`
+	ValidUntil  *time.Time   `json:"valid_until"`
+	// MaxUses and MaxUsesPerEmail limit redemptions; 0 = unlimited
+	MaxUses          int  `json:"max_uses"`
+	MaxUsesPerEmail  int  `json:"max_uses_per_email"`
+	FirstBookingOnly bool `json:"first_booking_only"`
+	// CourtIds and TimeslotIds restrict the discount, e.g. "1,3"; empty = all
+	CourtIds    string `json:"court_ids"`
+	TimeslotIds string `json:"timeslot_ids"`
+	IsActive    *bool  `json:"is_active"`
+}
+
+// promoCodePattern matches codes customers can type: letters, digits, - and _
+var promoCodePattern = regexp.MustCompile(`^[A-Z0-9_-]{3,50}$`)
+
+// loadPromo reads the :id promo code or writes the error response and returns nil
+func (c *AdminPromoController) loadPromo() *models.PromoCode {
+	id, err := strconv.Atoi(c.Ctx.Input.Param(":id"))
+	if err != nil {
+		utils.SendBadRequest(&c.Controller, "Invalid promo code id", nil)
+		return nil
+	}
+	p, err := models.GetPromoCodeById(id)
+	if errors.Is(err, orm.ErrNoRows) {
+		utils.SendNotFound(&c.Controller, "Promo code not found")
+		return nil
+	}
+	if err != nil {
+		utils.SendInternalError(&c.Controller, "Error retrieving promo code", err.Error())
+		return nil
+	}
+	return p
+}
+
+// applyRequest copies the request body onto p and validates it, writing a 400 on failure
+func (c *AdminPromoController) applyRequest(p *models.PromoCode) bool {
+	var req PromoCodeRequest
+	if err := json.Unmarshal(c.Ctx.Input.RequestBody, &req); err != nil {
+		utils.SendBadRequest(&c.Controller, "Invalid request body", err.Error())
+		return false
+	}
+
+	req.Code = models.NormalizePromoCode(req.Code)
+	switch {
+	case !promoCodePattern.MatchString(req.Code):
+		utils.SendBadRequest(&c.Controller, "code must be 3-50 letters, digits, - or _", nil)
+		return false
+	case req.DiscountType != models.PromoPercent && req.DiscountType != models.PromoFixed:
+		utils.SendBadRequest(&c.Controller, "discount_type must be percent or fixed", nil)
+		return false
+	case req.DiscountType == models.PromoFixed && req.DiscountAmount.Amount <= 0:
+		utils.SendBadRequest(&c.Controller, "discount_amount must be positive for fixed codes", nil)
+		return false
+	case req.DiscountType == models.PromoPercent && (req.DiscountPercent <= 0 || req.DiscountPercent > 100):
+		utils.SendBadRequest(&c.Controller, "discount_percent must be greater than 0 and at most 100 for percent codes", nil)
+		return false
+	case req.MaxDiscount.Amount < 0 || req.MinOrder.Amount < 0 || req.MaxUses < 0 || req.MaxUsesPerEmail < 0:
+		utils.SendBadRequest(&c.Controller, "max_discount, min_order, max_uses and max_uses_per_email must not be negative", nil)
+		return false
+	case req.ValidFrom != nil && req.ValidUntil != nil && !req.ValidUntil.After(*req.ValidFrom):
+		utils.SendBadRequest(&c.Controller, "valid_until must be after valid_from", nil)
+		return false
+	}
+	if _, err := models.ParseIdList(req.CourtIds); err != nil {
+		utils.SendBadRequest(&c.Controller, "Invalid court_ids", err.Error())
+		return false
+	}
+	if _, err := models.ParseIdList(req.TimeslotIds); err != nil {
+		utils.SendBadRequest(&c.Controller, "Invalid timeslot_ids", err.Error())
+		return false
+	}
+
+	p.Code = req.Code
+	p.Description = req.Description
+	p.DiscountType = req.DiscountType
+	// Only the column the discount type uses is kept
+	p.DiscountAmount = models.Money{}
+	p.DiscountPercent = 0
+	if req.DiscountType == models.PromoFixed {
+		p.DiscountAmount = req.DiscountAmount
+	} else {
+		p.DiscountPercent = req.DiscountPercent
+	}
+	p.MaxDiscount = req.MaxDiscount
+	p.MinOrder = req.MinOrder
+	p.ValidFrom = req.ValidFrom
+	p.ValidUntil = req.ValidUntil
+	p.MaxUses = req.MaxUses
+	p.MaxUsesPerEmail = req.MaxUsesPerEmail
+	p.FirstBookingOnly = req.FirstBookingOnly
+	p.CourtIds = req.CourtIds
+	p.TimeslotIds = req.TimeslotIds
+	if req.IsActive != nil {
+		p.IsActive = *req.IsActive
+	}
+	return true
+}
+
+// ListPromoCodes godoc
+// @Summary List promo codes with their usage (admin)
+// @Tags admin-promo
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} utils.Response
+// @Router /api/v1/admin/promo-codes [get]
+func (c *AdminPromoController) ListPromoCodes() {
+	list, err := models.GetAllPromoCodes()
+	if err != nil {
+		utils.SendInternalError(&c.Controller, "Error retrieving promo codes", err.Error())
+		return
+	}
+	utils.SendSuccess(&c.Controller, "Promo codes retrieved successfully", list)
+}
+
+// CreatePromoCode godoc
+// @Summary Create a promo code (admin)
+// @Description discount_type is percent (discount_percent of the eligible slots, optionally capped by max_discount) or fixed (discount_amount). Usage only counts reservations that were not cancelled or expired.
+// @Tags admin-promo
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param promo body PromoCodeRequest true "Promo code"
+// @Success 201 {object} utils.Response
+// @Router /api/v1/admin/promo-codes [post]
+func (c *AdminPromoController) CreatePromoCode() {
+	p := &models.PromoCode{IsActive: true}
+	if !c.applyRequest(p) {
+		return
+	}
+	err := models.CreatePromoCode(p)
+	if errors.Is(err, models.ErrDuplicatePromoCode) {
+		utils.SendConflict(&c.Controller, "Promo code already exists", nil)
+		return
+	}
+	if err != nil {
+		utils.SendInternalError(&c.Controller, "Error creating promo code", err.Error())
+		return
+	}
+
+	c.Ctx.Output.SetStatus(201)
+	utils.SendSuccess(&c.Controller, "Promo code created successfully", p)
+}
+
+// UpdatePromoCode godoc
+// @Summary Replace a promo code (admin)
+// @Description Reservations that already redeemed the code keep their discount.
+// @Tags admin-promo
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Promo code ID"
+// @Param promo body PromoCodeRequest true "Promo code"
+// @Success 200 {object} utils.Response
+// @Router /api/v1/admin/promo-codes/{id} [put]
+func (c *AdminPromoController) UpdatePromoCode() {
+	p := c.loadPromo()
+	if p == nil {
+		return
+	}
+	if !c.applyRequest(p) {
+		return
+	}
+	err := models.UpdatePromoCode(p)
+	if errors.Is(err, models.ErrDuplicatePromoCode) {
+		utils.SendConflict(&c.Controller, "Promo code already exists", nil)
+		return
+	}
+	if err != nil {
+		utils.SendInternalError(&c.Controller, "Error updating promo code", err.Error())
+		return
+	}
+	utils.SendSuccess(&c.Controller, "Promo code updated successfully", p)
+}
+
+// DeletePromoCode godoc
+// @Summary Delete a promo code (admin)
+// @Description Refused with 409 once the code has been redeemed; deactivate it instead.
+// @Tags admin-promo
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Promo code ID"
+// @Success 200 {object} utils.Response
+// @Router /api/v1/admin/promo-codes/{id} [delete]
+func (c *AdminPromoController) DeletePromoCode() {
+	p := c.loadPromo()
+	if p == nil {
+		return
+	}
+	err := models.DeletePromoCode(p.Id)
+	if errors.Is(err, models.ErrPromoCodeInUse) {
+		utils.SendConflict(&c.Controller, "Promo code has been redeemed; deactivate it instead", nil)
+		return
+	}
+	if err != nil {
+		utils.SendInternalError(&c.Controller, "Error deleting promo code", err.Error())
+		return
+	}
+	utils.SendSuccess(&c.Controller, "Promo code deleted successfully", map[string]int{"id": p.Id})
+}
@@ -0,0 +1,256 @@
+package controllers
+
+import (
+	"badminton-reservation-api/models"
+	"badminton-reservation-api/utils"
+	"encoding/json"
+	"errors"
+	"strconv"
+
+	"github.com/beego/beego/v2/client/orm"
+	"github.com/beego/beego/v2/server/web"
+)
+
+// AdminTimeslotController manages timeslot definitions. All routes require an admin token.
+type AdminTimeslotController struct {
+	web.Controller
+}
+
+type TimeslotRequest struct {
+	StartTime string `json:"start_time"`
+	EndTime   string `json:"end_time"`
+	IsActive  *bool  `json:"is_active"`
+}
+
+// validateTimeRange checks both times are HH:MM:SS and end is after start, writing a 400 otherwise
+func (c *AdminTimeslotController) validateTimeRange(start, end string) bool {
+	if !utils.ValidateTime(start) || !utils.ValidateTime(end) {
+		utils.SendBadRequest(&c.Controller, "start_time and end_time must use HH:MM:SS format", nil)
+		return false
+	}
+	// Zero-padded HH:MM:SS strings compare in time order
+	if end <= start {
+		utils.SendBadRequest(&c.Controller, "end_time must be after start_time", nil)
+		return false
+	}
+	return true
+}
+
+// checkNoOverlap writes a 409 and returns false when another active slot overlaps the range
+func (c *AdminTimeslotController) checkNoOverlap(start, end string, excludeId int) bool {
+	overlap, err := models.FindOverlappingActiveTimeslot(start, end, excludeId)
+	if err != nil {
+		utils.SendInternalError(&c.Controller, "Error checking overlapping timeslots", err.Error())
+		return false
+	}
+	if overlap != nil {
+		utils.SendConflict(&c.Controller, "Timeslot overlaps an existing active timeslot", overlap)
+		return false
+	}
+	return true
+}
+
+// loadTimeslot reads the :id timeslot or writes the error response and returns nil
+func (c *AdminTimeslotController) loadTimeslot() *models.Timeslot {
+	id, err := strconv.Atoi(c.Ctx.Input.Param(":id"))
+	if err != nil {
+		utils.SendBadRequest(&c.Controller, "Invalid timeslot id", nil)
+		return nil
+	}
+	slot, err := models.GetTimeslotById(id)
+	if errors.Is(err, orm.ErrNoRows) {
+		utils.SendNotFound(&c.Controller, "Timeslot not found")
+		return nil
+	}
+	if err != nil {
+		utils.SendInternalError(&c.Controller, "Error retrieving timeslot", err.Error())
+		return nil
+	}
+	return slot
+}
+
+// ListTimeslots godoc
+// @Summary List all timeslots (admin)
+// @Tags admin-timeslots
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} utils.Response
+// @Router /api/v1/admin/timeslots [get]
+func (c *AdminTimeslotController) ListTimeslots() {
+	slots, err := models.GetAllTimeslots()
+	if err != nil {
+		utils.SendInternalError(&c.Controller, "Error retrieving timeslots", err.Error())
+		return
+	}
+	utils.SendSuccess(&c.Controller, "Timeslots retrieved successfully", slots)
+}
+
+// CreateTimeslot godoc
+// @Summary Create a timeslot (admin)
+// @Description Times use HH:MM:SS. Active timeslots may not overlap.
+// @Tags admin-timeslots
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param timeslot body TimeslotRequest true "Timeslot definition"
+// @Success 201 {object} utils.Response
+// @Router /api/v1/admin/timeslots [post]
+func (c *AdminTimeslotController) CreateTimeslot() {
+	var req TimeslotRequest
+	if err := json.Unmarshal(c.Ctx.Input.RequestBody, &req); err != nil {
+		utils.SendBadRequest(&c.Controller, "Invalid request body", err.Error())
+		return
+	}
+	if !c.validateTimeRange(req.StartTime, req.EndTime) {
+		return
+	}
+
+	slot := &models.Timeslot{StartTime: req.StartTime, EndTime: req.EndTime, IsActive: true}
+	if req.IsActive != nil {
+		slot.IsActive = *req.IsActive
+	}
+	if slot.IsActive && !c.checkNoOverlap(slot.StartTime, slot.EndTime, 0) {
+		return
+	}
+
+	if err := models.CreateTimeslot(slot); err != nil {
+		utils.SendInternalError(&c.Controller, "Error creating timeslot", err.Error())
+		return
+	}
+
+	c.Ctx.Output.SetStatus(201)
+	utils.SendSuccess(&c.Controller, "Timeslot created successfully", slot)
+}
+
+// UpdateTimeslot godoc
+// @Summary Edit a timeslot (admin)
+// @Description Changing the times of a slot used by future reservations is refused with 409.
+// @Tags admin-timeslots
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Timeslot ID"
+// @Param timeslot body TimeslotRequest true "Timeslot definition"
+// @Success 200 {object} utils.Response
+// @Router /api/v1/admin/timeslots/{id} [put]
+func (c *AdminTimeslotController) UpdateTimeslot() {
+	slot := c.loadTimeslot()
+	if slot == nil {
+		return
+	}
+
+	var req TimeslotRequest
+	if err := json.Unmarshal(c.Ctx.Input.RequestBody, &req); err != nil {
+		utils.SendBadRequest(&c.Controller, "Invalid request body", err.Error())
+		return
+	}
+	if req.StartTime == "" {
+		req.StartTime = slot.StartTime
+	}
+	if req.EndTime == "" {
+		req.EndTime = slot.EndTime
+	}
+	if !c.validateTimeRange(req.StartTime, req.EndTime) {
+		return
+	}
+
+	// Moving a slot would silently change the time of bookings already made against it
+	if req.StartTime != slot.StartTime || req.EndTime != slot.EndTime {
+		cnt, err := models.CountFutureReservationsByTimeslot(slot.Id)
+		if err != nil {
+			utils.SendInternalError(&c.Controller, "Error checking timeslot reservations", err.Error())
+			return
+		}
+		if cnt > 0 {
+			utils.SendConflict(&c.Controller, "Timeslot times cannot change while future reservations use it", map[string]int64{"reservations": cnt})
+			return
+		}
+	}
+
+	slot.StartTime = req.StartTime
+	slot.EndTime = req.EndTime
+	if req.IsActive != nil {
+		slot.IsActive = *req.IsActive
+	}
+	if slot.IsActive && !c.checkNoOverlap(slot.StartTime, slot.EndTime, slot.Id) {
+		return
+	}
+
+	if err := models.UpdateTimeslot(slot, "start_time", "end_time", "is_active"); err != nil {
+		utils.SendInternalError(&c.Controller, "Error updating timeslot", err.Error())
+		return
+	}
+	utils.SendSuccess(&c.Controller, "Timeslot updated successfully", slot)
+}
+
+// Activate godoc
+// @Summary Activate a timeslot (admin)
+// @Tags admin-timeslots
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Timeslot ID"
+// @Success 200 {object} utils.Response
+// @Router /api/v1/admin/timeslots/{id}/activate [post]
+func (c *AdminTimeslotController) Activate() {
+	slot := c.loadTimeslot()
+	if slot == nil {
+		return
+	}
+	if !c.checkNoOverlap(slot.StartTime, slot.EndTime, slot.Id) {
+		return
+	}
+	slot.IsActive = true
+	if err := models.UpdateTimeslot(slot, "is_active"); err != nil {
+		utils.SendInternalError(&c.Controller, "Error updating timeslot", err.Error())
+		return
+	}
+	utils.SendSuccess(&c.Controller, "Timeslot activated successfully", slot)
+}
+
+// Deactivate godoc
+// @Summary Deactivate a timeslot (admin)
+// @Description Inactive timeslots can no longer be booked; existing reservations are kept.
+// @Tags admin-timeslots
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Timeslot ID"
+// @Success 200 {object} utils.Response
+// @Router /api/v1/admin/timeslots/{id}/deactivate [post]
+func (c *AdminTimeslotController) Deactivate() {
+	slot := c.loadTimeslot()
+	if slot == nil {
+		return
+	}
+	slot.IsActive = false
+	if err := models.UpdateTimeslot(slot, "is_active"); err != nil {
+		utils.SendInternalError(&c.Controller, "Error updating timeslot", err.Error())
+		return
+	}
+	utils.SendSuccess(&c.Controller, "Timeslot deactivated successfully", slot)
+}
+
+// DeleteTimeslot godoc
+// @Summary Delete a timeslot (admin)
+// @Description Refused with 409 while reservations reference the timeslot; deactivate it instead.
+// @Tags admin-timeslots
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Timeslot ID"
+// @Success 200 {object} utils.Response
+// @Router /api/v1/admin/timeslots/{id} [delete]
+func (c *AdminTimeslotController) DeleteTimeslot() {
+	slot := c.loadTimeslot()
+	if slot == nil {
+		return
+	}
+	err := models.DeleteTimeslot(slot.Id)
+	if errors.Is(err, models.ErrTimeslotInUse) {
+		utils.SendConflict(&c.Controller, "Timeslot is referenced by reservations; deactivate it instead", nil)
+		return
+	}
+	if err != nil {
+		utils.SendInternalError(&c.Controller, "Error deleting timeslot", err.Error())
+		return
+	}
+	utils.SendSuccess(&c.Controller, "Timeslot deleted successfully", map[string]int{"id": slot.Id})
+}
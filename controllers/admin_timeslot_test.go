@@ -0,0 +1,143 @@
+package controllers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"badminton-reservation-api/models"
+
+	"github.com/beego/beego/v2/client/orm"
+	"github.com/google/uuid"
+)
+
+func adminTimeslotRouter() http.Handler {
+	return newTestRouter(
+		testRoute{"/api/v1/admin/timeslots", &AdminTimeslotController{}, "get:ListTimeslots;post:CreateTimeslot"},
+		testRoute{"/api/v1/admin/timeslots/:id", &AdminTimeslotController{}, "put:UpdateTimeslot;delete:DeleteTimeslot"},
+		testRoute{"/api/v1/admin/timeslots/:id/activate", &AdminTimeslotController{}, "post:Activate"},
+		testRoute{"/api/v1/admin/timeslots/:id/deactivate", &AdminTimeslotController{}, "post:Deactivate"},
+	)
+}
+
+func TestCreateTimeslotValidation(t *testing.T) {
+	router := adminTimeslotRouter()
+	tests := []struct {
+		name       string
+		start, end string
+		message    string
+	}{
+		{"hours and minutes only", "09:00", "10:00", "start_time and end_time must use HH:MM:SS format"},
+		{"unpadded hour", "9:00:00", "10:00:00", "start_time and end_time must use HH:MM:SS format"},
+		{"hour out of range", "23:00:00", "24:00:00", "start_time and end_time must use HH:MM:SS format"},
+		{"trailing zone", "09:00:00Z", "10:00:00", "start_time and end_time must use HH:MM:SS format"},
+		{"missing end", "09:00:00", "", "start_time and end_time must use HH:MM:SS format"},
+		{"end before start", "10:00:00", "09:00:00", "end_time must be after start_time"},
+		{"empty range", "10:00:00", "10:00:00", "end_time must be after start_time"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body := fmt.Sprintf(`{"start_time":%q,"end_time":%q}`, tt.start, tt.end)
+			res := do(t, router, "POST", "/api/v1/admin/timeslots", body)
+			if res.Status != http.StatusBadRequest || res.Message != tt.message {
+				t.Errorf("got %d %q, want 400 %q", res.Status, res.Message, tt.message)
+			}
+		})
+	}
+}
+
+func TestTimeslotOverlapAndDelete(t *testing.T) {
+	requireDB(t)
+	router := adminTimeslotRouter()
+	// The tests share the database, so work in a window no other active slot uses
+	if existing, err := models.FindOverlappingActiveTimeslot("03:00:00", "04:00:00", 0); err != nil || existing != nil {
+		t.Skipf("03:00-04:00 is not free in the test database (%v)", err)
+	}
+
+	create := func(start, end string) (testResponse, *models.Timeslot) {
+		t.Helper()
+		res := do(t, router, "POST", "/api/v1/admin/timeslots", fmt.Sprintf(`{"start_time":%q,"end_time":%q}`, start, end))
+		if res.Status != http.StatusCreated {
+			return res, nil
+		}
+		var slot models.Timeslot
+		if err := json.Unmarshal(res.Data, &slot); err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { orm.NewOrm().Raw("DELETE FROM timeslots WHERE id = ?", slot.Id).Exec() })
+		return res, &slot
+	}
+
+	_, first := create("03:00:00", "03:30:00")
+	if first == nil {
+		t.Fatal("first slot was not created")
+	}
+	res, overlapping := create("03:15:00", "03:45:00")
+	if res.Status != http.StatusConflict || overlapping != nil {
+		t.Fatalf("overlapping slot: %d %s, want 409", res.Status, res.Message)
+	}
+	var reported models.Timeslot
+	if err := json.Unmarshal(res.Error, &reported); err != nil || reported.Id != first.Id {
+		t.Errorf("conflict names %s, want slot %d", res.Error, first.Id)
+	}
+	res, second := create("03:30:00", "04:00:00")
+	if second == nil {
+		t.Fatalf("adjacent slot: %d %s, want 201", res.Status, res.Message)
+	}
+
+	path := fmt.Sprintf("/api/v1/admin/timeslots/%d", second.Id)
+	if res := do(t, router, "PUT", path, `{"start_time":"03:20:00"}`); res.Status != http.StatusConflict {
+		t.Errorf("moving into an active slot: %d %s, want 409", res.Status, res.Message)
+	}
+	if res := do(t, router, "POST", path+"/deactivate", ""); res.Status != http.StatusOK {
+		t.Fatalf("deactivate: %d %s", res.Status, res.Message)
+	}
+	if res := do(t, router, "PUT", path, `{"start_time":"03:20:00"}`); res.Status != http.StatusOK {
+		t.Errorf("inactive slots may overlap: %d %s", res.Status, res.Message)
+	}
+	if res := do(t, router, "POST", path+"/activate", ""); res.Status != http.StatusConflict {
+		t.Errorf("activating an overlapping slot: %d %s, want 409", res.Status, res.Message)
+	}
+
+	// A past booking holds no slot but still references the timeslot through the foreign key
+	court := &models.Court{Name: "Test " + t.Name(), PricePerHour: models.Whole(100000)}
+	if err := models.CreateCourt(court); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		o := orm.NewOrm()
+		o.Raw("DELETE FROM reservations WHERE id IN (SELECT reservation_id FROM reservation_items WHERE court_id = ?)", court.Id).Exec()
+		o.Raw("DELETE FROM timeslot_availabilities WHERE court_id = ?", court.Id).Exec()
+		o.Raw("DELETE FROM courts WHERE id = ?", court.Id).Exec()
+	})
+	book := func(daysAhead int) {
+		t.Helper()
+		r := &models.Reservation{
+			Id: uuid.New().String(), CourtId: court.Id, TimeslotId: first.Id,
+			BookingDate:  time.Now().AddDate(0, 0, daysAhead).Format("2006-01-02"),
+			CustomerName: "Timeslot Tester", CustomerEmail: "timeslot@example.com", CustomerPhone: "+6281234567890",
+			TotalPrice: court.PricePerHour, Status: models.ReservationPaid, ExpiredAt: time.Now().Add(time.Hour),
+		}
+		if err := models.CreateReservation(r); err != nil {
+			t.Fatal(err)
+		}
+	}
+	firstPath := fmt.Sprintf("/api/v1/admin/timeslots/%d", first.Id)
+
+	book(-3)
+	if res := do(t, router, "DELETE", firstPath, ""); res.Status != http.StatusConflict {
+		t.Errorf("delete with a past booking: %d %s, want 409", res.Status, res.Message)
+	}
+	book(3)
+	if res := do(t, router, "DELETE", firstPath, ""); res.Status != http.StatusConflict {
+		t.Errorf("delete with a future booking: %d %s, want 409", res.Status, res.Message)
+	}
+	if res := do(t, router, "PUT", firstPath, `{"end_time":"03:25:00"}`); res.Status != http.StatusConflict {
+		t.Errorf("moving a booked slot: %d %s, want 409", res.Status, res.Message)
+	}
+	if res := do(t, router, "DELETE", path, ""); res.Status != http.StatusOK {
+		t.Errorf("delete of an unused slot: %d %s", res.Status, res.Message)
+	}
+}
@@ -0,0 +1,326 @@
+package controllers
+
+import (
+	"badminton-reservation-api/models"
+	"badminton-reservation-api/services/webhook"
+	"badminton-reservation-api/utils"
+	"encoding/json"
+	"errors"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/beego/beego/v2/client/orm"
+	"github.com/beego/beego/v2/server/web"
+)
+
+// AdminWebhookController manages outbound webhook endpoints and their delivery log. All
+// routes require an admin token.
+type AdminWebhookController struct {
+	web.Controller
+}
+
+type WebhookEndpointRequest struct {
+	Url         string `json:"url"`
+	Description string `json:"description"`
+	// EventTypes limits the events sent, e.g. ["ReservationPaid", "PaymentRefunded"]; empty sends all
+	EventTypes []string `json:"event_types"`
+	IsActive   *bool    `json:"is_active"`
+}
+
+// WebhookEndpointResponse shows an endpoint with its signing secret, only when it is created
+// or the secret is rotated
+type WebhookEndpointResponse struct {
+	*models.WebhookEndpoint
+	Secret string `json:"secret"`
+}
+
+// loadEndpoint reads the :id endpoint or writes the error response and returns nil
+func (c *AdminWebhookController) loadEndpoint() *models.WebhookEndpoint {
+	id, err := strconv.Atoi(c.Ctx.Input.Param(":id"))
+	if err != nil {
+		utils.SendBadRequest(&c.Controller, "Invalid webhook endpoint id", nil)
+		return nil
+	}
+	e, err := models.GetWebhookEndpointById(id)
+	if errors.Is(err, orm.ErrNoRows) {
+		utils.SendNotFound(&c.Controller, "Webhook endpoint not found")
+		return nil
+	}
+	if err != nil {
+		utils.SendInternalError(&c.Controller, "Error retrieving webhook endpoint", err.Error())
+		return nil
+	}
+	return e
+}
+
+// applyRequest copies the request body onto e and validates it, writing a 400 on failure
+func (c *AdminWebhookController) applyRequest(e *models.WebhookEndpoint) bool {
+	var req WebhookEndpointRequest
+	if err := json.Unmarshal(c.Ctx.Input.RequestBody, &req); err != nil {
+		utils.SendBadRequest(&c.Controller, "Invalid request body", err.Error())
+		return false
+	}
+
+	u, err := url.Parse(strings.TrimSpace(req.Url))
+	if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" || u.User != nil {
+		utils.SendBadRequest(&c.Controller, "url must be an absolute http or https URL without credentials", nil)
+		return false
+	}
+	known := map[string]bool{}
+	for _, t := range models.EventTypes() {
+		known[t] = true
+	}
+	var eventTypes []string
+	seen := map[string]bool{}
+	for _, t := range req.EventTypes {
+		t = strings.TrimSpace(t)
+		if !known[t] {
+			utils.SendBadRequest(&c.Controller, "Unknown event type", map[string]interface{}{"event_type": t, "event_types": models.EventTypes()})
+			return false
+		}
+		if !seen[t] {
+			seen[t] = true
+			eventTypes = append(eventTypes, t)
+		}
+	}
+
+	e.Url = u.String()
+	e.Description = req.Description
+	e.EventTypeList = eventTypes
+	if req.IsActive != nil {
+		e.IsActive = *req.IsActive
+	}
+	return true
+}
+
+// ListEndpoints godoc
+// @Summary List webhook endpoints (admin)
+// @Description Partner URLs that receive booking and payment events. Secrets are not shown.
+// @Tags admin-webhooks
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} utils.Response
+// @Router /api/v1/admin/webhooks [get]
+func (c *AdminWebhookController) ListEndpoints() {
+	list, err := models.GetWebhookEndpoints(false)
+	if err != nil {
+		utils.SendInternalError(&c.Controller, "Error retrieving webhook endpoints", err.Error())
+		return
+	}
+	utils.SendSuccess(&c.Controller, "Webhook endpoints retrieved successfully", map[string]interface{}{
+		"event_types": models.EventTypes(),
+		"endpoints":   list,
+	})
+}
+
+// CreateEndpoint godoc
+// @Summary Register a webhook endpoint (admin)
+// @Description The endpoint receives every event in event_types (all events when empty) as a signed POST from the moment it is registered. The response carries the signing secret; it is not shown again.
+// @Tags admin-webhooks
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param endpoint body WebhookEndpointRequest true "Webhook endpoint"
+// @Success 201 {object} utils.Response
+// @Failure 400 {object} utils.Response
+// @Router /api/v1/admin/webhooks [post]
+func (c *AdminWebhookController) CreateEndpoint() {
+	e := &models.WebhookEndpoint{IsActive: true}
+	if !c.applyRequest(e) {
+		return
+	}
+	secret, err := webhook.NewSecret()
+	if err != nil {
+		utils.SendInternalError(&c.Controller, "Error creating webhook secret", err.Error())
+		return
+	}
+	e.Secret = secret
+	if err := models.CreateWebhookEndpoint(e); err != nil {
+		utils.SendInternalError(&c.Controller, "Error creating webhook endpoint", err.Error())
+		return
+	}
+
+	c.Ctx.Output.SetStatus(201)
+	utils.SendSuccess(&c.Controller, "Webhook endpoint created successfully", &WebhookEndpointResponse{WebhookEndpoint: e, Secret: e.Secret})
+}
+
+// GetEndpoint godoc
+// @Summary Get a webhook endpoint (admin)
+// @Tags admin-webhooks
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Webhook endpoint ID"
+// @Success 200 {object} utils.Response
+// @Router /api/v1/admin/webhooks/{id} [get]
+func (c *AdminWebhookController) GetEndpoint() {
+	e := c.loadEndpoint()
+	if e == nil {
+		return
+	}
+	utils.SendSuccess(&c.Controller, "Webhook endpoint retrieved successfully", e)
+}
+
+// UpdateEndpoint godoc
+// @Summary Replace a webhook endpoint (admin)
+// @Description Deliveries already queued are sent to the new URL. A deactivated endpoint receives no new events; its pending deliveries wait until it is activated again.
+// @Tags admin-webhooks
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Webhook endpoint ID"
+// @Param endpoint body WebhookEndpointRequest true "Webhook endpoint"
+// @Success 200 {object} utils.Response
+// @Router /api/v1/admin/webhooks/{id} [put]
+func (c *AdminWebhookController) UpdateEndpoint() {
+	e := c.loadEndpoint()
+	if e == nil {
+		return
+	}
+	if !c.applyRequest(e) {
+		return
+	}
+	if err := models.UpdateWebhookEndpoint(e); err != nil {
+		utils.SendInternalError(&c.Controller, "Error updating webhook endpoint", err.Error())
+		return
+	}
+	utils.SendSuccess(&c.Controller, "Webhook endpoint updated successfully", e)
+}
+
+// DeleteEndpoint godoc
+// @Summary Delete a webhook endpoint (admin)
+// @Description Removes the endpoint with its delivery log. Deactivate it instead to keep the log.
+// @Tags admin-webhooks
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Webhook endpoint ID"
+// @Success 200 {object} utils.Response
+// @Router /api/v1/admin/webhooks/{id} [delete]
+func (c *AdminWebhookController) DeleteEndpoint() {
+	e := c.loadEndpoint()
+	if e == nil {
+		return
+	}
+	if err := models.DeleteWebhookEndpoint(e.Id); err != nil {
+		utils.SendInternalError(&c.Controller, "Error deleting webhook endpoint", err.Error())
+		return
+	}
+	utils.SendSuccess(&c.Controller, "Webhook endpoint deleted successfully", map[string]int{"id": e.Id})
+}
+
+// RotateSecret godoc
+// @Summary Rotate a webhook endpoint's signing secret (admin)
+// @Description Every request from now on, retries included, is signed with the new secret, which the response carries. The old secret stops working immediately.
+// @Tags admin-webhooks
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Webhook endpoint ID"
+// @Success 200 {object} utils.Response
+// @Router /api/v1/admin/webhooks/{id}/rotate-secret [post]
+func (c *AdminWebhookController) RotateSecret() {
+	e := c.loadEndpoint()
+	if e == nil {
+		return
+	}
+	secret, err := webhook.NewSecret()
+	if err != nil {
+		utils.SendInternalError(&c.Controller, "Error creating webhook secret", err.Error())
+		return
+	}
+	e.Secret = secret
+	if err := models.UpdateWebhookEndpoint(e); err != nil {
+		utils.SendInternalError(&c.Controller, "Error updating webhook endpoint", err.Error())
+		return
+	}
+	utils.SendSuccess(&c.Controller, "Webhook secret rotated successfully", &WebhookEndpointResponse{WebhookEndpoint: e, Secret: e.Secret})
+}
+
+// ListDeliveries godoc
+// @Summary List webhook deliveries (admin)
+// @Description The delivery log: one entry per event and endpoint. pending deliveries wait for their first attempt or a retry, dead ones failed WEBHOOK_MAX_ATTEMPTS times and need a replay.
+// @Tags admin-webhooks
+// @Produce json
+// @Security BearerAuth
+// @Param endpoint_id query int false "Webhook endpoint ID"
+// @Param status query string false "pending, delivered or dead"
+// @Param event_type query string false "Event type, e.g. ReservationPaid"
+// @Param limit query int false "Maximum number of deliveries (default 50, max 500)"
+// @Success 200 {object} utils.Response
+// @Router /api/v1/admin/webhook-deliveries [get]
+func (c *AdminWebhookController) ListDeliveries() {
+	limit, err := c.GetInt("limit", 50)
+	if err != nil || limit < 1 || limit > 500 {
+		utils.SendBadRequest(&c.Controller, "limit must be between 1 and 500", nil)
+		return
+	}
+	endpointId, err := c.GetInt("endpoint_id", 0)
+	if err != nil {
+		utils.SendBadRequest(&c.Controller, "Invalid endpoint_id", nil)
+		return
+	}
+	list, err := models.GetWebhookDeliveries(endpointId, c.GetString("status"), c.GetString("event_type"), limit)
+	if err != nil {
+		utils.SendInternalError(&c.Controller, "Error retrieving webhook deliveries", err.Error())
+		return
+	}
+	utils.SendSuccess(&c.Controller, "Webhook deliveries retrieved successfully", list)
+}
+
+// GetDelivery godoc
+// @Summary Get a webhook delivery with its attempts (admin)
+// @Description Every request made for the delivery with the status code, error, start of the response body and duration.
+// @Tags admin-webhooks
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Webhook delivery ID"
+// @Success 200 {object} utils.Response
+// @Router /api/v1/admin/webhook-deliveries/{id} [get]
+func (c *AdminWebhookController) GetDelivery() {
+	id, err := strconv.ParseInt(c.Ctx.Input.Param(":id"), 10, 64)
+	if err != nil {
+		utils.SendBadRequest(&c.Controller, "Invalid webhook delivery ID", nil)
+		return
+	}
+	d, err := models.GetWebhookDeliveryById(id)
+	if errors.Is(err, orm.ErrNoRows) {
+		utils.SendNotFound(&c.Controller, "Webhook delivery not found")
+		return
+	}
+	if err != nil {
+		utils.SendInternalError(&c.Controller, "Error retrieving webhook delivery", err.Error())
+		return
+	}
+	utils.SendSuccess(&c.Controller, "Webhook delivery retrieved successfully", d)
+}
+
+// ReplayDelivery godoc
+// @Summary Replay a webhook delivery (admin)
+// @Description Sends a delivered, pending or dead delivery again on the next run with a fresh set of attempts. The body is the one originally sent, with a new signature.
+// @Tags admin-webhooks
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Webhook delivery ID"
+// @Success 200 {object} utils.Response
+// @Failure 409 {object} utils.Response
+// @Router /api/v1/admin/webhook-deliveries/{id}/replay [post]
+func (c *AdminWebhookController) ReplayDelivery() {
+	id, err := strconv.ParseInt(c.Ctx.Input.Param(":id"), 10, 64)
+	if err != nil {
+		utils.SendBadRequest(&c.Controller, "Invalid webhook delivery ID", nil)
+		return
+	}
+	d, err := models.ReplayWebhookDelivery(id)
+	if errors.Is(err, orm.ErrNoRows) {
+		utils.SendNotFound(&c.Controller, "Webhook delivery not found")
+		return
+	}
+	if errors.Is(err, models.ErrWebhookEndpointInactive) {
+		utils.SendConflict(&c.Controller, "Webhook endpoint is inactive; activate it first", d)
+		return
+	}
+	if err != nil {
+		utils.SendInternalError(&c.Controller, "Error replaying webhook delivery", err.Error())
+		return
+	}
+	utils.SendSuccess(&c.Controller, "Webhook delivery queued for replay", d)
+}
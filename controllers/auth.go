@@ -0,0 +1,216 @@
+package controllers
+
+import (
+	"badminton-reservation-api/middleware"
+	"badminton-reservation-api/models"
+	"badminton-reservation-api/services/auth"
+	"badminton-reservation-api/utils"
+	"encoding/json"
+	"errors"
+	"strings"
+
+	"github.com/beego/beego/v2/server/web"
+	"github.com/google/uuid"
+)
+
+type AuthController struct {
+	web.Controller
+}
+
+type RegisterRequest struct {
+	Name     string `json:"name"`
+	Email    string `json:"email"`
+	Password string `json:"password"`
+}
+
+type LoginRequest struct {
+	Email    string `json:"email"`
+	Password string `json:"password"`
+}
+
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+type AuthResponse struct {
+	*auth.TokenPair
+	User *models.User `json:"user"`
+}
+
+// minPasswordLength is the minimum accepted password length on registration
+const minPasswordLength = 8
+
+// Register godoc
+// @Summary Register a customer account
+// @Description Create a new customer account and return an access/refresh token pair
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param body body RegisterRequest true "Account details"
+// @Success 201 {object} utils.Response
+// @Router /api/v1/auth/register [post]
+func (c *AuthController) Register() {
+	var req RegisterRequest
+	if err := json.Unmarshal(c.Ctx.Input.RequestBody, &req); err != nil {
+		utils.SendBadRequest(&c.Controller, "Invalid request body", err.Error())
+		return
+	}
+
+	missingFields := utils.ValidateRequired(map[string]string{
+		"name":     req.Name,
+		"email":    req.Email,
+		"password": req.Password,
+	})
+	if len(missingFields) > 0 {
+		utils.SendBadRequest(&c.Controller, "Missing required fields", missingFields)
+		return
+	}
+
+	if !utils.ValidateEmail(req.Email) {
+		utils.SendBadRequest(&c.Controller, "Invalid email format", nil)
+		return
+	}
+
+	if len(req.Password) < minPasswordLength {
+		utils.SendBadRequest(&c.Controller, "Password must be at least 8 characters", nil)
+		return
+	}
+
+	hash, err := auth.HashPassword(req.Password)
+	if err != nil {
+		utils.SendInternalError(&c.Controller, "Error creating account", err.Error())
+		return
+	}
+
+	// Self-registration always yields a customer; staff/admin accounts are created via cmd/createuser
+	user := &models.User{
+		Id:           uuid.New().String(),
+		Name:         strings.TrimSpace(req.Name),
+		Email:        strings.ToLower(strings.TrimSpace(req.Email)),
+		PasswordHash: hash,
+		Role:         models.RoleCustomer,
+		IsActive:     true,
+	}
+	err = models.CreateUser(user)
+	if errors.Is(err, models.ErrEmailTaken) {
+		utils.SendConflict(&c.Controller, "Email is already registered", nil)
+		return
+	}
+	if err != nil {
+		utils.SendInternalError(&c.Controller, "Error creating account", err.Error())
+		return
+	}
+
+	tokens, err := auth.GenerateTokenPair(user.Id, user.Email, user.Role)
+	if err != nil {
+		utils.SendInternalError(&c.Controller, "Error issuing token", err.Error())
+		return
+	}
+
+	c.Ctx.Output.SetStatus(201)
+	utils.SendSuccess(&c.Controller, "Account created successfully", AuthResponse{TokenPair: tokens, User: user})
+}
+
+// Login godoc
+// @Summary Log in
+// @Description Exchange email and password for an access/refresh token pair
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param body body LoginRequest true "Credentials"
+// @Success 200 {object} utils.Response
+// @Router /api/v1/auth/login [post]
+func (c *AuthController) Login() {
+	var req LoginRequest
+	if err := json.Unmarshal(c.Ctx.Input.RequestBody, &req); err != nil {
+		utils.SendBadRequest(&c.Controller, "Invalid request body", err.Error())
+		return
+	}
+
+	if req.Email == "" || req.Password == "" {
+		utils.SendBadRequest(&c.Controller, "email and password are required", nil)
+		return
+	}
+
+	// Use the same message for unknown email and wrong password to avoid account enumeration
+	user, err := models.GetUserByEmail(strings.TrimSpace(req.Email))
+	if err != nil || !user.IsActive || !auth.CheckPassword(user.PasswordHash, req.Password) {
+		utils.SendUnauthorized(&c.Controller, "Invalid email or password")
+		return
+	}
+
+	tokens, err := auth.GenerateTokenPair(user.Id, user.Email, user.Role)
+	if err != nil {
+		utils.SendInternalError(&c.Controller, "Error issuing token", err.Error())
+		return
+	}
+
+	utils.SendSuccess(&c.Controller, "Login successful", AuthResponse{TokenPair: tokens, User: user})
+}
+
+// Refresh godoc
+// @Summary Refresh tokens
+// @Description Exchange a valid refresh token for a new access/refresh token pair
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param body body RefreshRequest true "Refresh token"
+// @Success 200 {object} utils.Response
+// @Router /api/v1/auth/refresh [post]
+func (c *AuthController) Refresh() {
+	var req RefreshRequest
+	if err := json.Unmarshal(c.Ctx.Input.RequestBody, &req); err != nil {
+		utils.SendBadRequest(&c.Controller, "Invalid request body", err.Error())
+		return
+	}
+
+	if req.RefreshToken == "" {
+		utils.SendBadRequest(&c.Controller, "refresh_token is required", nil)
+		return
+	}
+
+	claims, err := auth.ParseToken(req.RefreshToken, auth.TokenTypeRefresh)
+	if err != nil {
+		utils.SendUnauthorized(&c.Controller, "Invalid or expired refresh token")
+		return
+	}
+
+	// Reload the user so deactivations and role changes take effect on refresh
+	user, err := models.GetUserById(claims.UserId())
+	if err != nil || !user.IsActive {
+		utils.SendUnauthorized(&c.Controller, "Invalid or expired refresh token")
+		return
+	}
+
+	tokens, err := auth.GenerateTokenPair(user.Id, user.Email, user.Role)
+	if err != nil {
+		utils.SendInternalError(&c.Controller, "Error issuing token", err.Error())
+		return
+	}
+
+	utils.SendSuccess(&c.Controller, "Token refreshed successfully", AuthResponse{TokenPair: tokens, User: user})
+}
+
+// Me godoc
+// @Summary Current user
+// @Description Returns the account of the authenticated caller
+// @Tags auth
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} utils.Response
+// @Router /api/v1/auth/me [get]
+func (c *AuthController) Me() {
+	claims := middleware.CurrentClaims(c.Ctx)
+	if claims == nil {
+		utils.SendUnauthorized(&c.Controller, "Missing or invalid bearer token")
+		return
+	}
+
+	user, err := models.GetUserById(claims.UserId())
+	if err != nil {
+		utils.SendNotFound(&c.Controller, "User not found")
+		return
+	}
+
+	utils.SendSuccess(&c.Controller, "User retrieved successfully", user)
+}
@@ -0,0 +1,80 @@
+package controllers
+
+import (
+	"badminton-reservation-api/models"
+	"badminton-reservation-api/services/pricing"
+	"badminton-reservation-api/utils"
+	"time"
+
+	"github.com/beego/beego/v2/server/web"
+)
+
+type AvailabilityController struct {
+	web.Controller
+}
+
+// maxAvailabilityDays caps the number of dates in one grid request
+const maxAvailabilityDays = 31
+
+// GetAvailability godoc
+// @Summary Get availability grid
+// @Description Returns a court × date × timeslot availability matrix for the date range in a single request. `from` defaults to today and `to` to six days after `from` (max 31 days). Without court_id all active courts are included. Each slot carries its `price` after pricing rules (peak hours, weekends, holidays).
+// @Tags availability
+// @Accept json
+// @Produce json
+// @Param from query string false "First date (YYYY-MM-DD)"
+// @Param to query string false "Last date, inclusive (YYYY-MM-DD)"
+// @Param court_id query int false "Court ID"
+// @Success 200 {object} utils.Response
+// @Router /api/v1/availability [get]
+func (c *AvailabilityController) GetAvailability() {
+	from := c.GetString("from")
+	to := c.GetString("to")
+	courtId, _ := c.GetInt("court_id", 0)
+
+	if from == "" {
+		from = time.Now().Format("2006-01-02")
+	}
+	if !utils.ValidateDate(from) {
+		utils.SendBadRequest(&c.Controller, "Invalid from date. Use YYYY-MM-DD", nil)
+		return
+	}
+	fromDate, _ := time.Parse("2006-01-02", from)
+	if to == "" {
+		to = fromDate.AddDate(0, 0, 6).Format("2006-01-02")
+	}
+	if !utils.ValidateDate(to) {
+		utils.SendBadRequest(&c.Controller, "Invalid to date. Use YYYY-MM-DD", nil)
+		return
+	}
+	toDate, _ := time.Parse("2006-01-02", to)
+	if toDate.Before(fromDate) {
+		utils.SendBadRequest(&c.Controller, "to must not be before from", nil)
+		return
+	}
+	if toDate.Sub(fromDate) >= maxAvailabilityDays*24*time.Hour {
+		utils.SendBadRequest(&c.Controller, "Date range must not exceed 31 days", nil)
+		return
+	}
+
+	grid, err := models.GetAvailabilityGrid(from, to, courtId)
+	if err != nil {
+		utils.SendInternalError(&c.Controller, "Error retrieving availability", err.Error())
+		return
+	}
+	if courtId > 0 && len(grid.Courts) == 0 {
+		utils.SendNotFound(&c.Controller, "Court not found")
+		return
+	}
+	rules, err := models.GetActivePricingRules()
+	if err != nil {
+		utils.SendInternalError(&c.Controller, "Error loading pricing rules", err.Error())
+		return
+	}
+	if err := pricing.PriceGrid(grid, rules); err != nil {
+		utils.SendInternalError(&c.Controller, "Error pricing timeslots", err.Error())
+		return
+	}
+
+	utils.SendSuccess(&c.Controller, "Availability retrieved successfully", grid)
+}
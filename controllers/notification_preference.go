@@ -0,0 +1,118 @@
+package controllers
+
+import (
+	"badminton-reservation-api/middleware"
+	"badminton-reservation-api/models"
+	"badminton-reservation-api/services/notification"
+	"badminton-reservation-api/utils"
+	"encoding/json"
+	"strings"
+
+	"github.com/beego/beego/v2/server/web"
+)
+
+// NotificationPreferenceController lets a signed-in customer choose how they hear about
+// their bookings. Preferences apply to the reservations made while signed in to the account;
+// bookings made without signing in are notified over the default channels.
+type NotificationPreferenceController struct {
+	web.Controller
+}
+
+type NotificationPreferenceRequest struct {
+	// Channels to notify over, in order: email, sms, whatsapp
+	Channels []string `json:"channels"`
+	// Phone for sms/whatsapp; empty uses the phone given with each reservation
+	Phone string `json:"phone"`
+	// Language is id or en; empty uses the default
+	Language string `json:"language"`
+}
+
+// GetPreferences godoc
+// @Summary Get notification preferences
+// @Description Channels, phone and language the caller's booking notifications use. Without saved preferences the default channels are returned.
+// @Tags notifications
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} utils.Response
+// @Router /api/v1/auth/me/notification-preferences [get]
+func (c *NotificationPreferenceController) GetPreferences() {
+	claims := middleware.CurrentClaims(c.Ctx)
+	if claims == nil {
+		utils.SendUnauthorized(&c.Controller, "Missing or invalid bearer token")
+		return
+	}
+
+	pref, err := models.GetNotificationPreference(claims.Email)
+	if err != nil {
+		utils.SendInternalError(&c.Controller, "Error retrieving notification preferences", err.Error())
+		return
+	}
+	if pref == nil {
+		pref = &models.NotificationPreference{Email: strings.ToLower(claims.Email), ChannelList: notification.DefaultChannels()}
+	}
+	utils.SendSuccess(&c.Controller, "Notification preferences retrieved successfully", pref)
+}
+
+// UpdatePreferences godoc
+// @Summary Set notification preferences
+// @Description Replace the channels (email, sms, whatsapp), phone and language of the caller's booking notifications. They apply to reservations made with the caller's bearer token. Notifications go out over every listed channel that is available; the phone is stored in E.164 form.
+// @Tags notifications
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param body body NotificationPreferenceRequest true "Preferences"
+// @Success 200 {object} utils.Response
+// @Failure 400 {object} utils.Response
+// @Router /api/v1/auth/me/notification-preferences [put]
+func (c *NotificationPreferenceController) UpdatePreferences() {
+	claims := middleware.CurrentClaims(c.Ctx)
+	if claims == nil {
+		utils.SendUnauthorized(&c.Controller, "Missing or invalid bearer token")
+		return
+	}
+
+	var req NotificationPreferenceRequest
+	if err := json.Unmarshal(c.Ctx.Input.RequestBody, &req); err != nil {
+		utils.SendBadRequest(&c.Controller, "Invalid request body", err.Error())
+		return
+	}
+
+	pref := &models.NotificationPreference{Email: claims.Email}
+	seen := map[string]bool{}
+	for _, channel := range req.Channels {
+		channel = strings.ToLower(strings.TrimSpace(channel))
+		if !models.IsValidChannel(channel) {
+			utils.SendBadRequest(&c.Controller, "channels must be email, sms or whatsapp", channel)
+			return
+		}
+		if !seen[channel] {
+			seen[channel] = true
+			pref.ChannelList = append(pref.ChannelList, channel)
+		}
+	}
+	if len(pref.ChannelList) == 0 {
+		utils.SendBadRequest(&c.Controller, "At least one channel is required", nil)
+		return
+	}
+	if strings.TrimSpace(req.Phone) != "" {
+		phone, err := utils.NormalizePhone(req.Phone, utils.PhoneCallingCode())
+		if err != nil {
+			utils.SendBadRequest(&c.Controller, "Invalid phone format", nil)
+			return
+		}
+		pref.Phone = phone
+	}
+	if req.Language != "" {
+		pref.Language = strings.ToLower(strings.TrimSpace(req.Language))
+		if notification.NormalizeLanguage(pref.Language) != pref.Language {
+			utils.SendBadRequest(&c.Controller, "language must be id or en", nil)
+			return
+		}
+	}
+
+	if err := models.SaveNotificationPreference(pref); err != nil {
+		utils.SendInternalError(&c.Controller, "Error saving notification preferences", err.Error())
+		return
+	}
+	utils.SendSuccess(&c.Controller, "Notification preferences updated successfully", pref)
+}
@@ -5,6 +5,7 @@ import (
 	"badminton-reservation-api/services/payment"
 	"badminton-reservation-api/utils"
 	"encoding/json"
+	"errors"
 	"net/url"
 	"time"
 
@@ -19,6 +20,9 @@ type PaymentController struct {
 
 type ProcessPaymentRequest struct {
 	ReservationId string `json:"reservation_id"`
+	SeriesId      string `json:"series_id"`
+	// Gateway picks the payment provider; defaults to PAYMENT_GATEWAY
+	Gateway string `json:"gateway"`
 }
 
 type ProcessPaymentResponse struct {
@@ -27,13 +31,30 @@ type ProcessPaymentResponse struct {
 	RedirectUrl string `json:"redirect_url"`
 }
 
+// resolveGateway returns the named gateway (or the deployment default) or writes a 400
+func (c *PaymentController) resolveGateway(name string) payment.PaymentGateway {
+	var gateway payment.PaymentGateway
+	var err error
+	if name == "" {
+		gateway, err = payment.Default()
+	} else {
+		gateway, err = payment.Get(name)
+	}
+	if err != nil {
+		utils.SendBadRequest(&c.Controller, err.Error(), map[string]interface{}{"allowed": payment.Names()})
+		return nil
+	}
+	return gateway
+}
+
 // ProcessPayment godoc
 // @Summary Process payment for a reservation
-// @Description Create payment transaction with Midtrans
+// @Description Create a payment transaction with the deployment's gateway (PAYMENT_GATEWAY) or the one named in `gateway` (midtrans, xendit). Send series_id instead of reservation_id to pay all pending occurrences of a prepaid series at once.
 // @Tags payments
 // @Accept json
 // @Produce json
 // @Param payment body ProcessPaymentRequest true "Payment details"
+// @Param Idempotency-Key header string false "Retries with the same key return the original response"
 // @Success 200 {object} utils.Response
 // @Router /api/v1/payments/process [post]
 func (c *PaymentController) ProcessPayment() {
@@ -45,6 +66,16 @@ func (c *PaymentController) ProcessPayment() {
 		return
 	}
 
+	gateway := c.resolveGateway(req.Gateway)
+	if gateway == nil {
+		return
+	}
+
+	if req.SeriesId != "" {
+		c.processSeriesPayment(req.SeriesId, gateway)
+		return
+	}
+
 	// Validate reservation_id
 	if req.ReservationId == "" {
 		utils.SendBadRequest(&c.Controller, "Reservation ID is required", nil)
@@ -58,8 +89,16 @@ func (c *PaymentController) ProcessPayment() {
 		return
 	}
 
+	// Occurrences of a prepaid series are paid together through the series
+	if reservation.SeriesId != "" {
+		if series, err := models.GetReservationSeriesById(reservation.SeriesId); err == nil && series.PaymentMode == models.SeriesPaymentPrepaid {
+			utils.SendBadRequest(&c.Controller, "Reservation belongs to a prepaid series; pay with series_id instead", map[string]string{"series_id": series.Id})
+			return
+		}
+	}
+
 	// Check if reservation is still pending
-	if reservation.Status != "pending" {
+	if reservation.Status != models.ReservationPending {
 		utils.SendBadRequest(&c.Controller, "Reservation is not in pending status", nil)
 		return
 	}
@@ -67,7 +106,7 @@ func (c *PaymentController) ProcessPayment() {
 	// Check if reservation has expired
 	if time.Now().After(reservation.ExpiredAt) {
 		// Update status to expired
-		_ = models.UpdateReservationStatus(reservation.Id, "expired")
+		_ = models.UpdateReservationStatus(reservation.Id, models.ReservationExpired)
 		utils.SendBadRequest(&c.Controller, "Reservation has expired", nil)
 		return
 	}
@@ -90,21 +129,20 @@ func (c *PaymentController) ProcessPayment() {
 		Id:             uuid.New().String(),
 		ReservationId:  reservation.Id,
 		Amount:         reservation.TotalPrice,
-		PaymentGateway: "midtrans",
-		Status:         "pending",
+		PaymentGateway: gateway.Name(),
+		Status:         models.PaymentPending,
 		ExpiredAt:      reservation.ExpiredAt,
 	}
 
-	// Create Midtrans transaction
-	midtransService := payment.NewMidtransService()
-	_, err = midtransService.CreateTransaction(reservation, paymentRecord)
+	// Create the checkout at the gateway
+	_, err = gateway.CreateTransaction(reservation, paymentRecord)
 	if err != nil {
 		utils.SendInternalError(&c.Controller, "Error creating payment transaction", err.Error())
 		return
 	}
 
 	// Save payment record
-	// Ensure paymentRecord has OrderId and PaymentUrl (set by midtrans service)
+	// Ensure paymentRecord has OrderId and PaymentUrl (set by the gateway)
 	err = models.CreatePayment(paymentRecord)
 	if err != nil {
 		utils.SendInternalError(&c.Controller, "Error saving payment record", err.Error())
@@ -112,7 +150,7 @@ func (c *PaymentController) ProcessPayment() {
 	}
 
 	// Update reservation status to waiting_payment
-	err = models.UpdateReservationStatus(reservation.Id, "waiting_payment")
+	err = models.UpdateReservationStatus(reservation.Id, models.ReservationWaitingPayment)
 	if err != nil {
 		logs.Error("Error updating reservation status:", err)
 	}
@@ -121,15 +159,118 @@ func (c *PaymentController) ProcessPayment() {
 	utils.SendSuccess(&c.Controller, "Payment transaction created successfully", paymentRecord)
 }
 
+// processSeriesPayment creates one payment covering every pending occurrence of a prepaid series
+func (c *PaymentController) processSeriesPayment(seriesId string, gateway payment.PaymentGateway) {
+	series, err := models.GetReservationSeriesById(seriesId)
+	if err != nil {
+		utils.SendNotFound(&c.Controller, "Reservation series not found")
+		return
+	}
+	if series.PaymentMode != models.SeriesPaymentPrepaid {
+		utils.SendBadRequest(&c.Controller, "Series is paid per occurrence; pay each reservation_id instead", nil)
+		return
+	}
+	if series.Status != models.SeriesActive {
+		utils.SendBadRequest(&c.Controller, "Reservation series is not active", nil)
+		return
+	}
+
+	existingPayment, err := models.GetPaymentBySeriesId(series.Id)
+	if err == nil && existingPayment != nil {
+		utils.SendSuccess(&c.Controller, "Payment already exists", ProcessPaymentResponse{
+			PaymentId:   existingPayment.Id,
+			RedirectUrl: existingPayment.PaymentUrl,
+		})
+		return
+	}
+
+	// Bill every occurrence still awaiting payment as a single transaction
+	var pending []*models.Reservation
+	for _, r := range series.Reservations {
+		if r.Status == models.ReservationPending {
+			pending = append(pending, r)
+		}
+	}
+	if len(pending) == 0 {
+		utils.SendBadRequest(&c.Controller, "Series has no pending reservations to pay", nil)
+		return
+	}
+
+	billed := &models.Reservation{
+		Id:            series.Id,
+		CourtId:       series.CourtId,
+		TimeslotId:    series.TimeslotId,
+		BookingDate:   pending[0].BookingDate,
+		CustomerName:  series.CustomerName,
+		CustomerEmail: series.CustomerEmail,
+		CustomerPhone: series.CustomerPhone,
+		ExpiredAt:     pending[0].ExpiredAt,
+	}
+	for _, r := range pending {
+		if time.Now().After(r.ExpiredAt) {
+			utils.SendBadRequest(&c.Controller, "Reservation series has expired", nil)
+			return
+		}
+		if r.ExpiredAt.Before(billed.ExpiredAt) {
+			billed.ExpiredAt = r.ExpiredAt
+		}
+		total, err := billed.TotalPrice.Add(r.TotalPrice)
+		if err != nil {
+			utils.SendInternalError(&c.Controller, "Error totalling reservation series", err.Error())
+			return
+		}
+		billed.TotalPrice = total
+		billed.Items = append(billed.Items, r.Items...)
+	}
+
+	paymentRecord := &models.Payment{
+		Id:             uuid.New().String(),
+		ReservationId:  pending[0].Id,
+		SeriesId:       series.Id,
+		Amount:         billed.TotalPrice,
+		PaymentGateway: gateway.Name(),
+		Status:         models.PaymentPending,
+		ExpiredAt:      billed.ExpiredAt,
+	}
+
+	if _, err := gateway.CreateTransaction(billed, paymentRecord); err != nil {
+		utils.SendInternalError(&c.Controller, "Error creating payment transaction", err.Error())
+		return
+	}
+	if err := models.CreatePayment(paymentRecord); err != nil {
+		utils.SendInternalError(&c.Controller, "Error saving payment record", err.Error())
+		return
+	}
+
+	for _, r := range pending {
+		if err := models.UpdateReservationStatus(r.Id, models.ReservationWaitingPayment); err != nil {
+			logs.Error("Error updating reservation status:", err)
+		}
+	}
+
+	utils.SendSuccess(&c.Controller, "Payment transaction created successfully", paymentRecord)
+}
+
 // PaymentCallback godoc
-// @Summary Handle payment callback from Midtrans
-// @Description Webhook endpoint for Midtrans payment notification
+// @Summary Handle payment callback from a gateway
+// @Description Webhook endpoint for gateway payment notifications. /payments/callback is the Midtrans endpoint; other gateways post to /payments/callback/{gateway}. Notifications whose amount, currency or order do not match the stored payment are rejected with 400 and flagged for admin review.
 // @Tags payments
 // @Accept json
 // @Produce json
+// @Param gateway path string false "Gateway name (midtrans, xendit)"
 // @Success 200 {object} utils.Response
-// @Router /api/v1/payments/callback [post]
+// @Router /api/v1/payments/callback/{gateway} [post]
 func (c *PaymentController) PaymentCallback() {
+	gatewayName := c.Ctx.Input.Param(":gateway")
+	if gatewayName == "" {
+		gatewayName = "midtrans"
+	}
+	gateway, err := payment.Get(gatewayName)
+	if err != nil {
+		utils.SendNotFound(&c.Controller, err.Error())
+		return
+	}
+
 	var notification map[string]interface{}
 
 	// Log raw body for debugging
@@ -175,62 +316,47 @@ func (c *PaymentController) PaymentCallback() {
 		}
 	}
 
-	// Initialize Midtrans service
-	midtransService := payment.NewMidtransService()
-
 	// Parse and verify notification
-	statusResp, err := midtransService.ParseNotification(notification)
+	update, err := gateway.VerifyNotification(c.Ctx.Request.Header, notification)
 	if err != nil {
-		logs.Error("Error parsing notification:", err)
+		logs.Error("Error verifying notification:", err)
 		utils.SendBadRequest(&c.Controller, "Invalid notification signature", err.Error())
 		return
 	}
 
-	// Get payment by order_id
-	paymentRecord, err := models.GetPaymentByOrderId(statusResp.OrderID)
-	if err != nil {
-		logs.Error("Payment not found for order:", statusResp.OrderID)
+	// Log the notification and apply it; gateways retry deliveries, so repeats of a finished event
+	// are acknowledged while repeats of one that failed are applied again
+	result, err := payment.ProcessEvent(gateway.Name(), update, models.EventSourceWebhook)
+	if errors.Is(err, payment.ErrPaymentNotFound) {
+		logs.Error("Payment not found for order:", update.OrderId)
 		utils.SendNotFound(&c.Controller, "Payment not found")
 		return
 	}
-
-	// Determine payment status
-	paymentStatus := payment.GetPaymentStatus(statusResp.TransactionStatus, statusResp.FraudStatus)
-
-	// Convert notification to JSON string
-	notificationJSON, _ := json.Marshal(notification)
-
-	// Update payment status
-	err = models.UpdatePaymentStatus(
-		paymentRecord.Id,
-		paymentStatus,
-		statusResp.TransactionID,
-		string(notificationJSON),
-	)
+	// A signed notification that disagrees with the payment is logged and flagged, never applied
+	if errors.Is(err, payment.ErrPaymentMismatch) || (err == nil && result.Event.Outcome == models.EventSuspicious) {
+		logs.Error("Rejected payment notification for order", update.OrderId, ":", result.Event.Error)
+		utils.SendBadRequest(&c.Controller, "Notification does not match the payment", map[string]interface{}{
+			"event_id": result.Event.Id,
+			"error":    result.Event.Error,
+		})
+		return
+	}
 	if err != nil {
 		logs.Error("Error updating payment status:", err)
 		utils.SendInternalError(&c.Controller, "Error updating payment status", err.Error())
 		return
 	}
-
-	// Update reservation status based on payment status
-	var reservationStatus string
-	switch paymentStatus {
-	case "success":
-		reservationStatus = "paid"
-	case "failed":
-		reservationStatus = "cancelled"
-	default:
-		reservationStatus = "waiting_payment"
-	}
-
-	err = models.UpdateReservationStatus(paymentRecord.ReservationId, reservationStatus)
-	if err != nil {
-		logs.Error("Error updating reservation status:", err)
+	if result.Duplicate {
+		utils.SendSuccess(&c.Controller, "Payment notification already processed", map[string]string{
+			"status":  update.Status,
+			"outcome": result.Event.Outcome,
+		})
+		return
 	}
 
 	utils.SendSuccess(&c.Controller, "Payment notification processed successfully", map[string]string{
-		"status": paymentStatus,
+		"status":  update.Status,
+		"outcome": result.Event.Outcome,
 	})
 }
 
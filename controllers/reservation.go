@@ -1,13 +1,23 @@
 package controllers
 
 import (
+	"badminton-reservation-api/middleware"
 	"badminton-reservation-api/models"
+	"badminton-reservation-api/services/auth"
+	"badminton-reservation-api/services/cancellation"
+	"badminton-reservation-api/services/payment"
+	"badminton-reservation-api/services/pricing"
 	"badminton-reservation-api/utils"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"os"
+	"sort"
 	"strconv"
 	"time"
 
+	"github.com/beego/beego/v2/client/orm"
+	"github.com/beego/beego/v2/core/logs"
 	"github.com/beego/beego/v2/server/web"
 	"github.com/google/uuid"
 )
@@ -16,6 +26,12 @@ type ReservationController struct {
 	web.Controller
 }
 
+// ReservationSlotRequest is one court/timeslot pair of a reservation
+type ReservationSlotRequest struct {
+	CourtId    int `json:"court_id"`
+	TimeslotId int `json:"timeslot_id"`
+}
+
 type CreateReservationRequest struct {
 	CourtId       int    `json:"court_id"`
 	TimeslotId    int    `json:"timeslot_id"`
@@ -24,19 +40,140 @@ type CreateReservationRequest struct {
 	CustomerEmail string `json:"customer_email"`
 	CustomerPhone string `json:"customer_phone"`
 	Notes         string `json:"notes"`
+	// Slots books several consecutive timeslots and/or courts at once; when empty the
+	// single court_id/timeslot_id pair is booked.
+	Slots []ReservationSlotRequest `json:"slots"`
+	// PromoCode applies a discount voucher; an invalid code fails the request with 400
+	PromoCode string `json:"promo_code"`
 }
 
 type UpdateStatusRequest struct {
 	Status string `json:"status"`
 }
 
+type CancelReservationRequest struct {
+	Reason string `json:"reason"`
+}
+
+// reservationTimeout is how long a pending reservation waits for payment (RESERVATION_TIMEOUT_MINUTES, default 30)
+func reservationTimeout() time.Duration {
+	timeoutMinutes := 30
+	if timeoutStr := os.Getenv("RESERVATION_TIMEOUT_MINUTES"); timeoutStr != "" {
+		if minutes, err := strconv.Atoi(timeoutStr); err == nil {
+			timeoutMinutes = minutes
+		}
+	}
+	return time.Duration(timeoutMinutes) * time.Minute
+}
+
+// maxReservationSlots caps the number of line items in one reservation
+const maxReservationSlots = 12
+
+// resolveReservationSlots validates the requested slots for bookingDate and turns them into
+// line items priced by the pricing rules, ordered by court and start time. Every court must be active, every
+// timeslot active, and the timeslots booked on each court must be consecutive. On failure
+// the error response is written and ok is false.
+func resolveReservationSlots(c *web.Controller, slots []ReservationSlotRequest, bookingDate string) (items []*models.ReservationItem, total models.Money, ok bool) {
+	if len(slots) > maxReservationSlots {
+		utils.SendBadRequest(c, fmt.Sprintf("A reservation can cover at most %d slots", maxReservationSlots), nil)
+		return nil, models.Money{}, false
+	}
+
+	courts := map[int]*models.Court{}
+	timeslots := map[int]*models.Timeslot{}
+	seen := map[ReservationSlotRequest]bool{}
+	for _, slot := range slots {
+		if seen[slot] {
+			utils.SendBadRequest(c, "Duplicate slot in request", slot)
+			return nil, models.Money{}, false
+		}
+		seen[slot] = true
+
+		// Verify court exists and is active
+		if _, loaded := courts[slot.CourtId]; !loaded {
+			court, err := models.GetCourtById(slot.CourtId)
+			if err != nil {
+				utils.SendNotFound(c, "Court not found")
+				return nil, models.Money{}, false
+			}
+			if court.Status != models.CourtActive {
+				utils.SendBadRequest(c, "Court is not available", nil)
+				return nil, models.Money{}, false
+			}
+			courts[slot.CourtId] = court
+		}
+
+		// Verify timeslot exists and is active
+		if _, loaded := timeslots[slot.TimeslotId]; !loaded {
+			timeslot, err := models.GetTimeslotById(slot.TimeslotId)
+			if err != nil {
+				utils.SendNotFound(c, "Timeslot not found")
+				return nil, models.Money{}, false
+			}
+			if !timeslot.IsActive {
+				utils.SendBadRequest(c, "Timeslot is not available", nil)
+				return nil, models.Money{}, false
+			}
+			timeslots[slot.TimeslotId] = timeslot
+		}
+	}
+
+	rules, err := models.GetActivePricingRules()
+	if err != nil {
+		utils.SendInternalError(c, "Error loading pricing rules", err.Error())
+		return nil, models.Money{}, false
+	}
+
+	sorted := append([]ReservationSlotRequest(nil), slots...)
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].CourtId != sorted[j].CourtId {
+			return sorted[i].CourtId < sorted[j].CourtId
+		}
+		return timeslots[sorted[i].TimeslotId].StartTime < timeslots[sorted[j].TimeslotId].StartTime
+	})
+
+	for i, slot := range sorted {
+		// Slots on the same court must follow each other without gaps
+		if i > 0 && sorted[i-1].CourtId == slot.CourtId {
+			prev := timeslots[sorted[i-1].TimeslotId]
+			if prev.EndTime != timeslots[slot.TimeslotId].StartTime {
+				utils.SendBadRequest(c, "Timeslots booked on the same court must be consecutive", nil)
+				return nil, models.Money{}, false
+			}
+		}
+
+		quote, err := pricing.Calculate(pricing.Slot{
+			CourtId:     slot.CourtId,
+			BasePrice:   courts[slot.CourtId].PricePerHour,
+			BookingDate: bookingDate,
+			StartTime:   timeslots[slot.TimeslotId].StartTime,
+		}, rules)
+		if err == nil {
+			total, err = total.Add(quote.Price)
+		}
+		if err != nil {
+			utils.SendInternalError(c, "Error calculating price", err.Error())
+			return nil, models.Money{}, false
+		}
+		items = append(items, &models.ReservationItem{
+			CourtId:     slot.CourtId,
+			TimeslotId:  slot.TimeslotId,
+			BookingDate: bookingDate,
+			Price:       quote.Price,
+		})
+	}
+	return items, total, true
+}
+
 // CreateReservation godoc
 // @Summary Create a new reservation
-// @Description Create a new court reservation
+// @Description Create a new court reservation. Use `slots` to book several consecutive timeslots and/or courts as one reservation with a single payment; all slots are reserved or none are. An optional `promo_code` is taken off `total_price` (shown as `discount_amount`). With a bearer token the reservation belongs to that account, which can cancel it with its token; the returned manage_token works either way.
 // @Tags reservations
 // @Accept json
 // @Produce json
+// @Security BearerAuth
 // @Param reservation body CreateReservationRequest true "Reservation details"
+// @Param Idempotency-Key header string false "Retries with the same key return the original response"
 // @Success 201 {object} utils.Response
 // @Router /api/v1/reservations [post]
 func (c *ReservationController) CreateReservation() {
@@ -67,8 +204,9 @@ func (c *ReservationController) CreateReservation() {
 		return
 	}
 
-	// Validate phone format
-	if !utils.ValidatePhone(req.CustomerPhone) {
+	// Validate phone format and store it in E.164 form
+	phone, err := utils.NormalizePhone(req.CustomerPhone, utils.PhoneCallingCode())
+	if err != nil {
 		utils.SendBadRequest(&c.Controller, "Invalid phone format", nil)
 		return
 	}
@@ -86,64 +224,69 @@ func (c *ReservationController) CreateReservation() {
 		return
 	}
 
-	// Verify court exists and is active
-	court, err := models.GetCourtById(req.CourtId)
-	if err != nil {
-		utils.SendNotFound(&c.Controller, "Court not found")
-		return
-	}
-	if court.Status != "active" {
-		utils.SendBadRequest(&c.Controller, "Court is not available", nil)
-		return
+	slots := req.Slots
+	if len(slots) == 0 {
+		slots = []ReservationSlotRequest{{CourtId: req.CourtId, TimeslotId: req.TimeslotId}}
 	}
 
-	// Verify timeslot exists and is active
-	timeslot, err := models.GetTimeslotById(req.TimeslotId)
-	if err != nil {
-		utils.SendNotFound(&c.Controller, "Timeslot not found")
-		return
-	}
-	if !timeslot.IsActive {
-		utils.SendBadRequest(&c.Controller, "Timeslot is not available", nil)
+	// Verify courts/timeslots and price every slot
+	items, totalPrice, ok := resolveReservationSlots(&c.Controller, slots, req.BookingDate)
+	if !ok {
 		return
 	}
 
-	// Check availability
-	isAvailable, err := models.CheckAvailability(req.CourtId, req.TimeslotId, req.BookingDate)
-	if err != nil {
-		utils.SendInternalError(&c.Controller, "Error checking availability", err.Error())
-		return
+	// Check availability of every slot up front so the caller gets a full conflict list
+	var unavailable []ReservationSlotRequest
+	for _, item := range items {
+		isAvailable, err := models.CheckAvailability(item.CourtId, item.TimeslotId, item.BookingDate)
+		if err != nil {
+			utils.SendInternalError(&c.Controller, "Error checking availability", err.Error())
+			return
+		}
+		if !isAvailable {
+			unavailable = append(unavailable, ReservationSlotRequest{CourtId: item.CourtId, TimeslotId: item.TimeslotId})
+		}
 	}
-	if !isAvailable {
-		utils.SendConflict(&c.Controller, "This court is already booked for the selected date and timeslot", nil)
+	if len(unavailable) > 0 {
+		utils.SendConflict(&c.Controller, "This court is already booked for the selected date and timeslot", unavailable)
 		return
 	}
 
 	// Calculate expiration time (default 30 minutes)
-	timeoutMinutes := 30
-	if timeoutStr := os.Getenv("RESERVATION_TIMEOUT_MINUTES"); timeoutStr != "" {
-		if minutes, err := strconv.Atoi(timeoutStr); err == nil {
-			timeoutMinutes = minutes
-		}
-	}
-	expiredAt := time.Now().Add(time.Duration(timeoutMinutes) * time.Minute)
+	expiredAt := time.Now().Add(reservationTimeout())
 
 	// Create reservation
 	reservation := &models.Reservation{
 		Id:            uuid.New().String(),
-		CourtId:       req.CourtId,
-		TimeslotId:    req.TimeslotId,
+		CourtId:       items[0].CourtId,
+		TimeslotId:    items[0].TimeslotId,
 		BookingDate:   req.BookingDate,
 		CustomerName:  req.CustomerName,
 		CustomerEmail: req.CustomerEmail,
-		CustomerPhone: req.CustomerPhone,
-		TotalPrice:    court.PricePerHour,
-		Status:        "pending",
+		CustomerPhone: phone,
+		TotalPrice:    totalPrice,
+		Status:        models.ReservationPending,
 		Notes:         req.Notes,
 		ExpiredAt:     expiredAt,
+		Items:         items,
+		PromoCode:     models.NormalizePromoCode(req.PromoCode),
+	}
+	// Signed-in customers can later cancel with their token; others use the manage link
+	if claims := middleware.CurrentClaims(c.Ctx); claims != nil {
+		reservation.UserId = claims.UserId()
 	}
 
 	err = models.CreateReservation(reservation)
+	if errors.Is(err, models.ErrSlotAlreadyBooked) {
+		// Lost the race against a concurrent booking for the same slot
+		utils.SendConflict(&c.Controller, "This court is already booked for the selected date and timeslot", nil)
+		return
+	}
+	var promoErr *models.PromoError
+	if errors.As(err, &promoErr) {
+		utils.SendBadRequest(&c.Controller, "Promo code cannot be applied", promoErr.Error())
+		return
+	}
 	if err != nil {
 		utils.SendInternalError(&c.Controller, "Error creating reservation", err.Error())
 		return
@@ -151,6 +294,14 @@ func (c *ReservationController) CreateReservation() {
 
 	// Get full reservation with relations
 	fullReservation, _ := models.GetReservationById(reservation.Id)
+	if fullReservation != nil {
+		// Lets the customer cancel without an account via the manage link
+		if token, err := auth.ManageToken(fullReservation.Id); err == nil {
+			fullReservation.ManageToken = token
+		} else {
+			logs.Warn("Could not sign manage token:", err)
+		}
+	}
 
 	c.Ctx.Output.SetStatus(201)
 	utils.SendSuccess(&c.Controller, "Reservation created successfully. Please complete payment within 30 minutes.", fullReservation)
@@ -208,12 +359,13 @@ func (c *ReservationController) GetReservationsByEmail() {
 	utils.SendSuccess(&c.Controller, "Reservations retrieved successfully", reservations)
 }
 
-// UpdateStatus updates reservation status (admin/test endpoint)
+// UpdateStatus updates reservation status (staff or admin)
 // @Summary Update reservation status
-// @Description Update reservation status (e.g., expired, cancelled, paid)
+// @Description Update reservation status (e.g., expired, cancelled, paid). Requires a staff or admin token.
 // @Tags reservations
 // @Accept json
 // @Produce json
+// @Security BearerAuth
 // @Param id path string true "Reservation ID"
 // @Param body body UpdateStatusRequest true "Status payload"
 // @Success 200 {object} utils.Response
@@ -231,10 +383,162 @@ func (c *ReservationController) UpdateStatus() {
 		return
 	}
 
-	if err := models.UpdateReservationStatus(id, req.Status); err != nil {
-		utils.SendInternalError(&c.Controller, "Error updating reservation status", err.Error())
+	err := models.UpdateReservationStatus(id, req.Status)
+	if err != nil {
+		var transitionErr *models.InvalidTransitionError
+		switch {
+		case errors.Is(err, orm.ErrNoRows):
+			utils.SendNotFound(&c.Controller, "Reservation not found")
+		case errors.Is(err, models.ErrUnknownStatus):
+			utils.SendBadRequest(&c.Controller, "Unknown reservation status", map[string]interface{}{"allowed": models.ReservationStatuses()})
+		case errors.As(err, &transitionErr):
+			utils.SendConflict(&c.Controller, transitionErr.Error(), map[string]interface{}{
+				"from":    transitionErr.From,
+				"to":      transitionErr.To,
+				"allowed": transitionErr.Allowed(),
+			})
+		default:
+			utils.SendInternalError(&c.Controller, "Error updating reservation status", err.Error())
+		}
 		return
 	}
 
 	utils.SendSuccess(&c.Controller, "Reservation status updated", map[string]string{"id": id, "status": req.Status})
 }
+
+// reservationStart returns when the earliest slot of the reservation begins
+func reservationStart(r *models.Reservation) (time.Time, error) {
+	items := r.Items
+	if len(items) == 0 {
+		items = []*models.ReservationItem{{CourtId: r.CourtId, TimeslotId: r.TimeslotId, BookingDate: r.BookingDate}}
+	}
+	var start time.Time
+	for _, item := range items {
+		slot, err := models.GetTimeslotById(item.TimeslotId)
+		if err != nil {
+			return time.Time{}, err
+		}
+		t, err := time.ParseInLocation("2006-01-02 15:04:05", item.BookingDate+" "+slot.StartTime, time.Local)
+		if err != nil {
+			return time.Time{}, err
+		}
+		if start.IsZero() || t.Before(start) {
+			start = t
+		}
+	}
+	return start, nil
+}
+
+// CancelReservation godoc
+// @Summary Cancel a reservation
+// @Description Cancels the reservation and releases its slots. Authorized by the bearer token of the account that made the booking, a staff/admin token, or the manage-link token returned on creation (X-Manage-Token header or token query). Paid reservations are refunded according to the cancellation policy (CANCELLATION_POLICY, default full refund 24h+ before the game, 50% until it starts, none after); the amount owed is recorded as refund_amount and, unless AUTO_REFUND_ON_CANCEL=false, refunded through the payment gateway.
+// @Tags reservations
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Reservation ID"
+// @Param token query string false "Manage-link token"
+// @Param body body CancelReservationRequest false "Cancellation reason"
+// @Success 200 {object} utils.Response
+// @Router /api/v1/reservations/{id}/cancel [post]
+func (c *ReservationController) CancelReservation() {
+	id := c.Ctx.Input.Param(":id")
+
+	reservation, err := models.GetReservationById(id)
+	if err != nil {
+		utils.SendNotFound(&c.Controller, "Reservation not found")
+		return
+	}
+
+	claims := middleware.CurrentClaims(c.Ctx)
+	manageToken := c.Ctx.Input.Header("X-Manage-Token")
+	if manageToken == "" {
+		manageToken = c.GetString("token")
+	}
+	if claims == nil && manageToken == "" {
+		utils.SendUnauthorized(&c.Controller, "A bearer token or manage-link token is required")
+		return
+	}
+	if !canManageBooking(claims, reservation.UserId) && !auth.VerifyManageToken(reservation.Id, manageToken) {
+		utils.SendError(&c.Controller, 403, "You do not have permission to cancel this reservation", nil)
+		return
+	}
+
+	var req CancelReservationRequest
+	if len(c.Ctx.Input.RequestBody) > 0 {
+		if err := json.Unmarshal(c.Ctx.Input.RequestBody, &req); err != nil {
+			utils.SendBadRequest(&c.Controller, "Invalid request body", err.Error())
+			return
+		}
+	}
+
+	start, err := reservationStart(reservation)
+	if err != nil {
+		utils.SendInternalError(&c.Controller, "Error resolving reservation time", err.Error())
+		return
+	}
+	policy := cancellation.PolicyFromEnv()
+	percent := policy.RefundPercent(start, time.Now())
+
+	cancelled, err := models.CancelReservation(reservation.Id, percent, req.Reason)
+	if err != nil {
+		var transitionErr *models.InvalidTransitionError
+		switch {
+		case errors.Is(err, orm.ErrNoRows):
+			utils.SendNotFound(&c.Controller, "Reservation not found")
+		case errors.As(err, &transitionErr):
+			utils.SendConflict(&c.Controller, fmt.Sprintf("Reservation cannot be cancelled while %s", transitionErr.From), nil)
+		default:
+			utils.SendInternalError(&c.Controller, "Error cancelling reservation", err.Error())
+		}
+		return
+	}
+
+	if cancelled.RefundAmount.IsZero() {
+		percent = 0
+	}
+	data := map[string]interface{}{
+		"reservation":    cancelled,
+		"refund_amount":  cancelled.RefundAmount,
+		"refund_percent": percent,
+		"policy":         policy,
+	}
+
+	// Pay the refund owed back through the gateway; on failure staff can retry via the admin refund endpoint
+	if cancelled.RefundAmount.Amount > 0 && os.Getenv("AUTO_REFUND_ON_CANCEL") != "false" {
+		if refund := refundCancelledReservation(cancelled); refund != nil {
+			data["refund"] = refund
+			if updated, err := models.GetReservationById(cancelled.Id); err == nil {
+				data["reservation"] = updated
+			}
+		}
+	}
+
+	utils.SendSuccess(&c.Controller, "Reservation cancelled", data)
+}
+
+// paymentForReservation returns the settled payment covering a reservation, which for an
+// occurrence of a prepaid series is the series payment
+func paymentForReservation(r *models.Reservation) (*models.Payment, error) {
+	if r.SeriesId != "" {
+		if p, err := models.GetPaymentBySeriesId(r.SeriesId); err == nil {
+			return p, nil
+		}
+	}
+	return models.GetPaymentByReservationId(r.Id)
+}
+
+// refundCancelledReservation refunds the amount owed for a cancelled reservation. Errors are
+// logged rather than returned: the cancellation itself has already succeeded.
+func refundCancelledReservation(r *models.Reservation) *models.Refund {
+	p, err := paymentForReservation(r)
+	if err != nil {
+		logs.Error("No payment found to refund reservation", r.Id, ":", err)
+		return nil
+	}
+	refund, err := payment.IssueRefund(p, r.Id, r.RefundAmount, "Cancelled by customer")
+	if err != nil {
+		logs.Error("Refund for reservation", r.Id, "failed:", err)
+	}
+	return refund
+}
@@ -0,0 +1,347 @@
+package controllers
+
+import (
+	"badminton-reservation-api/middleware"
+	"badminton-reservation-api/models"
+	"badminton-reservation-api/services/auth"
+	"badminton-reservation-api/services/pricing"
+	"badminton-reservation-api/utils"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/beego/beego/v2/client/orm"
+	"github.com/beego/beego/v2/server/web"
+	"github.com/google/uuid"
+)
+
+type SeriesController struct {
+	web.Controller
+}
+
+type CreateSeriesRequest struct {
+	CourtId       int    `json:"court_id"`
+	TimeslotId    int    `json:"timeslot_id"`
+	StartDate     string `json:"start_date"`
+	Weekday       *int   `json:"weekday"`
+	Occurrences   int    `json:"occurrences"`
+	PaymentMode   string `json:"payment_mode"`
+	CustomerName  string `json:"customer_name"`
+	CustomerEmail string `json:"customer_email"`
+	CustomerPhone string `json:"customer_phone"`
+	Notes         string `json:"notes"`
+}
+
+type CancelSeriesRequest struct {
+	ReservationId string `json:"reservation_id"`
+}
+
+// SeriesConflict is an occurrence date that cannot be booked
+type SeriesConflict struct {
+	BookingDate string `json:"booking_date"`
+	CourtId     int    `json:"court_id"`
+	TimeslotId  int    `json:"timeslot_id"`
+}
+
+// canManageBooking reports whether the caller may manage a booking owned by userId: the
+// account that made it, or staff/admin. The booking's customer email is not enough, as
+// anyone can register an account with an email they do not own.
+func canManageBooking(claims *auth.Claims, userId string) bool {
+	if claims == nil {
+		return false
+	}
+	if claims.Role == models.RoleAdmin || claims.Role == models.RoleStaff {
+		return true
+	}
+	return userId != "" && claims.UserId() == userId
+}
+
+// occurrenceExpiry returns when an unpaid per-occurrence reservation expires: payment is due
+// SERIES_PAYMENT_LEAD_HOURS (default 24) before the game, but never sooner than the normal
+// reservation timeout from now.
+func occurrenceExpiry(bookingDate string, startTime string) time.Time {
+	minExpiry := time.Now().Add(reservationTimeout())
+	start, err := time.ParseInLocation("2006-01-02 15:04:05", bookingDate+" "+startTime, time.Local)
+	if err != nil {
+		return minExpiry
+	}
+	due := start.Add(-time.Duration(utils.EnvInt("SERIES_PAYMENT_LEAD_HOURS", 24)) * time.Hour)
+	if due.Before(minExpiry) {
+		return minExpiry
+	}
+	return due
+}
+
+// CreateSeries godoc
+// @Summary Create a recurring weekly reservation
+// @Description Books the same court and timeslot weekly for N occurrences. Every occurrence is checked up front; if any is unavailable nothing is booked and the conflicts are returned with 409. payment_mode is per_occurrence (pay each reservation separately) or prepaid (pay once via /payments/process with series_id). Requires a signed-in account, which owns the series and can cancel it.
+// @Tags series
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param series body CreateSeriesRequest true "Series details"
+// @Success 201 {object} utils.Response
+// @Router /api/v1/series [post]
+func (c *SeriesController) CreateSeries() {
+	claims := middleware.CurrentClaims(c.Ctx)
+	if claims == nil {
+		utils.SendUnauthorized(&c.Controller, "Missing or invalid bearer token")
+		return
+	}
+
+	var req CreateSeriesRequest
+	if err := json.Unmarshal(c.Ctx.Input.RequestBody, &req); err != nil {
+		utils.SendBadRequest(&c.Controller, "Invalid request body", err.Error())
+		return
+	}
+
+	missingFields := utils.ValidateRequired(map[string]string{
+		"customer_name":  req.CustomerName,
+		"customer_email": req.CustomerEmail,
+		"customer_phone": req.CustomerPhone,
+		"start_date":     req.StartDate,
+	})
+	if len(missingFields) > 0 {
+		utils.SendBadRequest(&c.Controller, "Missing required fields", missingFields)
+		return
+	}
+	if !utils.ValidateEmail(req.CustomerEmail) {
+		utils.SendBadRequest(&c.Controller, "Invalid email format", nil)
+		return
+	}
+	phone, err := utils.NormalizePhone(req.CustomerPhone, utils.PhoneCallingCode())
+	if err != nil {
+		utils.SendBadRequest(&c.Controller, "Invalid phone format", nil)
+		return
+	}
+	if !utils.ValidateDate(req.StartDate) {
+		utils.SendBadRequest(&c.Controller, "Invalid date format. Use YYYY-MM-DD", nil)
+		return
+	}
+	if req.StartDate < time.Now().Format("2006-01-02") {
+		utils.SendBadRequest(&c.Controller, "start_date must not be in the past", nil)
+		return
+	}
+
+	maxOccurrences := utils.EnvInt("SERIES_MAX_OCCURRENCES", 26)
+	if req.Occurrences < 1 || req.Occurrences > maxOccurrences {
+		utils.SendBadRequest(&c.Controller, fmt.Sprintf("occurrences must be between 1 and %d", maxOccurrences), nil)
+		return
+	}
+
+	startDate, _ := time.Parse("2006-01-02", req.StartDate)
+	weekday := startDate.Weekday()
+	if req.Weekday != nil {
+		if *req.Weekday < 0 || *req.Weekday > 6 {
+			utils.SendBadRequest(&c.Controller, "weekday must be between 0 (Sunday) and 6 (Saturday)", nil)
+			return
+		}
+		weekday = time.Weekday(*req.Weekday)
+	}
+
+	if req.PaymentMode == "" {
+		req.PaymentMode = models.SeriesPaymentPerOccurrence
+	}
+	if !models.IsValidSeriesPaymentMode(req.PaymentMode) {
+		utils.SendBadRequest(&c.Controller, "payment_mode must be per_occurrence or prepaid", nil)
+		return
+	}
+
+	dates, err := models.SeriesDates(req.StartDate, weekday, req.Occurrences)
+	if err != nil {
+		utils.SendBadRequest(&c.Controller, "Invalid start_date", err.Error())
+		return
+	}
+
+	// Validate court/timeslot once; every occurrence uses the same slot
+	slot := ReservationSlotRequest{CourtId: req.CourtId, TimeslotId: req.TimeslotId}
+	if _, _, ok := resolveReservationSlots(&c.Controller, []ReservationSlotRequest{slot}, dates[0]); !ok {
+		return
+	}
+	court, _ := models.GetCourtById(req.CourtId)
+	timeslot, _ := models.GetTimeslotById(req.TimeslotId)
+
+	// Occurrences can fall on holidays or under rules that start later, so each date is priced separately
+	rules, err := models.GetActivePricingRules()
+	if err != nil {
+		utils.SendInternalError(&c.Controller, "Error loading pricing rules", err.Error())
+		return
+	}
+	prices := make([]models.Money, len(dates))
+	var totalPrice models.Money
+	for i, d := range dates {
+		quote, err := pricing.Calculate(pricing.Slot{
+			CourtId:     req.CourtId,
+			BasePrice:   court.PricePerHour,
+			BookingDate: d,
+			StartTime:   timeslot.StartTime,
+		}, rules)
+		if err == nil {
+			totalPrice, err = totalPrice.Add(quote.Price)
+		}
+		if err != nil {
+			utils.SendInternalError(&c.Controller, "Error calculating price", err.Error())
+			return
+		}
+		prices[i] = quote.Price
+	}
+
+	// Check availability of every occurrence and report all conflicts
+	var conflicts []SeriesConflict
+	for _, d := range dates {
+		isAvailable, err := models.CheckAvailability(req.CourtId, req.TimeslotId, d)
+		if err != nil {
+			utils.SendInternalError(&c.Controller, "Error checking availability", err.Error())
+			return
+		}
+		if !isAvailable {
+			conflicts = append(conflicts, SeriesConflict{BookingDate: d, CourtId: req.CourtId, TimeslotId: req.TimeslotId})
+		}
+	}
+	if len(conflicts) > 0 {
+		utils.SendConflict(&c.Controller, "Some occurrences are already booked", conflicts)
+		return
+	}
+
+	series := &models.ReservationSeries{
+		Id:            uuid.New().String(),
+		CourtId:       req.CourtId,
+		TimeslotId:    req.TimeslotId,
+		Weekday:       int(weekday),
+		StartDate:     dates[0],
+		Occurrences:   req.Occurrences,
+		PaymentMode:   req.PaymentMode,
+		CustomerName:  req.CustomerName,
+		CustomerEmail: req.CustomerEmail,
+		CustomerPhone: phone,
+		TotalPrice:    totalPrice,
+		Status:        models.SeriesActive,
+		Notes:         req.Notes,
+		UserId:        claims.UserId(),
+	}
+
+	occurrences := make([]*models.Reservation, 0, len(dates))
+	prepaidExpiry := time.Now().Add(reservationTimeout())
+	for i, d := range dates {
+		expiredAt := prepaidExpiry
+		if req.PaymentMode == models.SeriesPaymentPerOccurrence {
+			expiredAt = occurrenceExpiry(d, timeslot.StartTime)
+		}
+		occurrences = append(occurrences, &models.Reservation{
+			Id:            uuid.New().String(),
+			CourtId:       req.CourtId,
+			TimeslotId:    req.TimeslotId,
+			BookingDate:   d,
+			CustomerName:  req.CustomerName,
+			CustomerEmail: req.CustomerEmail,
+			CustomerPhone: phone,
+			TotalPrice:    prices[i],
+			Status:        models.ReservationPending,
+			Notes:         req.Notes,
+			ExpiredAt:     expiredAt,
+			Items: []*models.ReservationItem{{
+				CourtId:     req.CourtId,
+				TimeslotId:  req.TimeslotId,
+				BookingDate: d,
+				Price:       prices[i],
+			}},
+		})
+	}
+
+	err = models.CreateReservationSeries(series, occurrences)
+	if errors.Is(err, models.ErrSlotAlreadyBooked) {
+		// Lost the race against a concurrent booking for one of the dates
+		utils.SendConflict(&c.Controller, "Some occurrences are already booked", nil)
+		return
+	}
+	if err != nil {
+		utils.SendInternalError(&c.Controller, "Error creating reservation series", err.Error())
+		return
+	}
+
+	fullSeries, _ := models.GetReservationSeriesById(series.Id)
+
+	c.Ctx.Output.SetStatus(201)
+	utils.SendSuccess(&c.Controller, "Reservation series created successfully", fullSeries)
+}
+
+// GetSeries godoc
+// @Summary Get a reservation series
+// @Description Returns the series rule and all its occurrences. Requires the token of the account that booked the series (or staff/admin).
+// @Tags series
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Series ID"
+// @Success 200 {object} utils.Response
+// @Router /api/v1/series/{id} [get]
+func (c *SeriesController) GetSeries() {
+	series, err := models.GetReservationSeriesById(c.Ctx.Input.Param(":id"))
+	if err != nil {
+		utils.SendNotFound(&c.Controller, "Reservation series not found")
+		return
+	}
+	if !canManageBooking(middleware.CurrentClaims(c.Ctx), series.UserId) {
+		utils.SendError(&c.Controller, 403, "You do not have permission to view this series", nil)
+		return
+	}
+	utils.SendSuccess(&c.Controller, "Reservation series retrieved successfully", series)
+}
+
+// CancelSeries godoc
+// @Summary Cancel a series or one occurrence
+// @Description With reservation_id only that occurrence is cancelled; without it the series and all remaining occurrences are cancelled. Requires the token of the account that booked the series (or staff/admin).
+// @Tags series
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Series ID"
+// @Param body body CancelSeriesRequest false "Occurrence to cancel"
+// @Success 200 {object} utils.Response
+// @Router /api/v1/series/{id}/cancel [post]
+func (c *SeriesController) CancelSeries() {
+	id := c.Ctx.Input.Param(":id")
+	series, err := models.GetReservationSeriesById(id)
+	if err != nil {
+		utils.SendNotFound(&c.Controller, "Reservation series not found")
+		return
+	}
+	if !canManageBooking(middleware.CurrentClaims(c.Ctx), series.UserId) {
+		utils.SendError(&c.Controller, 403, "You do not have permission to cancel this series", nil)
+		return
+	}
+
+	var req CancelSeriesRequest
+	if len(c.Ctx.Input.RequestBody) > 0 {
+		if err := json.Unmarshal(c.Ctx.Input.RequestBody, &req); err != nil {
+			utils.SendBadRequest(&c.Controller, "Invalid request body", err.Error())
+			return
+		}
+	}
+
+	if req.ReservationId != "" {
+		err := models.CancelSeriesOccurrence(series.Id, req.ReservationId)
+		var transitionErr *models.InvalidTransitionError
+		switch {
+		case errors.Is(err, orm.ErrNoRows), errors.Is(err, models.ErrNotInSeries):
+			utils.SendNotFound(&c.Controller, "Reservation not found in this series")
+		case errors.As(err, &transitionErr):
+			utils.SendConflict(&c.Controller, transitionErr.Error(), nil)
+		case err != nil:
+			utils.SendInternalError(&c.Controller, "Error cancelling reservation", err.Error())
+		default:
+			utils.SendSuccess(&c.Controller, "Occurrence cancelled", map[string]string{"series_id": series.Id, "reservation_id": req.ReservationId})
+		}
+		return
+	}
+
+	cancelled, err := models.CancelReservationSeries(series.Id)
+	if err != nil {
+		utils.SendInternalError(&c.Controller, "Error cancelling reservation series", err.Error())
+		return
+	}
+	utils.SendSuccess(&c.Controller, "Reservation series cancelled", map[string]interface{}{
+		"series_id": series.Id,
+		"cancelled": cancelled,
+	})
+}
@@ -0,0 +1,45 @@
+package controllers
+
+import (
+	"net/http"
+	"testing"
+
+	"badminton-reservation-api/models"
+	"badminton-reservation-api/services/auth"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func TestCanManageBooking(t *testing.T) {
+	claims := func(userId, role string) *auth.Claims {
+		return &auth.Claims{Role: role, RegisteredClaims: jwt.RegisteredClaims{Subject: userId}}
+	}
+	tests := []struct {
+		name   string
+		claims *auth.Claims
+		owner  string
+		want   bool
+	}{
+		{"anonymous", nil, "user-1", false},
+		{"owner", claims("user-1", models.RoleCustomer), "user-1", true},
+		{"another customer", claims("user-2", models.RoleCustomer), "user-1", false},
+		{"customer on a booking without an account", claims("user-2", models.RoleCustomer), "", false},
+		{"staff", claims("user-3", models.RoleStaff), "user-1", true},
+		{"admin", claims("user-4", models.RoleAdmin), "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := canManageBooking(tt.claims, tt.owner); got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCreateSeriesWithoutClaims(t *testing.T) {
+	router := newTestRouter(testRoute{"/api/v1/series", &SeriesController{}, "post:CreateSeries"})
+	res := do(t, router, "POST", "/api/v1/series", `{"court_id":1,"timeslot_id":1,"start_date":"2026-10-20","occurrences":4}`)
+	if res.Status != http.StatusUnauthorized {
+		t.Errorf("status %d (%s), want 401", res.Status, res.Message)
+	}
+}
@@ -2,9 +2,9 @@ package controllers
 
 import (
 	"badminton-reservation-api/models"
+	"badminton-reservation-api/services/pricing"
 	"badminton-reservation-api/utils"
 
-	"github.com/beego/beego/v2/client/orm"
 	"github.com/beego/beego/v2/server/web"
 )
 
@@ -15,7 +15,7 @@ type TimeslotController struct {
 // GetAvailableTimeslots returns timeslots for a given court and booking_date with availability flag
 // GetAvailableTimeslots godoc
 // @Summary Get timeslots for a court and date (includes availability flag)
-// @Description Returns all globally active timeslots and an `available` boolean per timeslot for the specified court and booking_date. `available=false` means the slot is already booked/unavailable for that date and court.
+// @Description Returns all globally active timeslots and an `available` boolean per timeslot for the specified court and booking_date. `available=false` means the slot is already booked/unavailable for that date and court. `price` is the slot price after pricing rules.
 // @Tags timeslots
 // @Accept json
 // @Produce json
@@ -32,51 +32,50 @@ func (c *TimeslotController) GetAvailableTimeslots() {
 		return
 	}
 
-	// Return all globally active timeslots along with an `available` flag per timeslot
-	allSlots, err := models.GetAllTimeslots()
+	if !utils.ValidateDate(bookingDate) {
+		utils.SendBadRequest(&c.Controller, "Invalid date format. Use YYYY-MM-DD", nil)
+		return
+	}
+
+	// Build the single-court, single-date availability grid (fixed number of queries)
+	grid, err := models.GetAvailabilityGrid(bookingDate, bookingDate, courtId)
 	if err != nil {
 		utils.SendInternalError(&c.Controller, "Error retrieving timeslots", err.Error())
 		return
 	}
+	rules, err := models.GetActivePricingRules()
+	if err != nil {
+		utils.SendInternalError(&c.Controller, "Error loading pricing rules", err.Error())
+		return
+	}
+	if err := pricing.PriceGrid(grid, rules); err != nil {
+		utils.SendInternalError(&c.Controller, "Error pricing timeslots", err.Error())
+		return
+	}
 
 	type SlotWithAvailability struct {
-		Id        int    `json:"id"`
-		StartTime string `json:"start_time"`
-		EndTime   string `json:"end_time"`
-		IsActive  bool   `json:"is_active"`
-		Available bool   `json:"available"`
+		Id        int          `json:"id"`
+		StartTime string       `json:"start_time"`
+		EndTime   string       `json:"end_time"`
+		IsActive  bool         `json:"is_active"`
+		Available bool         `json:"available"`
+		Price     models.Money `json:"price"`
 	}
 
 	var result []SlotWithAvailability
-	for _, s := range allSlots {
-		// default: only consider globally active timeslots
-		if !s.IsActive {
-			continue
-		}
-		// Check timeslot_availabilities: if an explicit unavailable row exists -> available=false
-		o := orm.NewOrm()
-		cnt, err := o.QueryTable("timeslot_availabilities").Filter("court_id", courtId).Filter("timeslot_id", s.Id).Filter("booking_date", bookingDate).Filter("is_active", false).Count()
-
-		available := true
-		if err == nil && cnt > 0 {
-			available = false
-		} else {
-			// fallback to checking reservations table
-			ok, err := models.CheckAvailability(courtId, s.Id, bookingDate)
-			if err != nil {
-				utils.SendInternalError(&c.Controller, "Error checking availability", err.Error())
-				return
+	for _, court := range grid.Courts {
+		for _, date := range court.Dates {
+			for _, s := range date.Slots {
+				result = append(result, SlotWithAvailability{
+					Id:        s.TimeslotId,
+					StartTime: s.StartTime,
+					EndTime:   s.EndTime,
+					IsActive:  true,
+					Available: s.Available,
+					Price:     s.Price,
+				})
 			}
-			available = ok
 		}
-
-		result = append(result, SlotWithAvailability{
-			Id:        s.Id,
-			StartTime: s.StartTime,
-			EndTime:   s.EndTime,
-			IsActive:  s.IsActive,
-			Available: available,
-		})
 	}
 
 	utils.SendSuccess(&c.Controller, "Timeslots retrieved successfully", result)
@@ -0,0 +1,415 @@
+package database
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrChecksumMismatch is returned when an applied migration file has been edited since it ran.
+// Applied migrations are immutable; add a new migration instead.
+var ErrChecksumMismatch = errors.New("applied migration has been modified")
+
+// migrationLockId is the Postgres advisory lock that keeps two migrators from running at once
+const migrationLockId = 72601
+
+// migrationFile matches NNN_name.sql and NNN_name.down.sql
+var migrationFile = regexp.MustCompile(`^(\d+)_([a-z0-9_]+?)(\.down)?\.sql$`)
+
+// Migration is one numbered schema change. Up is NNN_name.sql and Down the optional
+// NNN_name.down.sql; Checksum is the SHA-256 of Up.
+type Migration struct {
+	Version  int
+	Name     string
+	Up       string
+	Down     string
+	Checksum string
+}
+
+// AppliedMigration is a row of schema_migrations
+type AppliedMigration struct {
+	Version   int
+	Name      string
+	Checksum  string
+	AppliedAt time.Time
+}
+
+// MigrationStatus is a migration file together with its schema_migrations row, if any
+type MigrationStatus struct {
+	Version   int
+	Name      string
+	Applied   bool
+	AppliedAt *time.Time
+	// Modified is set when the file no longer matches the checksum recorded when it ran
+	Modified bool
+	// Missing is set for an applied migration whose file no longer exists
+	Missing bool
+}
+
+// LoadMigrations reads the migration files of fsys, sorted by version
+func LoadMigrations(fsys fs.FS) ([]*Migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := map[int]*Migration{}
+	downs := map[int]string{}
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".sql") {
+			continue
+		}
+		m := migrationFile.FindStringSubmatch(entry.Name())
+		if m == nil {
+			return nil, fmt.Errorf("invalid migration file name %s: use NNN_name.sql or NNN_name.down.sql", entry.Name())
+		}
+		version, _ := strconv.Atoi(m[1])
+		if version <= 0 {
+			return nil, fmt.Errorf("invalid migration version in %s", entry.Name())
+		}
+		body, err := fs.ReadFile(fsys, entry.Name())
+		if err != nil {
+			return nil, err
+		}
+
+		if m[3] != "" {
+			if _, dup := downs[version]; dup {
+				return nil, fmt.Errorf("duplicate down migration for version %d", version)
+			}
+			downs[version] = string(body)
+			continue
+		}
+		if existing, dup := byVersion[version]; dup {
+			return nil, fmt.Errorf("duplicate migration version %d: %s and %s", version, existing.Name, m[2])
+		}
+		sum := sha256.Sum256(body)
+		byVersion[version] = &Migration{Version: version, Name: m[2], Up: string(body), Checksum: hex.EncodeToString(sum[:])}
+	}
+
+	for version, down := range downs {
+		m, ok := byVersion[version]
+		if !ok {
+			return nil, fmt.Errorf("down migration for version %d has no up migration", version)
+		}
+		m.Down = down
+	}
+
+	list := make([]*Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		list = append(list, m)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Version < list[j].Version })
+	return list, nil
+}
+
+// Migrator applies migrations to a database and records them in schema_migrations
+type Migrator struct {
+	db         *sql.DB
+	migrations []*Migration
+}
+
+// NewMigrator returns a migrator for the given migrations (see LoadMigrations)
+func NewMigrator(db *sql.DB, migrations []*Migration) *Migrator {
+	return &Migrator{db: db, migrations: migrations}
+}
+
+// Status lists every migration file and every applied migration, by version
+func (m *Migrator) Status(ctx context.Context) ([]*MigrationStatus, error) {
+	var statuses []*MigrationStatus
+	err := m.withLock(ctx, func(conn *sql.Conn) error {
+		applied, err := appliedMigrations(ctx, conn)
+		if err != nil {
+			return err
+		}
+		statuses = MigrationStatuses(m.migrations, applied)
+		return nil
+	})
+	return statuses, err
+}
+
+// Up applies every pending migration in order and returns those it applied
+func (m *Migrator) Up(ctx context.Context) ([]*Migration, error) {
+	latest := 0
+	if len(m.migrations) > 0 {
+		latest = m.migrations[len(m.migrations)-1].Version
+	}
+	return m.To(ctx, latest)
+}
+
+// Down reverts the most recently applied migration and returns it (nil when none is applied)
+func (m *Migrator) Down(ctx context.Context) ([]*Migration, error) {
+	var reverted []*Migration
+	err := m.withLock(ctx, func(conn *sql.Conn) error {
+		applied, err := m.verify(ctx, conn)
+		if err != nil {
+			return err
+		}
+		if len(applied) == 0 {
+			return nil
+		}
+		// Migrating to the previous applied version reverts exactly the latest one
+		target := 0
+		if len(applied) > 1 {
+			target = applied[len(applied)-2].Version
+		}
+		steps, err := PlanMigrations(m.migrations, applied, target)
+		if err != nil {
+			return err
+		}
+		reverted, err = m.run(ctx, conn, steps)
+		return err
+	})
+	return reverted, err
+}
+
+// To migrates up or down until version is the latest applied migration (0 reverts everything)
+// and returns the migrations it applied or reverted
+func (m *Migrator) To(ctx context.Context, version int) ([]*Migration, error) {
+	var done []*Migration
+	err := m.withLock(ctx, func(conn *sql.Conn) error {
+		applied, err := m.verify(ctx, conn)
+		if err != nil {
+			return err
+		}
+		steps, err := PlanMigrations(m.migrations, applied, version)
+		if err != nil {
+			return err
+		}
+		done, err = m.run(ctx, conn, steps)
+		return err
+	})
+	return done, err
+}
+
+// Baseline records every migration up to version as applied without running it, for a
+// database whose schema was created before schema_migrations existed. It refuses to run when
+// any migration is already recorded.
+func (m *Migrator) Baseline(ctx context.Context, version int) ([]*Migration, error) {
+	var recorded []*Migration
+	err := m.withLock(ctx, func(conn *sql.Conn) error {
+		applied, err := appliedMigrations(ctx, conn)
+		if err != nil {
+			return err
+		}
+		if len(applied) > 0 {
+			return errors.New("schema_migrations is not empty; baseline only applies to an untracked database")
+		}
+		if version != 0 && findMigration(m.migrations, version) == nil {
+			return fmt.Errorf("unknown migration version %d", version)
+		}
+		tx, err := conn.BeginTx(ctx, nil)
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback()
+		for _, mig := range m.migrations {
+			if mig.Version > version {
+				break
+			}
+			if _, err := tx.ExecContext(ctx, "INSERT INTO schema_migrations (version, name, checksum) VALUES ($1, $2, $3)", mig.Version, mig.Name, mig.Checksum); err != nil {
+				return err
+			}
+			recorded = append(recorded, mig)
+		}
+		return tx.Commit()
+	})
+	return recorded, err
+}
+
+// withLock runs fn on a single connection holding the migration advisory lock, after making
+// sure schema_migrations exists
+func (m *Migrator) withLock(ctx context.Context, fn func(conn *sql.Conn) error) error {
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, "SELECT pg_advisory_lock($1)", migrationLockId); err != nil {
+		return fmt.Errorf("acquiring migration lock: %w", err)
+	}
+	defer conn.ExecContext(context.Background(), "SELECT pg_advisory_unlock($1)", migrationLockId)
+
+	if _, err := conn.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version INTEGER PRIMARY KEY,
+		name VARCHAR(255) NOT NULL,
+		checksum CHAR(64) NOT NULL,
+		applied_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+	)`); err != nil {
+		return err
+	}
+	return fn(conn)
+}
+
+// verify loads the applied migrations and refuses to continue when one was edited or removed
+func (m *Migrator) verify(ctx context.Context, conn *sql.Conn) ([]*AppliedMigration, error) {
+	applied, err := appliedMigrations(ctx, conn)
+	if err != nil {
+		return nil, err
+	}
+	for _, s := range MigrationStatuses(m.migrations, applied) {
+		if s.Modified {
+			return nil, fmt.Errorf("%w: %03d_%s", ErrChecksumMismatch, s.Version, s.Name)
+		}
+		if s.Missing {
+			return nil, fmt.Errorf("applied migration %03d_%s has no file", s.Version, s.Name)
+		}
+	}
+	return applied, nil
+}
+
+// run applies or reverts steps in order, each in its own transaction together with its
+// schema_migrations row
+func (m *Migrator) run(ctx context.Context, conn *sql.Conn, steps []MigrationStep) ([]*Migration, error) {
+	done := make([]*Migration, 0, len(steps))
+	for _, step := range steps {
+		if err := runStep(ctx, conn, step); err != nil {
+			direction := "applying"
+			if !step.Up {
+				direction = "reverting"
+			}
+			return done, fmt.Errorf("%s migration %03d_%s: %w", direction, step.Migration.Version, step.Migration.Name, err)
+		}
+		done = append(done, step.Migration)
+	}
+	return done, nil
+}
+
+func runStep(ctx context.Context, conn *sql.Conn, step MigrationStep) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	mig := step.Migration
+	if step.Up {
+		if _, err := tx.ExecContext(ctx, mig.Up); err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, "INSERT INTO schema_migrations (version, name, checksum) VALUES ($1, $2, $3)", mig.Version, mig.Name, mig.Checksum); err != nil {
+			return err
+		}
+	} else {
+		if _, err := tx.ExecContext(ctx, mig.Down); err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, "DELETE FROM schema_migrations WHERE version = $1", mig.Version); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func appliedMigrations(ctx context.Context, conn *sql.Conn) ([]*AppliedMigration, error) {
+	rows, err := conn.QueryContext(ctx, "SELECT version, name, checksum, applied_at FROM schema_migrations ORDER BY version")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var applied []*AppliedMigration
+	for rows.Next() {
+		a := &AppliedMigration{}
+		if err := rows.Scan(&a.Version, &a.Name, &a.Checksum, &a.AppliedAt); err != nil {
+			return nil, err
+		}
+		applied = append(applied, a)
+	}
+	return applied, rows.Err()
+}
+
+// MigrationStatuses merges the migration files with the applied rows, by version
+func MigrationStatuses(migrations []*Migration, applied []*AppliedMigration) []*MigrationStatus {
+	byVersion := map[int]*MigrationStatus{}
+	for _, mig := range migrations {
+		byVersion[mig.Version] = &MigrationStatus{Version: mig.Version, Name: mig.Name}
+	}
+	for _, a := range applied {
+		s, ok := byVersion[a.Version]
+		if !ok {
+			s = &MigrationStatus{Version: a.Version, Name: a.Name, Missing: true}
+			byVersion[a.Version] = s
+		} else if mig := findMigration(migrations, a.Version); mig.Checksum != a.Checksum {
+			s.Modified = true
+		}
+		appliedAt := a.AppliedAt
+		s.Applied = true
+		s.AppliedAt = &appliedAt
+	}
+
+	list := make([]*MigrationStatus, 0, len(byVersion))
+	for _, s := range byVersion {
+		list = append(list, s)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Version < list[j].Version })
+	return list
+}
+
+// MigrationStep applies (Up) or reverts a migration
+type MigrationStep struct {
+	Migration *Migration
+	Up        bool
+}
+
+// PlanMigrations returns the steps that bring the database from applied to target: pending
+// migrations up to target in ascending order, or applied migrations above target in
+// descending order. A pending migration older than an applied one is refused rather than run
+// out of order, and so is reverting a migration without a down file.
+func PlanMigrations(migrations []*Migration, applied []*AppliedMigration, target int) ([]MigrationStep, error) {
+	if target != 0 && findMigration(migrations, target) == nil {
+		return nil, fmt.Errorf("unknown migration version %d", target)
+	}
+	isApplied := map[int]bool{}
+	current := 0
+	for _, a := range applied {
+		isApplied[a.Version] = true
+		if a.Version > current {
+			current = a.Version
+		}
+	}
+
+	var steps []MigrationStep
+	if target >= current {
+		for _, mig := range migrations {
+			if isApplied[mig.Version] || mig.Version > target {
+				continue
+			}
+			if mig.Version < current {
+				return nil, fmt.Errorf("migration %03d_%s is pending but %d is already applied; renumber it after %d", mig.Version, mig.Name, current, current)
+			}
+			steps = append(steps, MigrationStep{Migration: mig, Up: true})
+		}
+		return steps, nil
+	}
+
+	for i := len(migrations) - 1; i >= 0; i-- {
+		mig := migrations[i]
+		if mig.Version <= target || !isApplied[mig.Version] {
+			continue
+		}
+		if strings.TrimSpace(mig.Down) == "" {
+			return nil, fmt.Errorf("migration %03d_%s has no down migration", mig.Version, mig.Name)
+		}
+		steps = append(steps, MigrationStep{Migration: mig, Up: false})
+	}
+	return steps, nil
+}
+
+func findMigration(migrations []*Migration, version int) *Migration {
+	for _, mig := range migrations {
+		if mig.Version == version {
+			return mig
+		}
+	}
+	return nil
+}
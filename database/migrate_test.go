@@ -0,0 +1,148 @@
+package database
+
+import (
+	"badminton-reservation-api/database/migrations"
+	"fmt"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+func TestEmbeddedMigrations(t *testing.T) {
+	list, err := LoadMigrations(migrations.FS)
+	if err != nil || len(list) == 0 {
+		t.Fatalf("load embedded migrations: %d loaded, %v", len(list), err)
+	}
+	for i, m := range list {
+		if m.Version != i+1 {
+			t.Errorf("migration %03d_%s should be numbered %03d", m.Version, m.Name, i+1)
+		}
+		if m.Down == "" {
+			t.Errorf("migration %03d_%s has no down file", m.Version, m.Name)
+		}
+	}
+}
+
+func appliedAt(list []*Migration, versions ...int) []*AppliedMigration {
+	var rows []*AppliedMigration
+	for _, v := range versions {
+		for _, m := range list {
+			if m.Version == v {
+				rows = append(rows, &AppliedMigration{Version: v, Name: m.Name, Checksum: m.Checksum, AppliedAt: time.Now()})
+			}
+		}
+	}
+	return rows
+}
+
+// stepVersions lists the planned versions, negative for reverts
+func stepVersions(steps []MigrationStep) string {
+	var out []int
+	for _, s := range steps {
+		v := s.Migration.Version
+		if !s.Up {
+			v = -v
+		}
+		out = append(out, v)
+	}
+	return fmt.Sprint(out)
+}
+
+func testMigrations(t *testing.T) []*Migration {
+	t.Helper()
+	list, err := LoadMigrations(fstest.MapFS{
+		"001_create_a.sql":      {Data: []byte("CREATE TABLE a (id INT);")},
+		"001_create_a.down.sql": {Data: []byte("DROP TABLE a;")},
+		"002_create_b.sql":      {Data: []byte("CREATE TABLE b (id INT);")},
+		"002_create_b.down.sql": {Data: []byte("DROP TABLE b;")},
+		"003_add_c.sql":         {Data: []byte("ALTER TABLE b ADD COLUMN c INT;")},
+		"README.md":             {Data: []byte("ignored")},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 3 || list[0].Down == "" || list[2].Down != "" {
+		t.Fatalf("loaded %d migrations, want 3 with a down file for all but 003", len(list))
+	}
+	return list
+}
+
+func TestPlanMigrations(t *testing.T) {
+	list := testMigrations(t)
+	tests := []struct {
+		name    string
+		applied []int
+		target  int
+		want    string
+	}{
+		{"up from empty applies all in order", nil, 3, "[1 2 3]"},
+		{"to 2 applies only 2", []int{1}, 2, "[2]"},
+		{"to 0 reverts newest first", []int{1, 2}, 0, "[-2 -1]"},
+		{"already at target is a no-op", []int{1, 2}, 2, "[]"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			steps, err := PlanMigrations(list, appliedAt(list, tt.applied...), tt.target)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := stepVersions(steps); got != tt.want {
+				t.Errorf("planned %s, want %s", got, tt.want)
+			}
+		})
+	}
+
+	refused := []struct {
+		name    string
+		applied []int
+		target  int
+	}{
+		{"reverting without a down file", []int{1, 2, 3}, 2},
+		{"pending migration older than an applied one", []int{1, 3}, 3},
+		{"unknown target", nil, 9},
+	}
+	for _, tt := range refused {
+		t.Run(tt.name, func(t *testing.T) {
+			if steps, err := PlanMigrations(list, appliedAt(list, tt.applied...), tt.target); err == nil {
+				t.Errorf("planned %s, want an error", stepVersions(steps))
+			}
+		})
+	}
+}
+
+func TestMigrationStatuses(t *testing.T) {
+	list := testMigrations(t)
+	rows := appliedAt(list, 1, 2)
+	rows[1].Checksum = "edited"
+	rows = append(rows, &AppliedMigration{Version: 7, Name: "gone"})
+
+	statuses := MigrationStatuses(list, rows)
+	if len(statuses) != 4 {
+		t.Fatalf("%d statuses, want 4", len(statuses))
+	}
+	if statuses[0].Modified || !statuses[1].Modified {
+		t.Errorf("only 002 was edited: %+v %+v", statuses[0], statuses[1])
+	}
+	if statuses[2].Applied {
+		t.Errorf("003 is not applied: %+v", statuses[2])
+	}
+	if !statuses[3].Missing {
+		t.Errorf("007 has no file: %+v", statuses[3])
+	}
+}
+
+func TestLoadMigrationsRefusesBadFiles(t *testing.T) {
+	for name, fsys := range map[string]fstest.MapFS{
+		"invalid file name": {"create_a.sql": {Data: []byte("")}},
+		"duplicate version": {"001_a.sql": {Data: []byte("")}, "001_b.sql": {Data: []byte("")}},
+		"down without up":   {"002_b.down.sql": {Data: []byte("")}},
+		"version zero":      {"000_a.sql": {Data: []byte("")}},
+		"upper-case name":   {"001_Create.sql": {Data: []byte("")}},
+	} {
+		t.Run(name, func(t *testing.T) {
+			if _, err := LoadMigrations(fsys); err == nil {
+				t.Error("loaded, want an error")
+			}
+		})
+	}
+}
@@ -0,0 +1,3 @@
+-- Revert 001_create_courts.sql
+DROP TABLE IF EXISTS courts;
+DROP FUNCTION IF EXISTS update_updated_at_column();
@@ -0,0 +1,2 @@
+-- Revert 002_create_timeslots.sql
+DROP TABLE IF EXISTS timeslots;
@@ -0,0 +1,2 @@
+-- Revert 003_create_reservations.sql
+DROP TABLE IF EXISTS reservations;
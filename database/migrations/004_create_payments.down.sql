@@ -0,0 +1,2 @@
+-- Revert 004_create_payments.sql
+DROP TABLE IF EXISTS payments;
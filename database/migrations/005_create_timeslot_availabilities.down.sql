@@ -0,0 +1,2 @@
+-- Revert 005_create_timeslot_availabilities.sql
+DROP TABLE IF EXISTS timeslot_availabilities;
@@ -0,0 +1,2 @@
+-- Revert 006_create_reservations_active_slot_index.sql
+DROP INDEX IF EXISTS idx_reservations_active_slot;
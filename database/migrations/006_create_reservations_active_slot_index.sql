@@ -0,0 +1,9 @@
+-- Prevent double-booking at the database level: at most one active reservation
+-- (pending, waiting_payment, paid) may hold a given court/timeslot/date.
+-- NOTE: creation fails if existing data already contains duplicate active bookings;
+-- resolve those (e.g. mark the extra rows cancelled) before applying.
+CREATE UNIQUE INDEX IF NOT EXISTS idx_reservations_active_slot
+	ON reservations(court_id, timeslot_id, booking_date)
+	WHERE status IN ('pending', 'waiting_payment', 'paid');
+
+COMMENT ON INDEX idx_reservations_active_slot IS 'One active reservation per court/timeslot/date';
@@ -0,0 +1,2 @@
+-- Revert 007_create_users.sql
+DROP TABLE IF EXISTS users;
@@ -0,0 +1,23 @@
+-- Create users table: accounts that can authenticate against the API
+CREATE TABLE IF NOT EXISTS users (
+	id VARCHAR(36) PRIMARY KEY,
+	name VARCHAR(255) NOT NULL,
+	email VARCHAR(255) NOT NULL,
+	password_hash VARCHAR(255) NOT NULL,
+	role VARCHAR(20) NOT NULL DEFAULT 'customer' CHECK (role IN ('customer', 'staff', 'admin')),
+	is_active BOOLEAN NOT NULL DEFAULT TRUE,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Emails are unique regardless of case
+CREATE UNIQUE INDEX IF NOT EXISTS idx_users_email ON users(LOWER(email));
+
+-- Trigger to keep updated_at current
+CREATE TRIGGER update_users_updated_at
+	BEFORE UPDATE ON users
+	FOR EACH ROW
+	EXECUTE FUNCTION update_updated_at_column();
+
+COMMENT ON TABLE users IS 'API accounts (customers, staff, admins)';
+COMMENT ON COLUMN users.role IS 'User role: customer, staff, admin';
@@ -0,0 +1,8 @@
+-- Revert 008_add_reservation_status_constraint.sql: drop the status constraint and restore
+-- the double-booking guard from 006
+ALTER TABLE reservations DROP CONSTRAINT IF EXISTS chk_reservations_status;
+
+DROP INDEX IF EXISTS idx_reservations_active_slot;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_reservations_active_slot
+	ON reservations(court_id, timeslot_id, booking_date)
+	WHERE status IN ('pending', 'waiting_payment', 'paid');
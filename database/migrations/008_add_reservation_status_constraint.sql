@@ -0,0 +1,15 @@
+-- Restrict reservations.status to the statuses known by the reservation state machine
+-- (models/reservation_status.go). Fix any rows with unknown statuses before applying.
+ALTER TABLE reservations DROP CONSTRAINT IF EXISTS chk_reservations_status;
+ALTER TABLE reservations ADD CONSTRAINT chk_reservations_status CHECK (status IN (
+	'pending', 'waiting_payment', 'paid', 'cancelled', 'expired',
+	'checked_in', 'completed', 'no_show', 'refunded'
+));
+
+-- Checked-in and completed bookings keep holding their slot, so widen the double-booking guard
+DROP INDEX IF EXISTS idx_reservations_active_slot;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_reservations_active_slot
+	ON reservations(court_id, timeslot_id, booking_date)
+	WHERE status IN ('pending', 'waiting_payment', 'paid', 'checked_in', 'completed');
+
+COMMENT ON COLUMN reservations.status IS 'Reservation status: pending, waiting_payment, paid, cancelled, expired, checked_in, completed, no_show, refunded';
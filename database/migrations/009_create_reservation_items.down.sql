@@ -0,0 +1,7 @@
+-- Revert 009_create_reservation_items.sql: the double-booking guard moves back to reservations.
+-- Reservations covering several slots keep only their first slot.
+DROP TABLE IF EXISTS reservation_items;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_reservations_active_slot
+	ON reservations(court_id, timeslot_id, booking_date)
+	WHERE status IN ('pending', 'waiting_payment', 'paid', 'checked_in', 'completed');
@@ -0,0 +1,41 @@
+-- Create reservation_items table: one row per court/timeslot/date covered by a reservation,
+-- so a single booking (and payment) can span several consecutive timeslots and courts.
+CREATE TABLE IF NOT EXISTS reservation_items (
+	id SERIAL PRIMARY KEY,
+	reservation_id VARCHAR(36) NOT NULL REFERENCES reservations(id) ON DELETE CASCADE,
+	court_id INTEGER NOT NULL REFERENCES courts(id) ON DELETE RESTRICT,
+	timeslot_id INTEGER NOT NULL REFERENCES timeslots(id) ON DELETE RESTRICT,
+	booking_date VARCHAR(10) NOT NULL,
+	price NUMERIC(10,2) NOT NULL,
+	holds_slot BOOLEAN NOT NULL DEFAULT TRUE,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_reservation_items_reservation_id ON reservation_items(reservation_id);
+CREATE INDEX IF NOT EXISTS idx_reservation_items_date ON reservation_items(booking_date);
+
+-- Backfill one item per existing reservation
+INSERT INTO reservation_items (reservation_id, court_id, timeslot_id, booking_date, price, holds_slot)
+SELECT r.id, r.court_id, r.timeslot_id, r.booking_date, r.total_price,
+	r.status IN ('pending', 'waiting_payment', 'paid', 'checked_in', 'completed')
+FROM reservations r
+WHERE NOT EXISTS (SELECT 1 FROM reservation_items ri WHERE ri.reservation_id = r.id);
+
+-- Double-booking guard now lives on the items: at most one slot-holding item per court/timeslot/date.
+-- holds_slot mirrors whether the parent reservation's status holds its slot.
+CREATE UNIQUE INDEX IF NOT EXISTS idx_reservation_items_active_slot
+	ON reservation_items(court_id, timeslot_id, booking_date)
+	WHERE holds_slot;
+DROP INDEX IF EXISTS idx_reservations_active_slot;
+
+-- Trigger to keep updated_at current
+CREATE TRIGGER update_reservation_items_updated_at
+	BEFORE UPDATE ON reservation_items
+	FOR EACH ROW
+	EXECUTE FUNCTION update_updated_at_column();
+
+COMMENT ON TABLE reservation_items IS 'Line items of a reservation: one court/timeslot/date each';
+COMMENT ON COLUMN reservation_items.holds_slot IS 'True while the parent reservation status holds the slot';
+COMMENT ON COLUMN reservations.court_id IS 'Court of the first line item (see reservation_items)';
+COMMENT ON COLUMN reservations.timeslot_id IS 'Timeslot of the first line item (see reservation_items)';
@@ -0,0 +1,4 @@
+-- Revert 010_create_reservation_series.sql
+ALTER TABLE payments DROP COLUMN IF EXISTS series_id;
+ALTER TABLE reservations DROP COLUMN IF EXISTS series_id;
+DROP TABLE IF EXISTS reservation_series;
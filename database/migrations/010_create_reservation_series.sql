@@ -0,0 +1,39 @@
+-- Create reservation_series table: a recurring weekly booking (e.g. every Tuesday 19:00)
+-- whose occurrences are individual reservations linked through reservations.series_id
+CREATE TABLE IF NOT EXISTS reservation_series (
+	id VARCHAR(36) PRIMARY KEY,
+	court_id INTEGER NOT NULL REFERENCES courts(id) ON DELETE RESTRICT,
+	timeslot_id INTEGER NOT NULL REFERENCES timeslots(id) ON DELETE RESTRICT,
+	weekday SMALLINT NOT NULL CHECK (weekday BETWEEN 0 AND 6),
+	start_date VARCHAR(10) NOT NULL,
+	occurrences INTEGER NOT NULL CHECK (occurrences > 0),
+	payment_mode VARCHAR(20) NOT NULL DEFAULT 'per_occurrence' CHECK (payment_mode IN ('per_occurrence', 'prepaid')),
+	customer_name VARCHAR(255) NOT NULL,
+	customer_email VARCHAR(255) NOT NULL,
+	customer_phone VARCHAR(50) NOT NULL,
+	total_price NUMERIC(10,2) NOT NULL,
+	status VARCHAR(20) NOT NULL DEFAULT 'active' CHECK (status IN ('active', 'cancelled')),
+	notes TEXT,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_reservation_series_customer_email ON reservation_series(customer_email);
+
+-- Trigger to keep updated_at current
+CREATE TRIGGER update_reservation_series_updated_at
+	BEFORE UPDATE ON reservation_series
+	FOR EACH ROW
+	EXECUTE FUNCTION update_updated_at_column();
+
+-- Link occurrences (and prepaid series payments) to their series
+ALTER TABLE reservations ADD COLUMN IF NOT EXISTS series_id VARCHAR(36) NULL REFERENCES reservation_series(id) ON DELETE SET NULL;
+CREATE INDEX IF NOT EXISTS idx_reservations_series_id ON reservations(series_id);
+
+ALTER TABLE payments ADD COLUMN IF NOT EXISTS series_id VARCHAR(36) NULL REFERENCES reservation_series(id) ON DELETE SET NULL;
+CREATE INDEX IF NOT EXISTS idx_payments_series_id ON payments(series_id);
+
+COMMENT ON TABLE reservation_series IS 'Recurring weekly reservations; occurrences live in reservations.series_id';
+COMMENT ON COLUMN reservation_series.weekday IS 'Day of week: 0 = Sunday ... 6 = Saturday';
+COMMENT ON COLUMN reservation_series.payment_mode IS 'per_occurrence: each reservation paid separately; prepaid: one payment for the whole series';
+COMMENT ON COLUMN payments.series_id IS 'Set for prepaid series payments, which cover every occurrence';
@@ -0,0 +1,4 @@
+-- Revert 011_add_reservation_cancellation.sql
+ALTER TABLE reservations DROP COLUMN IF EXISTS cancellation_reason;
+ALTER TABLE reservations DROP COLUMN IF EXISTS refund_amount;
+ALTER TABLE reservations DROP COLUMN IF EXISTS cancelled_at;
@@ -0,0 +1,7 @@
+-- Record customer cancellations and the refund owed under the cancellation policy
+ALTER TABLE reservations ADD COLUMN IF NOT EXISTS cancelled_at TIMESTAMP NULL;
+ALTER TABLE reservations ADD COLUMN IF NOT EXISTS refund_amount NUMERIC(10,2) NOT NULL DEFAULT 0;
+ALTER TABLE reservations ADD COLUMN IF NOT EXISTS cancellation_reason TEXT;
+
+COMMENT ON COLUMN reservations.cancelled_at IS 'When the reservation was cancelled';
+COMMENT ON COLUMN reservations.refund_amount IS 'Amount owed back to the customer under the cancellation policy';
@@ -0,0 +1,3 @@
+-- Revert 012_create_refunds.sql
+ALTER TABLE payments DROP COLUMN IF EXISTS refunded_amount;
+DROP TABLE IF EXISTS refunds;
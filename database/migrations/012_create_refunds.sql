@@ -0,0 +1,29 @@
+-- Create refunds table: full or partial refunds issued against a payment through the gateway
+CREATE TABLE IF NOT EXISTS refunds (
+	id VARCHAR(36) PRIMARY KEY,
+	payment_id VARCHAR(36) NOT NULL REFERENCES payments(id) ON DELETE CASCADE,
+	reservation_id VARCHAR(36) NOT NULL REFERENCES reservations(id) ON DELETE CASCADE,
+	amount NUMERIC(10,2) NOT NULL CHECK (amount > 0),
+	reason TEXT,
+	refund_key VARCHAR(64) NOT NULL,
+	status VARCHAR(20) NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'succeeded', 'failed')),
+	gateway_refund_id VARCHAR(128),
+	gateway_response TEXT,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_refunds_refund_key ON refunds(refund_key);
+CREATE INDEX IF NOT EXISTS idx_refunds_payment_id ON refunds(payment_id);
+
+-- Trigger to keep updated_at current
+CREATE TRIGGER update_refunds_updated_at
+	BEFORE UPDATE ON refunds
+	FOR EACH ROW
+	EXECUTE FUNCTION update_updated_at_column();
+
+-- Running total of succeeded refunds; payments.status becomes partially_refunded / refunded
+ALTER TABLE payments ADD COLUMN IF NOT EXISTS refunded_amount NUMERIC(10,2) NOT NULL DEFAULT 0;
+
+COMMENT ON TABLE refunds IS 'Refunds issued through the payment gateway';
+COMMENT ON COLUMN refunds.refund_key IS 'Idempotency key sent to the gateway so a retried refund is not paid twice';
@@ -0,0 +1,2 @@
+-- Revert 013_create_payment_events.sql
+DROP TABLE IF EXISTS payment_events;
@@ -0,0 +1,26 @@
+-- Create payment_events table: every gateway notification (and reconciler result) as received.
+-- dedupe_key (order_id|transaction_status|status_code) makes redelivered webhooks no-ops.
+CREATE TABLE IF NOT EXISTS payment_events (
+	id BIGSERIAL PRIMARY KEY,
+	dedupe_key VARCHAR(255) NOT NULL,
+	gateway VARCHAR(64) NOT NULL,
+	order_id VARCHAR(128) NOT NULL,
+	transaction_status VARCHAR(64) NOT NULL DEFAULT '',
+	status_code VARCHAR(16) NOT NULL DEFAULT '',
+	payment_status VARCHAR(32) NOT NULL,
+	transaction_id VARCHAR(128),
+	payment_id VARCHAR(36) NULL REFERENCES payments(id) ON DELETE SET NULL,
+	source VARCHAR(20) NOT NULL DEFAULT 'webhook' CHECK (source IN ('webhook', 'reconciler')),
+	payload TEXT,
+	outcome VARCHAR(20) NOT NULL DEFAULT 'received' CHECK (outcome IN ('received', 'applied', 'stale', 'error')),
+	error TEXT,
+	processed_at TIMESTAMP NULL,
+	replay_count INTEGER NOT NULL DEFAULT 0,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_payment_events_dedupe_key ON payment_events(dedupe_key);
+CREATE INDEX IF NOT EXISTS idx_payment_events_order_id ON payment_events(order_id);
+
+COMMENT ON TABLE payment_events IS 'Append-only log of payment gateway notifications';
+COMMENT ON COLUMN payment_events.outcome IS 'received: not processed yet; applied: changed the payment; stale: older than the current status; error: see error';
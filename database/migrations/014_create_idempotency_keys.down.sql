@@ -0,0 +1,2 @@
+-- Revert 014_create_idempotency_keys.sql
+DROP TABLE IF EXISTS idempotency_keys;
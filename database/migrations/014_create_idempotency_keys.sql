@@ -0,0 +1,20 @@
+-- Create idempotency_keys table: responses of POST requests sent with an Idempotency-Key header,
+-- replayed when a client retries the same request
+CREATE TABLE IF NOT EXISTS idempotency_keys (
+	id BIGSERIAL PRIMARY KEY,
+	idempotency_key VARCHAR(255) NOT NULL,
+	scope VARCHAR(255) NOT NULL,
+	request_hash VARCHAR(64) NOT NULL,
+	status VARCHAR(20) NOT NULL DEFAULT 'in_progress' CHECK (status IN ('in_progress', 'completed')),
+	response_status INTEGER,
+	response_body TEXT,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	expires_at TIMESTAMP NOT NULL
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_idempotency_keys_scope_key ON idempotency_keys(scope, idempotency_key);
+CREATE INDEX IF NOT EXISTS idx_idempotency_keys_expires_at ON idempotency_keys(expires_at);
+
+COMMENT ON TABLE idempotency_keys IS 'Stored responses for retried requests; rows past expires_at are purged';
+COMMENT ON COLUMN idempotency_keys.scope IS 'HTTP method and path the key was used on';
+COMMENT ON COLUMN idempotency_keys.request_hash IS 'SHA-256 of the request body; reusing a key with another body is rejected';
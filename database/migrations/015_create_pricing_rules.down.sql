@@ -0,0 +1,2 @@
+-- Revert 015_create_pricing_rules.sql
+DROP TABLE IF EXISTS pricing_rules;
@@ -0,0 +1,33 @@
+-- Create pricing_rules table: price adjustments for peak hours, weekends and holidays.
+-- A slot uses the matching active rule with the highest priority; without one it costs courts.price_per_hour.
+CREATE TABLE IF NOT EXISTS pricing_rules (
+	id SERIAL PRIMARY KEY,
+	name VARCHAR(100) NOT NULL,
+	court_id INTEGER NULL REFERENCES courts(id) ON DELETE CASCADE,
+	weekdays VARCHAR(20) NOT NULL DEFAULT '',
+	start_time VARCHAR(10) NOT NULL DEFAULT '',
+	end_time VARCHAR(10) NOT NULL DEFAULT '',
+	start_date VARCHAR(10) NOT NULL DEFAULT '',
+	end_date VARCHAR(10) NOT NULL DEFAULT '',
+	adjustment VARCHAR(20) NOT NULL CHECK (adjustment IN ('fixed', 'multiplier', 'surcharge')),
+	value NUMERIC(10,2) NOT NULL CHECK (value >= 0),
+	priority INTEGER NOT NULL DEFAULT 0,
+	is_active BOOLEAN NOT NULL DEFAULT TRUE,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_pricing_rules_is_active ON pricing_rules(is_active);
+
+-- Trigger to keep updated_at current
+CREATE TRIGGER update_pricing_rules_updated_at
+	BEFORE UPDATE ON pricing_rules
+	FOR EACH ROW
+	EXECUTE FUNCTION update_updated_at_column();
+
+COMMENT ON TABLE pricing_rules IS 'Peak/weekend/holiday price rules; highest priority matching rule wins';
+COMMENT ON COLUMN pricing_rules.court_id IS 'NULL applies to every court';
+COMMENT ON COLUMN pricing_rules.weekdays IS 'Comma-separated weekdays, 0 = Sunday .. 6 = Saturday; empty matches every day';
+COMMENT ON COLUMN pricing_rules.start_time IS 'Slots starting in [start_time, end_time) match; empty bounds are open';
+COMMENT ON COLUMN pricing_rules.start_date IS 'Inclusive YYYY-MM-DD date range, e.g. a holiday; empty bounds are open';
+COMMENT ON COLUMN pricing_rules.adjustment IS 'fixed: value is the slot price; multiplier: base price x value; surcharge: base price + value';
@@ -0,0 +1,5 @@
+-- Revert 016_create_promo_codes.sql
+ALTER TABLE reservations DROP COLUMN IF EXISTS promo_code;
+ALTER TABLE reservations DROP COLUMN IF EXISTS discount_amount;
+DROP TABLE IF EXISTS promo_redemptions;
+DROP TABLE IF EXISTS promo_codes;
@@ -0,0 +1,52 @@
+-- Create promo_codes and promo_redemptions: discount vouchers applied when a reservation is created
+CREATE TABLE IF NOT EXISTS promo_codes (
+	id SERIAL PRIMARY KEY,
+	code VARCHAR(50) NOT NULL,
+	description TEXT,
+	discount_type VARCHAR(20) NOT NULL CHECK (discount_type IN ('percent', 'fixed')),
+	discount_value NUMERIC(10,2) NOT NULL CHECK (discount_value > 0),
+	max_discount NUMERIC(10,2) NOT NULL DEFAULT 0,
+	min_order NUMERIC(10,2) NOT NULL DEFAULT 0,
+	valid_from TIMESTAMP NULL,
+	valid_until TIMESTAMP NULL,
+	max_uses INTEGER NOT NULL DEFAULT 0,
+	max_uses_per_email INTEGER NOT NULL DEFAULT 0,
+	first_booking_only BOOLEAN NOT NULL DEFAULT FALSE,
+	court_ids VARCHAR(255) NOT NULL DEFAULT '',
+	timeslot_ids VARCHAR(255) NOT NULL DEFAULT '',
+	is_active BOOLEAN NOT NULL DEFAULT TRUE,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_promo_codes_code ON promo_codes(code);
+
+-- Trigger to keep updated_at current
+CREATE TRIGGER update_promo_codes_updated_at
+	BEFORE UPDATE ON promo_codes
+	FOR EACH ROW
+	EXECUTE FUNCTION update_updated_at_column();
+
+CREATE TABLE IF NOT EXISTS promo_redemptions (
+	id BIGSERIAL PRIMARY KEY,
+	promo_code_id INTEGER NOT NULL REFERENCES promo_codes(id) ON DELETE RESTRICT,
+	reservation_id VARCHAR(36) NOT NULL REFERENCES reservations(id) ON DELETE CASCADE,
+	customer_email VARCHAR(255) NOT NULL,
+	discount_amount NUMERIC(10,2) NOT NULL,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_promo_redemptions_reservation_id ON promo_redemptions(reservation_id);
+CREATE INDEX IF NOT EXISTS idx_promo_redemptions_promo_code_id ON promo_redemptions(promo_code_id);
+
+-- total_price is the amount due after the discount
+ALTER TABLE reservations ADD COLUMN IF NOT EXISTS discount_amount NUMERIC(10,2) NOT NULL DEFAULT 0;
+ALTER TABLE reservations ADD COLUMN IF NOT EXISTS promo_code VARCHAR(50) NULL;
+
+COMMENT ON TABLE promo_codes IS 'Discount vouchers; usage counts redemptions whose reservation was not cancelled or expired';
+COMMENT ON COLUMN promo_codes.code IS 'Stored upper-case; codes are matched case-insensitively';
+COMMENT ON COLUMN promo_codes.max_discount IS 'Cap for percent discounts; 0 = no cap';
+COMMENT ON COLUMN promo_codes.max_uses IS '0 = unlimited';
+COMMENT ON COLUMN promo_codes.max_uses_per_email IS '0 = unlimited';
+COMMENT ON COLUMN promo_codes.court_ids IS 'Comma-separated court ids the discount applies to; empty = all courts';
+COMMENT ON COLUMN promo_codes.timeslot_ids IS 'Comma-separated timeslot ids the discount applies to; empty = all timeslots';
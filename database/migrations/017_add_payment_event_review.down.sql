@@ -0,0 +1,13 @@
+-- Revert 017_add_payment_event_review.sql. Suspicious events fall back to the error outcome.
+DROP INDEX IF EXISTS idx_payment_events_needs_review;
+
+UPDATE payment_events SET outcome = 'error' WHERE outcome = 'suspicious';
+ALTER TABLE payment_events DROP CONSTRAINT IF EXISTS payment_events_outcome_check;
+ALTER TABLE payment_events ADD CONSTRAINT payment_events_outcome_check
+	CHECK (outcome IN ('received', 'applied', 'stale', 'error'));
+
+ALTER TABLE payment_events DROP COLUMN IF EXISTS review_note;
+ALTER TABLE payment_events DROP COLUMN IF EXISTS reviewed_at;
+ALTER TABLE payment_events DROP COLUMN IF EXISTS needs_review;
+ALTER TABLE payment_events DROP COLUMN IF EXISTS currency;
+ALTER TABLE payment_events DROP COLUMN IF EXISTS amount;
@@ -0,0 +1,17 @@
+-- Cross-check webhooks against the stored payment: keep the amount and currency the gateway
+-- reported, and flag notifications that do not match the payment for admin review.
+ALTER TABLE payment_events ADD COLUMN IF NOT EXISTS amount VARCHAR(32) NOT NULL DEFAULT '';
+ALTER TABLE payment_events ADD COLUMN IF NOT EXISTS currency VARCHAR(3) NOT NULL DEFAULT '';
+ALTER TABLE payment_events ADD COLUMN IF NOT EXISTS needs_review BOOLEAN NOT NULL DEFAULT false;
+ALTER TABLE payment_events ADD COLUMN IF NOT EXISTS reviewed_at TIMESTAMP NULL;
+ALTER TABLE payment_events ADD COLUMN IF NOT EXISTS review_note TEXT;
+
+ALTER TABLE payment_events DROP CONSTRAINT IF EXISTS payment_events_outcome_check;
+ALTER TABLE payment_events ADD CONSTRAINT payment_events_outcome_check
+	CHECK (outcome IN ('received', 'applied', 'stale', 'error', 'suspicious'));
+
+CREATE INDEX IF NOT EXISTS idx_payment_events_needs_review ON payment_events(id) WHERE needs_review;
+
+COMMENT ON COLUMN payment_events.outcome IS 'received: not processed yet; applied: changed the payment; stale: older than the current status; error: see error; suspicious: amount, currency or reservation did not match the payment, not applied';
+COMMENT ON COLUMN payment_events.amount IS 'Gross amount as reported by the gateway';
+COMMENT ON COLUMN payment_events.needs_review IS 'Set for suspicious events until an admin reviews them';
@@ -0,0 +1,2 @@
+-- Revert 018_create_scheduled_jobs.sql
+DROP TABLE IF EXISTS scheduled_jobs;
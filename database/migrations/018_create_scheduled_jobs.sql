@@ -0,0 +1,27 @@
+-- Create scheduled_jobs table: one row per background job with the outcome of its latest
+-- run, written by whichever instance holds the scheduler lock
+CREATE TABLE IF NOT EXISTS scheduled_jobs (
+	name VARCHAR(64) PRIMARY KEY,
+	schedule VARCHAR(100) NOT NULL,
+	last_started_at TIMESTAMP NULL,
+	last_finished_at TIMESTAMP NULL,
+	last_duration_ms BIGINT NOT NULL DEFAULT 0,
+	last_success_at TIMESTAMP NULL,
+	last_error TEXT,
+	last_error_at TIMESTAMP NULL,
+	next_run_at TIMESTAMP NULL,
+	run_count BIGINT NOT NULL DEFAULT 0,
+	failure_count BIGINT NOT NULL DEFAULT 0,
+	run_by VARCHAR(255) NOT NULL DEFAULT '',
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TRIGGER update_scheduled_jobs_updated_at
+	BEFORE UPDATE ON scheduled_jobs
+	FOR EACH ROW
+	EXECUTE FUNCTION update_updated_at_column();
+
+COMMENT ON TABLE scheduled_jobs IS 'Background jobs and their latest run; only the instance holding the scheduler advisory lock runs jobs';
+COMMENT ON COLUMN scheduled_jobs.last_error IS 'Error of the latest run; NULL when it succeeded';
+COMMENT ON COLUMN scheduled_jobs.run_by IS 'Instance (host:pid) that ran the job last';
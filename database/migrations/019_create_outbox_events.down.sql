@@ -0,0 +1,2 @@
+-- Revert 019_create_outbox_events.sql
+DROP TABLE IF EXISTS outbox_events;
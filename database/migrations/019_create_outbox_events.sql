@@ -0,0 +1,24 @@
+-- Create outbox_events table: domain events written in the same transaction as the state
+-- change they describe, delivered to handlers afterwards by the outbox dispatcher
+CREATE TABLE IF NOT EXISTS outbox_events (
+	id BIGSERIAL PRIMARY KEY,
+	event_type VARCHAR(64) NOT NULL,
+	aggregate_type VARCHAR(32) NOT NULL,
+	aggregate_id VARCHAR(36) NOT NULL,
+	payload TEXT NOT NULL,
+	status VARCHAR(20) NOT NULL DEFAULT 'pending',
+	attempts INTEGER NOT NULL DEFAULT 0,
+	next_attempt_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	completed_handlers TEXT NOT NULL DEFAULT '',
+	last_error TEXT,
+	delivered_at TIMESTAMP NULL,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_outbox_events_status_next_attempt ON outbox_events(status, next_attempt_at);
+CREATE INDEX IF NOT EXISTS idx_outbox_events_aggregate ON outbox_events(aggregate_type, aggregate_id);
+
+COMMENT ON TABLE outbox_events IS 'Transactional outbox of booking and payment domain events, delivered at least once';
+COMMENT ON COLUMN outbox_events.status IS 'pending (waiting for delivery or a retry), delivered, or dead after the last allowed attempt';
+COMMENT ON COLUMN outbox_events.payload IS 'JSON body of the event';
+COMMENT ON COLUMN outbox_events.completed_handlers IS 'Comma-separated handlers that already processed the event; skipped on retries';
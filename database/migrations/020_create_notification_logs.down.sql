@@ -0,0 +1,2 @@
+-- Revert 020_create_notification_logs.sql
+DROP TABLE IF EXISTS notification_logs;
@@ -0,0 +1,23 @@
+-- Create notification_logs table: every attempt to notify a customer, so notifications are
+-- sent once per reservation and failures can be inspected
+CREATE TABLE IF NOT EXISTS notification_logs (
+	id BIGSERIAL PRIMARY KEY,
+	reservation_id VARCHAR(36) NOT NULL REFERENCES reservations(id) ON DELETE CASCADE,
+	kind VARCHAR(64) NOT NULL,
+	channel VARCHAR(20) NOT NULL DEFAULT 'email',
+	recipient VARCHAR(255) NOT NULL,
+	language VARCHAR(5) NOT NULL,
+	subject VARCHAR(255) NOT NULL DEFAULT '',
+	status VARCHAR(20) NOT NULL,
+	error TEXT,
+	outbox_event_id BIGINT NULL,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_notification_logs_reservation_kind ON notification_logs(reservation_id, kind);
+CREATE INDEX IF NOT EXISTS idx_notification_logs_status ON notification_logs(status);
+
+COMMENT ON TABLE notification_logs IS 'Customer notification attempts (confirmation, reminders, receipts, ...)';
+COMMENT ON COLUMN notification_logs.kind IS 'reservation_created, payment_reminder, reservation_paid, reservation_cancelled, reservation_expired or game_reminder';
+COMMENT ON COLUMN notification_logs.status IS 'sent or failed';
+COMMENT ON COLUMN notification_logs.outbox_event_id IS 'Outbox event that triggered the notification; NULL for reminders';
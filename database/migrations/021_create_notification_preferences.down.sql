@@ -0,0 +1,2 @@
+-- Revert 021_create_notification_preferences.sql
+DROP TABLE IF EXISTS notification_preferences;
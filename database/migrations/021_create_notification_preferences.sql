@@ -0,0 +1,14 @@
+-- Create notification_preferences table: how a customer wants to hear about their bookings.
+-- Keyed by the lower-cased email that reservations carry in customer_email.
+CREATE TABLE IF NOT EXISTS notification_preferences (
+	email VARCHAR(255) PRIMARY KEY,
+	channels VARCHAR(100) NOT NULL DEFAULT 'email',
+	phone VARCHAR(20) NOT NULL DEFAULT '',
+	language VARCHAR(5) NOT NULL DEFAULT '',
+	updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+COMMENT ON TABLE notification_preferences IS 'Customer notification channel preferences';
+COMMENT ON COLUMN notification_preferences.channels IS 'Comma-separated channels to notify over: email, sms, whatsapp';
+COMMENT ON COLUMN notification_preferences.phone IS 'E.164 number for sms/whatsapp; empty uses the reservation''s customer_phone';
+COMMENT ON COLUMN notification_preferences.language IS 'id or en; empty uses NOTIFICATION_LANGUAGE';
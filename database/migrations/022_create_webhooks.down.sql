@@ -0,0 +1,4 @@
+-- Revert 022_create_webhooks.sql
+DROP TABLE IF EXISTS webhook_attempts;
+DROP TABLE IF EXISTS webhook_deliveries;
+DROP TABLE IF EXISTS webhook_endpoints;
@@ -0,0 +1,53 @@
+-- Create webhook tables: partner endpoints registered by admins, one delivery per endpoint
+-- and domain event, and every HTTP attempt made for a delivery
+CREATE TABLE IF NOT EXISTS webhook_endpoints (
+	id SERIAL PRIMARY KEY,
+	url VARCHAR(2048) NOT NULL,
+	description VARCHAR(255) NOT NULL DEFAULT '',
+	secret VARCHAR(100) NOT NULL,
+	event_types TEXT NOT NULL DEFAULT '',
+	is_active BOOLEAN NOT NULL DEFAULT true,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS webhook_deliveries (
+	id BIGSERIAL PRIMARY KEY,
+	endpoint_id INTEGER NOT NULL REFERENCES webhook_endpoints(id) ON DELETE CASCADE,
+	outbox_event_id BIGINT NOT NULL,
+	event_type VARCHAR(64) NOT NULL,
+	payload TEXT NOT NULL,
+	status VARCHAR(20) NOT NULL DEFAULT 'pending',
+	attempts INTEGER NOT NULL DEFAULT 0,
+	next_attempt_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	last_status_code INTEGER NOT NULL DEFAULT 0,
+	last_error TEXT,
+	delivered_at TIMESTAMP NULL,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_webhook_deliveries_endpoint_event ON webhook_deliveries(endpoint_id, outbox_event_id);
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_status_next_attempt ON webhook_deliveries(status, next_attempt_at);
+
+CREATE TABLE IF NOT EXISTS webhook_attempts (
+	id BIGSERIAL PRIMARY KEY,
+	delivery_id BIGINT NOT NULL REFERENCES webhook_deliveries(id) ON DELETE CASCADE,
+	attempt INTEGER NOT NULL,
+	status_code INTEGER NOT NULL DEFAULT 0,
+	error TEXT,
+	response_body TEXT,
+	duration_ms INTEGER NOT NULL DEFAULT 0,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_webhook_attempts_delivery ON webhook_attempts(delivery_id);
+
+COMMENT ON TABLE webhook_endpoints IS 'Partner URLs that receive booking and payment events';
+COMMENT ON COLUMN webhook_endpoints.secret IS 'HMAC-SHA256 key signing every request to the endpoint';
+COMMENT ON COLUMN webhook_endpoints.event_types IS 'Comma-separated event types the endpoint receives; empty for all';
+COMMENT ON TABLE webhook_deliveries IS 'One domain event to one endpoint, retried with backoff until delivered or dead';
+COMMENT ON COLUMN webhook_deliveries.outbox_event_id IS 'Outbox event delivered; not a foreign key since delivered events are purged';
+COMMENT ON COLUMN webhook_deliveries.payload IS 'JSON body sent on every attempt';
+COMMENT ON COLUMN webhook_deliveries.status IS 'pending (waiting for delivery or a retry), delivered, or dead after the last allowed attempt';
+COMMENT ON TABLE webhook_attempts IS 'Every HTTP request made for a webhook delivery and its outcome';
@@ -0,0 +1,3 @@
+-- Revert 023_add_booking_owner.sql
+ALTER TABLE reservation_series DROP COLUMN IF EXISTS user_id;
+ALTER TABLE reservations DROP COLUMN IF EXISTS user_id;
@@ -0,0 +1,10 @@
+-- Remember the account that made a booking. Customers may only manage bookings their own
+-- account made: the customer email is typed in by whoever books and is not proof of ownership.
+ALTER TABLE reservations ADD COLUMN IF NOT EXISTS user_id VARCHAR(36) NULL REFERENCES users(id) ON DELETE SET NULL;
+CREATE INDEX IF NOT EXISTS idx_reservations_user_id ON reservations(user_id);
+
+ALTER TABLE reservation_series ADD COLUMN IF NOT EXISTS user_id VARCHAR(36) NULL REFERENCES users(id) ON DELETE SET NULL;
+CREATE INDEX IF NOT EXISTS idx_reservation_series_user_id ON reservation_series(user_id);
+
+COMMENT ON COLUMN reservations.user_id IS 'Account that made the booking; NULL for bookings made without signing in';
+COMMENT ON COLUMN reservation_series.user_id IS 'Account that made the series';
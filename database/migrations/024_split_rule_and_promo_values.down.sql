@@ -0,0 +1,15 @@
+-- Revert 024_split_rule_and_promo_values.sql
+ALTER TABLE promo_codes ADD COLUMN IF NOT EXISTS discount_value NUMERIC(10,2) NOT NULL DEFAULT 0;
+UPDATE promo_codes SET discount_value = CASE WHEN discount_type = 'percent' THEN discount_percent ELSE discount_amount END;
+ALTER TABLE promo_codes ADD CONSTRAINT promo_codes_discount_value_check CHECK (discount_value > 0);
+ALTER TABLE promo_codes ALTER COLUMN discount_value DROP DEFAULT;
+ALTER TABLE promo_codes DROP COLUMN IF EXISTS discount_percent;
+ALTER TABLE promo_codes DROP COLUMN IF EXISTS discount_amount;
+
+ALTER TABLE pricing_rules ADD COLUMN IF NOT EXISTS value NUMERIC(10,2) NOT NULL DEFAULT 0 CHECK (value >= 0);
+UPDATE pricing_rules SET value = CASE WHEN adjustment = 'multiplier' THEN multiplier ELSE amount END;
+ALTER TABLE pricing_rules ALTER COLUMN value DROP DEFAULT;
+ALTER TABLE pricing_rules DROP COLUMN IF EXISTS multiplier;
+ALTER TABLE pricing_rules DROP COLUMN IF EXISTS amount;
+
+COMMENT ON COLUMN pricing_rules.adjustment IS 'fixed: value is the slot price; multiplier: base price x value; surcharge: base price + value';
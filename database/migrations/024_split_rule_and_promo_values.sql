@@ -0,0 +1,20 @@
+-- Split the value columns that held either money or a factor. Amounts now live in their own
+-- money columns and multipliers/percentages in columns of their own, so no amount is ever
+-- read as a float.
+ALTER TABLE pricing_rules ADD COLUMN IF NOT EXISTS amount NUMERIC(10,2) NOT NULL DEFAULT 0 CHECK (amount >= 0);
+ALTER TABLE pricing_rules ADD COLUMN IF NOT EXISTS multiplier NUMERIC(12,4) NOT NULL DEFAULT 1 CHECK (multiplier >= 0);
+UPDATE pricing_rules SET amount = value WHERE adjustment IN ('fixed', 'surcharge');
+UPDATE pricing_rules SET multiplier = value WHERE adjustment = 'multiplier';
+ALTER TABLE pricing_rules DROP COLUMN IF EXISTS value;
+
+ALTER TABLE promo_codes ADD COLUMN IF NOT EXISTS discount_amount NUMERIC(10,2) NOT NULL DEFAULT 0 CHECK (discount_amount >= 0);
+ALTER TABLE promo_codes ADD COLUMN IF NOT EXISTS discount_percent NUMERIC(5,2) NOT NULL DEFAULT 0 CHECK (discount_percent >= 0 AND discount_percent <= 100);
+UPDATE promo_codes SET discount_amount = discount_value WHERE discount_type = 'fixed';
+UPDATE promo_codes SET discount_percent = LEAST(discount_value, 100) WHERE discount_type = 'percent';
+ALTER TABLE promo_codes DROP COLUMN IF EXISTS discount_value;
+
+COMMENT ON COLUMN pricing_rules.adjustment IS 'fixed: amount is the slot price; multiplier: base price x multiplier; surcharge: base price + amount';
+COMMENT ON COLUMN pricing_rules.amount IS 'Slot price (fixed) or amount added (surcharge); unused for multiplier rules';
+COMMENT ON COLUMN pricing_rules.multiplier IS 'Factor applied to the base price (multiplier); unused for other rules';
+COMMENT ON COLUMN promo_codes.discount_amount IS 'Amount taken off (fixed codes); unused for percent codes';
+COMMENT ON COLUMN promo_codes.discount_percent IS 'Percentage of the eligible slots taken off (percent codes); unused for fixed codes';
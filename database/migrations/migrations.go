@@ -0,0 +1,10 @@
+// Package migrations embeds the numbered SQL schema migrations so the migrate binary does
+// not need the source tree at run time.
+package migrations
+
+import "embed"
+
+// FS holds the NNN_name.sql (up) and NNN_name.down.sql (down) files
+//
+//go:embed *.sql
+var FS embed.FS
@@ -0,0 +1,539 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// Schema issue kinds. Only IssueExtraColumn is a warning; every other kind is drift that
+// breaks queries or lets bad data in.
+const (
+	IssueMissingTable      = "missing_table"
+	IssueMissingColumn     = "missing_column"
+	IssueTypeMismatch      = "type_mismatch"
+	IssueNotNull           = "not_null"
+	IssueMissingPrimaryKey = "missing_primary_key"
+	IssueMissingIndex      = "missing_index"
+	IssueMissingUnique     = "missing_unique"
+	IssueMissingForeignKey = "missing_foreign_key"
+	IssueExtraColumn       = "extra_column"
+)
+
+// ColumnSchema is a column and its Postgres type. Type is one of varchar, char, text,
+// numeric, smallint, integer, bigint, real, double precision, boolean, date, timestamp,
+// timestamptz, or the data_type reported by the database for anything else.
+type ColumnSchema struct {
+	Name      string
+	Type      string
+	Length    int
+	Precision int
+	Scale     int
+	Nullable  bool
+}
+
+// TypeString formats the column type the way it would be written in a migration
+func (c *ColumnSchema) TypeString() string {
+	switch {
+	case (c.Type == "varchar" || c.Type == "char") && c.Length > 0:
+		return fmt.Sprintf("%s(%d)", c.Type, c.Length)
+	case c.Type == "numeric" && c.Precision > 0:
+		return fmt.Sprintf("numeric(%d,%d)", c.Precision, c.Scale)
+	}
+	return c.Type
+}
+
+// IndexSchema is an index; Columns holds column names, or the expression for expression indexes
+type IndexSchema struct {
+	Name    string
+	Columns []string
+	Unique  bool
+	Primary bool
+}
+
+// ForeignKeySchema is a single-column foreign key
+type ForeignKeySchema struct {
+	Column    string
+	RefTable  string
+	RefColumn string
+}
+
+// TableSchema describes a table, either as the models expect it or as it exists
+type TableSchema struct {
+	Name        string
+	Columns     []*ColumnSchema
+	PrimaryKey  []string
+	Indexes     []*IndexSchema
+	ForeignKeys []*ForeignKeySchema
+}
+
+// Column returns the named column, or nil
+func (t *TableSchema) Column(name string) *ColumnSchema {
+	for _, c := range t.Columns {
+		if c.Name == name {
+			return c
+		}
+	}
+	return nil
+}
+
+// SchemaIssue is one difference between the models and the database
+type SchemaIssue struct {
+	Table   string
+	Column  string
+	Kind    string
+	Detail  string
+	Warning bool
+}
+
+func (i SchemaIssue) String() string {
+	target := i.Table
+	if i.Column != "" {
+		target += "." + i.Column
+	}
+	return fmt.Sprintf("%-20s %-40s %s", i.Kind, target, i.Detail)
+}
+
+// Models may declare their indexes with beego's TableIndex/TableUnique and their foreign
+// keys with TableReferences (column → "table(column)")
+type tableNamer interface{ TableName() string }
+type tableIndexer interface{ TableIndex() [][]string }
+type tableUniquer interface{ TableUnique() [][]string }
+type tableReferencer interface{ TableReferences() map[string]string }
+
+// ModelSchema derives the expected schema from registered ORM models: columns and types
+// from the orm struct tags, the primary key from the pk tag, and indexes and foreign keys
+// from the TableIndex, TableUnique and TableReferences methods.
+func ModelSchema(models []interface{}) ([]*TableSchema, error) {
+	var tables []*TableSchema
+	for _, model := range models {
+		table, err := modelTable(model)
+		if err != nil {
+			return nil, err
+		}
+		tables = append(tables, table)
+	}
+	return tables, nil
+}
+
+func modelTable(model interface{}) (*TableSchema, error) {
+	typ := reflect.TypeOf(model)
+	for typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	if typ.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("model %T is not a struct", model)
+	}
+	table := &TableSchema{Name: snakeCase(typ.Name())}
+	if namer, ok := model.(tableNamer); ok {
+		table.Name = namer.TableName()
+	}
+
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		tag := parseOrmTag(field.Tag.Get("orm"))
+		if field.PkgPath != "" || tag.has("-") {
+			continue
+		}
+		column, err := fieldColumn(field, tag)
+		if err != nil {
+			return nil, fmt.Errorf("%s.%s: %w", typ.Name(), field.Name, err)
+		}
+		table.Columns = append(table.Columns, column)
+		if tag.has("pk") {
+			table.PrimaryKey = append(table.PrimaryKey, column.Name)
+		}
+		if tag.has("index") {
+			table.Indexes = append(table.Indexes, &IndexSchema{Columns: []string{column.Name}})
+		}
+		if tag.has("unique") {
+			table.Indexes = append(table.Indexes, &IndexSchema{Columns: []string{column.Name}, Unique: true})
+		}
+	}
+
+	if indexer, ok := model.(tableIndexer); ok {
+		for _, cols := range indexer.TableIndex() {
+			table.Indexes = append(table.Indexes, &IndexSchema{Columns: cols})
+		}
+	}
+	if uniquer, ok := model.(tableUniquer); ok {
+		for _, cols := range uniquer.TableUnique() {
+			table.Indexes = append(table.Indexes, &IndexSchema{Columns: cols, Unique: true})
+		}
+	}
+	if referencer, ok := model.(tableReferencer); ok {
+		refs := referencer.TableReferences()
+		columns := make([]string, 0, len(refs))
+		for column := range refs {
+			columns = append(columns, column)
+		}
+		sort.Strings(columns)
+		for _, column := range columns {
+			ref := refs[column]
+			open := strings.Index(ref, "(")
+			if open <= 0 || !strings.HasSuffix(ref, ")") {
+				return nil, fmt.Errorf("%s: reference %q of %s must look like table(column)", typ.Name(), ref, column)
+			}
+			table.ForeignKeys = append(table.ForeignKeys, &ForeignKeySchema{Column: column, RefTable: ref[:open], RefColumn: ref[open+1 : len(ref)-1]})
+		}
+	}
+
+	// Declarations naming a column the model does not have are typos, not drift
+	for _, index := range table.Indexes {
+		for _, column := range index.Columns {
+			if table.Column(column) == nil {
+				return nil, fmt.Errorf("%s: index column %s is not a column of %s", typ.Name(), column, table.Name)
+			}
+		}
+	}
+	for _, fk := range table.ForeignKeys {
+		if table.Column(fk.Column) == nil {
+			return nil, fmt.Errorf("%s: foreign key column %s is not a column of %s", typ.Name(), fk.Column, table.Name)
+		}
+	}
+	return table, nil
+}
+
+// ormTag is a parsed orm:"..." struct tag: flags such as pk or null map to "", options
+// such as size(36) to their argument
+type ormTag map[string]string
+
+func parseOrmTag(tag string) ormTag {
+	parsed := ormTag{}
+	for _, part := range strings.Split(tag, ";") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		if open := strings.Index(part, "("); open > 0 && strings.HasSuffix(part, ")") {
+			parsed[part[:open]] = part[open+1 : len(part)-1]
+		} else {
+			parsed[part] = ""
+		}
+	}
+	return parsed
+}
+
+func (t ormTag) has(name string) bool {
+	_, ok := t[name]
+	return ok
+}
+
+func (t ormTag) int(name string) int {
+	var n int
+	fmt.Sscanf(t[name], "%d", &n)
+	return n
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// fieldColumn maps a struct field to the column the ORM reads and writes, following
+// beego's defaults: strings are varchar(255) unless sized or typed, time.Time is a
+// timestamp, and pointers are nullable
+func fieldColumn(field reflect.StructField, tag ormTag) (*ColumnSchema, error) {
+	column := &ColumnSchema{Name: tag["column"], Nullable: tag.has("null")}
+	if column.Name == "" {
+		column.Name = snakeCase(field.Name)
+	}
+	typ := field.Type
+	if typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+		column.Nullable = true
+	}
+
+	if tag.has("digits") {
+		column.Type = "numeric"
+		column.Precision = tag.int("digits")
+		column.Scale = tag.int("decimals")
+		return column, nil
+	}
+	switch tag["type"] {
+	case "":
+	case "text", "json", "jsonb", "date", "time":
+		column.Type = tag["type"]
+		return column, nil
+	case "datetime":
+		column.Type = "timestamp"
+		return column, nil
+	case "char":
+		column.Type = "char"
+		column.Length = tag.int("size")
+		return column, nil
+	default:
+		return nil, fmt.Errorf("unsupported orm type(%s)", tag["type"])
+	}
+
+	switch typ.Kind() {
+	case reflect.String:
+		column.Type = "varchar"
+		column.Length = 255
+		if tag.has("size") {
+			column.Length = tag.int("size")
+		}
+	case reflect.Bool:
+		column.Type = "boolean"
+	case reflect.Int8, reflect.Int16, reflect.Uint8:
+		column.Type = "smallint"
+	case reflect.Int32, reflect.Uint16:
+		column.Type = "integer"
+	case reflect.Int, reflect.Int64, reflect.Uint32, reflect.Uint, reflect.Uint64:
+		column.Type = "bigint"
+	case reflect.Float32:
+		column.Type = "real"
+	case reflect.Float64:
+		column.Type = "double precision"
+	case reflect.Struct:
+		if typ != timeType {
+			return nil, fmt.Errorf("cannot derive a column type for %s; add digits()/decimals() or type()", typ)
+		}
+		column.Type = "timestamp"
+	default:
+		return nil, fmt.Errorf("cannot derive a column type for %s", typ)
+	}
+	return column, nil
+}
+
+func snakeCase(name string) string {
+	var b strings.Builder
+	for i, r := range name {
+		if r >= 'A' && r <= 'Z' {
+			if i > 0 {
+				b.WriteByte('_')
+			}
+			r += 'a' - 'A'
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// LoadSchema reads the tables of the current schema from information_schema, and their
+// indexes from pg_index (information_schema has no view for indexes)
+func LoadSchema(ctx context.Context, db *sql.DB) (map[string]*TableSchema, error) {
+	tables := map[string]*TableSchema{}
+	table := func(name string) *TableSchema {
+		if tables[name] == nil {
+			tables[name] = &TableSchema{Name: name}
+		}
+		return tables[name]
+	}
+
+	rows, err := db.QueryContext(ctx, `
+		SELECT c.table_name, c.column_name, c.data_type, COALESCE(c.character_maximum_length, 0),
+			COALESCE(c.numeric_precision, 0), COALESCE(c.numeric_scale, 0), c.is_nullable = 'YES'
+		FROM information_schema.columns c
+		JOIN information_schema.tables t ON t.table_schema = c.table_schema AND t.table_name = c.table_name
+		WHERE c.table_schema = current_schema() AND t.table_type = 'BASE TABLE'
+		ORDER BY c.table_name, c.ordinal_position`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var tableName, dataType string
+		column := &ColumnSchema{}
+		if err := rows.Scan(&tableName, &column.Name, &dataType, &column.Length, &column.Precision, &column.Scale, &column.Nullable); err != nil {
+			return nil, err
+		}
+		column.Type = normalizeDataType(dataType)
+		if column.Type != "numeric" {
+			// information_schema reports the bit precision of integer and float types
+			column.Precision, column.Scale = 0, 0
+		}
+		t := table(tableName)
+		t.Columns = append(t.Columns, column)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	rows, err = db.QueryContext(ctx, `
+		SELECT t.relname, i.relname, ix.indisunique, ix.indisprimary,
+			ARRAY(SELECT pg_get_indexdef(ix.indexrelid, k, true) FROM generate_series(1, ix.indnkeyatts) AS k ORDER BY k)
+		FROM pg_index ix
+		JOIN pg_class i ON i.oid = ix.indexrelid
+		JOIN pg_class t ON t.oid = ix.indrelid
+		JOIN pg_namespace n ON n.oid = t.relnamespace
+		WHERE n.nspname = current_schema()
+		ORDER BY t.relname, i.relname`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var tableName string
+		index := &IndexSchema{}
+		if err := rows.Scan(&tableName, &index.Name, &index.Unique, &index.Primary, pq.Array(&index.Columns)); err != nil {
+			return nil, err
+		}
+		for i, column := range index.Columns {
+			index.Columns[i] = strings.Trim(column, `"`)
+		}
+		t := table(tableName)
+		t.Indexes = append(t.Indexes, index)
+		if index.Primary {
+			t.PrimaryKey = index.Columns
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	rows, err = db.QueryContext(ctx, `
+		SELECT kcu.table_name, kcu.column_name, ref.table_name, ref.column_name
+		FROM information_schema.referential_constraints rc
+		JOIN information_schema.key_column_usage kcu
+			ON kcu.constraint_schema = rc.constraint_schema AND kcu.constraint_name = rc.constraint_name
+		JOIN information_schema.key_column_usage ref
+			ON ref.constraint_schema = rc.unique_constraint_schema AND ref.constraint_name = rc.unique_constraint_name
+			AND ref.ordinal_position = kcu.position_in_unique_constraint
+		WHERE rc.constraint_schema = current_schema()
+		ORDER BY kcu.table_name, kcu.column_name`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var tableName string
+		fk := &ForeignKeySchema{}
+		if err := rows.Scan(&tableName, &fk.Column, &fk.RefTable, &fk.RefColumn); err != nil {
+			return nil, err
+		}
+		t := table(tableName)
+		t.ForeignKeys = append(t.ForeignKeys, fk)
+	}
+	return tables, rows.Err()
+}
+
+func normalizeDataType(dataType string) string {
+	switch dataType {
+	case "character varying":
+		return "varchar"
+	case "character":
+		return "char"
+	case "timestamp without time zone":
+		return "timestamp"
+	case "timestamp with time zone":
+		return "timestamptz"
+	case "time without time zone":
+		return "time"
+	}
+	return dataType
+}
+
+// integerWidth orders the integer types; a model integer can read any narrower column
+var integerWidth = map[string]int{"smallint": 2, "integer": 4, "bigint": 8}
+
+// columnTypesMatch reports whether a column of type actual can hold what the model stores
+func columnTypesMatch(expected, actual *ColumnSchema) bool {
+	if ew, ok := integerWidth[expected.Type]; ok {
+		aw, ok := integerWidth[actual.Type]
+		return ok && aw <= ew
+	}
+	if expected.Type != actual.Type {
+		return false
+	}
+	switch expected.Type {
+	case "varchar", "char":
+		return expected.Length == actual.Length
+	case "numeric":
+		return expected.Precision == actual.Precision && expected.Scale == actual.Scale
+	}
+	return true
+}
+
+// CompareSchema lists the differences between the schema the models expect and the
+// actual one. Tables that no model maps are ignored; columns no model field maps are
+// reported as warnings.
+func CompareSchema(expected []*TableSchema, actual map[string]*TableSchema) []SchemaIssue {
+	var issues []SchemaIssue
+	for _, want := range expected {
+		have := actual[want.Name]
+		if have == nil {
+			issues = append(issues, SchemaIssue{Table: want.Name, Kind: IssueMissingTable, Detail: "table does not exist"})
+			continue
+		}
+		issue := func(column, kind, detail string) {
+			issues = append(issues, SchemaIssue{Table: want.Name, Column: column, Kind: kind, Detail: detail})
+		}
+
+		for _, wc := range want.Columns {
+			hc := have.Column(wc.Name)
+			switch {
+			case hc == nil:
+				issue(wc.Name, IssueMissingColumn, "model expects "+wc.TypeString())
+			case !columnTypesMatch(wc, hc):
+				issue(wc.Name, IssueTypeMismatch, fmt.Sprintf("column is %s, model expects %s", hc.TypeString(), wc.TypeString()))
+			case wc.Nullable && !hc.Nullable:
+				issue(wc.Name, IssueNotNull, "column is NOT NULL but the model may store NULL")
+			}
+		}
+		for _, hc := range have.Columns {
+			if want.Column(hc.Name) == nil {
+				issues = append(issues, SchemaIssue{Table: want.Name, Column: hc.Name, Kind: IssueExtraColumn,
+					Detail: hc.TypeString() + " is not mapped by the model", Warning: true})
+			}
+		}
+
+		if len(want.PrimaryKey) > 0 && !sameColumns(want.PrimaryKey, have.PrimaryKey) {
+			detail := "no primary key"
+			if len(have.PrimaryKey) > 0 {
+				detail = "primary key is (" + strings.Join(have.PrimaryKey, ", ") + ")"
+			}
+			issue(strings.Join(want.PrimaryKey, ", "), IssueMissingPrimaryKey, detail)
+		}
+		for _, wi := range want.Indexes {
+			if !hasIndex(have, wi) {
+				kind := IssueMissingIndex
+				if wi.Unique {
+					kind = IssueMissingUnique
+				}
+				issue("", kind, "no index on ("+strings.Join(wi.Columns, ", ")+")")
+			}
+		}
+		for _, wf := range want.ForeignKeys {
+			if !hasForeignKey(have, wf) {
+				issue(wf.Column, IssueMissingForeignKey, fmt.Sprintf("no foreign key to %s(%s)", wf.RefTable, wf.RefColumn))
+			}
+		}
+	}
+	return issues
+}
+
+// hasIndex reports whether table has an index on exactly the wanted columns, in order. A
+// unique index also serves a wanted plain index; partial indexes count.
+func hasIndex(table *TableSchema, want *IndexSchema) bool {
+	for _, index := range table.Indexes {
+		if sameColumns(index.Columns, want.Columns) && (index.Unique || !want.Unique) {
+			return true
+		}
+	}
+	return false
+}
+
+func hasForeignKey(table *TableSchema, want *ForeignKeySchema) bool {
+	for _, fk := range table.ForeignKeys {
+		if *fk == *want {
+			return true
+		}
+	}
+	return false
+}
+
+func sameColumns(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,159 @@
+package database
+
+import (
+	"badminton-reservation-api/models"
+	"fmt"
+	"testing"
+)
+
+// liveSchema copies the expected schema the way LoadSchema would return it, with named indexes
+func liveSchema(expected []*TableSchema) map[string]*TableSchema {
+	tables := map[string]*TableSchema{}
+	for _, want := range expected {
+		t := &TableSchema{Name: want.Name, PrimaryKey: append([]string(nil), want.PrimaryKey...)}
+		for _, c := range want.Columns {
+			copied := *c
+			t.Columns = append(t.Columns, &copied)
+		}
+		t.Indexes = append(t.Indexes, &IndexSchema{Name: want.Name + "_pkey", Columns: want.PrimaryKey, Unique: true, Primary: true})
+		for i, index := range want.Indexes {
+			t.Indexes = append(t.Indexes, &IndexSchema{Name: fmt.Sprintf("idx_%s_%d", want.Name, i), Columns: index.Columns, Unique: index.Unique})
+		}
+		for _, fk := range want.ForeignKeys {
+			copied := *fk
+			t.ForeignKeys = append(t.ForeignKeys, &copied)
+		}
+		tables[t.Name] = t
+	}
+	return tables
+}
+
+func issueKinds(issues []SchemaIssue) []string {
+	var out []string
+	for _, issue := range issues {
+		out = append(out, issue.Kind+" "+issue.Table+"."+issue.Column)
+	}
+	return out
+}
+
+func modelSchema(t *testing.T) []*TableSchema {
+	t.Helper()
+	expected, err := ModelSchema(models.RegisteredModels())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(expected) != len(models.RegisteredModels()) {
+		t.Fatalf("described %d tables for %d models", len(expected), len(models.RegisteredModels()))
+	}
+	return expected
+}
+
+func TestModelSchema(t *testing.T) {
+	expected := modelSchema(t)
+	byName := map[string]*TableSchema{}
+	for _, table := range expected {
+		byName[table.Name] = table
+	}
+
+	payments := byName["payments"]
+	if payments == nil {
+		t.Fatal("no payments table")
+	}
+	if got := payments.Column("reservation_id").TypeString(); got != "varchar(36)" || byName["reservations"].Column("id").TypeString() != got {
+		t.Errorf("payments.reservation_id is %s, want varchar(36) like reservations.id", got)
+	}
+	if got := payments.Column("amount").TypeString(); got != "numeric(10,2)" {
+		t.Errorf("money column payments.amount is %s, want numeric(10,2)", got)
+	}
+	if !byName["reservations"].Column("cancelled_at").Nullable {
+		t.Error("pointer field reservations.cancelled_at is not nullable")
+	}
+	for _, table := range expected {
+		if len(table.PrimaryKey) != 1 {
+			t.Errorf("%s has primary key %v", table.Name, table.PrimaryKey)
+		}
+		for _, fk := range table.ForeignKeys {
+			ref := byName[fk.RefTable]
+			if ref == nil || ref.Column(fk.RefColumn) == nil {
+				t.Errorf("%s.%s references %s.%s, which is not a model column", table.Name, fk.Column, fk.RefTable, fk.RefColumn)
+			} else if table.Column(fk.Column).TypeString() != ref.Column(fk.RefColumn).TypeString() {
+				t.Errorf("%s.%s is not of the type of %s.%s", table.Name, fk.Column, fk.RefTable, fk.RefColumn)
+			}
+		}
+	}
+}
+
+func TestCompareSchema(t *testing.T) {
+	expected := modelSchema(t)
+	if issues := CompareSchema(expected, liveSchema(expected)); len(issues) != 0 {
+		t.Errorf("matching database reports %v", issueKinds(issues))
+	}
+
+	actual := liveSchema(expected)
+	actual["payments"].Column("reservation_id").Length = 64
+	actual["reservations"].Columns = actual["reservations"].Columns[1:]
+	actual["refunds"].Column("amount").Scale = 0
+	actual["payment_events"].Column("payload").Nullable = false
+	actual["courts"].Columns = append(actual["courts"].Columns, &ColumnSchema{Name: "legacy", Type: "text", Nullable: true})
+	actual["reservation_series"].Column("weekday").Type = "smallint"
+	actual["timeslots"].Column("id").Type = "text"
+	delete(actual, "users")
+	actual["promo_codes"].Indexes[1].Unique = false
+	actual["pricing_rules"].Indexes = actual["pricing_rules"].Indexes[:1]
+	actual["refunds"].ForeignKeys = actual["refunds"].ForeignKeys[1:]
+	actual["idempotency_keys"].PrimaryKey = nil
+
+	issues := CompareSchema(expected, actual)
+	got := map[string]bool{}
+	warnings := 0
+	for _, issue := range issues {
+		got[issue.Kind+" "+issue.Table+"."+issue.Column] = true
+		if issue.Warning {
+			warnings++
+		}
+	}
+	for _, want := range []string{
+		"type_mismatch payments.reservation_id",
+		"missing_column reservations.id",
+		"type_mismatch refunds.amount",
+		"not_null payment_events.payload",
+		"extra_column courts.legacy",
+		"type_mismatch timeslots.id",
+		"missing_table users.",
+		"missing_unique promo_codes.",
+		"missing_index pricing_rules.",
+		"missing_foreign_key refunds.payment_id",
+		"missing_primary_key idempotency_keys.id",
+	} {
+		if !got[want] {
+			t.Errorf("%s not reported in %v", want, issueKinds(issues))
+		}
+	}
+	if got["type_mismatch reservation_series.weekday"] {
+		t.Error("narrower integer column reported as a mismatch")
+	}
+	if warnings != 1 {
+		t.Errorf("%d warnings, want 1 for the unmapped column", warnings)
+	}
+}
+
+type badIndex struct {
+	Id int `orm:"column(id);auto;pk"`
+}
+
+func (b *badIndex) TableIndex() [][]string { return [][]string{{"missing"}} }
+
+type badReference struct {
+	Id int `orm:"column(id);auto;pk"`
+}
+
+func (b *badReference) TableReferences() map[string]string { return map[string]string{"id": "courts"} }
+
+func TestModelSchemaRefusesBadModels(t *testing.T) {
+	if _, err := ModelSchema([]interface{}{&badIndex{}}); err == nil {
+		t.Error("index on an unknown column was accepted")
+	}
+	if _, err := ModelSchema([]interface{}{&badReference{}}); err == nil {
+		t.Error("malformed reference was accepted")
+	}
+}
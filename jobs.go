@@ -0,0 +1,208 @@
+package main
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"badminton-reservation-api/models"
+	"badminton-reservation-api/services/notification"
+	"badminton-reservation-api/services/outbox"
+	"badminton-reservation-api/services/payment"
+	"badminton-reservation-api/services/scheduler"
+	"badminton-reservation-api/services/webhook"
+	"badminton-reservation-api/utils"
+
+	"github.com/beego/beego/v2/client/orm"
+	"github.com/beego/beego/v2/core/logs"
+)
+
+// startScheduler registers the background jobs and starts running them. Every instance
+// starts a scheduler but only the one holding the scheduler lock runs jobs. Set
+// SCHEDULER_ENABLED=false to keep an instance out of the election entirely.
+func startScheduler() *scheduler.Scheduler {
+	if os.Getenv("SCHEDULER_ENABLED") == "false" {
+		logs.Info("SCHEDULER_ENABLED=false, background jobs do not run on this instance")
+		return nil
+	}
+	db, err := orm.GetDB("default")
+	if err != nil {
+		logs.Error("Scheduler not started:", err)
+		return nil
+	}
+
+	s := scheduler.New(db)
+	addJob(s, "expire_reservations", "5m", expireReservations)
+	addJob(s, "purge_idempotency_keys", "1h", purgeIdempotencyKeys)
+	addJob(s, "reconcile_payments", payment.ReconcileInterval().String(), reconcilePayments)
+	addJob(s, "reconcile_refunds", payment.ReconcileInterval().String(), reconcileRefunds)
+	addJob(s, "dispatch_outbox", "10s", dispatchOutbox)
+	addJob(s, "purge_outbox", "@daily", purgeOutbox)
+	addJob(s, "deliver_webhooks", "10s", deliverWebhooks)
+	addJob(s, "purge_webhook_deliveries", "@daily", purgeWebhookDeliveries)
+	if notifier != nil {
+		addJob(s, "remind_pending_payments", "2m", remindPendingPayments)
+		addJob(s, "remind_upcoming_games", "15m", remindUpcomingGames)
+	}
+	s.Start()
+	return s
+}
+
+// addJob registers a job on its schedule from JOB_<NAME>_SCHEDULE, falling back to def.
+// "off" disables the job.
+func addJob(s *scheduler.Scheduler, name, def string, run scheduler.JobFunc) {
+	key := "JOB_" + strings.ToUpper(name) + "_SCHEDULE"
+	spec := os.Getenv(key)
+	if spec == "" {
+		spec = def
+	}
+	if spec == "off" {
+		logs.Info("Job", name, "disabled by", key)
+		return
+	}
+	schedule, err := scheduler.ParseSchedule(spec)
+	if err != nil {
+		logs.Error("Job", name, "not scheduled,", key, ":", err)
+		return
+	}
+	if err := s.Register(name, schedule, run); err != nil {
+		logs.Error("Job", name, "not scheduled:", err)
+	}
+}
+
+// stopSchedulerOnSignal lets running jobs finish (up to SCHEDULER_SHUTDOWN_TIMEOUT, default
+// 30s) and releases the scheduler lock on SIGINT/SIGTERM, so another instance takes over
+// without waiting for this connection to time out
+func stopSchedulerOnSignal(s *scheduler.Scheduler) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	sig := <-signals
+
+	timeout := utils.EnvDuration("SCHEDULER_SHUTDOWN_TIMEOUT", 30*time.Second)
+	logs.Info("Received", sig.String()+", stopping background jobs...")
+	if err := s.Stop(timeout); err != nil {
+		logs.Warn("Scheduler stopped with", err)
+	}
+	os.Exit(0)
+}
+
+// expireReservations expires pending reservations whose payment window has passed
+func expireReservations(ctx context.Context) error {
+	return models.ExpireOldReservations(ctx)
+}
+
+// purgeIdempotencyKeys deletes stored responses past their expiry
+func purgeIdempotencyKeys(ctx context.Context) error {
+	purged, err := models.DeleteExpiredIdempotencyKeys()
+	if err == nil && purged > 0 {
+		logs.Info("Purged", purged, "expired idempotency keys")
+	}
+	return err
+}
+
+// reconcilePayments checks pending payments at the gateway in case a webhook was lost
+func reconcilePayments(ctx context.Context) error {
+	result, err := payment.ReconcilePendingPayments(ctx)
+	if err != nil {
+		return err
+	}
+	logs.Info("Payment reconciliation:", result.Checked, "checked,", result.Updated, "updated,", result.Expired, "expired,", result.Failed, "failed")
+	return nil
+}
+
+// reconcileRefunds settles refunds left pending because the gateway timed out or had not completed them
+func reconcileRefunds(ctx context.Context) error {
+	result, err := payment.ReconcilePendingRefunds(ctx)
+	if err == nil && result.Checked > 0 {
+		logs.Info("Refund reconciliation:", result.Checked, "checked,", result.Updated, "completed,", result.Failed, "failed")
+	}
+	return err
+}
+
+// notifier tells customers about their bookings; nil when no notification channel is configured
+var notifier *notification.Service
+
+// subscribeEventHandlers subscribes the outbox handlers of this process. Events are kept in
+// the outbox until every handler subscribed here has processed them.
+func subscribeEventHandlers() {
+	if os.Getenv("OUTBOX_LOG_EVENTS") == "true" {
+		_ = outbox.Subscribe("log", logEvent)
+	}
+	_ = outbox.Subscribe("webhooks", webhook.Fanout)
+	notifier = notification.FromEnv()
+	if notifier == nil {
+		logs.Info("No notification channel is configured (SMTP_HOST, SMS_PROVIDER_URL, WHATSAPP_PROVIDER_URL), customers are not notified")
+		return
+	}
+	logs.Info("Notifying customers over", strings.Join(notifier.ChannelNames(), ", "))
+	_ = outbox.Subscribe("notify", notifier.HandleEvent, notification.EventTypes()...)
+}
+
+// logEvent writes every domain event to the application log
+func logEvent(ctx context.Context, event *models.OutboxEvent) error {
+	logs.Info("Event", event.Id, event.EventType, event.AggregateType, event.AggregateId, event.Payload)
+	return nil
+}
+
+// dispatchOutbox delivers pending domain events to the subscribed handlers
+func dispatchOutbox(ctx context.Context) error {
+	result, err := outbox.Dispatch(ctx)
+	if err == nil && result.Claimed > 0 {
+		logs.Info("Outbox dispatch:", result.Claimed, "claimed,", result.Delivered, "delivered,", result.Retrying, "retrying,", result.Dead, "dead")
+	}
+	return err
+}
+
+// purgeOutbox deletes events delivered longer than OUTBOX_RETENTION (default 168h) ago;
+// dead events are kept until an admin retries them
+func purgeOutbox(ctx context.Context) error {
+	retention := utils.EnvDuration("OUTBOX_RETENTION", 7*24*time.Hour)
+	purged, err := models.DeleteDeliveredOutboxEvents(time.Now().Add(-retention))
+	if err == nil && purged > 0 {
+		logs.Info("Purged", purged, "delivered outbox events")
+	}
+	return err
+}
+
+// deliverWebhooks sends due deliveries to the partner webhook endpoints
+func deliverWebhooks(ctx context.Context) error {
+	result, err := webhook.Deliver(ctx)
+	if err == nil && result.Claimed > 0 {
+		logs.Info("Webhook delivery:", result.Claimed, "claimed,", result.Delivered, "delivered,", result.Retrying, "retrying,", result.Dead, "dead")
+	}
+	return err
+}
+
+// purgeWebhookDeliveries deletes deliveries delivered longer than WEBHOOK_RETENTION (default
+// 720h) ago with their attempts; dead deliveries are kept until replayed
+func purgeWebhookDeliveries(ctx context.Context) error {
+	retention := utils.EnvDuration("WEBHOOK_RETENTION", 30*24*time.Hour)
+	purged, err := models.DeleteDeliveredWebhookDeliveries(time.Now().Add(-retention))
+	if err == nil && purged > 0 {
+		logs.Info("Purged", purged, "delivered webhook deliveries")
+	}
+	return err
+}
+
+// remindPendingPayments reminds customers whose unpaid reservation expires within
+// PAYMENT_REMINDER_BEFORE (default 10m)
+func remindPendingPayments(ctx context.Context) error {
+	sent, err := notifier.SendPaymentReminders(ctx, utils.EnvDuration("PAYMENT_REMINDER_BEFORE", 10*time.Minute))
+	if sent > 0 {
+		logs.Info("Sent", sent, "payment reminder(s)")
+	}
+	return err
+}
+
+// remindUpcomingGames reminds customers whose paid game starts within GAME_REMINDER_BEFORE
+// (default 24h)
+func remindUpcomingGames(ctx context.Context) error {
+	sent, err := notifier.SendGameReminders(ctx, utils.EnvDuration("GAME_REMINDER_BEFORE", 24*time.Hour))
+	if sent > 0 {
+		logs.Info("Sent", sent, "game reminder(s)")
+	}
+	return err
+}
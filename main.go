@@ -1,13 +1,16 @@
 package main
 
 import (
+	"context"
+	"fmt"
 	"os"
 	"time"
 
 	"badminton-reservation-api/database"
+	"badminton-reservation-api/database/migrations"
 	"badminton-reservation-api/middleware"
-	"badminton-reservation-api/models"
 	_ "badminton-reservation-api/routers"
+	"badminton-reservation-api/services/auth"
 	"badminton-reservation-api/utils"
 
 	"github.com/beego/beego/v2/client/orm"
@@ -37,8 +40,6 @@ func init() {
 			dbInitialized = false
 		} else {
 			dbInitialized = true
-			// Start background job to expire old reservations only when DB is initialized
-			go expireReservationsJob()
 		}
 	}
 
@@ -81,37 +82,42 @@ func initDatabase() error {
 		orm.Debug = true
 	}
 
-	// Optionally run GORM migrations to apply schema (useful for cloud Postgres / Neon migrations)
-	// Enable by setting MIGRATE_WITH_GORM=true in environment
-	if os.Getenv("MIGRATE_WITH_GORM") == "true" {
-		logs.Info("MIGRATE_WITH_GORM=true, running GORM AutoMigrate...")
-		if err := database.RunGormMigrations(); err != nil {
-			logs.Error("GORM migration failed:", err)
+	// Optionally apply pending SQL migrations on startup (same as `go run ./cmd/migrate up`)
+	// Enable by setting MIGRATE_ON_START=true in environment
+	if os.Getenv("MIGRATE_ON_START") == "true" {
+		logs.Info("MIGRATE_ON_START=true, applying pending migrations...")
+		if err := runMigrations(); err != nil {
+			logs.Error("Migration failed:", err)
 			// Do not treat migration failure as fatal for startup
-		} else {
-			logs.Info("GORM migrations applied successfully")
 		}
 	}
 	return nil
 }
 
-// expireReservationsJob runs periodically to expire old pending reservations
-func expireReservationsJob() {
-	ticker := time.NewTicker(5 * time.Minute) // Run every 5 minutes
-	defer ticker.Stop()
-
-	for range ticker.C {
-		logs.Info("Running reservation expiration job...")
-		err := models.ExpireOldReservations()
-		if err != nil {
-			logs.Error("Error expiring reservations:", err)
-		} else {
-			logs.Info("Reservation expiration job completed")
-		}
+// runMigrations applies the embedded SQL migrations through the ORM's connection pool
+func runMigrations() error {
+	list, err := database.LoadMigrations(migrations.FS)
+	if err != nil {
+		return err
+	}
+	db, err := orm.GetDB("default")
+	if err != nil {
+		return err
 	}
+	applied, err := database.NewMigrator(db, list).Up(context.Background())
+	for _, m := range applied {
+		logs.Info(fmt.Sprintf("Applied migration %03d_%s", m.Version, m.Name))
+	}
+	return err
 }
 
 func main() {
+	// Every bearer token would be rejected without a usable signing key
+	if err := auth.CheckSecret(); err != nil {
+		logs.Critical("Invalid auth configuration:", err)
+		os.Exit(1)
+	}
+
 	// Get port from environment
 	port := os.Getenv("APP_PORT")
 	if port == "" {
@@ -135,11 +141,19 @@ func main() {
 	logs.Info("========================================")
 	logs.Info("API Endpoints:")
 	logs.Info("  GET  /health")
+	logs.Info("  POST /api/v1/auth/register | /api/v1/auth/login | /api/v1/auth/refresh")
+	logs.Info("      - Body: {name,email,password} | {email,password} | {refresh_token}")
+	logs.Info("  GET  /api/v1/auth/me (Bearer token)")
+	logs.Info("  GET|PUT /api/v1/auth/me/notification-preferences (Bearer token)")
+	logs.Info("      - Body: {channels:[email|sms|whatsapp],phone?,language?}")
 	logs.Info("  GET  /api/v1/dates")
 	logs.Info("      - Query: none (returns next N available dates, see MAX_BOOKING_DAYS_AHEAD env)")
 	logs.Info("  GET  /api/v1/timeslots?booking_date=YYYY-MM-DD&court_id=X")
 	logs.Info("      - Params: booking_date (required), court_id (required)")
 	logs.Info("      - Response: returns globally active timeslots and an 'available' boolean per timeslot; available=false means already booked for that date and court")
+	logs.Info("  GET  /api/v1/availability?from=YYYY-MM-DD&to=YYYY-MM-DD&court_id=X")
+	logs.Info("      - Params: all optional (defaults: from=today, to=from+6 days, all active courts; max 31 days)")
+	logs.Info("      - Response: court x date x timeslot availability matrix in one request")
 	logs.Info("  GET  /api/v1/timeslots/all")
 	logs.Info("      - Returns all defined timeslots")
 	logs.Info("  GET  /api/v1/courts?booking_date=YYYY-MM-DD&timeslot_id=X")
@@ -148,17 +162,65 @@ func main() {
 	logs.Info("      - Returns all active courts")
 	logs.Info("  POST /api/v1/reservations")
 	logs.Info("      - Body: {court_id,timeslot_id,booking_date,customer_name,customer_email,customer_phone,notes}")
+	logs.Info("      - Optional slots: [{court_id,timeslot_id}, ...] books consecutive timeslots/courts as one reservation")
+	logs.Info("      - Optional promo_code: discount is taken off total_price")
+	logs.Info("      - Optional Idempotency-Key header: a retry returns the original response (also on /payments/process)")
 	logs.Info("  GET  /api/v1/reservations/:id")
 	logs.Info("  GET  /api/v1/reservations/customer?email=you@example.com")
 	logs.Info("      - Query: email (required)")
+	logs.Info("  POST /api/v1/reservations/:id/status (staff or admin)")
+	logs.Info("      - Body: {status}")
+	logs.Info("  POST /api/v1/reservations/:id/cancel (Bearer token or X-Manage-Token)")
+	logs.Info("      - Body: {reason?}; refund owed follows CANCELLATION_POLICY (default 24:100,0:50)")
+	logs.Info("  POST /api/v1/series (Bearer token)")
+	logs.Info("      - Body: {court_id,timeslot_id,start_date,occurrences,weekday?,payment_mode?,customer_name,customer_email,customer_phone}")
+	logs.Info("      - Books the slot weekly; 409 lists every conflicting date and nothing is booked")
+	logs.Info("  GET  /api/v1/series/:id (Bearer token)")
+	logs.Info("  POST /api/v1/series/:id/cancel (Bearer token)")
+	logs.Info("      - Body: {reservation_id?} cancels one occurrence, empty body cancels the series")
+	logs.Info("  GET|POST /api/v1/admin/courts, PUT /api/v1/admin/courts/:id[/price] (admin)")
+	logs.Info("  POST /api/v1/admin/courts/:id/{activate,deactivate,maintenance} (admin)")
+	logs.Info("  GET|POST /api/v1/admin/timeslots, PUT|DELETE /api/v1/admin/timeslots/:id (admin)")
+	logs.Info("  POST /api/v1/admin/timeslots/:id/{activate,deactivate} (admin)")
+	logs.Info("  GET|POST /api/v1/admin/pricing-rules, PUT|DELETE /api/v1/admin/pricing-rules/:id (admin)")
+	logs.Info("      - Peak/weekend/holiday prices; slot price shows as 'price' in /timeslots and /availability")
+	logs.Info("  GET|POST /api/v1/admin/promo-codes, PUT|DELETE /api/v1/admin/promo-codes/:id (admin)")
+	logs.Info("  POST /api/v1/admin/payments/reconcile (admin)")
+	logs.Info("      - Checks stale pending payments at the gateway (also runs every RECONCILE_INTERVAL)")
+	logs.Info("  POST /api/v1/admin/payments/:id/refund, GET /api/v1/admin/payments/:id/refunds (admin)")
+	logs.Info("      - Body: {amount?,reason?}; omitted amount refunds everything still refundable")
+	logs.Info("  GET  /api/v1/admin/payment-events?order_id=&outcome=&needs_review=&limit=, POST /api/v1/admin/payment-events/:id/replay (admin)")
+	logs.Info("  POST /api/v1/admin/payment-events/:id/review (admin)")
+	logs.Info("      - Logged gateway notifications; redeliveries are not applied twice")
+	logs.Info("  GET  /api/v1/admin/jobs (admin)")
+	logs.Info("      - Background jobs with last run, last error and next run; schedules via JOB_<NAME>_SCHEDULE")
+	logs.Info("  GET  /api/v1/admin/outbox?status=&event_type=&aggregate_id=&limit=, POST /api/v1/admin/outbox/:id/retry (admin)")
+	logs.Info("      - Booking and payment domain events, delivered at least once to the subscribed handlers")
+	logs.Info("  GET  /api/v1/admin/notifications?reservation_id=&kind=&channel=&status=&limit= (admin)")
+	logs.Info("      - Customer notifications sent by email, SMS or WhatsApp and their outcome")
+	logs.Info("  GET|POST /api/v1/admin/webhooks, GET|PUT|DELETE /api/v1/admin/webhooks/:id, POST /api/v1/admin/webhooks/:id/rotate-secret (admin)")
+	logs.Info("      - Body: {url,description?,event_types?:[ReservationPaid,...],is_active?}")
+	logs.Info("  GET  /api/v1/admin/webhook-deliveries?endpoint_id=&status=&event_type=&limit=, GET /api/v1/admin/webhook-deliveries/:id (admin)")
+	logs.Info("  POST /api/v1/admin/webhook-deliveries/:id/replay (admin)")
+	logs.Info("      - Signed event deliveries to partner endpoints, retried with backoff until delivered or dead")
 	logs.Info("  POST /api/v1/payments/process")
-	logs.Info("      - Body: {reservation_id}")
+	logs.Info("      - Body: {reservation_id} or {series_id} for a prepaid series; optional gateway (midtrans|xendit, default PAYMENT_GATEWAY)")
 	logs.Info("  POST /api/v1/payments/callback")
-	logs.Info("      - Webhook endpoint for payment notifications from the gateway")
+	logs.Info("      - Webhook endpoint for payment notifications from Midtrans")
+	logs.Info("  POST /api/v1/payments/callback/:gateway")
+	logs.Info("      - Webhook endpoint per gateway, e.g. /payments/callback/xendit")
 	logs.Info("  GET  /api/v1/payments/:id")
 	logs.Info("      - id can be a payment ID or reservation ID")
 	logs.Info("========================================")
 
+	// Background jobs only run when DB is initialized, and on one instance at a time
+	if dbInitialized {
+		subscribeEventHandlers()
+		if s := startScheduler(); s != nil {
+			go stopSchedulerOnSignal(s)
+		}
+	}
+
 	// Run the application
 	web.Run(":" + port)
 }
@@ -0,0 +1,82 @@
+package middleware
+
+import (
+	"strings"
+
+	"badminton-reservation-api/services/auth"
+	"badminton-reservation-api/utils"
+
+	"github.com/beego/beego/v2/server/web"
+	"github.com/beego/beego/v2/server/web/context"
+)
+
+// claimsKey is the ctx.Input data key under which validated claims are stored
+const claimsKey = "auth_claims"
+
+// RequireRoles returns a filter that validates the bearer access token and only lets the
+// request through when the token's role is one of roles. With no roles any valid token is accepted.
+// Register it at web.BeforeExec so the CORS filter (BeforeRouter) has already run.
+func RequireRoles(roles ...string) web.FilterFunc {
+	return func(ctx *context.Context) {
+		// Never block CORS preflight requests
+		if strings.ToUpper(ctx.Input.Method()) == "OPTIONS" {
+			return
+		}
+
+		claims, ok := authenticate(ctx)
+		if !ok {
+			abortJSON(ctx, 401, "Missing or invalid bearer token")
+			return
+		}
+
+		if len(roles) > 0 && !hasRole(claims.Role, roles) {
+			abortJSON(ctx, 403, "You do not have permission to access this resource")
+			return
+		}
+	}
+}
+
+// OptionalAuth returns a filter that attaches claims when a valid bearer token is present
+// but lets anonymous requests through. Handlers decide what anonymous callers may do.
+func OptionalAuth() web.FilterFunc {
+	return func(ctx *context.Context) {
+		authenticate(ctx)
+	}
+}
+
+// CurrentClaims returns the claims attached by RequireRoles/OptionalAuth, or nil
+func CurrentClaims(ctx *context.Context) *auth.Claims {
+	if claims, ok := ctx.Input.GetData(claimsKey).(*auth.Claims); ok {
+		return claims
+	}
+	return nil
+}
+
+// authenticate parses the Authorization header and stores the claims on success
+func authenticate(ctx *context.Context) (*auth.Claims, bool) {
+	header := ctx.Input.Header("Authorization")
+	if len(header) < 7 || !strings.EqualFold(header[:7], "Bearer ") {
+		return nil, false
+	}
+	claims, err := auth.ParseToken(strings.TrimSpace(header[7:]), auth.TokenTypeAccess)
+	if err != nil {
+		return nil, false
+	}
+	ctx.Input.SetData(claimsKey, claims)
+	return claims, true
+}
+
+func hasRole(role string, roles []string) bool {
+	for _, r := range roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+// abortJSON writes a standard error response and stops the filter chain
+func abortJSON(ctx *context.Context, status int, message string) {
+	ctx.Output.SetStatus(status)
+	_ = ctx.Output.JSON(utils.Response{Success: false, Message: message}, false, false)
+}
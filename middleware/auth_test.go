@@ -0,0 +1,91 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"badminton-reservation-api/models"
+	"badminton-reservation-api/services/auth"
+
+	"github.com/beego/beego/v2/server/web/context"
+)
+
+func newTestContext(method, authorization string) (*context.Context, *httptest.ResponseRecorder) {
+	req := httptest.NewRequest(method, "/api/v1/reservations/r-1/status", nil)
+	if authorization != "" {
+		req.Header.Set("Authorization", authorization)
+	}
+	rec := httptest.NewRecorder()
+	ctx := context.NewContext()
+	ctx.Reset(rec, req)
+	return ctx, rec
+}
+
+func TestRequireRoles(t *testing.T) {
+	t.Setenv("JWT_SECRET", "test-secret-key-with-at-least-32-chars")
+	bearer := func(role string) string {
+		pair, err := auth.GenerateTokenPair("user-1", role+"@example.com", role)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return "Bearer " + pair.AccessToken
+	}
+	pair, err := auth.GenerateTokenPair("user-1", "budi@example.com", models.RoleAdmin)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	staffOrAdmin := []string{models.RoleStaff, models.RoleAdmin}
+	tests := []struct {
+		name          string
+		roles         []string
+		method        string
+		authorization string
+		want          int
+	}{
+		{"no token", staffOrAdmin, "POST", "", http.StatusUnauthorized},
+		{"not a bearer token", staffOrAdmin, "POST", "Basic dXNlcjpwYXNz", http.StatusUnauthorized},
+		{"refresh token", staffOrAdmin, "POST", "Bearer " + pair.RefreshToken, http.StatusUnauthorized},
+		{"customer", staffOrAdmin, "POST", bearer(models.RoleCustomer), http.StatusForbidden},
+		{"staff", staffOrAdmin, "POST", bearer(models.RoleStaff), 0},
+		{"admin", staffOrAdmin, "POST", bearer(models.RoleAdmin), 0},
+		{"staff on an admin route", []string{models.RoleAdmin}, "POST", bearer(models.RoleStaff), http.StatusForbidden},
+		{"any role when none required", nil, "GET", bearer(models.RoleCustomer), 0},
+		{"preflight", staffOrAdmin, "OPTIONS", "", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, rec := newTestContext(tt.method, tt.authorization)
+			RequireRoles(tt.roles...)(ctx)
+			if tt.want == 0 {
+				if ctx.ResponseWriter.Started {
+					t.Errorf("request blocked with %d", rec.Code)
+				}
+				return
+			}
+			if rec.Code != tt.want {
+				t.Errorf("status %d, want %d", rec.Code, tt.want)
+			}
+		})
+	}
+}
+
+func TestOptionalAuth(t *testing.T) {
+	t.Setenv("JWT_SECRET", "test-secret-key-with-at-least-32-chars")
+	pair, err := auth.GenerateTokenPair("user-1", "budi@example.com", models.RoleCustomer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, _ := newTestContext("POST", "Bearer "+pair.AccessToken)
+	OptionalAuth()(ctx)
+	if claims := CurrentClaims(ctx); claims == nil || claims.UserId() != "user-1" {
+		t.Errorf("claims are %+v", claims)
+	}
+
+	ctx, _ = newTestContext("POST", "Bearer "+pair.RefreshToken)
+	OptionalAuth()(ctx)
+	if ctx.ResponseWriter.Started || CurrentClaims(ctx) != nil {
+		t.Error("an invalid token was attached or blocked an anonymous request")
+	}
+}
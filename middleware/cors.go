@@ -38,8 +38,8 @@ func CORS(ctx *context.Context) {
 
 	ctx.Output.Header("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS, PATCH")
 	// Allow common request headers and the Access-Control request headers
-	ctx.Output.Header("Access-Control-Allow-Headers", "Content-Type, Authorization, X-Requested-With, Accept, Origin, Access-Control-Request-Method, Access-Control-Request-Headers")
-	ctx.Output.Header("Access-Control-Expose-Headers", "Content-Length, Content-Type")
+	ctx.Output.Header("Access-Control-Allow-Headers", "Content-Type, Authorization, X-Requested-With, Accept, Origin, Access-Control-Request-Method, Access-Control-Request-Headers, Idempotency-Key")
+	ctx.Output.Header("Access-Control-Expose-Headers", "Content-Length, Content-Type, Idempotent-Replayed")
 	ctx.Output.Header("Access-Control-Allow-Credentials", "true")
 	ctx.Output.Header("Access-Control-Max-Age", "86400")
 
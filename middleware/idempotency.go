@@ -0,0 +1,225 @@
+package middleware
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"badminton-reservation-api/models"
+	"badminton-reservation-api/services/auth"
+	"badminton-reservation-api/utils"
+
+	"github.com/beego/beego/v2/core/logs"
+	"github.com/beego/beego/v2/server/web"
+	"github.com/beego/beego/v2/server/web/context"
+)
+
+// IdempotencyHeader is the request header carrying the client's idempotency key
+const IdempotencyHeader = "Idempotency-Key"
+
+// idempotencyKey is the ctx.Input data key of the key claimed by the current request
+const idempotencyKey = "idempotency_key"
+
+const (
+	defaultIdempotencyTTL = 24 * time.Hour
+	maxIdempotencyKeyLen  = 255
+)
+
+// IdempotencyTTL is how long a key and its stored response are kept (env IDEMPOTENCY_KEY_TTL,
+// a Go duration such as 24h)
+func IdempotencyTTL() time.Duration {
+	return utils.EnvDuration("IDEMPOTENCY_KEY_TTL", defaultIdempotencyTTL)
+}
+
+// claimedKey is the key claimed by a request, kept until its response is stored
+type claimedKey struct {
+	key      string
+	scope    string
+	recorder *responseRecorder
+}
+
+// responseRecorder copies the response body while it is written to the client
+type responseRecorder struct {
+	http.ResponseWriter
+	body bytes.Buffer
+}
+
+func (r *responseRecorder) Write(p []byte) (int, error) {
+	r.body.Write(p)
+	return r.ResponseWriter.Write(p)
+}
+
+// RegisterIdempotency makes POST requests to pattern honour the Idempotency-Key header: the
+// first request with a key runs normally and its response is stored; a retry with the same
+// key and body gets the stored response without running the handler again, and reusing the
+// key with a different body is rejected with 422. Keys are scoped to the caller, so the same
+// key sent by another account or client is a different key. Requests without the header are
+// unaffected. Register it after the route's auth filter so the caller's claims are known.
+func RegisterIdempotency(pattern string) {
+	registerIdempotency(web.BeeApp.Handlers, pattern)
+}
+
+func registerIdempotency(handlers *web.ControllerRegister, pattern string) {
+	handlers.InsertFilter(pattern, web.BeforeExec, beginIdempotent)
+	// A chain rather than a FinishRouter filter, which Beego skips when the handler panics
+	handlers.InsertFilterChain(pattern, finishIdempotent)
+}
+
+// idempotencyScope is the endpoint and the caller a key belongs to: the authenticated account,
+// or for anonymous callers a fingerprint of their address and user agent
+func idempotencyScope(ctx *context.Context) string {
+	scope := ctx.Input.Method() + " " + ctx.Input.URL()
+	if claims := CurrentClaims(ctx); claims != nil {
+		return scope + " user:" + claims.UserId()
+	}
+	sum := sha256.Sum256([]byte(ctx.Input.IP() + "|" + ctx.Input.UserAgent()))
+	return scope + " client:" + hex.EncodeToString(sum[:8])
+}
+
+// beginIdempotent claims the request's key or answers from the stored response
+func beginIdempotent(ctx *context.Context) {
+	key := strings.TrimSpace(ctx.Input.Header(IdempotencyHeader))
+	if key == "" || ctx.Input.Method() != http.MethodPost {
+		return
+	}
+	if len(key) > maxIdempotencyKeyLen {
+		abortJSON(ctx, 400, "Idempotency-Key must be at most 255 characters")
+		return
+	}
+
+	scope := idempotencyScope(ctx)
+	sum := sha256.Sum256(ctx.Input.RequestBody)
+	requestHash := hex.EncodeToString(sum[:])
+
+	existing, err := models.ClaimIdempotencyKey(key, scope, requestHash, IdempotencyTTL())
+	if err != nil {
+		logs.Error("Error claiming idempotency key:", err)
+		abortJSON(ctx, 500, "Error processing Idempotency-Key")
+		return
+	}
+
+	if existing == nil {
+		recorder := &responseRecorder{ResponseWriter: ctx.ResponseWriter.ResponseWriter}
+		ctx.ResponseWriter.ResponseWriter = recorder
+		ctx.Input.SetData(idempotencyKey, &claimedKey{key: key, scope: scope, recorder: recorder})
+		return
+	}
+
+	switch {
+	case existing.RequestHash != requestHash:
+		abortJSON(ctx, 422, "Idempotency-Key was already used with a different request body")
+	case existing.Status != models.IdempotencyCompleted:
+		abortJSON(ctx, 409, "A request with this Idempotency-Key is still being processed")
+	default:
+		ctx.Output.Header("Content-Type", "application/json; charset=utf-8")
+		ctx.Output.Header("Idempotent-Replayed", "true")
+		ctx.Output.SetStatus(existing.ResponseStatus)
+		_ = ctx.Output.Body(replayedBody(existing.ResponseBody))
+	}
+}
+
+// finishIdempotent runs the request and then stores the response of a request that claimed a
+// key. Server errors are not stored so the client can retry with the same key; neither is a
+// request whose handler panicked, whether or not Beego recovered the panic.
+func finishIdempotent(next web.FilterFunc) web.FilterFunc {
+	return func(ctx *context.Context) {
+		defer func() {
+			if p := recover(); p != nil {
+				releaseIdempotent(ctx)
+				panic(p)
+			}
+		}()
+		next(ctx)
+		storeIdempotent(ctx)
+	}
+}
+
+func storeIdempotent(ctx *context.Context) {
+	claimed, ok := ctx.Input.GetData(idempotencyKey).(*claimedKey)
+	if !ok {
+		return
+	}
+
+	status := ctx.ResponseWriter.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	if status >= 500 {
+		releaseIdempotent(ctx)
+		return
+	}
+	if err := models.CompleteIdempotencyKey(claimed.key, claimed.scope, status, storedBody(claimed.recorder.body.Bytes())); err != nil {
+		logs.Error("Error storing idempotent response:", err)
+	}
+}
+
+func releaseIdempotent(ctx *context.Context) {
+	claimed, ok := ctx.Input.GetData(idempotencyKey).(*claimedKey)
+	if !ok {
+		return
+	}
+	if err := models.ReleaseIdempotencyKey(claimed.key, claimed.scope); err != nil {
+		logs.Error("Error releasing idempotency key:", err)
+	}
+}
+
+// storedBody is the response body as stored with its key. A reservation's manage_token grants
+// access to the booking, so it is blanked; replayedBody signs it again from the reservation id.
+func storedBody(body []byte) string {
+	envelope, data, ok := responseData(body)
+	if !ok || data["manage_token"] == nil {
+		return string(body)
+	}
+	data["manage_token"] = json.RawMessage(`""`)
+	stored, err := rewriteData(envelope, data)
+	if err != nil {
+		// Never store the token, even if the body cannot be rewritten
+		return ""
+	}
+	return string(stored)
+}
+
+// replayedBody restores the manage_token blanked by storedBody
+func replayedBody(stored string) []byte {
+	envelope, data, ok := responseData([]byte(stored))
+	if !ok || string(data["manage_token"]) != `""` {
+		return []byte(stored)
+	}
+	var id string
+	if json.Unmarshal(data["id"], &id) != nil || id == "" {
+		return []byte(stored)
+	}
+	token, err := auth.ManageToken(id)
+	if err != nil {
+		logs.Warn("Could not sign manage token for replayed response:", err)
+		delete(data, "manage_token")
+	} else {
+		data["manage_token"], _ = json.Marshal(token)
+	}
+	replayed, err := rewriteData(envelope, data)
+	if err != nil {
+		return []byte(stored)
+	}
+	return replayed
+}
+
+// responseData splits a utils.Response body into its fields and the fields of its data object
+func responseData(body []byte) (envelope, data map[string]json.RawMessage, ok bool) {
+	if json.Unmarshal(body, &envelope) != nil || json.Unmarshal(envelope["data"], &data) != nil || data == nil {
+		return nil, nil, false
+	}
+	return envelope, data, true
+}
+
+func rewriteData(envelope, data map[string]json.RawMessage) ([]byte, error) {
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+	envelope["data"] = raw
+	return json.Marshal(envelope)
+}
@@ -0,0 +1,231 @@
+package middleware
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"badminton-reservation-api/models"
+	"badminton-reservation-api/services/auth"
+	"badminton-reservation-api/utils"
+
+	"github.com/beego/beego/v2/client/orm"
+	"github.com/beego/beego/v2/server/web"
+	"github.com/google/uuid"
+)
+
+const testReservationId = "3f2c9a1e-7b4d-4c1a-9e2f-5d6c7b8a9f01"
+
+// idempotencyTestController counts how often its handlers really run
+type idempotencyTestController struct {
+	web.Controller
+}
+
+var handlerRuns int
+
+func (c *idempotencyTestController) Create() {
+	handlerRuns++
+	token, _ := auth.ManageToken(testReservationId)
+	c.Ctx.Output.SetStatus(201)
+	utils.SendSuccess(&c.Controller, "Created", map[string]interface{}{"id": testReservationId, "run": handlerRuns, "manage_token": token})
+}
+
+func (c *idempotencyTestController) Fail() {
+	handlerRuns++
+	utils.SendInternalError(&c.Controller, "Database unavailable", nil)
+}
+
+func (c *idempotencyTestController) Crash() {
+	handlerRuns++
+	panic("handler crashed")
+}
+
+func newIdempotencyRouter() http.Handler {
+	handler := web.NewControllerRegister()
+	for pattern, methods := range map[string]string{"/things": "post:Create", "/failing": "post:Fail", "/crashing": "post:Crash"} {
+		c := &idempotencyTestController{}
+		handler.Add(pattern, c, web.WithRouterMethods(c, methods))
+		registerIdempotency(handler, pattern)
+	}
+	handler.Init()
+	return handler
+}
+
+// newKey returns an idempotency key of the test's own, deleted when t ends
+func newKey(t *testing.T) string {
+	key := uuid.New().String()
+	t.Cleanup(func() {
+		orm.NewOrm().Raw("DELETE FROM idempotency_keys WHERE idempotency_key = ?", key).Exec()
+	})
+	return key
+}
+
+func post(handler http.Handler, url, key, body, userAgent string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, url, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(IdempotencyHeader, key)
+	req.Header.Set("User-Agent", userAgent)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestIdempotencyScope(t *testing.T) {
+	t.Setenv("JWT_SECRET", "test-secret-key-with-at-least-32-chars")
+	scope := func(userAgent, authorization string) string {
+		ctx, _ := newTestContext(http.MethodPost, authorization)
+		ctx.Request.Header.Set("User-Agent", userAgent)
+		OptionalAuth()(ctx)
+		return idempotencyScope(ctx)
+	}
+	pair, err := auth.GenerateTokenPair("user-1", "budi@example.com", models.RoleCustomer)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	anonymous := scope("app/1.0", "")
+	if !strings.HasPrefix(anonymous, "POST /api/v1/reservations/r-1/status client:") {
+		t.Errorf("anonymous scope %q", anonymous)
+	}
+	if other := scope("curl/8.0", ""); other == anonymous {
+		t.Error("anonymous clients with different user agents share a scope")
+	}
+	if user := scope("app/1.0", "Bearer "+pair.AccessToken); user != "POST /api/v1/reservations/r-1/status user:user-1" {
+		t.Errorf("authenticated scope %q", user)
+	}
+}
+
+func TestStoredBody(t *testing.T) {
+	t.Setenv("JWT_SECRET", "test-secret-key-with-at-least-32-chars")
+	token, err := auth.ManageToken(testReservationId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body := `{"success":true,"message":"Created","data":{"id":"` + testReservationId + `","total_price":"150000.00","manage_token":"` + token + `"}}`
+
+	stored := storedBody([]byte(body))
+	if strings.Contains(stored, token) || !strings.Contains(stored, `"total_price":"150000.00"`) {
+		t.Errorf("stored body %s, want the token blanked and the rest kept", stored)
+	}
+	var replayed utils.Response
+	if err := json.Unmarshal(replayedBody(stored), &replayed); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := replayed.Data.(map[string]interface{}); data["manage_token"] != token {
+		t.Errorf("replayed data %v, want the manage token signed again", replayed.Data)
+	}
+
+	for _, unchanged := range []string{`{"success":true,"data":{"id":"x"}}`, `{"success":false,"message":"Slot taken"}`, `not json`} {
+		if got := storedBody([]byte(unchanged)); got != unchanged {
+			t.Errorf("stored %s as %s", unchanged, got)
+		}
+		if got := string(replayedBody(unchanged)); got != unchanged {
+			t.Errorf("replayed %s as %s", unchanged, got)
+		}
+	}
+}
+
+func TestIdempotentReplay(t *testing.T) {
+	requireDB(t)
+	t.Setenv("JWT_SECRET", "test-secret-key-with-at-least-32-chars")
+	handler := newIdempotencyRouter()
+	key := newKey(t)
+	handlerRuns = 0
+
+	first := post(handler, "/things", key, `{"court_id":1}`, "app/1.0")
+	if first.Code != 201 || handlerRuns != 1 {
+		t.Fatalf("first request: %d after %d runs", first.Code, handlerRuns)
+	}
+
+	retry := post(handler, "/things", key, `{"court_id":1}`, "app/1.0")
+	if retry.Code != 201 || handlerRuns != 1 || retry.Header().Get("Idempotent-Replayed") != "true" {
+		t.Errorf("retry: %d after %d runs, replayed %q; want the stored 201 without running again", retry.Code, handlerRuns, retry.Header().Get("Idempotent-Replayed"))
+	}
+	if !jsonEqual(t, retry.Body.Bytes(), first.Body.Bytes()) {
+		t.Errorf("retry body %s, want %s", retry.Body.String(), first.Body.String())
+	}
+
+	var storedResponse string
+	if err := orm.NewOrm().Raw("SELECT response_body FROM idempotency_keys WHERE idempotency_key = ?", key).QueryRow(&storedResponse); err != nil {
+		t.Fatal(err)
+	}
+	token, _ := auth.ManageToken(testReservationId)
+	if strings.Contains(storedResponse, token) {
+		t.Error("the manage token was stored with the response")
+	}
+
+	mismatch := post(handler, "/things", key, `{"court_id":2}`, "app/1.0")
+	if mismatch.Code != 422 || handlerRuns != 1 {
+		t.Errorf("different body: %d after %d runs, want 422 without running", mismatch.Code, handlerRuns)
+	}
+
+	other := post(handler, "/things", key, `{"court_id":1}`, "other-client/1.0")
+	if other.Code != 201 || handlerRuns != 2 || other.Header().Get("Idempotent-Replayed") != "" {
+		t.Errorf("another client with the same key: %d after %d runs; want its own request to run", other.Code, handlerRuns)
+	}
+}
+
+func TestIdempotencyKeyExpiry(t *testing.T) {
+	requireDB(t)
+	handler := newIdempotencyRouter()
+	key := newKey(t)
+	handlerRuns = 0
+
+	if rec := post(handler, "/things", key, `{}`, "app/1.0"); rec.Code != 201 {
+		t.Fatalf("first request: %d", rec.Code)
+	}
+	if _, err := orm.NewOrm().Raw("UPDATE idempotency_keys SET expires_at = now() - interval '1 minute' WHERE idempotency_key = ?", key).Exec(); err != nil {
+		t.Fatal(err)
+	}
+	rec := post(handler, "/things", key, `{"changed":true}`, "app/1.0")
+	if rec.Code != 201 || handlerRuns != 2 || rec.Header().Get("Idempotent-Replayed") != "" {
+		t.Errorf("after expiry: %d after %d runs; want the key claimed again", rec.Code, handlerRuns)
+	}
+}
+
+func TestIdempotencyServerErrorsNotStored(t *testing.T) {
+	requireDB(t)
+	handler := newIdempotencyRouter()
+	tests := []struct {
+		name string
+		url  string
+	}{
+		{"error response", "/failing"},
+		{"handler panic", "/crashing"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key := newKey(t)
+			handlerRuns = 0
+			for attempt := 1; attempt <= 2; attempt++ {
+				rec := post(handler, tt.url, key, `{}`, "app/1.0")
+				if rec.Code != 500 || handlerRuns != attempt {
+					t.Fatalf("attempt %d: %d after %d runs; want every retry to run again", attempt, rec.Code, handlerRuns)
+				}
+			}
+			var cnt int
+			if err := orm.NewOrm().Raw("SELECT COUNT(*) FROM idempotency_keys WHERE idempotency_key = ?", key).QueryRow(&cnt); err != nil {
+				t.Fatal(err)
+			}
+			if cnt != 0 {
+				t.Errorf("%d keys left behind", cnt)
+			}
+		})
+	}
+}
+
+func jsonEqual(t *testing.T, a, b []byte) bool {
+	t.Helper()
+	var x, y interface{}
+	if err := json.Unmarshal(a, &x); err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(b, &y); err != nil {
+		t.Fatal(err)
+	}
+	xs, _ := json.Marshal(x)
+	ys, _ := json.Marshal(y)
+	return string(xs) == string(ys)
+}
@@ -0,0 +1,57 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"testing"
+
+	"badminton-reservation-api/database"
+	"badminton-reservation-api/database/migrations"
+
+	"github.com/beego/beego/v2/client/orm"
+	"github.com/beego/beego/v2/server/web"
+)
+
+// testDB is set when TEST_DATABASE_URL points at a Postgres database the tests may write to.
+// Migrations are applied once on start; tests that need the database call requireDB.
+var testDB bool
+
+func TestMain(m *testing.M) {
+	web.BConfig.CopyRequestBody = true
+	if dataSource := os.Getenv("TEST_DATABASE_URL"); dataSource != "" {
+		if err := openTestDB(dataSource); err != nil {
+			fmt.Fprintln(os.Stderr, "test database:", err)
+			os.Exit(1)
+		}
+		testDB = true
+	}
+	os.Exit(m.Run())
+}
+
+func openTestDB(dataSource string) error {
+	if err := orm.RegisterDriver("postgres", orm.DRPostgres); err != nil {
+		return err
+	}
+	if err := orm.RegisterDataBase("default", "postgres", dataSource); err != nil {
+		return err
+	}
+	list, err := database.LoadMigrations(migrations.FS)
+	if err != nil {
+		return err
+	}
+	db, err := orm.GetDB("default")
+	if err != nil {
+		return err
+	}
+	_, err = database.NewMigrator(db, list).Up(context.Background())
+	return err
+}
+
+// requireDB skips t unless TEST_DATABASE_URL is set
+func requireDB(t *testing.T) {
+	t.Helper()
+	if !testDB {
+		t.Skip("TEST_DATABASE_URL is not set")
+	}
+}
@@ -0,0 +1,143 @@
+package models
+
+import (
+	"time"
+
+	"github.com/beego/beego/v2/client/orm"
+)
+
+// AvailabilityGrid is a court × date × timeslot availability matrix
+type AvailabilityGrid struct {
+	From      string               `json:"from"`
+	To        string               `json:"to"`
+	Timeslots []*Timeslot          `json:"timeslots"`
+	Courts    []*AvailabilityCourt `json:"courts"`
+}
+
+type AvailabilityCourt struct {
+	Id           int                 `json:"id"`
+	Name         string              `json:"name"`
+	Status       string              `json:"status"`
+	PricePerHour Money               `json:"price_per_hour"`
+	Dates        []*AvailabilityDate `json:"dates"`
+}
+
+type AvailabilityDate struct {
+	Date  string              `json:"date"`
+	Slots []*AvailabilitySlot `json:"slots"`
+}
+
+type AvailabilitySlot struct {
+	TimeslotId int    `json:"timeslot_id"`
+	StartTime  string `json:"start_time"`
+	EndTime    string `json:"end_time"`
+	Available  bool   `json:"available"`
+	Price      Money  `json:"price"`
+}
+
+// bookedSlot is one occupied court/timeslot/date combination
+type bookedSlot struct {
+	CourtId     int
+	TimeslotId  int
+	BookingDate string
+}
+
+type slotKey struct {
+	courtId    int
+	timeslotId int
+	date       string
+}
+
+// GetAvailabilityGrid builds the availability matrix for active timeslots on every date in
+// [from, to] (YYYY-MM-DD, inclusive). With courtId > 0 only that court is included (whatever
+// its status; non-active courts are reported fully unavailable), otherwise all active courts.
+// It always runs exactly three queries regardless of the size of the grid.
+func GetAvailabilityGrid(from string, to string, courtId int) (*AvailabilityGrid, error) {
+	o := orm.NewOrm()
+
+	// 1. Courts
+	var courts []*Court
+	qs := o.QueryTable(new(Court))
+	if courtId > 0 {
+		qs = qs.Filter("id", courtId)
+	} else {
+		qs = qs.Filter("status", CourtActive)
+	}
+	if _, err := qs.OrderBy("id").All(&courts); err != nil {
+		return nil, err
+	}
+
+	// 2. Globally active timeslots
+	var slots []*Timeslot
+	if _, err := o.QueryTable(new(Timeslot)).Filter("is_active", true).OrderBy("start_time").All(&slots); err != nil {
+		return nil, err
+	}
+
+	// 3. Everything occupied in the range: explicit unavailable marks plus slot-holding reservation items
+	courtFilter := ""
+	args := []interface{}{from, to}
+	if courtId > 0 {
+		courtFilter = " AND court_id = ?"
+		args = append(args, courtId)
+	}
+	args = append(args, from, to)
+	if courtId > 0 {
+		args = append(args, courtId)
+	}
+	var booked []bookedSlot
+	_, err := o.Raw(`SELECT court_id, timeslot_id, booking_date FROM timeslot_availabilities
+		WHERE is_active = false AND booking_date BETWEEN ? AND ?`+courtFilter+`
+		UNION
+		SELECT court_id, timeslot_id, booking_date FROM reservation_items
+		WHERE holds_slot = true AND booking_date BETWEEN ? AND ?`+courtFilter, args...).QueryRows(&booked)
+	if err != nil {
+		return nil, err
+	}
+
+	taken := make(map[slotKey]bool, len(booked))
+	for _, b := range booked {
+		taken[slotKey{b.CourtId, b.TimeslotId, b.BookingDate}] = true
+	}
+
+	dates, err := dateRange(from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	grid := &AvailabilityGrid{From: from, To: to, Timeslots: slots, Courts: make([]*AvailabilityCourt, 0, len(courts))}
+	for _, c := range courts {
+		ac := &AvailabilityCourt{Id: c.Id, Name: c.Name, Status: c.Status, PricePerHour: c.PricePerHour, Dates: make([]*AvailabilityDate, 0, len(dates))}
+		for _, d := range dates {
+			ad := &AvailabilityDate{Date: d, Slots: make([]*AvailabilitySlot, 0, len(slots))}
+			for _, s := range slots {
+				ad.Slots = append(ad.Slots, &AvailabilitySlot{
+					TimeslotId: s.Id,
+					StartTime:  s.StartTime,
+					EndTime:    s.EndTime,
+					Available:  c.Status == CourtActive && !taken[slotKey{c.Id, s.Id, d}],
+				})
+			}
+			ac.Dates = append(ac.Dates, ad)
+		}
+		grid.Courts = append(grid.Courts, ac)
+	}
+
+	return grid, nil
+}
+
+// dateRange returns every YYYY-MM-DD date from from to to inclusive
+func dateRange(from string, to string) ([]string, error) {
+	start, err := time.Parse("2006-01-02", from)
+	if err != nil {
+		return nil, err
+	}
+	end, err := time.Parse("2006-01-02", to)
+	if err != nil {
+		return nil, err
+	}
+	var dates []string
+	for d := start; !d.After(end); d = d.AddDate(0, 0, 1) {
+		dates = append(dates, d.Format("2006-01-02"))
+	}
+	return dates, nil
+}
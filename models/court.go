@@ -6,11 +6,18 @@ import (
 	"github.com/beego/beego/v2/client/orm"
 )
 
+// Court statuses (see courts.status CHECK constraint)
+const (
+	CourtActive      = "active"
+	CourtInactive    = "inactive"
+	CourtMaintenance = "maintenance"
+)
+
 type Court struct {
 	Id           int       `orm:"column(id);auto;pk" json:"id"`
 	Name         string    `orm:"column(name);size(100)" json:"name"`
 	Description  string    `orm:"column(description);type(text);null" json:"description"`
-	PricePerHour float64   `orm:"column(price_per_hour);digits(10);decimals(2)" json:"price_per_hour"`
+	PricePerHour Money     `orm:"column(price_per_hour);digits(10);decimals(2)" json:"price_per_hour"`
 	Status       string    `orm:"column(status);size(20);default(active)" json:"status"`
 	CreatedAt    time.Time `orm:"column(created_at);auto_now_add;type(datetime)" json:"created_at"`
 	UpdatedAt    time.Time `orm:"column(updated_at);auto_now;type(datetime)" json:"updated_at"`
@@ -20,18 +27,76 @@ func (c *Court) TableName() string {
 	return "courts"
 }
 
+func (c *Court) TableIndex() [][]string {
+	return [][]string{{"status"}}
+}
+
 func init() {
-	orm.RegisterModel(new(Court))
+	registerModels(new(Court))
 }
 
 // GetAllActiveCourts retrieves all active courts
 func GetAllActiveCourts() ([]*Court, error) {
 	o := orm.NewOrm()
 	var courts []*Court
-	_, err := o.QueryTable(new(Court)).Filter("status", "active").All(&courts)
+	_, err := o.QueryTable(new(Court)).Filter("status", CourtActive).All(&courts)
 	return courts, err
 }
 
+// GetAllCourts retrieves all courts regardless of status
+func GetAllCourts() ([]*Court, error) {
+	o := orm.NewOrm()
+	var courts []*Court
+	_, err := o.QueryTable(new(Court)).OrderBy("id").All(&courts)
+	return courts, err
+}
+
+// IsValidCourtStatus reports whether status is allowed by the courts CHECK constraint
+func IsValidCourtStatus(status string) bool {
+	switch status {
+	case CourtActive, CourtInactive, CourtMaintenance:
+		return true
+	}
+	return false
+}
+
+// CreateCourt inserts a new court and sets its generated id
+func CreateCourt(c *Court) error {
+	o := orm.NewOrm()
+	if c.Status == "" {
+		c.Status = CourtActive
+	}
+	// Use RETURNING instead of LastInsertId, which the Postgres driver does not support
+	return o.Raw(`INSERT INTO courts (name, description, price_per_hour, status, created_at, updated_at)
+		VALUES (?, ?, ?, ?, now(), now()) RETURNING id`, c.Name, c.Description, c.PricePerHour.String(), c.Status).QueryRow(&c.Id)
+}
+
+// UpdateCourt saves the given columns of a court (all columns when none are given)
+func UpdateCourt(c *Court, cols ...string) error {
+	o := orm.NewOrm()
+	_, err := o.Update(c, cols...)
+	return err
+}
+
+// GetFuturePaidReservationsByCourt returns paid reservations with an item on the court from
+// today onwards. Used to report conflicts before a court is taken out of service.
+func GetFuturePaidReservationsByCourt(courtId int) ([]*Reservation, error) {
+	o := orm.NewOrm()
+	var list []*Reservation
+	today := time.Now().Format("2006-01-02")
+	_, err := o.Raw(`SELECT r.* FROM reservations r
+		WHERE r.status = ?
+		AND r.id IN (
+			SELECT ri.reservation_id FROM reservation_items ri
+			WHERE ri.court_id = ? AND ri.booking_date >= ?
+		)
+		ORDER BY r.booking_date, r.timeslot_id`, ReservationPaid, courtId, today).QueryRows(&list)
+	if err != nil {
+		return nil, err
+	}
+	return list, attachReservationItems(list)
+}
+
 // GetCourtById retrieves a court by ID
 func GetCourtById(id int) (*Court, error) {
 	o := orm.NewOrm()
@@ -53,10 +118,10 @@ func GetAvailableCourts(bookingDate string, timeslotId int) ([]*Court, error) {
 		SELECT c.* FROM courts c
 		WHERE c.status = 'active'
 		AND c.id NOT IN (
-			SELECT r.court_id FROM reservations r
-			WHERE r.booking_date = ?
-			AND r.timeslot_id = ?
-			AND r.status IN ('pending', 'waiting_payment', 'paid')
+			SELECT ri.court_id FROM reservation_items ri
+			WHERE ri.booking_date = ?
+			AND ri.timeslot_id = ?
+			AND ri.holds_slot = true
 		)
 		ORDER BY c.id
 	`, bookingDate, timeslotId).QueryRows(&courts)
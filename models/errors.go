@@ -0,0 +1,48 @@
+package models
+
+import (
+	"errors"
+
+	"github.com/lib/pq"
+)
+
+// Postgres SQLSTATE codes we map to domain errors
+const (
+	pqUniqueViolation     = "23505"
+	pqForeignKeyViolation = "23503"
+)
+
+// isUniqueViolation reports whether err is a Postgres unique violation on the given
+// constraint/index. An empty constraint matches any unique violation.
+func isUniqueViolation(err error, constraint string) bool {
+	var pqErr *pq.Error
+	if !errors.As(err, &pqErr) {
+		return false
+	}
+	if string(pqErr.Code) != pqUniqueViolation {
+		return false
+	}
+	return constraint == "" || pqErr.Constraint == constraint
+}
+
+// isForeignKeyViolation reports whether err is a Postgres foreign key violation
+func isForeignKeyViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && string(pqErr.Code) == pqForeignKeyViolation
+}
+
+// nullString maps an empty string to SQL NULL for optional foreign key columns
+func nullString(s string) interface{} {
+	if s == "" {
+		return nil
+	}
+	return s
+}
+
+// nullInt maps a nil pointer to SQL NULL for optional integer foreign key columns
+func nullInt(v *int) interface{} {
+	if v == nil {
+		return nil
+	}
+	return *v
+}
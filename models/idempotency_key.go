@@ -0,0 +1,99 @@
+package models
+
+import (
+	"context"
+	"time"
+
+	"github.com/beego/beego/v2/client/orm"
+)
+
+// Idempotency key statuses
+const (
+	IdempotencyInProgress = "in_progress"
+	IdempotencyCompleted  = "completed"
+)
+
+// IdempotencyKey is a client-supplied key and the response of the request first sent with it
+type IdempotencyKey struct {
+	Id             int64     `orm:"column(id);auto;pk" json:"id"`
+	Key            string    `orm:"column(idempotency_key);size(255)" json:"idempotency_key"`
+	Scope          string    `orm:"column(scope);size(255)" json:"scope"`
+	RequestHash    string    `orm:"column(request_hash);size(64)" json:"request_hash"`
+	Status         string    `orm:"column(status);size(20)" json:"status"`
+	ResponseStatus int       `orm:"column(response_status);null" json:"response_status"`
+	ResponseBody   string    `orm:"column(response_body);type(text);null" json:"response_body"`
+	CreatedAt      time.Time `orm:"column(created_at);auto_now_add;type(datetime)" json:"created_at"`
+	ExpiresAt      time.Time `orm:"column(expires_at);type(datetime)" json:"expires_at"`
+}
+
+func (k *IdempotencyKey) TableName() string {
+	return "idempotency_keys"
+}
+
+func (k *IdempotencyKey) TableIndex() [][]string {
+	return [][]string{{"expires_at"}}
+}
+
+func (k *IdempotencyKey) TableUnique() [][]string {
+	return [][]string{{"scope", "idempotency_key"}}
+}
+
+func init() {
+	registerModels(new(IdempotencyKey))
+}
+
+// ClaimIdempotencyKey reserves key within scope for a new request. It returns nil, nil when
+// the key was claimed and the request should run; otherwise it returns the existing entry
+// (in progress or completed). An expired entry is discarded and the key claimed again.
+func ClaimIdempotencyKey(key, scope, requestHash string, ttl time.Duration) (*IdempotencyKey, error) {
+	o := orm.NewOrm()
+	var existing *IdempotencyKey
+	err := o.DoTx(func(ctx context.Context, txOrm orm.TxOrmer) error {
+		if _, err := txOrm.Raw("DELETE FROM idempotency_keys WHERE scope = ? AND idempotency_key = ? AND expires_at <= now()",
+			scope, key).Exec(); err != nil {
+			return err
+		}
+		var ids []int64
+		if _, err := txOrm.Raw(`INSERT INTO idempotency_keys (idempotency_key, scope, request_hash, status, created_at, expires_at)
+			VALUES (?, ?, ?, ?, now(), ?)
+			ON CONFLICT (scope, idempotency_key) DO NOTHING RETURNING id`,
+			key, scope, requestHash, IdempotencyInProgress, time.Now().Add(ttl)).QueryRows(&ids); err != nil {
+			return err
+		}
+		if len(ids) == 1 {
+			return nil
+		}
+		existing = &IdempotencyKey{}
+		return txOrm.QueryTable(new(IdempotencyKey)).Filter("scope", scope).Filter("key", key).One(existing)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return existing, nil
+}
+
+// CompleteIdempotencyKey stores the response of the request that claimed the key
+func CompleteIdempotencyKey(key, scope string, status int, body string) error {
+	o := orm.NewOrm()
+	_, err := o.Raw("UPDATE idempotency_keys SET status = ?, response_status = ?, response_body = ? WHERE scope = ? AND idempotency_key = ?",
+		IdempotencyCompleted, status, body, scope, key).Exec()
+	return err
+}
+
+// ReleaseIdempotencyKey forgets a key whose request did not complete, so it can be retried
+func ReleaseIdempotencyKey(key, scope string) error {
+	o := orm.NewOrm()
+	_, err := o.Raw("DELETE FROM idempotency_keys WHERE scope = ? AND idempotency_key = ? AND status = ?",
+		scope, key, IdempotencyInProgress).Exec()
+	return err
+}
+
+// DeleteExpiredIdempotencyKeys purges keys past their TTL
+func DeleteExpiredIdempotencyKeys() (int64, error) {
+	o := orm.NewOrm()
+	res, err := o.Raw("DELETE FROM idempotency_keys WHERE expires_at <= now()").Exec()
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
@@ -0,0 +1,77 @@
+package models
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"testing"
+
+	"badminton-reservation-api/database"
+	"badminton-reservation-api/database/migrations"
+
+	"github.com/beego/beego/v2/client/orm"
+)
+
+// testDB is set when TEST_DATABASE_URL points at a Postgres database the tests may write to.
+// Migrations are applied once on start; tests that need the database call requireDB.
+var testDB bool
+
+func TestMain(m *testing.M) {
+	if dataSource := os.Getenv("TEST_DATABASE_URL"); dataSource != "" {
+		if err := openTestDB(dataSource); err != nil {
+			fmt.Fprintln(os.Stderr, "test database:", err)
+			os.Exit(1)
+		}
+		testDB = true
+	}
+	os.Exit(m.Run())
+}
+
+func openTestDB(dataSource string) error {
+	if err := orm.RegisterDriver("postgres", orm.DRPostgres); err != nil {
+		return err
+	}
+	if err := orm.RegisterDataBase("default", "postgres", dataSource); err != nil {
+		return err
+	}
+	list, err := database.LoadMigrations(migrations.FS)
+	if err != nil {
+		return err
+	}
+	db, err := orm.GetDB("default")
+	if err != nil {
+		return err
+	}
+	_, err = database.NewMigrator(db, list).Up(context.Background())
+	return err
+}
+
+// requireDB skips t unless TEST_DATABASE_URL is set
+func requireDB(t *testing.T) {
+	t.Helper()
+	if !testDB {
+		t.Skip("TEST_DATABASE_URL is not set")
+	}
+}
+
+// testCourtSlot creates a court and an inactive timeslot of its own for t, removed with
+// everything booked on them when t ends
+func testCourtSlot(t *testing.T) (*Court, *Timeslot) {
+	t.Helper()
+	court := &Court{Name: "Test " + t.Name(), PricePerHour: Whole(100000)}
+	if err := CreateCourt(court); err != nil {
+		t.Fatal(err)
+	}
+	slot := &Timeslot{StartTime: "23:00:00", EndTime: "23:59:00"}
+	if err := CreateTimeslot(slot); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		o := orm.NewOrm()
+		o.Raw("DELETE FROM reservations WHERE id IN (SELECT reservation_id FROM reservation_items WHERE court_id = ?)", court.Id).Exec()
+		o.Raw("DELETE FROM timeslot_availabilities WHERE court_id = ?", court.Id).Exec()
+		o.Raw("DELETE FROM timeslots WHERE id = ?", slot.Id).Exec()
+		o.Raw("DELETE FROM courts WHERE id = ?", court.Id).Exec()
+	})
+	return court, slot
+}
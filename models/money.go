@@ -0,0 +1,339 @@
+package models
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/beego/beego/v2/client/orm"
+)
+
+var (
+	// ErrMoneyPrecision is returned when an amount has more decimals than its currency allows
+	ErrMoneyPrecision = errors.New("amount has more decimal places than the currency allows")
+	// ErrCurrencyMismatch is returned when combining amounts of two different currencies
+	ErrCurrencyMismatch = errors.New("currency mismatch")
+)
+
+// currencyExponents is the number of minor-unit digits per ISO 4217 currency. Amounts are
+// stored in NUMERIC(10,2) columns, so no currency may use more than 2.
+var currencyExponents = map[string]int{
+	"IDR": 2,
+	"SGD": 2,
+	"MYR": 2,
+	"USD": 2,
+	"JPY": 0,
+}
+
+// DefaultCurrency is the currency of every amount in the system (env CURRENCY, default IDR)
+func DefaultCurrency() string {
+	if c := strings.ToUpper(strings.TrimSpace(os.Getenv("CURRENCY"))); c != "" {
+		if _, ok := currencyExponents[c]; ok {
+			return c
+		}
+	}
+	return "IDR"
+}
+
+// Money is an exact amount in minor units (e.g. sen for IDR) of a currency. The zero value
+// is zero in DefaultCurrency. It is stored in NUMERIC columns and serialized to JSON as a
+// plain decimal number, e.g. 150000 or 12.5.
+type Money struct {
+	Amount   int64
+	Currency string
+}
+
+// NewMoney returns minor units of currency ("" means DefaultCurrency)
+func NewMoney(minor int64, currency string) Money {
+	return Money{Amount: minor, Currency: currency}
+}
+
+// Whole returns units whole currency units (e.g. 150000 rupiah) of DefaultCurrency
+func Whole(units int64) Money {
+	m := Money{}
+	return Money{Amount: units * m.scale()}
+}
+
+// ParseMoney parses a decimal amount such as "150000", "150000.00" or "-12.5" exactly.
+// Trailing zero decimals beyond the currency exponent are accepted; other extra digits
+// fail with ErrMoneyPrecision instead of being rounded.
+func ParseMoney(s string, currency string) (Money, error) {
+	m := Money{Currency: currency}
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return m, fmt.Errorf("invalid amount %q", s)
+	}
+
+	neg := false
+	if s[0] == '-' || s[0] == '+' {
+		neg = s[0] == '-'
+		s = s[1:]
+	}
+	intPart, fracPart := s, ""
+	if i := strings.IndexByte(s, '.'); i >= 0 {
+		intPart, fracPart = s[:i], s[i+1:]
+	}
+	if intPart == "" && fracPart == "" {
+		return m, fmt.Errorf("invalid amount %q", s)
+	}
+	for _, r := range intPart + fracPart {
+		if r < '0' || r > '9' {
+			return m, fmt.Errorf("invalid amount %q", s)
+		}
+	}
+
+	exp := m.exponent()
+	if len(fracPart) > exp {
+		if strings.Trim(fracPart[exp:], "0") != "" {
+			return m, ErrMoneyPrecision
+		}
+		fracPart = fracPart[:exp]
+	}
+	fracPart += strings.Repeat("0", exp-len(fracPart))
+
+	digits := strings.TrimLeft(intPart+fracPart, "0")
+	if digits == "" {
+		return m, nil
+	}
+	v, err := strconv.ParseInt(digits, 10, 64)
+	if err != nil {
+		return m, fmt.Errorf("amount %q out of range", s)
+	}
+	if neg {
+		v = -v
+	}
+	m.Amount = v
+	return m, nil
+}
+
+// MoneyFromFloat converts a float amount, rounding half away from zero to the nearest minor
+// unit. Use it only for values that are not money themselves (e.g. a configured surcharge
+// held as float64); it goes through the shortest decimal form of f so 0.35 stays 0.35.
+func MoneyFromFloat(f float64, currency string) Money {
+	m := Money{Currency: currency}
+	r, ok := new(big.Rat).SetString(strconv.FormatFloat(f, 'f', -1, 64))
+	if !ok {
+		return m
+	}
+	m.Amount = roundRat(r.Mul(r, new(big.Rat).SetInt64(m.scale())))
+	return m
+}
+
+// exponent returns the number of minor-unit digits of the currency
+func (m Money) exponent() int {
+	c := m.Currency
+	if c == "" {
+		c = DefaultCurrency()
+	}
+	if e, ok := currencyExponents[c]; ok {
+		return e
+	}
+	return 2
+}
+
+// scale is 10^exponent, the number of minor units in one whole unit
+func (m Money) scale() int64 {
+	return int64(math.Pow10(m.exponent()))
+}
+
+// CurrencyCode returns the currency, resolving "" to DefaultCurrency
+func (m Money) CurrencyCode() string {
+	if m.Currency == "" {
+		return DefaultCurrency()
+	}
+	return m.Currency
+}
+
+// with returns minor in the currency of m
+func (m Money) with(minor int64) Money {
+	return Money{Amount: minor, Currency: m.Currency}
+}
+
+// match fails with ErrCurrencyMismatch when m and o are in different currencies. Stored
+// amounts carry no currency and take CURRENCY from the environment, so a mismatch is
+// reported rather than trusted to never happen.
+func (m Money) match(o Money) error {
+	if m.CurrencyCode() != o.CurrencyCode() {
+		return fmt.Errorf("%w: %s and %s", ErrCurrencyMismatch, m.CurrencyCode(), o.CurrencyCode())
+	}
+	return nil
+}
+
+// Add returns m + o
+func (m Money) Add(o Money) (Money, error) {
+	if err := m.match(o); err != nil {
+		return m, err
+	}
+	return m.with(m.Amount + o.Amount), nil
+}
+
+// Sub returns m - o
+func (m Money) Sub(o Money) (Money, error) {
+	if err := m.match(o); err != nil {
+		return m, err
+	}
+	return m.with(m.Amount - o.Amount), nil
+}
+
+// Mul returns m × factor rounded half away from zero to a minor unit. factor is taken at its
+// shortest decimal form, so Whole(50).Mul(0.35) is exactly 17.50.
+func (m Money) Mul(factor float64) Money {
+	r, ok := new(big.Rat).SetString(strconv.FormatFloat(factor, 'f', -1, 64))
+	if !ok {
+		return m.with(0)
+	}
+	return m.with(roundRat(r.Mul(r, new(big.Rat).SetInt64(m.Amount))))
+}
+
+// Percent returns percent % of m, rounded half away from zero to a minor unit
+func (m Money) Percent(percent float64) Money {
+	r, ok := new(big.Rat).SetString(strconv.FormatFloat(percent, 'f', -1, 64))
+	if !ok {
+		return m.with(0)
+	}
+	r.Mul(r, new(big.Rat).SetInt64(m.Amount))
+	return m.with(roundRat(r.Quo(r, big.NewRat(100, 1))))
+}
+
+// FloorWhole drops the minor units of a positive amount, e.g. 1234.56 → 1234.00
+func (m Money) FloorWhole() Money {
+	s := m.scale()
+	return m.with(m.Amount / s * s)
+}
+
+// RoundWhole rounds to whole currency units, half away from zero, e.g. 1234.50 → 1235.00
+func (m Money) RoundWhole() Money {
+	return m.with(roundRat(big.NewRat(m.Amount, m.scale())) * m.scale())
+}
+
+// WholeUnits returns the amount in whole currency units for gateways that take integers
+// (Midtrans/Xendit for IDR). It fails instead of truncating when there are minor units.
+func (m Money) WholeUnits() (int64, error) {
+	s := m.scale()
+	if m.Amount%s != 0 {
+		return 0, fmt.Errorf("amount %s %s is not a whole number of units", m.String(), m.CurrencyCode())
+	}
+	return m.Amount / s, nil
+}
+
+// IsZero reports whether the amount is zero
+func (m Money) IsZero() bool {
+	return m.Amount == 0
+}
+
+// Min returns the smaller of m and o
+func (m Money) Min(o Money) (Money, error) {
+	if err := m.match(o); err != nil {
+		return m, err
+	}
+	if o.Amount < m.Amount {
+		return o, nil
+	}
+	return m, nil
+}
+
+// String formats the amount with all currency decimals, e.g. "150000.00"
+func (m Money) String() string {
+	exp := m.exponent()
+	neg := m.Amount < 0
+	v := m.Amount
+	if neg {
+		v = -v
+	}
+	digits := strconv.FormatInt(v, 10)
+	if exp > 0 {
+		if len(digits) <= exp {
+			digits = strings.Repeat("0", exp-len(digits)+1) + digits
+		}
+		digits = digits[:len(digits)-exp] + "." + digits[len(digits)-exp:]
+	}
+	if neg {
+		return "-" + digits
+	}
+	return digits
+}
+
+// roundRat rounds r half away from zero to an integer
+func roundRat(r *big.Rat) int64 {
+	num := new(big.Int).Set(r.Num())
+	den := r.Denom()
+	neg := num.Sign() < 0
+	num.Abs(num)
+	q, rem := new(big.Int).QuoRem(num, den, new(big.Int))
+	if rem.Mul(rem, big.NewInt(2)).Cmp(den) >= 0 {
+		q.Add(q, big.NewInt(1))
+	}
+	if neg {
+		q.Neg(q)
+	}
+	return q.Int64()
+}
+
+// MarshalJSON writes the amount as a JSON number without trailing decimal zeros
+func (m Money) MarshalJSON() ([]byte, error) {
+	s := m.String()
+	if strings.Contains(s, ".") {
+		s = strings.TrimRight(strings.TrimRight(s, "0"), ".")
+	}
+	return []byte(s), nil
+}
+
+// UnmarshalJSON reads a JSON number or numeric string exactly, in DefaultCurrency
+func (m *Money) UnmarshalJSON(data []byte) error {
+	data = bytes.TrimSpace(data)
+	if string(data) == "null" {
+		*m = Money{}
+		return nil
+	}
+	parsed, err := ParseMoney(strings.Trim(string(data), `"`), "")
+	if err != nil {
+		return err
+	}
+	*m = parsed
+	return nil
+}
+
+// FieldType implements orm.Fielder. Amounts are read as text rather than float so NUMERIC
+// values round-trip exactly.
+func (m *Money) FieldType() int {
+	return orm.TypeVarCharField
+}
+
+// SetRaw implements orm.Fielder
+func (m *Money) SetRaw(value interface{}) error {
+	switch v := value.(type) {
+	case nil:
+		*m = Money{}
+		return nil
+	case []byte:
+		return m.setString(string(v))
+	case string:
+		return m.setString(v)
+	case int64:
+		*m = Whole(v)
+		return nil
+	case float64:
+		*m = MoneyFromFloat(v, "")
+		return nil
+	}
+	return fmt.Errorf("money: unsupported value %T", value)
+}
+
+func (m *Money) setString(s string) error {
+	parsed, err := ParseMoney(s, "")
+	if err != nil {
+		return err
+	}
+	*m = parsed
+	return nil
+}
+
+// RawValue implements orm.Fielder; Postgres casts the decimal text to NUMERIC
+func (m *Money) RawValue() interface{} {
+	return m.String()
+}
@@ -0,0 +1,180 @@
+package models
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func mustParse(t *testing.T, s string) Money {
+	t.Helper()
+	m, err := ParseMoney(s, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+func TestParseMoney(t *testing.T) {
+	t.Setenv("CURRENCY", "IDR")
+	for in, want := range map[string]int64{
+		"150000":       15000000,
+		"150000.5":     15000050,
+		"150000.50":    15000050,
+		"150000.500":   15000050,
+		"0.01":         1,
+		".5":           50,
+		"-12.34":       -1234,
+		"99999999.99":  9999999999,
+		" 1000.00 ":    100000,
+		"000123.40000": 12340,
+	} {
+		m, err := ParseMoney(in, "")
+		if err != nil || m.Amount != want {
+			t.Errorf("ParseMoney(%q) = %d, %v; want %d", in, m.Amount, err, want)
+		}
+	}
+
+	if _, err := ParseMoney("0.005", ""); !errors.Is(err, ErrMoneyPrecision) {
+		t.Errorf("sub-minor digits: got %v, want ErrMoneyPrecision", err)
+	}
+	if _, err := ParseMoney("100.5", "JPY"); !errors.Is(err, ErrMoneyPrecision) {
+		t.Errorf("decimals for a zero-exponent currency: got %v, want ErrMoneyPrecision", err)
+	}
+	for _, in := range []string{"", "-", ".", "1e5", "12,50", "abc", "99999999999999999999"} {
+		if _, err := ParseMoney(in, ""); err == nil {
+			t.Errorf("ParseMoney(%q) succeeded, want an error", in)
+		}
+	}
+}
+
+func TestMoneyString(t *testing.T) {
+	t.Setenv("CURRENCY", "IDR")
+	for _, s := range []string{"0.00", "0.01", "-0.50", "150000.00", "99999999.99"} {
+		if got := mustParse(t, s).String(); got != s {
+			t.Errorf("%s formats as %s", s, got)
+		}
+	}
+	if got := NewMoney(1500, "JPY").String(); got != "1500" {
+		t.Errorf("JPY formats as %s, want 1500", got)
+	}
+}
+
+func TestMoneyRounding(t *testing.T) {
+	t.Setenv("CURRENCY", "IDR")
+	tests := []struct {
+		name string
+		got  Money
+		want string
+	}{
+		// 0.35 and 1.15 are not exact in binary; the factor is taken at its decimal form
+		{"50 × 0.35", Whole(50).Mul(0.35), "17.50"},
+		{"0.10 × 1.15 rounds half up", mustParse(t, "0.10").Mul(1.15), "0.12"},
+		{"0.01 × 0.5 rounds half away from zero", mustParse(t, "0.01").Mul(0.5), "0.01"},
+		{"-0.01 × 0.5 rounds half away from zero", mustParse(t, "-0.01").Mul(0.5), "-0.01"},
+		{"0.01 × 0.49 rounds down", mustParse(t, "0.01").Mul(0.49), "0.00"},
+		{"33.33% of 100", Whole(100).Percent(33.33), "33.33"},
+		{"50% of 75001 keeps the sen", Whole(75001).Percent(50), "37500.50"},
+		{"RoundWhole 37500.50", mustParse(t, "37500.50").RoundWhole(), "37501.00"},
+		{"RoundWhole 37500.49", mustParse(t, "37500.49").RoundWhole(), "37500.00"},
+		{"RoundWhole -0.50", mustParse(t, "-0.50").RoundWhole(), "-1.00"},
+		{"FloorWhole 1234.99", mustParse(t, "1234.99").FloorWhole(), "1234.00"},
+		{"MoneyFromFloat 0.1+0.2", MoneyFromFloat(0.1+0.2, ""), "0.30"},
+		{"MoneyFromFloat 2.675", MoneyFromFloat(2.675, ""), "2.68"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.got.String(); got != tt.want {
+				t.Errorf("got %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMoneyAddIsExact(t *testing.T) {
+	t.Setenv("CURRENCY", "IDR")
+	// Ten thousand 0.10 additions stay exact (float64 drifts to 999.9999999999)
+	var sum Money
+	tenth := mustParse(t, "0.10")
+	for i := 0; i < 10000; i++ {
+		var err error
+		if sum, err = sum.Add(tenth); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if sum.String() != "1000.00" {
+		t.Errorf("sum is %s, want 1000.00", sum)
+	}
+}
+
+func TestMoneyCurrencyMismatch(t *testing.T) {
+	t.Setenv("CURRENCY", "IDR")
+	idr, usd := NewMoney(100, "IDR"), NewMoney(100, "USD")
+	if _, err := idr.Add(usd); !errors.Is(err, ErrCurrencyMismatch) {
+		t.Errorf("Add: got %v, want ErrCurrencyMismatch", err)
+	}
+	if _, err := idr.Sub(usd); !errors.Is(err, ErrCurrencyMismatch) {
+		t.Errorf("Sub: got %v, want ErrCurrencyMismatch", err)
+	}
+	if _, err := idr.Min(usd); !errors.Is(err, ErrCurrencyMismatch) {
+		t.Errorf("Min: got %v, want ErrCurrencyMismatch", err)
+	}
+	// An empty currency is DefaultCurrency
+	if sum, err := idr.Add(Whole(1)); err != nil || sum.Amount != 200 {
+		t.Errorf("IDR + default currency = %d, %v; want 200", sum.Amount, err)
+	}
+}
+
+func TestMoneyWholeUnits(t *testing.T) {
+	t.Setenv("CURRENCY", "IDR")
+	if units, err := Whole(150000).WholeUnits(); err != nil || units != 150000 {
+		t.Errorf("WholeUnits(150000.00) = %d, %v", units, err)
+	}
+	if _, err := mustParse(t, "150000.50").WholeUnits(); err == nil {
+		t.Error("WholeUnits truncated 150000.50")
+	}
+}
+
+func TestMoneyJSON(t *testing.T) {
+	t.Setenv("CURRENCY", "IDR")
+	out, err := json.Marshal(map[string]Money{"a": Whole(150000), "b": mustParse(t, "12.50"), "c": {}})
+	if err != nil || string(out) != `{"a":150000,"b":12.5,"c":0}` {
+		t.Errorf("Marshal = %s, %v", out, err)
+	}
+
+	var req struct {
+		A Money `json:"a"`
+		B Money `json:"b"`
+		C Money `json:"c"`
+	}
+	err = json.Unmarshal([]byte(`{"a": 150000.25, "b": "99.90", "c": null}`), &req)
+	if err != nil || req.A.Amount != 15000025 || req.B.Amount != 9990 || !req.C.IsZero() {
+		t.Errorf("Unmarshal = %+v, %v", req, err)
+	}
+	if err := json.Unmarshal([]byte(`{"a": 1.005}`), &req); err == nil {
+		t.Error("Unmarshal accepted sub-minor digits")
+	}
+}
+
+func TestMoneyRawValues(t *testing.T) {
+	t.Setenv("CURRENCY", "IDR")
+	// NUMERIC columns are read as text, so no float conversion happens on the way in
+	tests := []struct {
+		raw  interface{}
+		want string
+	}{
+		{[]byte("12345678.91"), "12345678.91"},
+		{nil, "0.00"},
+		{int64(42), "42.00"},
+		{0.1 + 0.2, "0.30"},
+	}
+	for _, tt := range tests {
+		var m Money
+		if err := m.SetRaw(tt.raw); err != nil || m.String() != tt.want {
+			t.Errorf("SetRaw(%v) = %s, %v; want %s", tt.raw, m, err, tt.want)
+		}
+	}
+	if m := mustParse(t, "99999999.99"); m.RawValue() != "99999999.99" {
+		t.Errorf("RawValue = %v", m.RawValue())
+	}
+}
@@ -0,0 +1,112 @@
+package models
+
+import (
+	"time"
+
+	"github.com/beego/beego/v2/client/orm"
+)
+
+// Notification kinds
+const (
+	NotifyReservationCreated   = "reservation_created"
+	NotifyPaymentReminder      = "payment_reminder"
+	NotifyReservationPaid      = "reservation_paid"
+	NotifyReservationCancelled = "reservation_cancelled"
+	NotifyReservationExpired   = "reservation_expired"
+	NotifyGameReminder         = "game_reminder"
+)
+
+// Notification channels
+const (
+	ChannelEmail    = "email"
+	ChannelSMS      = "sms"
+	ChannelWhatsApp = "whatsapp"
+)
+
+// IsValidChannel reports whether channel is one of the notification channels
+func IsValidChannel(channel string) bool {
+	switch channel {
+	case ChannelEmail, ChannelSMS, ChannelWhatsApp:
+		return true
+	}
+	return false
+}
+
+// Notification statuses
+const (
+	NotificationSent   = "sent"
+	NotificationFailed = "failed"
+)
+
+// NotificationLog is one attempt to notify a customer about a reservation
+type NotificationLog struct {
+	Id            int64     `orm:"column(id);auto;pk" json:"id"`
+	ReservationId string    `orm:"column(reservation_id);size(36)" json:"reservation_id"`
+	Kind          string    `orm:"column(kind);size(64)" json:"kind"`
+	Channel       string    `orm:"column(channel);size(20);default(email)" json:"channel"`
+	Recipient     string    `orm:"column(recipient);size(255)" json:"recipient"`
+	Language      string    `orm:"column(language);size(5)" json:"language"`
+	Subject       string    `orm:"column(subject);size(255)" json:"subject"`
+	Status        string    `orm:"column(status);size(20)" json:"status"`
+	Error         string    `orm:"column(error);type(text);null" json:"error,omitempty"`
+	OutboxEventId *int64    `orm:"column(outbox_event_id);null" json:"outbox_event_id,omitempty"`
+	CreatedAt     time.Time `orm:"column(created_at);auto_now_add;type(datetime)" json:"created_at"`
+}
+
+func (l *NotificationLog) TableName() string {
+	return "notification_logs"
+}
+
+func (l *NotificationLog) TableIndex() [][]string {
+	return [][]string{{"reservation_id", "kind"}, {"status"}}
+}
+
+func (l *NotificationLog) TableReferences() map[string]string {
+	return map[string]string{"reservation_id": "reservations(id)"}
+}
+
+func init() {
+	registerModels(new(NotificationLog))
+}
+
+// CreateNotificationLog records a notification attempt
+func CreateNotificationLog(l *NotificationLog) error {
+	var eventId interface{}
+	if l.OutboxEventId != nil {
+		eventId = *l.OutboxEventId
+	}
+	o := orm.NewOrm()
+	return o.Raw(`INSERT INTO notification_logs (reservation_id, kind, channel, recipient, language, subject, status, error, outbox_event_id, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, now()) RETURNING id`,
+		l.ReservationId, l.Kind, l.Channel, l.Recipient, l.Language, l.Subject, l.Status, nullString(l.Error), eventId).QueryRow(&l.Id)
+}
+
+// HasSentNotification reports whether kind was already sent for the reservation over channel
+func HasSentNotification(reservationId, kind, channel string) (bool, error) {
+	o := orm.NewOrm()
+	cnt, err := o.QueryTable(new(NotificationLog)).Filter("reservation_id", reservationId).Filter("kind", kind).
+		Filter("channel", channel).Filter("status", NotificationSent).Count()
+	return cnt > 0, err
+}
+
+// GetNotificationLogs lists notification attempts, newest first, optionally filtered by
+// reservation, kind, channel and status
+func GetNotificationLogs(reservationId, kind, channel, status string, limit int) ([]*NotificationLog, error) {
+	o := orm.NewOrm()
+	qs := o.QueryTable(new(NotificationLog))
+	if reservationId != "" {
+		qs = qs.Filter("reservation_id", reservationId)
+	}
+	if kind != "" {
+		qs = qs.Filter("kind", kind)
+	}
+	if channel != "" {
+		qs = qs.Filter("channel", channel)
+	}
+	if status != "" {
+		qs = qs.Filter("status", status)
+	}
+	var list []*NotificationLog
+	_, err := qs.OrderBy("-id").Limit(limit).All(&list)
+	return list, err
+}
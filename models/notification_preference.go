@@ -0,0 +1,91 @@
+package models
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/beego/beego/v2/client/orm"
+)
+
+// NotificationPreference is how a customer, identified by email, wants to be notified
+type NotificationPreference struct {
+	Email string `orm:"column(email);pk;size(255)" json:"email"`
+	// Channels is a comma-separated list of channels, e.g. "whatsapp,email"
+	Channels  string    `orm:"column(channels);size(100);default(email)" json:"-"`
+	Phone     string    `orm:"column(phone);size(20)" json:"phone"`
+	Language  string    `orm:"column(language);size(5)" json:"language"`
+	UpdatedAt time.Time `orm:"column(updated_at);auto_now;type(datetime)" json:"updated_at"`
+
+	ChannelList []string `orm:"-" json:"channels"`
+}
+
+func (p *NotificationPreference) TableName() string {
+	return "notification_preferences"
+}
+
+func init() {
+	registerModels(new(NotificationPreference))
+}
+
+// JoinChannels turns a channel list into the stored form
+func JoinChannels(channels []string) string {
+	return strings.Join(channels, ",")
+}
+
+// SplitChannels turns the stored form into a channel list
+func SplitChannels(channels string) []string {
+	var list []string
+	for _, c := range strings.Split(channels, ",") {
+		if c = strings.TrimSpace(c); c != "" {
+			list = append(list, c)
+		}
+	}
+	return list
+}
+
+// GetNotificationPreference returns the preferences stored for email, or nil when the
+// customer has none
+func GetNotificationPreference(email string) (*NotificationPreference, error) {
+	o := orm.NewOrm()
+	p := &NotificationPreference{Email: strings.ToLower(strings.TrimSpace(email))}
+	if err := o.Read(p); err != nil {
+		if errors.Is(err, orm.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	p.ChannelList = SplitChannels(p.Channels)
+	return p, nil
+}
+
+// GetReservationNotificationPreference returns the preferences of the account that made r,
+// or nil when r was booked without signing in or the account has none. The customer email
+// typed into a booking is not proof of ownership: anyone can register an account with it, so
+// looking preferences up by that email would let them redirect the booking's notifications,
+// manage link included, to their own phone.
+func GetReservationNotificationPreference(r *Reservation) (*NotificationPreference, error) {
+	if r.UserId == "" {
+		return nil, nil
+	}
+	u, err := GetUserById(r.UserId)
+	if errors.Is(err, orm.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return GetNotificationPreference(u.Email)
+}
+
+// SaveNotificationPreference creates or replaces the preferences of p.Email
+func SaveNotificationPreference(p *NotificationPreference) error {
+	p.Email = strings.ToLower(strings.TrimSpace(p.Email))
+	p.Channels = JoinChannels(p.ChannelList)
+	o := orm.NewOrm()
+	return o.Raw(`INSERT INTO notification_preferences (email, channels, phone, language, updated_at)
+		VALUES (?, ?, ?, ?, now())
+		ON CONFLICT (email) DO UPDATE SET channels = EXCLUDED.channels, phone = EXCLUDED.phone,
+			language = EXCLUDED.language, updated_at = EXCLUDED.updated_at
+		RETURNING updated_at`, p.Email, p.Channels, p.Phone, p.Language).QueryRow(&p.UpdatedAt)
+}
@@ -0,0 +1,65 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/beego/beego/v2/client/orm"
+	"github.com/google/uuid"
+)
+
+func TestChannelsRoundTrip(t *testing.T) {
+	if got := JoinChannels(SplitChannels(" whatsapp, email ,")); got != "whatsapp,email" {
+		t.Errorf("channels round-trip to %q, want whatsapp,email", got)
+	}
+}
+
+func TestReservationNotificationPreferenceFollowsOwner(t *testing.T) {
+	requireDB(t)
+	court, slot := testCourtSlot(t)
+
+	// An account registered with the customer's email, which did not make the booking
+	email := "prefs-" + uuid.New().String() + "@example.com"
+	account := &User{Id: uuid.New().String(), Name: "Other", Email: email, PasswordHash: "x", Role: RoleCustomer, IsActive: true}
+	if err := CreateUser(account); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		o := orm.NewOrm()
+		o.Raw("DELETE FROM notification_preferences WHERE email = ?", email).Exec()
+		o.Raw("DELETE FROM users WHERE id = ?", account.Id).Exec()
+	})
+	if err := SaveNotificationPreference(&NotificationPreference{Email: email, ChannelList: []string{ChannelWhatsApp}, Phone: "+6289999999999"}); err != nil {
+		t.Fatal(err)
+	}
+
+	booking := func(date, userId string) *Reservation {
+		r := &Reservation{
+			Id:            uuid.New().String(),
+			CourtId:       court.Id,
+			TimeslotId:    slot.Id,
+			BookingDate:   date,
+			CustomerName:  "Customer",
+			CustomerEmail: email,
+			CustomerPhone: "+6281234567890",
+			TotalPrice:    court.PricePerHour,
+			Status:        ReservationPending,
+			ExpiredAt:     time.Now().Add(30 * time.Minute),
+			UserId:        userId,
+		}
+		if err := CreateReservation(r); err != nil {
+			t.Fatal(err)
+		}
+		return r
+	}
+
+	anonymous := booking(time.Now().AddDate(0, 0, 1).Format("2006-01-02"), "")
+	if pref, err := GetReservationNotificationPreference(anonymous); err != nil || pref != nil {
+		t.Errorf("booking made without signing in got preferences %+v, err %v", pref, err)
+	}
+	owned := booking(time.Now().AddDate(0, 0, 2).Format("2006-01-02"), account.Id)
+	pref, err := GetReservationNotificationPreference(owned)
+	if err != nil || pref == nil || pref.Phone != "+6289999999999" {
+		t.Errorf("booking made by the account got preferences %+v, err %v", pref, err)
+	}
+}
@@ -0,0 +1,379 @@
+package models
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/beego/beego/v2/client/orm"
+)
+
+// ErrOutboxEventDelivered is returned when retrying an event that was already delivered
+var ErrOutboxEventDelivered = errors.New("outbox event was already delivered")
+
+// Outbox event statuses
+const (
+	OutboxPending   = "pending"
+	OutboxDelivered = "delivered"
+	OutboxDead      = "dead"
+)
+
+// Domain event types written to the outbox
+const (
+	EventReservationCreated         = "ReservationCreated"
+	EventReservationAwaitingPayment = "ReservationAwaitingPayment"
+	EventReservationPaid            = "ReservationPaid"
+	EventReservationCancelled       = "ReservationCancelled"
+	EventReservationExpired         = "ReservationExpired"
+	EventReservationCheckedIn       = "ReservationCheckedIn"
+	EventReservationCompleted       = "ReservationCompleted"
+	EventReservationNoShow          = "ReservationNoShow"
+	EventReservationRefunded        = "ReservationRefunded"
+	EventSeriesCreated              = "SeriesCreated"
+	EventSeriesCancelled            = "SeriesCancelled"
+	EventPaymentCreated             = "PaymentCreated"
+	EventPaymentSucceeded           = "PaymentSucceeded"
+	EventPaymentFailed              = "PaymentFailed"
+	EventPaymentExpired             = "PaymentExpired"
+	EventPaymentRefunded            = "PaymentRefunded"
+	EventRefundSucceeded            = "RefundSucceeded"
+	EventRefundFailed               = "RefundFailed"
+)
+
+// Aggregates the outbox events are about
+const (
+	AggregateReservation = "reservation"
+	AggregateSeries      = "series"
+	AggregatePayment     = "payment"
+	AggregateRefund      = "refund"
+)
+
+// reservationStatusEvents is the event announcing a reservation reaching each status
+var reservationStatusEvents = map[string]string{
+	ReservationWaitingPayment: EventReservationAwaitingPayment,
+	ReservationPaid:           EventReservationPaid,
+	ReservationCancelled:      EventReservationCancelled,
+	ReservationExpired:        EventReservationExpired,
+	ReservationCheckedIn:      EventReservationCheckedIn,
+	ReservationCompleted:      EventReservationCompleted,
+	ReservationNoShow:         EventReservationNoShow,
+	ReservationRefunded:       EventReservationRefunded,
+}
+
+// paymentStatusEvents is the event announcing a payment reaching each status. Partial
+// refunds are announced by RefundSucceeded only.
+var paymentStatusEvents = map[string]string{
+	PaymentSuccess:  EventPaymentSucceeded,
+	PaymentFailed:   EventPaymentFailed,
+	PaymentExpired:  EventPaymentExpired,
+	PaymentRefunded: EventPaymentRefunded,
+}
+
+// EventTypes lists every domain event type
+func EventTypes() []string {
+	return []string{
+		EventReservationCreated, EventReservationAwaitingPayment, EventReservationPaid, EventReservationCancelled,
+		EventReservationExpired, EventReservationCheckedIn, EventReservationCompleted, EventReservationNoShow,
+		EventReservationRefunded, EventSeriesCreated, EventSeriesCancelled, EventPaymentCreated,
+		EventPaymentSucceeded, EventPaymentFailed, EventPaymentExpired, EventPaymentRefunded,
+		EventRefundSucceeded, EventRefundFailed,
+	}
+}
+
+// OutboxEvent is a domain event waiting for, or done with, delivery to the event handlers
+type OutboxEvent struct {
+	Id                int64      `orm:"column(id);auto;pk" json:"id"`
+	EventType         string     `orm:"column(event_type);size(64)" json:"event_type"`
+	AggregateType     string     `orm:"column(aggregate_type);size(32)" json:"aggregate_type"`
+	AggregateId       string     `orm:"column(aggregate_id);size(36)" json:"aggregate_id"`
+	Payload           string     `orm:"column(payload);type(text)" json:"payload"`
+	Status            string     `orm:"column(status);size(20);default(pending)" json:"status"`
+	Attempts          int        `orm:"column(attempts);default(0)" json:"attempts"`
+	NextAttemptAt     time.Time  `orm:"column(next_attempt_at);type(datetime)" json:"next_attempt_at"`
+	CompletedHandlers string     `orm:"column(completed_handlers);type(text)" json:"completed_handlers"`
+	LastError         string     `orm:"column(last_error);type(text);null" json:"last_error,omitempty"`
+	DeliveredAt       *time.Time `orm:"column(delivered_at);type(datetime);null" json:"delivered_at,omitempty"`
+	CreatedAt         time.Time  `orm:"column(created_at);auto_now_add;type(datetime)" json:"created_at"`
+}
+
+func (e *OutboxEvent) TableName() string {
+	return "outbox_events"
+}
+
+func (e *OutboxEvent) TableIndex() [][]string {
+	return [][]string{{"status", "next_attempt_at"}, {"aggregate_type", "aggregate_id"}}
+}
+
+func init() {
+	registerModels(new(OutboxEvent))
+}
+
+// Handled lists the handlers that already processed the event
+func (e *OutboxEvent) Handled() []string {
+	if e.CompletedHandlers == "" {
+		return nil
+	}
+	return strings.Split(e.CompletedHandlers, ",")
+}
+
+// ReservationPayload is the body of reservation events. The manage token is never included.
+type ReservationPayload struct {
+	ReservationId      string             `json:"reservation_id"`
+	SeriesId           string             `json:"series_id,omitempty"`
+	Status             string             `json:"status"`
+	PreviousStatus     string             `json:"previous_status,omitempty"`
+	CourtId            int                `json:"court_id"`
+	TimeslotId         int                `json:"timeslot_id"`
+	BookingDate        string             `json:"booking_date"`
+	CustomerName       string             `json:"customer_name"`
+	CustomerEmail      string             `json:"customer_email"`
+	CustomerPhone      string             `json:"customer_phone"`
+	TotalPrice         Money              `json:"total_price"`
+	DiscountAmount     Money              `json:"discount_amount"`
+	PromoCode          string             `json:"promo_code,omitempty"`
+	RefundAmount       Money              `json:"refund_amount"`
+	CancellationReason string             `json:"cancellation_reason,omitempty"`
+	ExpiredAt          time.Time          `json:"expired_at"`
+	Items              []*ReservationItem `json:"items,omitempty"`
+}
+
+// NewReservationPayload describes r as it is after the change; from is its previous status
+func NewReservationPayload(r *Reservation, from string) *ReservationPayload {
+	return &ReservationPayload{
+		ReservationId:      r.Id,
+		SeriesId:           r.SeriesId,
+		Status:             r.Status,
+		PreviousStatus:     from,
+		CourtId:            r.CourtId,
+		TimeslotId:         r.TimeslotId,
+		BookingDate:        r.BookingDate,
+		CustomerName:       r.CustomerName,
+		CustomerEmail:      r.CustomerEmail,
+		CustomerPhone:      r.CustomerPhone,
+		TotalPrice:         r.TotalPrice,
+		DiscountAmount:     r.DiscountAmount,
+		PromoCode:          r.PromoCode,
+		RefundAmount:       r.RefundAmount,
+		CancellationReason: r.CancellationReason,
+		ExpiredAt:          r.ExpiredAt,
+		Items:              r.Items,
+	}
+}
+
+// SeriesPayload is the body of series events
+type SeriesPayload struct {
+	SeriesId       string   `json:"series_id"`
+	Status         string   `json:"status"`
+	CourtId        int      `json:"court_id"`
+	TimeslotId     int      `json:"timeslot_id"`
+	Weekday        int      `json:"weekday"`
+	StartDate      string   `json:"start_date"`
+	Occurrences    int      `json:"occurrences"`
+	PaymentMode    string   `json:"payment_mode"`
+	CustomerName   string   `json:"customer_name"`
+	CustomerEmail  string   `json:"customer_email"`
+	CustomerPhone  string   `json:"customer_phone"`
+	TotalPrice     Money    `json:"total_price"`
+	ReservationIds []string `json:"reservation_ids,omitempty"`
+}
+
+// NewSeriesPayload describes series and the given occurrences
+func NewSeriesPayload(series *ReservationSeries, reservationIds []string) *SeriesPayload {
+	return &SeriesPayload{
+		SeriesId:       series.Id,
+		Status:         series.Status,
+		CourtId:        series.CourtId,
+		TimeslotId:     series.TimeslotId,
+		Weekday:        series.Weekday,
+		StartDate:      series.StartDate,
+		Occurrences:    series.Occurrences,
+		PaymentMode:    series.PaymentMode,
+		CustomerName:   series.CustomerName,
+		CustomerEmail:  series.CustomerEmail,
+		CustomerPhone:  series.CustomerPhone,
+		TotalPrice:     series.TotalPrice,
+		ReservationIds: reservationIds,
+	}
+}
+
+// PaymentPayload is the body of payment events. The gateway notification is left out.
+type PaymentPayload struct {
+	PaymentId      string `json:"payment_id"`
+	ReservationId  string `json:"reservation_id"`
+	SeriesId       string `json:"series_id,omitempty"`
+	OrderId        string `json:"order_id"`
+	Gateway        string `json:"payment_gateway"`
+	Status         string `json:"status"`
+	PreviousStatus string `json:"previous_status,omitempty"`
+	Amount         Money  `json:"amount"`
+	RefundedAmount Money  `json:"refunded_amount"`
+	TransactionId  string `json:"transaction_id,omitempty"`
+	PaymentUrl     string `json:"payment_url,omitempty"`
+}
+
+// NewPaymentPayload describes p as it is after the change; from is its previous status
+func NewPaymentPayload(p *Payment, from string) *PaymentPayload {
+	return &PaymentPayload{
+		PaymentId:      p.Id,
+		ReservationId:  p.ReservationId,
+		SeriesId:       p.SeriesId,
+		OrderId:        p.OrderId,
+		Gateway:        p.PaymentGateway,
+		Status:         p.Status,
+		PreviousStatus: from,
+		Amount:         p.Amount,
+		RefundedAmount: p.RefundedAmount,
+		TransactionId:  p.TransactionId,
+		PaymentUrl:     p.PaymentUrl,
+	}
+}
+
+// RefundPayload is the body of refund events
+type RefundPayload struct {
+	RefundId        string `json:"refund_id"`
+	PaymentId       string `json:"payment_id"`
+	ReservationId   string `json:"reservation_id"`
+	Amount          Money  `json:"amount"`
+	Reason          string `json:"reason,omitempty"`
+	Status          string `json:"status"`
+	GatewayRefundId string `json:"gateway_refund_id,omitempty"`
+}
+
+// NewRefundPayload describes r
+func NewRefundPayload(r *Refund) *RefundPayload {
+	return &RefundPayload{
+		RefundId:        r.Id,
+		PaymentId:       r.PaymentId,
+		ReservationId:   r.ReservationId,
+		Amount:          r.Amount,
+		Reason:          r.Reason,
+		Status:          r.Status,
+		GatewayRefundId: r.GatewayRefundId,
+	}
+}
+
+// enqueueEvent writes an event to the outbox. Call it on the transaction that makes the
+// change, so the event exists exactly when the change is committed.
+func enqueueEvent(q orm.QueryExecutor, eventType, aggregateType, aggregateId string, payload interface{}) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	_, err = q.Raw(`INSERT INTO outbox_events (event_type, aggregate_type, aggregate_id, payload, status, attempts, next_attempt_at, completed_handlers, created_at)
+		VALUES (?, ?, ?, ?, ?, 0, now(), '', now())`, eventType, aggregateType, aggregateId, string(body), OutboxPending).Exec()
+	return err
+}
+
+// enqueueReservationEvent reads the reservation with its items on q and writes eventType
+func enqueueReservationEvent(q orm.QueryExecutor, eventType, id, from string) error {
+	r := &Reservation{Id: id}
+	if err := q.Read(r); err != nil {
+		return err
+	}
+	items, err := getReservationItems(q, id)
+	if err != nil {
+		return err
+	}
+	r.Items = items
+	return enqueueEvent(q, eventType, AggregateReservation, id, NewReservationPayload(r, from))
+}
+
+// ClaimOutboxEvents leases up to limit due pending events to the caller for lease and counts
+// the attempt. Other dispatchers skip leased events; an event whose dispatcher dies before
+// recording the outcome is claimed again once the lease ends. Events come oldest first.
+func ClaimOutboxEvents(limit int, lease time.Duration) ([]*OutboxEvent, error) {
+	o := orm.NewOrm()
+	var ids []int64
+	if _, err := o.Raw(`UPDATE outbox_events SET attempts = attempts + 1, next_attempt_at = ?
+		WHERE id IN (SELECT id FROM outbox_events WHERE status = ? AND next_attempt_at <= now()
+			ORDER BY id LIMIT ? FOR UPDATE SKIP LOCKED)
+		RETURNING id`, time.Now().Add(lease), OutboxPending, limit).QueryRows(&ids); err != nil {
+		return nil, err
+	}
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	var list []*OutboxEvent
+	_, err := o.QueryTable(new(OutboxEvent)).Filter("id__in", ids).OrderBy("id").All(&list)
+	return list, err
+}
+
+// MarkOutboxEventDelivered records that every handler processed the event
+func MarkOutboxEventDelivered(id int64, handled []string) error {
+	o := orm.NewOrm()
+	_, err := o.Raw("UPDATE outbox_events SET status = ?, completed_handlers = ?, last_error = NULL, delivered_at = now() WHERE id = ?",
+		OutboxDelivered, strings.Join(handled, ","), id).Exec()
+	return err
+}
+
+// MarkOutboxEventFailed records a failed delivery. The event is retried at nextAttemptAt, or
+// becomes dead when dead is set. handled are the handlers that succeeded so far.
+func MarkOutboxEventFailed(id int64, handled []string, deliveryErr string, nextAttemptAt time.Time, dead bool) error {
+	status := OutboxPending
+	if dead {
+		status = OutboxDead
+	}
+	o := orm.NewOrm()
+	_, err := o.Raw("UPDATE outbox_events SET status = ?, completed_handlers = ?, last_error = ?, next_attempt_at = ? WHERE id = ?",
+		status, strings.Join(handled, ","), deliveryErr, nextAttemptAt, id).Exec()
+	return err
+}
+
+// RetryOutboxEvent puts a dead or pending event back in line for delivery now with a fresh
+// set of attempts. Handlers that already processed it are still skipped.
+func RetryOutboxEvent(id int64) (*OutboxEvent, error) {
+	o := orm.NewOrm()
+	res, err := o.Raw("UPDATE outbox_events SET status = ?, attempts = 0, next_attempt_at = now() WHERE id = ? AND status <> ?",
+		OutboxPending, id, OutboxDelivered).Exec()
+	if err != nil {
+		return nil, err
+	}
+	e, err := GetOutboxEventById(id)
+	if err != nil {
+		return nil, err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return e, ErrOutboxEventDelivered
+	}
+	return e, nil
+}
+
+// GetOutboxEventById returns an outbox event
+func GetOutboxEventById(id int64) (*OutboxEvent, error) {
+	o := orm.NewOrm()
+	e := &OutboxEvent{Id: id}
+	if err := o.Read(e); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// GetOutboxEvents lists events, newest first, optionally filtered by status, event type and
+// aggregate id
+func GetOutboxEvents(status, eventType, aggregateId string, limit int) ([]*OutboxEvent, error) {
+	o := orm.NewOrm()
+	qs := o.QueryTable(new(OutboxEvent))
+	if status != "" {
+		qs = qs.Filter("status", status)
+	}
+	if eventType != "" {
+		qs = qs.Filter("event_type", eventType)
+	}
+	if aggregateId != "" {
+		qs = qs.Filter("aggregate_id", aggregateId)
+	}
+	var list []*OutboxEvent
+	_, err := qs.OrderBy("-id").Limit(limit).All(&list)
+	return list, err
+}
+
+// DeleteDeliveredOutboxEvents deletes events delivered before t and returns how many
+func DeleteDeliveredOutboxEvents(before time.Time) (int64, error) {
+	o := orm.NewOrm()
+	res, err := o.Raw("DELETE FROM outbox_events WHERE status = ? AND delivered_at < ?", OutboxDelivered, before).Exec()
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
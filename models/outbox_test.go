@@ -0,0 +1,64 @@
+package models
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestReservationPayload(t *testing.T) {
+	r := &Reservation{
+		Id: "res-1", Status: ReservationCancelled, CustomerEmail: "a@example.com",
+		TotalPrice: Whole(150000), RefundAmount: Whole(75000), ManageToken: "secret",
+		Items: []*ReservationItem{{CourtId: 1, TimeslotId: 2, BookingDate: "2026-10-20", Price: Whole(150000)}},
+	}
+	body, err := json.Marshal(NewReservationPayload(r, ReservationPaid))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded map[string]interface{}
+	if err := json.Unmarshal(body, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if decoded["status"] != "cancelled" || decoded["previous_status"] != "paid" {
+		t.Errorf("payload does not carry the transition: %s", body)
+	}
+	if decoded["refund_amount"] == nil {
+		t.Errorf("payload does not carry the refund: %s", body)
+	}
+	if strings.Contains(string(body), "secret") {
+		t.Errorf("payload leaks the manage token: %s", body)
+	}
+}
+
+func TestPaymentPayload(t *testing.T) {
+	p := &Payment{Id: "pay-1", Status: PaymentSuccess, Notification: `{"signature_key":"x"}`, Amount: Whole(150000)}
+	body, err := json.Marshal(NewPaymentPayload(p, PaymentPending))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(body), "signature_key") {
+		t.Errorf("payload leaks the gateway notification: %s", body)
+	}
+}
+
+func TestOutboxEventHandled(t *testing.T) {
+	e := &OutboxEvent{CompletedHandlers: "audit,mailer"}
+	if got := e.Handled(); !reflect.DeepEqual(got, []string{"audit", "mailer"}) {
+		t.Errorf("Handled() = %v", got)
+	}
+	if got := (&OutboxEvent{}).Handled(); got != nil {
+		t.Errorf("Handled() without completed handlers = %v, want nil", got)
+	}
+}
+
+func TestEventTypesAreUnique(t *testing.T) {
+	seen := map[string]bool{}
+	for _, eventType := range EventTypes() {
+		if seen[eventType] {
+			t.Errorf("event type %s is listed twice", eventType)
+		}
+		seen[eventType] = true
+	}
+}
@@ -1,17 +1,18 @@
 package models
 
 import (
+	"context"
 	"time"
 
 	"github.com/beego/beego/v2/client/orm"
 )
 
 type Payment struct {
-	Id             string    `orm:"column(id);pk" json:"id"`
-	ReservationId  string    `orm:"column(reservation_id);size(64)" json:"reservation_id"`
+	Id             string    `orm:"column(id);pk;size(36)" json:"id"`
+	ReservationId  string    `orm:"column(reservation_id);size(36)" json:"reservation_id"`
 	OrderId        string    `orm:"column(order_id);size(128);null" json:"order_id"`
 	PaymentUrl     string    `orm:"column(payment_url);type(text);null" json:"payment_url"`
-	Amount         float64   `orm:"column(amount);digits(10);decimals(2)" json:"amount"`
+	Amount         Money     `orm:"column(amount);digits(10);decimals(2)" json:"amount"`
 	PaymentGateway string    `orm:"column(payment_gateway);size(64)" json:"payment_gateway"`
 	Status         string    `orm:"column(status);size(32)" json:"status"`
 	TransactionId  string    `orm:"column(transaction_id);size(128);null" json:"transaction_id"`
@@ -19,23 +20,41 @@ type Payment struct {
 	ExpiredAt      time.Time `orm:"column(expired_at);type(datetime);null" json:"expired_at"`
 	CreatedAt      time.Time `orm:"column(created_at);auto_now_add;type(datetime)" json:"created_at"`
 	UpdatedAt      time.Time `orm:"column(updated_at);auto_now;type(datetime)" json:"updated_at"`
+	SeriesId       string    `orm:"column(series_id);size(36);null" json:"series_id,omitempty"`
+	RefundedAmount Money     `orm:"column(refunded_amount);digits(10);decimals(2);default(0)" json:"refunded_amount"`
 }
 
 func (p *Payment) TableName() string {
 	return "payments"
 }
 
+func (p *Payment) TableIndex() [][]string {
+	return [][]string{{"reservation_id"}, {"status"}, {"series_id"}}
+}
+
+func (p *Payment) TableReferences() map[string]string {
+	return map[string]string{
+		"reservation_id": "reservations(id)",
+		"series_id":      "reservation_series(id)",
+	}
+}
+
 func init() {
-	orm.RegisterModel(new(Payment))
+	registerModels(new(Payment))
 }
 
-// CreatePayment inserts a new payment record
+// CreatePayment inserts a new payment record together with its PaymentCreated outbox event
 func CreatePayment(p *Payment) error {
 	o := orm.NewOrm()
-	// Use raw insert to avoid LastInsertId issues on Postgres drivers
-	_, err := o.Raw(`INSERT INTO payments (id, reservation_id, order_id, payment_url, amount, payment_gateway, status, transaction_id, notification, expired_at, created_at, updated_at)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, now(), now())`, p.Id, p.ReservationId, p.OrderId, p.PaymentUrl, p.Amount, p.PaymentGateway, p.Status, p.TransactionId, p.Notification, p.ExpiredAt).Exec()
-	return err
+	return o.DoTx(func(ctx context.Context, txOrm orm.TxOrmer) error {
+		// Use raw insert to avoid LastInsertId issues on Postgres drivers
+		_, err := txOrm.Raw(`INSERT INTO payments (id, reservation_id, order_id, payment_url, amount, payment_gateway, status, transaction_id, notification, expired_at, series_id, created_at, updated_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, now(), now())`, p.Id, p.ReservationId, p.OrderId, p.PaymentUrl, p.Amount.String(), p.PaymentGateway, p.Status, p.TransactionId, p.Notification, p.ExpiredAt, nullString(p.SeriesId)).Exec()
+		if err != nil {
+			return err
+		}
+		return enqueueEvent(txOrm, EventPaymentCreated, AggregatePayment, p.Id, NewPaymentPayload(p, ""))
+	})
 }
 
 // GetPaymentByReservationId returns payment by reservation id
@@ -49,6 +68,17 @@ func GetPaymentByReservationId(reservationId string) (*Payment, error) {
 	return payment, nil
 }
 
+// GetPaymentBySeriesId returns the prepaid payment of a reservation series
+func GetPaymentBySeriesId(seriesId string) (*Payment, error) {
+	o := orm.NewOrm()
+	payment := &Payment{}
+	err := o.QueryTable(new(Payment)).Filter("series_id", seriesId).One(payment)
+	if err != nil {
+		return nil, err
+	}
+	return payment, nil
+}
+
 // GetPaymentByOrderId returns payment by order id
 func GetPaymentByOrderId(orderId string) (*Payment, error) {
 	o := orm.NewOrm()
@@ -85,6 +115,15 @@ func UpdatePaymentStatus(id string, status string, transactionId string, notific
 	if notification != "" {
 		p.Notification = notification
 	}
-	_, err := o.Update(p)
+	// Only touch the columns we change; nullable FK columns such as series_id must stay NULL
+	_, err := o.Update(p, "status", "transaction_id", "notification", "updated_at")
 	return err
 }
+
+// GetPendingPaymentsCreatedBefore returns payments still pending that were created before t
+func GetPendingPaymentsCreatedBefore(t time.Time) ([]*Payment, error) {
+	o := orm.NewOrm()
+	var list []*Payment
+	_, err := o.QueryTable(new(Payment)).Filter("status", PaymentPending).Filter("created_at__lt", t).OrderBy("created_at").All(&list)
+	return list, err
+}
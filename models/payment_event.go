@@ -0,0 +1,259 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/beego/beego/v2/client/orm"
+)
+
+// Payment event sources
+const (
+	EventSourceWebhook    = "webhook"
+	EventSourceReconciler = "reconciler"
+)
+
+// Payment event outcomes
+const (
+	EventReceived = "received"
+	EventApplied  = "applied"
+	EventStale    = "stale"
+	EventError    = "error"
+	// EventSuspicious is a notification whose amount or currency does not match
+	// the stored payment; it is not applied and is flagged for admin review
+	EventSuspicious = "suspicious"
+)
+
+// PaymentEvent is one gateway notification as received
+type PaymentEvent struct {
+	Id                int64      `orm:"column(id);auto;pk" json:"id"`
+	DedupeKey         string     `orm:"column(dedupe_key);size(255)" json:"dedupe_key"`
+	Gateway           string     `orm:"column(gateway);size(64)" json:"gateway"`
+	OrderId           string     `orm:"column(order_id);size(128)" json:"order_id"`
+	TransactionStatus string     `orm:"column(transaction_status);size(64)" json:"transaction_status"`
+	StatusCode        string     `orm:"column(status_code);size(16)" json:"status_code"`
+	PaymentStatus     string     `orm:"column(payment_status);size(32)" json:"payment_status"`
+	Amount            string     `orm:"column(amount);size(32)" json:"amount"`
+	Currency          string     `orm:"column(currency);size(3)" json:"currency"`
+	TransactionId     string     `orm:"column(transaction_id);size(128);null" json:"transaction_id"`
+	PaymentId         string     `orm:"column(payment_id);size(36);null" json:"payment_id,omitempty"`
+	Source            string     `orm:"column(source);size(20)" json:"source"`
+	Payload           string     `orm:"column(payload);type(text);null" json:"payload"`
+	Outcome           string     `orm:"column(outcome);size(20)" json:"outcome"`
+	Error             string     `orm:"column(error);type(text);null" json:"error,omitempty"`
+	ProcessedAt       *time.Time `orm:"column(processed_at);type(datetime);null" json:"processed_at,omitempty"`
+	ReplayCount       int        `orm:"column(replay_count);default(0)" json:"replay_count"`
+	NeedsReview       bool       `orm:"column(needs_review);default(false)" json:"needs_review"`
+	ReviewedAt        *time.Time `orm:"column(reviewed_at);type(datetime);null" json:"reviewed_at,omitempty"`
+	ReviewNote        string     `orm:"column(review_note);type(text);null" json:"review_note,omitempty"`
+	CreatedAt         time.Time  `orm:"column(created_at);auto_now_add;type(datetime)" json:"created_at"`
+}
+
+func (e *PaymentEvent) TableName() string {
+	return "payment_events"
+}
+
+func (e *PaymentEvent) TableIndex() [][]string {
+	return [][]string{{"order_id"}}
+}
+
+func (e *PaymentEvent) TableUnique() [][]string {
+	return [][]string{{"dedupe_key"}}
+}
+
+func (e *PaymentEvent) TableReferences() map[string]string {
+	return map[string]string{
+		"payment_id": "payments(id)",
+	}
+}
+
+func init() {
+	registerModels(new(PaymentEvent))
+}
+
+// PaymentEventDedupeKey identifies a notification: gateways redeliver the same
+// order/status/code combination, which must only be processed once
+func PaymentEventDedupeKey(orderId, transactionStatus, statusCode string) string {
+	return orderId + "|" + transactionStatus + "|" + statusCode
+}
+
+// Finished reports whether processing the event came to a conclusion: applied, stale or
+// suspicious. An event that errored or was never finished should be applied again when the
+// gateway redelivers it.
+func (e *PaymentEvent) Finished() bool {
+	switch e.Outcome {
+	case EventApplied, EventStale, EventSuspicious:
+		return true
+	}
+	return false
+}
+
+// RecordPaymentEvent stores e unless an event with the same dedupe key exists. It returns
+// true when e was stored (and e.Id set) and false for a duplicate, in which case e is
+// replaced by the stored event.
+func RecordPaymentEvent(e *PaymentEvent) (bool, error) {
+	o := orm.NewOrm()
+	e.Outcome = EventReceived
+	var ids []int64
+	_, err := o.Raw(`INSERT INTO payment_events (dedupe_key, gateway, order_id, transaction_status, status_code, payment_status, amount, currency, transaction_id, source, payload, outcome, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, now())
+		ON CONFLICT (dedupe_key) DO NOTHING RETURNING id`,
+		e.DedupeKey, e.Gateway, e.OrderId, e.TransactionStatus, e.StatusCode, e.PaymentStatus, e.Amount, e.Currency, e.TransactionId, e.Source, e.Payload, e.Outcome).QueryRows(&ids)
+	if err != nil {
+		return false, err
+	}
+	if len(ids) == 1 {
+		e.Id = ids[0]
+		return true, nil
+	}
+
+	existing := &PaymentEvent{}
+	if err := o.QueryTable(new(PaymentEvent)).Filter("dedupe_key", e.DedupeKey).One(existing); err != nil {
+		return false, err
+	}
+	*e = *existing
+	return false, nil
+}
+
+// FinishPaymentEvent stores the outcome of processing an event. A suspicious outcome, or an
+// applied one that needs a closer look (e.g. a settlement for a reservation that had already
+// expired), flags the event for review.
+func FinishPaymentEvent(id int64, paymentId string, outcome string, processErr string, needsReview bool) error {
+	o := orm.NewOrm()
+	_, err := o.Raw("UPDATE payment_events SET payment_id = ?, outcome = ?, error = ?, needs_review = ?, processed_at = now() WHERE id = ?",
+		nullString(paymentId), outcome, processErr, outcome == EventSuspicious || needsReview, id).Exec()
+	return err
+}
+
+// MarkPaymentEventReplayed counts a replay and stores its outcome. A replay that is still
+// suspicious or needs review is flagged for review again.
+func MarkPaymentEventReplayed(id int64, paymentId string, outcome string, processErr string, needsReview bool) error {
+	o := orm.NewOrm()
+	_, err := o.Raw(`UPDATE payment_events SET payment_id = ?, outcome = ?, error = ?, processed_at = now(), replay_count = replay_count + 1,
+		needs_review = needs_review OR ? WHERE id = ?`,
+		nullString(paymentId), outcome, processErr, outcome == EventSuspicious || needsReview, id).Exec()
+	return err
+}
+
+// ReviewPaymentEvent clears the review flag of an event and records the admin's note
+func ReviewPaymentEvent(id int64, note string) (*PaymentEvent, error) {
+	o := orm.NewOrm()
+	res, err := o.Raw("UPDATE payment_events SET needs_review = false, reviewed_at = now(), review_note = ? WHERE id = ?", note, id).Exec()
+	if err != nil {
+		return nil, err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return nil, orm.ErrNoRows
+	}
+	return GetPaymentEventById(id)
+}
+
+// GetPaymentEventById returns a stored event
+func GetPaymentEventById(id int64) (*PaymentEvent, error) {
+	o := orm.NewOrm()
+	e := &PaymentEvent{Id: id}
+	if err := o.Read(e); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// GetPaymentEvents lists events newest first, optionally for one order or outcome, or only
+// those awaiting review
+func GetPaymentEvents(orderId string, outcome string, needsReview bool, limit int) ([]*PaymentEvent, error) {
+	o := orm.NewOrm()
+	qs := o.QueryTable(new(PaymentEvent))
+	if orderId != "" {
+		qs = qs.Filter("order_id", orderId)
+	}
+	if outcome != "" {
+		qs = qs.Filter("outcome", outcome)
+	}
+	if needsReview {
+		qs = qs.Filter("needs_review", true)
+	}
+	var list []*PaymentEvent
+	_, err := qs.OrderBy("-id").Limit(limit).All(&list)
+	return list, err
+}
+
+// paymentStatusRank orders payment statuses so they only move forward. Failure outcomes rank
+// below success so a genuine late settlement still wins over a synthetic expiry; the
+// reservations it covers may be gone by then, which the caller has to resolve (see skipped in
+// AdvancePaymentStatus).
+var paymentStatusRank = map[string]int{
+	PaymentPending:           0,
+	PaymentFailed:            1,
+	PaymentExpired:           1,
+	PaymentSuccess:           2,
+	PaymentPartiallyRefunded: 3,
+	PaymentRefunded:          4,
+}
+
+// IsPaymentStatusAdvance reports whether a payment may move from -> to
+func IsPaymentStatusAdvance(from, to string) bool {
+	return paymentStatusRank[to] > paymentStatusRank[from]
+}
+
+// AdvancePaymentStatus moves the payment to status only when that is a step forward, storing
+// transactionId and the notification that caused it, and in the same transaction moves the
+// reservations the payment covers (every occurrence of a prepaid series) to
+// reservationStatus. It reports whether the payment changed; stale or duplicate statuses
+// leave it untouched. Reservations the state machine refuses to move, e.g. an occurrence
+// cancelled before payment, keep their status and are returned in skipped. Reaching success,
+// failed, expired or refunded writes the matching payment event to the outbox.
+func AdvancePaymentStatus(id string, status string, transactionId string, notification string, reservationStatus string) (advanced bool, skipped []*InvalidTransitionError, err error) {
+	o := orm.NewOrm()
+	err = o.DoTx(func(ctx context.Context, txOrm orm.TxOrmer) error {
+		advanced, skipped = false, nil
+		p := &Payment{Id: id}
+		if err := txOrm.ReadForUpdate(p); err != nil {
+			return err
+		}
+		if !IsPaymentStatusAdvance(p.Status, status) {
+			return nil
+		}
+		from := p.Status
+		p.Status = status
+		if transactionId != "" {
+			p.TransactionId = transactionId
+		}
+		if notification != "" {
+			p.Notification = notification
+		}
+		// Only touch the columns we change; nullable FK columns such as series_id must stay NULL
+		if _, err := txOrm.Update(p, "status", "transaction_id", "notification", "updated_at"); err != nil {
+			return err
+		}
+		advanced = true
+		if eventType, ok := paymentStatusEvents[status]; ok {
+			if err := enqueueEvent(txOrm, eventType, AggregatePayment, p.Id, NewPaymentPayload(p, from)); err != nil {
+				return err
+			}
+		}
+
+		reservationIds := []string{p.ReservationId}
+		if p.SeriesId != "" {
+			if _, err := txOrm.Raw("SELECT id FROM reservations WHERE series_id = ? ORDER BY booking_date", p.SeriesId).QueryRows(&reservationIds); err != nil {
+				return err
+			}
+		}
+		for _, reservationId := range reservationIds {
+			err := transitionReservation(txOrm, reservationId, reservationStatus)
+			var transitionErr *InvalidTransitionError
+			if errors.As(err, &transitionErr) {
+				skipped = append(skipped, transitionErr)
+				continue
+			}
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return false, nil, err
+	}
+	return advanced, skipped, nil
+}
@@ -0,0 +1,88 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestPaymentEventFinished(t *testing.T) {
+	for outcome, finished := range map[string]bool{
+		EventReceived:   false,
+		EventError:      false,
+		EventApplied:    true,
+		EventStale:      true,
+		EventSuspicious: true,
+	} {
+		if got := (&PaymentEvent{Outcome: outcome}).Finished(); got != finished {
+			t.Errorf("%s: Finished() = %v, want %v", outcome, got, finished)
+		}
+	}
+}
+
+func TestRecordPaymentEventRedeliveryAfterError(t *testing.T) {
+	requireDB(t)
+	key := PaymentEventDedupeKey("TEST-"+uuid.New().String(), "settlement", "200")
+	first := &PaymentEvent{DedupeKey: key, Gateway: "midtrans", OrderId: "TEST", Source: EventSourceWebhook}
+	if stored, err := RecordPaymentEvent(first); err != nil || !stored {
+		t.Fatalf("first delivery: stored %v, err %v", stored, err)
+	}
+	if err := FinishPaymentEvent(first.Id, "", EventError, "payment not found for order TEST", false); err != nil {
+		t.Fatal(err)
+	}
+
+	again := &PaymentEvent{DedupeKey: key, Gateway: "midtrans", OrderId: "TEST", Source: EventSourceWebhook}
+	stored, err := RecordPaymentEvent(again)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored || again.Id != first.Id || again.Finished() {
+		t.Errorf("redelivery: stored %v, id %d (first %d), outcome %s; want the unfinished first event", stored, again.Id, first.Id, again.Outcome)
+	}
+}
+
+func TestAdvancePaymentStatusMovesReservation(t *testing.T) {
+	requireDB(t)
+	court, slot := testCourtSlot(t)
+	r := &Reservation{
+		Id:            uuid.New().String(),
+		CourtId:       court.Id,
+		TimeslotId:    slot.Id,
+		BookingDate:   time.Now().AddDate(0, 0, 1).Format("2006-01-02"),
+		CustomerName:  "Payment Tester",
+		CustomerEmail: "payment@example.com",
+		CustomerPhone: "+6281234567890",
+		TotalPrice:    court.PricePerHour,
+		Status:        ReservationPending,
+		ExpiredAt:     time.Now().Add(30 * time.Minute),
+	}
+	if err := CreateReservation(r); err != nil {
+		t.Fatal(err)
+	}
+	p := &Payment{Id: uuid.New().String(), ReservationId: r.Id, OrderId: "TEST-" + uuid.New().String(), Amount: r.TotalPrice, PaymentGateway: "midtrans", Status: PaymentPending}
+	if err := CreatePayment(p); err != nil {
+		t.Fatal(err)
+	}
+
+	advanced, skipped, err := AdvancePaymentStatus(p.Id, PaymentSuccess, "TRX-1", "{}", ReservationPaid)
+	if err != nil || !advanced || len(skipped) != 0 {
+		t.Fatalf("advanced %v, skipped %v, err %v", advanced, skipped, err)
+	}
+	got, err := GetReservationById(r.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Status != ReservationPaid {
+		t.Errorf("reservation is %s, want paid", got.Status)
+	}
+
+	// A stale update leaves both untouched
+	advanced, _, err = AdvancePaymentStatus(p.Id, PaymentExpired, "", "", ReservationExpired)
+	if err != nil || advanced {
+		t.Fatalf("stale update: advanced %v, err %v", advanced, err)
+	}
+	if got, _ := GetReservationById(r.Id); got.Status != ReservationPaid {
+		t.Errorf("reservation moved to %s on a stale update", got.Status)
+	}
+}
@@ -0,0 +1,107 @@
+package models
+
+import (
+	"time"
+
+	"github.com/beego/beego/v2/client/orm"
+)
+
+// Pricing rule adjustments (see pricing_rules.adjustment CHECK constraint)
+const (
+	PricingFixed      = "fixed"
+	PricingMultiplier = "multiplier"
+	PricingSurcharge  = "surcharge"
+)
+
+// PricingRule changes the price of slots matching its court, weekday, time and date range.
+// Fixed and surcharge rules use Amount, multiplier rules use Multiplier. Empty
+// weekday/time/date fields and a nil CourtId match everything.
+type PricingRule struct {
+	Id         int       `orm:"column(id);auto;pk" json:"id"`
+	Name       string    `orm:"column(name);size(100)" json:"name"`
+	CourtId    *int      `orm:"column(court_id);null" json:"court_id"`
+	Weekdays   string    `orm:"column(weekdays);size(20)" json:"weekdays"`
+	StartTime  string    `orm:"column(start_time);size(10)" json:"start_time"`
+	EndTime    string    `orm:"column(end_time);size(10)" json:"end_time"`
+	StartDate  string    `orm:"column(start_date);size(10)" json:"start_date"`
+	EndDate    string    `orm:"column(end_date);size(10)" json:"end_date"`
+	Adjustment string    `orm:"column(adjustment);size(20)" json:"adjustment"`
+	Amount     Money     `orm:"column(amount);digits(10);decimals(2);default(0)" json:"amount"`
+	Multiplier float64   `orm:"column(multiplier);digits(12);decimals(4);default(1)" json:"multiplier"`
+	Priority   int       `orm:"column(priority);default(0)" json:"priority"`
+	IsActive   bool      `orm:"column(is_active);default(true)" json:"is_active"`
+	CreatedAt  time.Time `orm:"column(created_at);auto_now_add;type(datetime)" json:"created_at"`
+	UpdatedAt  time.Time `orm:"column(updated_at);auto_now;type(datetime)" json:"updated_at"`
+}
+
+func (r *PricingRule) TableName() string {
+	return "pricing_rules"
+}
+
+func (r *PricingRule) TableIndex() [][]string {
+	return [][]string{{"is_active"}}
+}
+
+func (r *PricingRule) TableReferences() map[string]string {
+	return map[string]string{
+		"court_id": "courts(id)",
+	}
+}
+
+func init() {
+	registerModels(new(PricingRule))
+}
+
+// IsValidPricingAdjustment reports whether a is a known adjustment
+func IsValidPricingAdjustment(a string) bool {
+	return a == PricingFixed || a == PricingMultiplier || a == PricingSurcharge
+}
+
+// GetActivePricingRules returns active rules, highest priority first
+func GetActivePricingRules() ([]*PricingRule, error) {
+	o := orm.NewOrm()
+	var rules []*PricingRule
+	_, err := o.QueryTable(new(PricingRule)).Filter("is_active", true).OrderBy("-priority", "id").All(&rules)
+	return rules, err
+}
+
+// GetAllPricingRules returns every rule, highest priority first
+func GetAllPricingRules() ([]*PricingRule, error) {
+	o := orm.NewOrm()
+	var rules []*PricingRule
+	_, err := o.QueryTable(new(PricingRule)).OrderBy("-priority", "id").All(&rules)
+	return rules, err
+}
+
+// GetPricingRuleById returns a rule by id
+func GetPricingRuleById(id int) (*PricingRule, error) {
+	o := orm.NewOrm()
+	r := &PricingRule{Id: id}
+	if err := o.Read(r); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// CreatePricingRule inserts a rule and sets its generated id
+func CreatePricingRule(r *PricingRule) error {
+	o := orm.NewOrm()
+	// Use RETURNING instead of LastInsertId, which the Postgres driver does not support
+	return o.Raw(`INSERT INTO pricing_rules (name, court_id, weekdays, start_time, end_time, start_date, end_date, adjustment, amount, multiplier, priority, is_active, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, now(), now()) RETURNING id`,
+		r.Name, nullInt(r.CourtId), r.Weekdays, r.StartTime, r.EndTime, r.StartDate, r.EndDate, r.Adjustment, r.Amount.String(), r.Multiplier, r.Priority, r.IsActive).QueryRow(&r.Id)
+}
+
+// UpdatePricingRule saves all editable columns of a rule
+func UpdatePricingRule(r *PricingRule) error {
+	o := orm.NewOrm()
+	_, err := o.Update(r, "name", "court_id", "weekdays", "start_time", "end_time", "start_date", "end_date", "adjustment", "amount", "multiplier", "priority", "is_active")
+	return err
+}
+
+// DeletePricingRule removes a rule
+func DeletePricingRule(id int) error {
+	o := orm.NewOrm()
+	_, err := o.Delete(&PricingRule{Id: id})
+	return err
+}
@@ -0,0 +1,332 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/beego/beego/v2/client/orm"
+)
+
+// Promo discount types (see promo_codes.discount_type CHECK constraint)
+const (
+	PromoPercent = "percent"
+	PromoFixed   = "fixed"
+)
+
+var (
+	// ErrPromoCodeInUse is returned when deleting a promo code that has been redeemed
+	ErrPromoCodeInUse = errors.New("promo code has been redeemed")
+	// ErrDuplicatePromoCode is returned when creating or renaming to a code that already exists
+	ErrDuplicatePromoCode = errors.New("promo code already exists")
+)
+
+// PromoError explains why a promo code cannot be applied to a reservation
+type PromoError struct {
+	Reason string
+}
+
+func (e *PromoError) Error() string {
+	return e.Reason
+}
+
+// PromoCode is a discount voucher. Fixed codes take DiscountAmount off, percent codes
+// DiscountPercent of the eligible slots. Zero limits mean unlimited; empty
+// CourtIds/TimeslotIds (comma-separated ids) mean every court/timeslot.
+type PromoCode struct {
+	Id               int        `orm:"column(id);auto;pk" json:"id"`
+	Code             string     `orm:"column(code);size(50)" json:"code"`
+	Description      string     `orm:"column(description);type(text);null" json:"description"`
+	DiscountType     string     `orm:"column(discount_type);size(20)" json:"discount_type"`
+	DiscountAmount   Money      `orm:"column(discount_amount);digits(10);decimals(2);default(0)" json:"discount_amount"`
+	DiscountPercent  float64    `orm:"column(discount_percent);digits(5);decimals(2);default(0)" json:"discount_percent"`
+	MaxDiscount      Money      `orm:"column(max_discount);digits(10);decimals(2);default(0)" json:"max_discount"`
+	MinOrder         Money      `orm:"column(min_order);digits(10);decimals(2);default(0)" json:"min_order"`
+	ValidFrom        *time.Time `orm:"column(valid_from);type(datetime);null" json:"valid_from"`
+	ValidUntil       *time.Time `orm:"column(valid_until);type(datetime);null" json:"valid_until"`
+	MaxUses          int        `orm:"column(max_uses);default(0)" json:"max_uses"`
+	MaxUsesPerEmail  int        `orm:"column(max_uses_per_email);default(0)" json:"max_uses_per_email"`
+	FirstBookingOnly bool       `orm:"column(first_booking_only);default(false)" json:"first_booking_only"`
+	CourtIds         string     `orm:"column(court_ids);size(255)" json:"court_ids"`
+	TimeslotIds      string     `orm:"column(timeslot_ids);size(255)" json:"timeslot_ids"`
+	IsActive         bool       `orm:"column(is_active);default(true)" json:"is_active"`
+	CreatedAt        time.Time  `orm:"column(created_at);auto_now_add;type(datetime)" json:"created_at"`
+	UpdatedAt        time.Time  `orm:"column(updated_at);auto_now;type(datetime)" json:"updated_at"`
+
+	// Uses counts redemptions of reservations that were not cancelled or expired (admin listing only)
+	Uses int64 `orm:"-" json:"uses"`
+}
+
+func (p *PromoCode) TableName() string {
+	return "promo_codes"
+}
+
+func (p *PromoCode) TableUnique() [][]string {
+	return [][]string{{"code"}}
+}
+
+// PromoRedemption records a promo code used by a reservation
+type PromoRedemption struct {
+	Id             int64     `orm:"column(id);auto;pk" json:"id"`
+	PromoCodeId    int       `orm:"column(promo_code_id)" json:"promo_code_id"`
+	ReservationId  string    `orm:"column(reservation_id);size(36)" json:"reservation_id"`
+	CustomerEmail  string    `orm:"column(customer_email);size(255)" json:"customer_email"`
+	DiscountAmount Money     `orm:"column(discount_amount);digits(10);decimals(2)" json:"discount_amount"`
+	CreatedAt      time.Time `orm:"column(created_at);auto_now_add;type(datetime)" json:"created_at"`
+}
+
+func (r *PromoRedemption) TableName() string {
+	return "promo_redemptions"
+}
+
+func (r *PromoRedemption) TableIndex() [][]string {
+	return [][]string{{"promo_code_id"}}
+}
+
+func (r *PromoRedemption) TableUnique() [][]string {
+	return [][]string{{"reservation_id"}}
+}
+
+func (r *PromoRedemption) TableReferences() map[string]string {
+	return map[string]string{
+		"promo_code_id":  "promo_codes(id)",
+		"reservation_id": "reservations(id)",
+	}
+}
+
+func init() {
+	registerModels(new(PromoCode), new(PromoRedemption))
+}
+
+// NormalizePromoCode trims and upper-cases a code; codes are matched case-insensitively
+func NormalizePromoCode(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
+
+// ParseIdList parses a comma-separated id list; an empty string yields an empty set
+func ParseIdList(s string) (map[int]bool, error) {
+	ids := map[int]bool{}
+	if strings.TrimSpace(s) == "" {
+		return ids, nil
+	}
+	for _, part := range strings.Split(s, ",") {
+		id, err := strconv.Atoi(strings.TrimSpace(part))
+		if err != nil || id <= 0 {
+			return nil, fmt.Errorf("invalid id %q", strings.TrimSpace(part))
+		}
+		ids[id] = true
+	}
+	return ids, nil
+}
+
+// Discount returns the discount p gives on items at time now, or a *PromoError when the code
+// does not apply. Only items on the code's courts/timeslots are discounted. Discounts are
+// rounded down to whole currency units and always leave at least 1 to pay, since the
+// gateways cannot charge 0.
+func (p *PromoCode) Discount(items []*ReservationItem, now time.Time) (Money, error) {
+	if !p.IsActive {
+		return Money{}, &PromoError{Reason: "promo code is not active"}
+	}
+	if p.ValidFrom != nil && now.Before(*p.ValidFrom) {
+		return Money{}, &PromoError{Reason: "promo code is not valid yet"}
+	}
+	if p.ValidUntil != nil && now.After(*p.ValidUntil) {
+		return Money{}, &PromoError{Reason: "promo code has expired"}
+	}
+
+	courts, _ := ParseIdList(p.CourtIds)
+	timeslots, _ := ParseIdList(p.TimeslotIds)
+	var subtotal, eligible Money
+	var err error
+	for _, item := range items {
+		if subtotal, err = subtotal.Add(item.Price); err != nil {
+			return Money{}, err
+		}
+		if (len(courts) == 0 || courts[item.CourtId]) && (len(timeslots) == 0 || timeslots[item.TimeslotId]) {
+			if eligible, err = eligible.Add(item.Price); err != nil {
+				return Money{}, err
+			}
+		}
+	}
+	if eligible.IsZero() {
+		return Money{}, &PromoError{Reason: "promo code does not apply to the selected court or timeslot"}
+	}
+	if subtotal.Amount < p.MinOrder.Amount {
+		return Money{}, &PromoError{Reason: "promo code requires a minimum order of " + p.MinOrder.String()}
+	}
+
+	discount := p.DiscountAmount
+	if p.DiscountType == PromoPercent {
+		discount = eligible.Percent(p.DiscountPercent)
+		if !p.MaxDiscount.IsZero() {
+			if discount, err = discount.Min(p.MaxDiscount); err != nil {
+				return Money{}, err
+			}
+		}
+	}
+	if discount, err = discount.FloorWhole().Min(eligible); err != nil {
+		return Money{}, err
+	}
+	// Leave one whole unit of the subtotal's currency to pay
+	maxDiscount, err := subtotal.Sub(subtotal.with(subtotal.scale()))
+	if err != nil {
+		return Money{}, err
+	}
+	if discount.Amount > maxDiscount.Amount {
+		discount = maxDiscount
+		if discount.Amount < 0 {
+			discount = Money{}
+		}
+	}
+	return discount, nil
+}
+
+// redeemPromoCode applies r.PromoCode to the new reservation r inside its creation
+// transaction: the code row is locked so usage limits hold under concurrent bookings, the
+// discount is taken off r.TotalPrice and the redemption is recorded. Must run after the
+// reservation row is inserted.
+func redeemPromoCode(txOrm orm.TxOrmer, r *Reservation) error {
+	p := &PromoCode{Code: r.PromoCode}
+	err := txOrm.ReadForUpdate(p, "Code")
+	if errors.Is(err, orm.ErrNoRows) {
+		return &PromoError{Reason: "promo code not found"}
+	}
+	if err != nil {
+		return err
+	}
+
+	discount, err := p.Discount(r.Items, time.Now())
+	if err != nil {
+		return err
+	}
+
+	if p.MaxUses > 0 || p.MaxUsesPerEmail > 0 {
+		var total, byEmail int64
+		if err := txOrm.Raw(`SELECT COUNT(*), COUNT(*) FILTER (WHERE lower(pr.customer_email) = lower(?))
+			FROM promo_redemptions pr JOIN reservations res ON res.id = pr.reservation_id
+			WHERE pr.promo_code_id = ? AND res.status NOT IN (?, ?)`,
+			r.CustomerEmail, p.Id, ReservationCancelled, ReservationExpired).QueryRow(&total, &byEmail); err != nil {
+			return err
+		}
+		if p.MaxUses > 0 && total >= int64(p.MaxUses) {
+			return &PromoError{Reason: "promo code has reached its usage limit"}
+		}
+		if p.MaxUsesPerEmail > 0 && byEmail >= int64(p.MaxUsesPerEmail) {
+			return &PromoError{Reason: "promo code has already been used by this customer"}
+		}
+	}
+	if p.FirstBookingOnly {
+		var previous int64
+		if err := txOrm.Raw(`SELECT COUNT(*) FROM reservations
+			WHERE lower(customer_email) = lower(?) AND id <> ? AND status NOT IN (?, ?)`,
+			r.CustomerEmail, r.Id, ReservationCancelled, ReservationExpired).QueryRow(&previous); err != nil {
+			return err
+		}
+		if previous > 0 {
+			return &PromoError{Reason: "promo code is only valid for a customer's first booking"}
+		}
+	}
+
+	total, err := r.TotalPrice.Sub(discount)
+	if err != nil {
+		return err
+	}
+	r.DiscountAmount = discount
+	r.TotalPrice = total
+	if _, err := txOrm.Raw("UPDATE reservations SET total_price = ?, discount_amount = ?, promo_code = ? WHERE id = ?",
+		r.TotalPrice.String(), r.DiscountAmount.String(), r.PromoCode, r.Id).Exec(); err != nil {
+		return err
+	}
+	_, err = txOrm.Raw(`INSERT INTO promo_redemptions (promo_code_id, reservation_id, customer_email, discount_amount, created_at)
+		VALUES (?, ?, ?, ?, now())`, p.Id, r.Id, r.CustomerEmail, discount.String()).Exec()
+	return err
+}
+
+// GetAllPromoCodes returns every promo code with its current usage, newest first
+func GetAllPromoCodes() ([]*PromoCode, error) {
+	o := orm.NewOrm()
+	var list []*PromoCode
+	if _, err := o.QueryTable(new(PromoCode)).OrderBy("-id").All(&list); err != nil {
+		return nil, err
+	}
+
+	type usage struct {
+		PromoCodeId int
+		Uses        int64
+	}
+	var counts []usage
+	if _, err := o.Raw(`SELECT pr.promo_code_id, COUNT(*) AS uses
+		FROM promo_redemptions pr JOIN reservations res ON res.id = pr.reservation_id
+		WHERE res.status NOT IN (?, ?) GROUP BY pr.promo_code_id`,
+		ReservationCancelled, ReservationExpired).QueryRows(&counts); err != nil {
+		return nil, err
+	}
+	byId := make(map[int]int64, len(counts))
+	for _, c := range counts {
+		byId[c.PromoCodeId] = c.Uses
+	}
+	for _, p := range list {
+		p.Uses = byId[p.Id]
+	}
+	return list, nil
+}
+
+// GetPromoCodeById returns a promo code by id
+func GetPromoCodeById(id int) (*PromoCode, error) {
+	o := orm.NewOrm()
+	p := &PromoCode{Id: id}
+	if err := o.Read(p); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// GetPromoCodeByCode returns a promo code by its (normalized) code
+func GetPromoCodeByCode(code string) (*PromoCode, error) {
+	o := orm.NewOrm()
+	p := &PromoCode{}
+	if err := o.QueryTable(new(PromoCode)).Filter("code", NormalizePromoCode(code)).One(p); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// CreatePromoCode inserts a promo code and sets its generated id. A duplicate code is
+// reported as ErrDuplicatePromoCode.
+func CreatePromoCode(p *PromoCode) error {
+	o := orm.NewOrm()
+	// Use RETURNING instead of LastInsertId, which the Postgres driver does not support
+	err := o.Raw(`INSERT INTO promo_codes (code, description, discount_type, discount_amount, discount_percent, max_discount, min_order, valid_from, valid_until,
+			max_uses, max_uses_per_email, first_booking_only, court_ids, timeslot_ids, is_active, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, now(), now()) RETURNING id`,
+		p.Code, p.Description, p.DiscountType, p.DiscountAmount.String(), p.DiscountPercent, p.MaxDiscount.String(), p.MinOrder.String(), p.ValidFrom, p.ValidUntil,
+		p.MaxUses, p.MaxUsesPerEmail, p.FirstBookingOnly, p.CourtIds, p.TimeslotIds, p.IsActive).QueryRow(&p.Id)
+	if isUniqueViolation(err, "idx_promo_codes_code") {
+		return ErrDuplicatePromoCode
+	}
+	return err
+}
+
+// UpdatePromoCode saves all editable columns of a promo code
+func UpdatePromoCode(p *PromoCode) error {
+	o := orm.NewOrm()
+	_, err := o.Update(p, "code", "description", "discount_type", "discount_amount", "discount_percent", "max_discount", "min_order", "valid_from", "valid_until",
+		"max_uses", "max_uses_per_email", "first_booking_only", "court_ids", "timeslot_ids", "is_active")
+	if isUniqueViolation(err, "idx_promo_codes_code") {
+		return ErrDuplicatePromoCode
+	}
+	return err
+}
+
+// DeletePromoCode removes a promo code. It returns ErrPromoCodeInUse once the code has been
+// redeemed (promo_redemptions.promo_code_id is ON DELETE RESTRICT); deactivate it instead.
+func DeletePromoCode(id int) error {
+	o := orm.NewOrm()
+	_, err := o.Delete(&PromoCode{Id: id})
+	if isForeignKeyViolation(err) {
+		return ErrPromoCodeInUse
+	}
+	return err
+}
@@ -0,0 +1,53 @@
+package models
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestPromoCodeDiscount(t *testing.T) {
+	t.Setenv("CURRENCY", "IDR")
+	now := time.Now()
+	items := []*ReservationItem{
+		{CourtId: 1, TimeslotId: 1, Price: Whole(75001)},
+		{CourtId: 2, TimeslotId: 1, Price: Whole(50000)},
+	}
+
+	tests := []struct {
+		name string
+		code *PromoCode
+		want string
+	}{
+		// 12.5% of 125001 = 15625.125, floored to whole units
+		{"percent floors to whole units", &PromoCode{DiscountType: PromoPercent, DiscountPercent: 12.5}, "15625.00"},
+		{"percent capped", &PromoCode{DiscountType: PromoPercent, DiscountPercent: 12.5, MaxDiscount: Whole(10000)}, "10000.00"},
+		{"only the eligible court", &PromoCode{DiscountType: PromoPercent, DiscountPercent: 10, CourtIds: "2"}, "5000.00"},
+		{"fixed", &PromoCode{DiscountType: PromoFixed, DiscountAmount: Whole(20000)}, "20000.00"},
+		{"fixed leaves 1 to pay", &PromoCode{DiscountType: PromoFixed, DiscountAmount: Whole(500000)}, "125000.00"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.code.IsActive = true
+			d, err := tt.code.Discount(items, now)
+			if err != nil || d.String() != tt.want {
+				t.Errorf("Discount = %s, %v; want %s", d, err, tt.want)
+			}
+		})
+	}
+
+	t.Run("minimum order compared exactly", func(t *testing.T) {
+		code := &PromoCode{DiscountType: PromoFixed, DiscountAmount: Whole(500000), MinOrder: mustParse(t, "125001.01"), IsActive: true}
+		var promoErr *PromoError
+		if _, err := code.Discount(items, now); !errors.As(err, &promoErr) {
+			t.Errorf("got %v, want a *PromoError", err)
+		}
+	})
+
+	t.Run("currency mismatch", func(t *testing.T) {
+		code := &PromoCode{DiscountType: PromoFixed, DiscountAmount: NewMoney(100, "USD"), IsActive: true}
+		if _, err := code.Discount(items, now); !errors.Is(err, ErrCurrencyMismatch) {
+			t.Errorf("got %v, want ErrCurrencyMismatch", err)
+		}
+	})
+}
@@ -0,0 +1,297 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/beego/beego/v2/client/orm"
+)
+
+// Payment statuses
+const (
+	PaymentPending           = "pending"
+	PaymentSuccess           = "success"
+	PaymentFailed            = "failed"
+	PaymentExpired           = "expired"
+	PaymentPartiallyRefunded = "partially_refunded"
+	PaymentRefunded          = "refunded"
+)
+
+// Refund statuses
+const (
+	RefundPending   = "pending"
+	RefundSucceeded = "succeeded"
+	RefundFailed    = "failed"
+)
+
+var (
+	// ErrPaymentNotRefundable is returned when refunding a payment that was never settled
+	ErrPaymentNotRefundable = errors.New("payment has not been settled and cannot be refunded")
+	// ErrRefundExceedsPayment is returned when a refund would return more than was paid
+	ErrRefundExceedsPayment = errors.New("refund exceeds the remaining refundable amount")
+	// ErrRefundNotCovered is returned when refunding a reservation the payment did not pay for
+	ErrRefundNotCovered = errors.New("reservation is not covered by this payment")
+)
+
+// Refund is a full or partial refund of a payment
+type Refund struct {
+	Id              string    `orm:"column(id);pk;size(36)" json:"id"`
+	PaymentId       string    `orm:"column(payment_id);size(36)" json:"payment_id"`
+	ReservationId   string    `orm:"column(reservation_id);size(36)" json:"reservation_id"`
+	Amount          Money     `orm:"column(amount);digits(10);decimals(2)" json:"amount"`
+	Reason          string    `orm:"column(reason);type(text);null" json:"reason"`
+	RefundKey       string    `orm:"column(refund_key);size(64)" json:"refund_key"`
+	Status          string    `orm:"column(status);size(20);default(pending)" json:"status"`
+	GatewayRefundId string    `orm:"column(gateway_refund_id);size(128);null" json:"gateway_refund_id"`
+	GatewayResponse string    `orm:"column(gateway_response);type(text);null" json:"gateway_response,omitempty"`
+	CreatedAt       time.Time `orm:"column(created_at);auto_now_add;type(datetime)" json:"created_at"`
+	UpdatedAt       time.Time `orm:"column(updated_at);auto_now;type(datetime)" json:"updated_at"`
+}
+
+func (r *Refund) TableName() string {
+	return "refunds"
+}
+
+func (r *Refund) TableIndex() [][]string {
+	return [][]string{{"payment_id"}}
+}
+
+func (r *Refund) TableUnique() [][]string {
+	return [][]string{{"refund_key"}}
+}
+
+func (r *Refund) TableReferences() map[string]string {
+	return map[string]string{
+		"payment_id":     "payments(id)",
+		"reservation_id": "reservations(id)",
+	}
+}
+
+func init() {
+	registerModels(new(Refund))
+}
+
+// RefundableAmount returns how much of the payment can still be refunded, counting refunds
+// that are pending at the gateway
+func RefundableAmount(paymentId string) (Money, error) {
+	o := orm.NewOrm()
+	p := &Payment{Id: paymentId}
+	if err := o.Read(p); err != nil {
+		return Money{}, err
+	}
+	return refundableAmount(o, p)
+}
+
+func refundableAmount(q orm.QueryExecutor, p *Payment) (Money, error) {
+	// Scanned as text so the NUMERIC sum stays exact
+	var sum string
+	err := q.Raw("SELECT COALESCE(SUM(amount), 0)::text FROM refunds WHERE payment_id = ? AND status IN (?, ?)", p.Id, RefundPending, RefundSucceeded).QueryRow(&sum)
+	if err != nil {
+		return Money{}, err
+	}
+	committed, err := ParseMoney(sum, p.Amount.Currency)
+	if err != nil {
+		return Money{}, err
+	}
+	return p.Amount.Sub(committed)
+}
+
+// refundedAmount is the total refunded for a reservation, counting refunds still pending at
+// the gateway since they may already have been paid out, and whether any of them is pending
+func refundedAmount(q orm.QueryExecutor, reservationId string, currency string) (Money, bool, error) {
+	var sum string
+	var pending int64
+	err := q.Raw(`SELECT COALESCE(SUM(amount), 0)::text, COUNT(*) FILTER (WHERE status = ?)
+		FROM refunds WHERE reservation_id = ? AND status IN (?, ?)`, RefundPending, reservationId, RefundPending, RefundSucceeded).QueryRow(&sum, &pending)
+	if err != nil {
+		return Money{}, false, err
+	}
+	refunded, err := ParseMoney(sum, currency)
+	return refunded, pending > 0, err
+}
+
+// coversReservation reports whether payment p paid for reservationId: its own reservation or,
+// for a prepaid series payment, any occurrence of the series
+func coversReservation(q orm.QueryExecutor, p *Payment, reservationId string) (bool, error) {
+	if reservationId == p.ReservationId {
+		return true, nil
+	}
+	if p.SeriesId == "" {
+		return false, nil
+	}
+	cnt, err := q.QueryTable(new(Reservation)).Filter("id", reservationId).Filter("series_id", p.SeriesId).Count()
+	return cnt > 0, err
+}
+
+// CreateRefund records a pending refund after checking, with the payment row locked, that the
+// payment was settled and the amount fits in what is left to refund. r.ReservationId is the
+// reservation the refund is for, e.g. a cancelled occurrence of a prepaid series; it defaults
+// to the payment's reservation.
+func CreateRefund(r *Refund) error {
+	o := orm.NewOrm()
+	return o.DoTx(func(ctx context.Context, txOrm orm.TxOrmer) error {
+		p := &Payment{Id: r.PaymentId}
+		if err := txOrm.ReadForUpdate(p); err != nil {
+			return err
+		}
+		if p.Status != PaymentSuccess && p.Status != PaymentPartiallyRefunded {
+			return ErrPaymentNotRefundable
+		}
+		remaining, err := refundableAmount(txOrm, p)
+		if err != nil {
+			return err
+		}
+		if r.Amount.Amount <= 0 || r.Amount.Amount > remaining.Amount {
+			return ErrRefundExceedsPayment
+		}
+
+		if r.ReservationId == "" {
+			r.ReservationId = p.ReservationId
+		} else if covered, err := coversReservation(txOrm, p, r.ReservationId); err != nil {
+			return err
+		} else if !covered {
+			return ErrRefundNotCovered
+		}
+		r.Status = RefundPending
+		_, err = txOrm.Raw(`INSERT INTO refunds (id, payment_id, reservation_id, amount, reason, refund_key, status, created_at, updated_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?, now(), now())`, r.Id, r.PaymentId, r.ReservationId, r.Amount.String(), r.Reason, r.RefundKey, r.Status).Exec()
+		return err
+	})
+}
+
+// RecordRefundAttempt stores the gateway's answer to a refund it has not completed yet, or the
+// error reaching the gateway, on a refund that stays pending
+func RecordRefundAttempt(refundId string, gatewayRefundId string, gatewayResponse string) error {
+	o := orm.NewOrm()
+	_, err := o.Raw(`UPDATE refunds SET gateway_refund_id = COALESCE(NULLIF(?, ''), gateway_refund_id), gateway_response = ?, updated_at = now()
+		WHERE id = ? AND status = ?`, gatewayRefundId, gatewayResponse, refundId, RefundPending).Exec()
+	return err
+}
+
+// CompleteRefund stores the gateway outcome of a refund. A succeeded refund is added to the
+// payment's refunded amount, moving the payment to partially_refunded or refunded, and the
+// reservation moves to refunded once the payment is fully refunded or, for a cancelled
+// reservation, once its own succeeded refunds cover what it is owed under the cancellation
+// policy and none of them is still pending (a series payment also covers the other
+// occurrences, which keep their status). The
+// outcome is written to the outbox as RefundSucceeded or RefundFailed, with PaymentRefunded
+// once nothing is left to refund.
+func CompleteRefund(refundId string, status string, gatewayRefundId string, gatewayResponse string) (*Refund, error) {
+	o := orm.NewOrm()
+	r := &Refund{Id: refundId}
+	err := o.DoTx(func(ctx context.Context, txOrm orm.TxOrmer) error {
+		if err := txOrm.ReadForUpdate(r); err != nil {
+			return err
+		}
+		if r.Status != RefundPending {
+			// Already completed; keep the first outcome
+			return nil
+		}
+		r.Status = status
+		r.GatewayRefundId = gatewayRefundId
+		r.GatewayResponse = gatewayResponse
+		if _, err := txOrm.Update(r, "status", "gateway_refund_id", "gateway_response", "updated_at"); err != nil {
+			return err
+		}
+		if status != RefundSucceeded {
+			return enqueueEvent(txOrm, EventRefundFailed, AggregateRefund, r.Id, NewRefundPayload(r))
+		}
+		if err := enqueueEvent(txOrm, EventRefundSucceeded, AggregateRefund, r.Id, NewRefundPayload(r)); err != nil {
+			return err
+		}
+
+		p := &Payment{Id: r.PaymentId}
+		if err := txOrm.ReadForUpdate(p); err != nil {
+			return err
+		}
+		refunded, err := p.RefundedAmount.Add(r.Amount)
+		if err != nil {
+			return err
+		}
+		paymentStatus := PaymentPartiallyRefunded
+		if refunded.Amount >= p.Amount.Amount {
+			paymentStatus = PaymentRefunded
+		}
+		if _, err := txOrm.Raw("UPDATE payments SET refunded_amount = ?, status = ?, updated_at = now() WHERE id = ?", refunded.String(), paymentStatus, p.Id).Exec(); err != nil {
+			return err
+		}
+		if paymentStatus == PaymentRefunded {
+			from := p.Status
+			p.Status, p.RefundedAmount = paymentStatus, refunded
+			if err := enqueueEvent(txOrm, EventPaymentRefunded, AggregatePayment, p.Id, NewPaymentPayload(p, from)); err != nil {
+				return err
+			}
+		}
+
+		// A prepaid series payment covers every occurrence of the series
+		var reservations []*Reservation
+		qs := txOrm.QueryTable(new(Reservation))
+		if p.SeriesId != "" {
+			qs = qs.Filter("series_id", p.SeriesId)
+		} else {
+			qs = qs.Filter("id", p.ReservationId)
+		}
+		if _, err := qs.All(&reservations); err != nil {
+			return err
+		}
+		for _, res := range reservations {
+			if paymentStatus != PaymentRefunded {
+				// Only a cancelled reservation whose own refunds cover what it is owed
+				if res.Status != ReservationCancelled || res.RefundAmount.Amount <= 0 {
+					continue
+				}
+				paid, pending, err := refundedAmount(txOrm, res.Id, p.Amount.Currency)
+				if err != nil {
+					return err
+				}
+				if pending || paid.Amount < res.RefundAmount.Amount {
+					continue
+				}
+			}
+			err := transitionReservation(txOrm, res.Id, ReservationRefunded)
+			var transitionErr *InvalidTransitionError
+			if errors.As(err, &transitionErr) {
+				// e.g. an occurrence that already expired; nothing to reflect
+				continue
+			}
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// GetRefundsByPaymentId returns the refunds of a payment, oldest first
+func GetRefundsByPaymentId(paymentId string) ([]*Refund, error) {
+	o := orm.NewOrm()
+	var list []*Refund
+	_, err := o.QueryTable(new(Refund)).Filter("payment_id", paymentId).OrderBy("created_at").All(&list)
+	return list, err
+}
+
+// GetPendingRefund returns the oldest refund of reservationId by the payment for amount that is
+// still pending, so a retry can send it again under its refund key, or orm.ErrNoRows
+func GetPendingRefund(paymentId string, reservationId string, amount Money) (*Refund, error) {
+	o := orm.NewOrm()
+	r := &Refund{}
+	err := o.QueryTable(new(Refund)).Filter("payment_id", paymentId).Filter("reservation_id", reservationId).
+		Filter("amount", amount.String()).Filter("status", RefundPending).OrderBy("created_at").One(r)
+	if err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// GetPendingRefundsCreatedBefore returns refunds still pending that were requested before t
+func GetPendingRefundsCreatedBefore(t time.Time) ([]*Refund, error) {
+	o := orm.NewOrm()
+	var list []*Refund
+	_, err := o.QueryTable(new(Refund)).Filter("status", RefundPending).Filter("created_at__lt", t).OrderBy("created_at").All(&list)
+	return list, err
+}
@@ -0,0 +1,105 @@
+package models
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/beego/beego/v2/client/orm"
+	"github.com/google/uuid"
+)
+
+func TestCompleteRefundPrepaidSeriesOccurrence(t *testing.T) {
+	requireDB(t)
+	court, slot := testCourtSlot(t)
+	start := time.Now().AddDate(0, 0, 1)
+
+	series := &ReservationSeries{
+		Id:            uuid.New().String(),
+		CourtId:       court.Id,
+		TimeslotId:    slot.Id,
+		Weekday:       int(start.Weekday()),
+		StartDate:     start.Format("2006-01-02"),
+		Occurrences:   2,
+		PaymentMode:   SeriesPaymentPrepaid,
+		CustomerName:  "Series Tester",
+		CustomerEmail: "series@example.com",
+		CustomerPhone: "+6281234567890",
+		TotalPrice:    Whole(200000),
+		Status:        SeriesActive,
+	}
+	var occurrences []*Reservation
+	for i := 0; i < 2; i++ {
+		occurrences = append(occurrences, &Reservation{
+			Id:            uuid.New().String(),
+			CourtId:       court.Id,
+			TimeslotId:    slot.Id,
+			BookingDate:   start.AddDate(0, 0, 7*i).Format("2006-01-02"),
+			CustomerName:  series.CustomerName,
+			CustomerEmail: series.CustomerEmail,
+			CustomerPhone: series.CustomerPhone,
+			TotalPrice:    Whole(100000),
+			Status:        ReservationPending,
+			ExpiredAt:     time.Now().Add(30 * time.Minute),
+		})
+	}
+	if err := CreateReservationSeries(series, occurrences); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		orm.NewOrm().Raw("DELETE FROM reservation_series WHERE id = ?", series.Id).Exec()
+	})
+
+	p := &Payment{
+		Id:             uuid.New().String(),
+		ReservationId:  occurrences[0].Id,
+		OrderId:        "TEST-" + uuid.New().String(),
+		Amount:         series.TotalPrice,
+		PaymentGateway: "midtrans",
+		Status:         PaymentSuccess,
+		SeriesId:       series.Id,
+	}
+	if err := CreatePayment(p); err != nil {
+		t.Fatal(err)
+	}
+	for _, r := range occurrences {
+		if err := UpdateReservationStatus(r.Id, ReservationPaid); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := CancelReservation(r.Id, 100, "test"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	stranger := &Refund{Id: uuid.New().String(), PaymentId: p.Id, ReservationId: uuid.New().String(), Amount: Whole(100000), RefundKey: "RF-" + uuid.New().String()}
+	if err := CreateRefund(stranger); !errors.Is(err, ErrRefundNotCovered) {
+		t.Fatalf("refund for a reservation outside the series: got %v, want ErrRefundNotCovered", err)
+	}
+
+	// Refunding the second occurrence must leave the first one, still owed, cancelled
+	refund := &Refund{Id: uuid.New().String(), PaymentId: p.Id, ReservationId: occurrences[1].Id, Amount: Whole(100000), RefundKey: "RF-" + uuid.New().String()}
+	if err := CreateRefund(refund); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := CompleteRefund(refund.Id, RefundSucceeded, "gw-1", "{}"); err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]string{occurrences[0].Id: ReservationCancelled, occurrences[1].Id: ReservationRefunded}
+	for id, status := range want {
+		r, err := GetReservationById(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if r.Status != status {
+			t.Errorf("reservation %s is %s, want %s", id, r.Status, status)
+		}
+	}
+	paid, err := GetPaymentBySeriesId(series.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if paid.Status != PaymentPartiallyRefunded || paid.RefundedAmount.Amount != Whole(100000).Amount {
+		t.Errorf("payment is %s with %s refunded, want partially_refunded with 100000", paid.Status, paid.RefundedAmount)
+	}
+}
@@ -0,0 +1,18 @@
+package models
+
+import "github.com/beego/beego/v2/client/orm"
+
+// registered lists every model passed to registerModels, in registration order
+var registered []interface{}
+
+// registerModels registers models with the ORM and remembers them so tools such as
+// cmd/schemacheck can compare them with the database
+func registerModels(models ...interface{}) {
+	orm.RegisterModel(models...)
+	registered = append(registered, models...)
+}
+
+// RegisteredModels returns the models registered with the ORM
+func RegisteredModels() []interface{} {
+	return append([]interface{}(nil), registered...)
+}
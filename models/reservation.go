@@ -1,55 +1,131 @@
 package models
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"time"
 
 	"github.com/beego/beego/v2/client/orm"
 )
 
+// ErrSlotAlreadyBooked is returned when another active reservation already holds one of
+// the requested court/timeslot/date combinations.
+var ErrSlotAlreadyBooked = errors.New("court is already booked for the selected date and timeslot")
+
+// activeSlotIndex is the partial unique index (see migration 009) that guarantees at most
+// one slot-holding reservation item per court/timeslot/date.
+const activeSlotIndex = "idx_reservation_items_active_slot"
+
 type Reservation struct {
-	Id            string    `orm:"column(id);pk" json:"id"`
+	Id            string    `orm:"column(id);pk;size(36)" json:"id"`
 	CourtId       int       `orm:"column(court_id)" json:"court_id"`
 	TimeslotId    int       `orm:"column(timeslot_id)" json:"timeslot_id"`
 	BookingDate   string    `orm:"column(booking_date);size(10)" json:"booking_date"`
 	CustomerName  string    `orm:"column(customer_name);size(255)" json:"customer_name"`
 	CustomerEmail string    `orm:"column(customer_email);size(255)" json:"customer_email"`
 	CustomerPhone string    `orm:"column(customer_phone);size(50)" json:"customer_phone"`
-	TotalPrice    float64   `orm:"column(total_price);digits(10);decimals(2)" json:"total_price"`
+	TotalPrice    Money     `orm:"column(total_price);digits(10);decimals(2)" json:"total_price"`
 	Status        string    `orm:"column(status);size(32)" json:"status"`
 	Notes         string    `orm:"column(notes);type(text);null" json:"notes"`
 	ExpiredAt     time.Time `orm:"column(expired_at);type(datetime);null" json:"expired_at"`
 	CreatedAt     time.Time `orm:"column(created_at);auto_now_add;type(datetime)" json:"created_at"`
 	UpdatedAt     time.Time `orm:"column(updated_at);auto_now;type(datetime)" json:"updated_at"`
+	SeriesId      string    `orm:"column(series_id);size(36);null" json:"series_id,omitempty"`
+
+	// UserId is the account that made the booking, empty when made without signing in
+	UserId string `orm:"column(user_id);size(36);null" json:"-"`
+
+	CancelledAt        *time.Time `orm:"column(cancelled_at);type(datetime);null" json:"cancelled_at,omitempty"`
+	RefundAmount       Money      `orm:"column(refund_amount);digits(10);decimals(2);default(0)" json:"refund_amount"`
+	CancellationReason string     `orm:"column(cancellation_reason);type(text);null" json:"cancellation_reason,omitempty"`
+
+	// TotalPrice is after DiscountAmount; PromoCode is the code redeemed when booking
+	DiscountAmount Money  `orm:"column(discount_amount);digits(10);decimals(2);default(0)" json:"discount_amount"`
+	PromoCode      string `orm:"column(promo_code);size(50);null" json:"promo_code,omitempty"`
+
+	// ManageToken is only returned when the reservation is created; it authorizes the manage link
+	ManageToken string `orm:"-" json:"manage_token,omitempty"`
+
+	// Items are the court/timeslot/date line items; CourtId/TimeslotId mirror the first one
+	Items []*ReservationItem `orm:"-" json:"items,omitempty"`
 }
 
 func (r *Reservation) TableName() string {
 	return "reservations"
 }
 
+func (r *Reservation) TableIndex() [][]string {
+	return [][]string{{"booking_date"}, {"court_id", "booking_date", "timeslot_id"}, {"status"}, {"series_id"}, {"user_id"}}
+}
+
+func (r *Reservation) TableReferences() map[string]string {
+	return map[string]string{
+		"court_id":    "courts(id)",
+		"timeslot_id": "timeslots(id)",
+		"series_id":   "reservation_series(id)",
+		"user_id":     "users(id)",
+	}
+}
+
 func init() {
-	orm.RegisterModel(new(Reservation))
+	registerModels(new(Reservation))
 }
 
-// CreateReservation inserts a new reservation record
+// CreateReservation inserts a new reservation with its line items and marks every timeslot
+// unavailable in a single transaction, so the booking is all-or-nothing. When r.Items is
+// empty a single item is created from CourtId/TimeslotId/TotalPrice. Concurrent attempts
+// for the same court/timeslot/date are rejected by the database and reported as
+// ErrSlotAlreadyBooked. With r.PromoCode set the code is redeemed in the same transaction;
+// a code that does not apply fails the booking with a *PromoError. A ReservationCreated
+// event is written to the outbox with the booking.
 func CreateReservation(r *Reservation) error {
 	o := orm.NewOrm()
+	return o.DoTx(func(ctx context.Context, txOrm orm.TxOrmer) error {
+		if err := insertReservation(txOrm, r); err != nil {
+			return err
+		}
+		if r.PromoCode != "" {
+			if err := redeemPromoCode(txOrm, r); err != nil {
+				return err
+			}
+		}
+		return enqueueReservationEvent(txOrm, EventReservationCreated, r.Id, "")
+	})
+}
+
+// insertReservation inserts the reservation and its items on the given transaction
+func insertReservation(txOrm orm.TxOrmer, r *Reservation) error {
+	if len(r.Items) == 0 {
+		r.Items = []*ReservationItem{{CourtId: r.CourtId, TimeslotId: r.TimeslotId, BookingDate: r.BookingDate, Price: r.TotalPrice}}
+	}
+
 	// Use raw insert to avoid drivers that do not support LastInsertId for Postgres
-	_, err := o.Raw(`INSERT INTO reservations (id, court_id, timeslot_id, booking_date, customer_name, customer_email, customer_phone, total_price, status, notes, expired_at, created_at, updated_at)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, now(), now())`, r.Id, r.CourtId, r.TimeslotId, r.BookingDate, r.CustomerName, r.CustomerEmail, r.CustomerPhone, r.TotalPrice, r.Status, r.Notes, r.ExpiredAt).Exec()
+	_, err := txOrm.Raw(`INSERT INTO reservations (id, court_id, timeslot_id, booking_date, customer_name, customer_email, customer_phone, total_price, status, notes, expired_at, series_id, user_id, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, now(), now())`, r.Id, r.CourtId, r.TimeslotId, r.BookingDate, r.CustomerName, r.CustomerEmail, r.CustomerPhone, r.TotalPrice.String(), r.Status, r.Notes, r.ExpiredAt, nullString(r.SeriesId), nullString(r.UserId)).Exec()
 	if err != nil {
 		return err
 	}
 
-	// Mark timeslot as unavailable for the specific court and booking date so frontend can label it as booked
-	if err := MarkTimeslotUnavailable(r.CourtId, r.TimeslotId, r.BookingDate); err != nil {
-		// Log the error but do not fail reservation creation; caller can handle.
-		return err
-	}
+	for _, item := range r.Items {
+		item.ReservationId = r.Id
+		item.HoldsSlot = true
+		if err := insertReservationItem(txOrm, item); err != nil {
+			if isUniqueViolation(err, activeSlotIndex) {
+				return ErrSlotAlreadyBooked
+			}
+			return err
+		}
 
+		// Mark timeslot as unavailable for the specific court and booking date so frontend can label it as booked
+		if err := markTimeslotUnavailable(txOrm, item.CourtId, item.TimeslotId, item.BookingDate); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
-// GetReservationById returns reservation by id
+// GetReservationById returns reservation by id, including its line items
 func GetReservationById(id string) (*Reservation, error) {
 	o := orm.NewOrm()
 	res := &Reservation{Id: id}
@@ -57,6 +133,9 @@ func GetReservationById(id string) (*Reservation, error) {
 	if err != nil {
 		return nil, err
 	}
+	if res.Items, err = getReservationItems(o, res.Id); err != nil {
+		return nil, err
+	}
 	return res, nil
 }
 
@@ -65,35 +144,110 @@ func GetReservationsByEmail(email string) ([]*Reservation, error) {
 	o := orm.NewOrm()
 	var list []*Reservation
 	_, err := o.QueryTable(new(Reservation)).Filter("customer_email", email).All(&list)
-	return list, err
+	if err != nil {
+		return nil, err
+	}
+	return list, attachReservationItems(list)
 }
 
-// UpdateReservationStatus updates status for a reservation
+// UpdateReservationStatus moves a reservation to status, enforcing the reservation state machine.
+// It returns ErrUnknownStatus or *InvalidTransitionError for illegal changes and releases the
+// timeslot when the reservation stops holding it.
 func UpdateReservationStatus(id string, status string) error {
 	o := orm.NewOrm()
+	return o.DoTx(func(ctx context.Context, txOrm orm.TxOrmer) error {
+		return transitionReservation(txOrm, id, status)
+	})
+}
+
+// transitionReservation locks the reservation row, validates and applies the status change,
+// and writes the event announcing the new status to the outbox. Must be called inside a
+// transaction so concurrent transitions are serialized.
+func transitionReservation(txOrm orm.TxOrmer, id string, status string) error {
 	r := &Reservation{Id: id}
-	if err := o.Read(r); err != nil {
+	if err := txOrm.ReadForUpdate(r); err != nil {
 		return err
 	}
-	r.Status = status
-	_, err := o.Update(r)
-	if err != nil {
+	if err := ValidateTransition(r.Status, status); err != nil {
 		return err
 	}
+	if r.Status == status {
+		return nil
+	}
 
-	// If reservation becomes non-active (expired or cancelled), attempt to release the timeslot
-	if status == "expired" || status == "cancelled" {
-		// Check if there are any other active reservations for same court/timeslot/date
-		cnt, err := o.QueryTable(new(Reservation)).Filter("court_id", r.CourtId).Filter("timeslot_id", r.TimeslotId).Filter("booking_date", r.BookingDate).Filter("status__in", "pending", "waiting_payment", "paid").Count()
-		if err == nil && cnt == 0 {
-			// No other active reservations, mark timeslot available again
-			_ = MarkTimeslotAvailable(r.CourtId, r.TimeslotId, r.BookingDate)
+	from := r.Status
+	if _, err := txOrm.Raw("UPDATE reservations SET status = ?, updated_at = now() WHERE id = ?", status, r.Id).Exec(); err != nil {
+		return err
+	}
+	if status == ReservationCancelled {
+		if _, err := txOrm.Raw("UPDATE reservations SET cancelled_at = now() WHERE id = ?", r.Id).Exec(); err != nil {
+			return err
 		}
 	}
 
+	// If reservation no longer occupies its slots (expired, cancelled, ...), release its items
+	if HoldsSlot(from) && !HoldsSlot(status) {
+		if _, err := txOrm.Raw("UPDATE reservation_items SET holds_slot = false, updated_at = now() WHERE reservation_id = ?", r.Id).Exec(); err != nil {
+			return err
+		}
+		items, err := getReservationItems(txOrm, r.Id)
+		if err != nil {
+			return err
+		}
+		for _, item := range items {
+			if err := releaseSlotIfFree(txOrm, item.CourtId, item.TimeslotId, item.BookingDate); err != nil {
+				return err
+			}
+		}
+	}
+	if eventType, ok := reservationStatusEvents[status]; ok {
+		return enqueueReservationEvent(txOrm, eventType, r.Id, from)
+	}
 	return nil
 }
 
+// CancelReservation cancels a reservation and records the refund owed: refundPercent of the
+// total rounded to whole units when it was paid, nothing otherwise (unpaid bookings have nothing to refund). The
+// slots are released through the state machine. Returns the updated reservation.
+func CancelReservation(id string, refundPercent float64, reason string) (*Reservation, error) {
+	o := orm.NewOrm()
+	err := o.DoTx(func(ctx context.Context, txOrm orm.TxOrmer) error {
+		r := &Reservation{Id: id}
+		if err := txOrm.ReadForUpdate(r); err != nil {
+			return err
+		}
+		if r.Status == ReservationCancelled {
+			return &InvalidTransitionError{From: r.Status, To: ReservationCancelled}
+		}
+
+		var refund Money
+		if r.Status == ReservationPaid {
+			refund = r.TotalPrice.Percent(refundPercent).RoundWhole()
+		}
+		// Recorded before the transition so the ReservationCancelled event carries them
+		if _, err := txOrm.Raw("UPDATE reservations SET refund_amount = ?, cancellation_reason = ? WHERE id = ?", refund.String(), reason, id).Exec(); err != nil {
+			return err
+		}
+		return transitionReservation(txOrm, id, ReservationCancelled)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return GetReservationById(id)
+}
+
+// releaseSlotIfFree marks the timeslot available again when no other reservation item holds it
+func releaseSlotIfFree(q orm.QueryExecutor, courtId int, timeslotId int, bookingDate string) error {
+	cnt, err := q.QueryTable(new(ReservationItem)).Filter("court_id", courtId).Filter("timeslot_id", timeslotId).Filter("booking_date", bookingDate).Filter("holds_slot", true).Count()
+	if err != nil {
+		return err
+	}
+	if cnt > 0 {
+		return nil
+	}
+	return markTimeslotAvailable(q, courtId, timeslotId, bookingDate)
+}
+
 // CheckAvailability checks if a court is available for a given timeslot and date
 func CheckAvailability(courtId int, timeslotId int, bookingDate string) (bool, error) {
 	o := orm.NewOrm()
@@ -104,37 +258,63 @@ func CheckAvailability(courtId int, timeslotId int, bookingDate string) (bool, e
 		return false, nil
 	}
 
-	// Fallback: check reservations with active statuses
-	cnt, err := o.QueryTable(new(Reservation)).Filter("court_id", courtId).Filter("timeslot_id", timeslotId).Filter("booking_date", bookingDate).Filter("status__in", "pending", "waiting_payment", "paid").Count()
+	// Fallback: check reservation items that hold the slot
+	cnt, err := o.QueryTable(new(ReservationItem)).Filter("court_id", courtId).Filter("timeslot_id", timeslotId).Filter("booking_date", bookingDate).Filter("holds_slot", true).Count()
 	if err != nil {
 		return false, err
 	}
 	return cnt == 0, nil
 }
 
-// ExpireOldReservations will mark pending reservations whose ExpiredAt is before now as expired
-func ExpireOldReservations() error {
+// ExpireOldReservations marks pending reservations whose ExpiredAt is before now as expired,
+// stopping early once ctx is done. A reservation paid in the meantime is refused by the state
+// machine and skipped; other failures do not stop the run and are returned joined.
+func ExpireOldReservations(ctx context.Context) error {
 	o := orm.NewOrm()
 	now := time.Now()
 	// Find pending reservations whose ExpiredAt < now
 	var list []*Reservation
-	_, err := o.QueryTable(new(Reservation)).Filter("status", "pending").Filter("expired_at__lt", now).All(&list)
+	_, err := o.QueryTable(new(Reservation)).Filter("status", ReservationPending).Filter("expired_at__lt", now).All(&list)
 	if err != nil {
 		return err
 	}
 
+	var errs []error
 	for _, r := range list {
-		// update status to expired
-		if _, err := o.Raw("UPDATE reservations SET status = ? WHERE id = ?", "expired", r.Id).Exec(); err != nil {
-			// continue on error
-			continue
+		if ctx.Err() != nil {
+			break
 		}
-		// if no other active reservations exist for same court/timeslot/date, mark timeslot available
-		cnt, err := o.QueryTable(new(Reservation)).Filter("court_id", r.CourtId).Filter("timeslot_id", r.TimeslotId).Filter("booking_date", r.BookingDate).Filter("status__in", "pending", "waiting_payment", "paid").Count()
-		if err == nil && cnt == 0 {
-			_ = MarkTimeslotAvailable(r.CourtId, r.TimeslotId, r.BookingDate)
+		err := UpdateReservationStatus(r.Id, ReservationExpired)
+		var transitionErr *InvalidTransitionError
+		if err != nil && !errors.As(err, &transitionErr) {
+			errs = append(errs, fmt.Errorf("expiring reservation %s: %w", r.Id, err))
 		}
 	}
+	return errors.Join(errs...)
+}
 
-	return nil
+// GetReservationsAwaitingPayment returns unpaid (pending or waiting_payment) reservations
+// whose payment deadline falls between now and deadline
+func GetReservationsAwaitingPayment(deadline time.Time) ([]*Reservation, error) {
+	o := orm.NewOrm()
+	var list []*Reservation
+	_, err := o.QueryTable(new(Reservation)).Filter("status__in", ReservationPending, ReservationWaitingPayment).
+		Filter("expired_at__gt", time.Now()).Filter("expired_at__lte", deadline).All(&list)
+	if err != nil {
+		return nil, err
+	}
+	return list, attachReservationItems(list)
+}
+
+// GetPaidReservationsBetween returns paid reservations booked from one date to another
+// (YYYY-MM-DD, inclusive)
+func GetPaidReservationsBetween(from, to string) ([]*Reservation, error) {
+	o := orm.NewOrm()
+	var list []*Reservation
+	_, err := o.QueryTable(new(Reservation)).Filter("status", ReservationPaid).
+		Filter("booking_date__gte", from).Filter("booking_date__lte", to).All(&list)
+	if err != nil {
+		return nil, err
+	}
+	return list, attachReservationItems(list)
 }
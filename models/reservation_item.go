@@ -0,0 +1,84 @@
+package models
+
+import (
+	"time"
+
+	"github.com/beego/beego/v2/client/orm"
+)
+
+// ReservationItem is one court/timeslot/date covered by a reservation
+type ReservationItem struct {
+	Id            int       `orm:"column(id);auto;pk" json:"id"`
+	ReservationId string    `orm:"column(reservation_id);size(36)" json:"reservation_id"`
+	CourtId       int       `orm:"column(court_id)" json:"court_id"`
+	TimeslotId    int       `orm:"column(timeslot_id)" json:"timeslot_id"`
+	BookingDate   string    `orm:"column(booking_date);size(10)" json:"booking_date"`
+	Price         Money     `orm:"column(price);digits(10);decimals(2)" json:"price"`
+	HoldsSlot     bool      `orm:"column(holds_slot);default(true)" json:"holds_slot"`
+	CreatedAt     time.Time `orm:"column(created_at);auto_now_add;type(datetime)" json:"created_at"`
+	UpdatedAt     time.Time `orm:"column(updated_at);auto_now;type(datetime)" json:"updated_at"`
+}
+
+func (i *ReservationItem) TableName() string {
+	return "reservation_items"
+}
+
+func (i *ReservationItem) TableIndex() [][]string {
+	return [][]string{{"reservation_id"}, {"booking_date"}}
+}
+
+// The slot index is partial (WHERE holds_slot): only items of an active reservation hold the slot
+func (i *ReservationItem) TableUnique() [][]string {
+	return [][]string{{"court_id", "timeslot_id", "booking_date"}}
+}
+
+func (i *ReservationItem) TableReferences() map[string]string {
+	return map[string]string{
+		"reservation_id": "reservations(id)",
+		"court_id":       "courts(id)",
+		"timeslot_id":    "timeslots(id)",
+	}
+}
+
+func init() {
+	registerModels(new(ReservationItem))
+}
+
+// insertReservationItem inserts a line item on the given executor and sets its generated id
+func insertReservationItem(q orm.QueryExecutor, item *ReservationItem) error {
+	// Use RETURNING instead of LastInsertId, which the Postgres driver does not support
+	return q.Raw(`INSERT INTO reservation_items (reservation_id, court_id, timeslot_id, booking_date, price, holds_slot, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, true, now(), now()) RETURNING id`, item.ReservationId, item.CourtId, item.TimeslotId, item.BookingDate, item.Price.String()).QueryRow(&item.Id)
+}
+
+// getReservationItems returns the line items of a reservation ordered by court and time
+func getReservationItems(q orm.QueryExecutor, reservationId string) ([]*ReservationItem, error) {
+	var items []*ReservationItem
+	_, err := q.QueryTable(new(ReservationItem)).Filter("reservation_id", reservationId).OrderBy("court_id", "booking_date", "timeslot_id").All(&items)
+	return items, err
+}
+
+// attachReservationItems loads the items of all given reservations with a single query
+func attachReservationItems(list []*Reservation) error {
+	if len(list) == 0 {
+		return nil
+	}
+	ids := make([]string, 0, len(list))
+	byId := make(map[string]*Reservation, len(list))
+	for _, r := range list {
+		ids = append(ids, r.Id)
+		byId[r.Id] = r
+	}
+
+	o := orm.NewOrm()
+	var items []*ReservationItem
+	if _, err := o.QueryTable(new(ReservationItem)).Filter("reservation_id__in", ids).OrderBy("court_id", "booking_date", "timeslot_id").All(&items); err != nil {
+		return err
+	}
+	for _, item := range items {
+		if r, ok := byId[item.ReservationId]; ok {
+			r.Items = append(r.Items, item)
+		}
+	}
+	return nil
+}
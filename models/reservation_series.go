@@ -0,0 +1,192 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/beego/beego/v2/client/orm"
+)
+
+// Series payment modes
+const (
+	SeriesPaymentPerOccurrence = "per_occurrence"
+	SeriesPaymentPrepaid       = "prepaid"
+)
+
+// Series statuses
+const (
+	SeriesActive    = "active"
+	SeriesCancelled = "cancelled"
+)
+
+// ErrNotInSeries is returned when cancelling an occurrence that belongs to another series
+var ErrNotInSeries = errors.New("reservation does not belong to this series")
+
+// ReservationSeries is a recurring weekly booking; each occurrence is a Reservation with SeriesId set
+type ReservationSeries struct {
+	Id            string    `orm:"column(id);pk;size(36)" json:"id"`
+	CourtId       int       `orm:"column(court_id)" json:"court_id"`
+	TimeslotId    int       `orm:"column(timeslot_id)" json:"timeslot_id"`
+	Weekday       int       `orm:"column(weekday)" json:"weekday"`
+	StartDate     string    `orm:"column(start_date);size(10)" json:"start_date"`
+	Occurrences   int       `orm:"column(occurrences)" json:"occurrences"`
+	PaymentMode   string    `orm:"column(payment_mode);size(20);default(per_occurrence)" json:"payment_mode"`
+	CustomerName  string    `orm:"column(customer_name);size(255)" json:"customer_name"`
+	CustomerEmail string    `orm:"column(customer_email);size(255)" json:"customer_email"`
+	CustomerPhone string    `orm:"column(customer_phone);size(50)" json:"customer_phone"`
+	TotalPrice    Money     `orm:"column(total_price);digits(10);decimals(2)" json:"total_price"`
+	Status        string    `orm:"column(status);size(20);default(active)" json:"status"`
+	Notes         string    `orm:"column(notes);type(text);null" json:"notes"`
+	UserId        string    `orm:"column(user_id);size(36);null" json:"-"`
+	CreatedAt     time.Time `orm:"column(created_at);auto_now_add;type(datetime)" json:"created_at"`
+	UpdatedAt     time.Time `orm:"column(updated_at);auto_now;type(datetime)" json:"updated_at"`
+
+	Reservations []*Reservation `orm:"-" json:"reservations,omitempty"`
+}
+
+func (s *ReservationSeries) TableName() string {
+	return "reservation_series"
+}
+
+func (s *ReservationSeries) TableIndex() [][]string {
+	return [][]string{{"customer_email"}, {"user_id"}}
+}
+
+func (s *ReservationSeries) TableReferences() map[string]string {
+	return map[string]string{
+		"court_id":    "courts(id)",
+		"timeslot_id": "timeslots(id)",
+		"user_id":     "users(id)",
+	}
+}
+
+func init() {
+	registerModels(new(ReservationSeries))
+}
+
+// IsValidSeriesPaymentMode reports whether mode is a known payment mode
+func IsValidSeriesPaymentMode(mode string) bool {
+	return mode == SeriesPaymentPerOccurrence || mode == SeriesPaymentPrepaid
+}
+
+// SeriesDates returns the dates of n weekly occurrences on weekday, starting at the first
+// such weekday on or after startDate (YYYY-MM-DD).
+func SeriesDates(startDate string, weekday time.Weekday, n int) ([]string, error) {
+	d, err := time.Parse("2006-01-02", startDate)
+	if err != nil {
+		return nil, err
+	}
+	for d.Weekday() != weekday {
+		d = d.AddDate(0, 0, 1)
+	}
+	dates := make([]string, 0, n)
+	for i := 0; i < n; i++ {
+		dates = append(dates, d.AddDate(0, 0, 7*i).Format("2006-01-02"))
+	}
+	return dates, nil
+}
+
+// CreateReservationSeries inserts the series and all its occurrences in one transaction.
+// Either every occurrence is reserved or none is (ErrSlotAlreadyBooked on a lost race).
+// SeriesCreated and a ReservationCreated per occurrence are written to the outbox.
+func CreateReservationSeries(series *ReservationSeries, occurrences []*Reservation) error {
+	o := orm.NewOrm()
+	return o.DoTx(func(ctx context.Context, txOrm orm.TxOrmer) error {
+		_, err := txOrm.Raw(`INSERT INTO reservation_series (id, court_id, timeslot_id, weekday, start_date, occurrences, payment_mode, customer_name, customer_email, customer_phone, total_price, status, notes, user_id, created_at, updated_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, now(), now())`, series.Id, series.CourtId, series.TimeslotId, series.Weekday, series.StartDate, series.Occurrences, series.PaymentMode, series.CustomerName, series.CustomerEmail, series.CustomerPhone, series.TotalPrice.String(), series.Status, series.Notes, nullString(series.UserId)).Exec()
+		if err != nil {
+			return err
+		}
+		ids := make([]string, 0, len(occurrences))
+		for _, r := range occurrences {
+			r.SeriesId = series.Id
+			r.UserId = series.UserId
+			if err := insertReservation(txOrm, r); err != nil {
+				return err
+			}
+			if err := enqueueReservationEvent(txOrm, EventReservationCreated, r.Id, ""); err != nil {
+				return err
+			}
+			ids = append(ids, r.Id)
+		}
+		return enqueueEvent(txOrm, EventSeriesCreated, AggregateSeries, series.Id, NewSeriesPayload(series, ids))
+	})
+}
+
+// GetReservationSeriesById returns a series with its occurrences
+func GetReservationSeriesById(id string) (*ReservationSeries, error) {
+	o := orm.NewOrm()
+	series := &ReservationSeries{Id: id}
+	if err := o.Read(series); err != nil {
+		return nil, err
+	}
+	list, err := GetReservationsBySeriesId(id)
+	if err != nil {
+		return nil, err
+	}
+	series.Reservations = list
+	return series, nil
+}
+
+// GetReservationsBySeriesId returns the occurrences of a series ordered by date
+func GetReservationsBySeriesId(seriesId string) ([]*Reservation, error) {
+	o := orm.NewOrm()
+	var list []*Reservation
+	_, err := o.QueryTable(new(Reservation)).Filter("series_id", seriesId).OrderBy("booking_date").All(&list)
+	if err != nil {
+		return nil, err
+	}
+	return list, attachReservationItems(list)
+}
+
+// CancelSeriesOccurrence cancels a single occurrence of a series
+func CancelSeriesOccurrence(seriesId string, reservationId string) error {
+	o := orm.NewOrm()
+	return o.DoTx(func(ctx context.Context, txOrm orm.TxOrmer) error {
+		r := &Reservation{Id: reservationId}
+		if err := txOrm.Read(r); err != nil {
+			return err
+		}
+		if r.SeriesId != seriesId {
+			return ErrNotInSeries
+		}
+		return transitionReservation(txOrm, reservationId, ReservationCancelled)
+	})
+}
+
+// CancelReservationSeries cancels the series and every occurrence from today onwards that
+// can still be cancelled. It returns the ids of the cancelled occurrences.
+func CancelReservationSeries(seriesId string) ([]string, error) {
+	var cancelled []string
+	o := orm.NewOrm()
+	err := o.DoTx(func(ctx context.Context, txOrm orm.TxOrmer) error {
+		series := &ReservationSeries{Id: seriesId}
+		if err := txOrm.ReadForUpdate(series); err != nil {
+			return err
+		}
+
+		var list []*Reservation
+		today := time.Now().Format("2006-01-02")
+		if _, err := txOrm.QueryTable(new(Reservation)).Filter("series_id", seriesId).Filter("booking_date__gte", today).All(&list); err != nil {
+			return err
+		}
+		for _, r := range list {
+			if r.Status == ReservationCancelled || ValidateTransition(r.Status, ReservationCancelled) != nil {
+				// Already cancelled or past the point of cancelling (expired, checked in, ...)
+				continue
+			}
+			if err := transitionReservation(txOrm, r.Id, ReservationCancelled); err != nil {
+				return err
+			}
+			cancelled = append(cancelled, r.Id)
+		}
+
+		if _, err := txOrm.Raw("UPDATE reservation_series SET status = ?, updated_at = now() WHERE id = ?", SeriesCancelled, seriesId).Exec(); err != nil {
+			return err
+		}
+		series.Status = SeriesCancelled
+		return enqueueEvent(txOrm, EventSeriesCancelled, AggregateSeries, seriesId, NewSeriesPayload(series, cancelled))
+	})
+	return cancelled, err
+}
@@ -0,0 +1,102 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Reservation statuses
+const (
+	ReservationPending        = "pending"
+	ReservationWaitingPayment = "waiting_payment"
+	ReservationPaid           = "paid"
+	ReservationCancelled      = "cancelled"
+	ReservationExpired        = "expired"
+	ReservationCheckedIn      = "checked_in"
+	ReservationCompleted      = "completed"
+	ReservationNoShow         = "no_show"
+	ReservationRefunded       = "refunded"
+)
+
+// reservationTransitions lists the statuses each status may legally move to.
+// Statuses with no outgoing transitions are terminal.
+var reservationTransitions = map[string][]string{
+	ReservationPending:        {ReservationWaitingPayment, ReservationPaid, ReservationCancelled, ReservationExpired},
+	ReservationWaitingPayment: {ReservationPaid, ReservationCancelled, ReservationExpired},
+	ReservationPaid:           {ReservationCheckedIn, ReservationNoShow, ReservationCancelled, ReservationRefunded},
+	ReservationCheckedIn:      {ReservationCompleted},
+	ReservationCancelled:      {ReservationRefunded},
+	ReservationCompleted:      {},
+	ReservationNoShow:         {},
+	ReservationExpired:        {},
+	ReservationRefunded:       {},
+}
+
+// SlotHoldingStatuses are the statuses in which a reservation occupies its court/timeslot/date.
+// reservation_items.holds_slot mirrors HoldsSlot of the parent reservation's status.
+var SlotHoldingStatuses = []string{
+	ReservationPending,
+	ReservationWaitingPayment,
+	ReservationPaid,
+	ReservationCheckedIn,
+	ReservationCompleted,
+}
+
+// ErrUnknownStatus is returned when a status is not part of the reservation state machine
+var ErrUnknownStatus = errors.New("unknown reservation status")
+
+// InvalidTransitionError is returned when a reservation cannot move from one status to another
+type InvalidTransitionError struct {
+	From string
+	To   string
+}
+
+func (e *InvalidTransitionError) Error() string {
+	return fmt.Sprintf("cannot change reservation status from %s to %s", e.From, e.To)
+}
+
+// Allowed returns the statuses that are legal from e.From
+func (e *InvalidTransitionError) Allowed() []string {
+	return reservationTransitions[e.From]
+}
+
+// ReservationStatuses returns all known reservation statuses
+func ReservationStatuses() []string {
+	return []string{
+		ReservationPending, ReservationWaitingPayment, ReservationPaid, ReservationCancelled, ReservationExpired,
+		ReservationCheckedIn, ReservationCompleted, ReservationNoShow, ReservationRefunded,
+	}
+}
+
+// IsValidReservationStatus reports whether status is part of the state machine
+func IsValidReservationStatus(status string) bool {
+	_, ok := reservationTransitions[status]
+	return ok
+}
+
+// HoldsSlot reports whether a reservation in status occupies its slot
+func HoldsSlot(status string) bool {
+	for _, s := range SlotHoldingStatuses {
+		if s == status {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidateTransition checks that a reservation may move from -> to. Moving to the same
+// status is accepted (as a no-op) so repeated notifications are harmless.
+func ValidateTransition(from, to string) error {
+	if !IsValidReservationStatus(to) {
+		return ErrUnknownStatus
+	}
+	if from == to {
+		return nil
+	}
+	for _, s := range reservationTransitions[from] {
+		if s == to {
+			return nil
+		}
+	}
+	return &InvalidTransitionError{From: from, To: to}
+}
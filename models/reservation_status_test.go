@@ -0,0 +1,100 @@
+package models
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestValidateTransition(t *testing.T) {
+	// Every legal edge of the reservation state machine; all other pairs of distinct statuses are rejected
+	legal := []struct{ from, to string }{
+		{ReservationPending, ReservationWaitingPayment},
+		{ReservationPending, ReservationPaid},
+		{ReservationPending, ReservationCancelled},
+		{ReservationPending, ReservationExpired},
+		{ReservationWaitingPayment, ReservationPaid},
+		{ReservationWaitingPayment, ReservationCancelled},
+		{ReservationWaitingPayment, ReservationExpired},
+		{ReservationPaid, ReservationCheckedIn},
+		{ReservationPaid, ReservationNoShow},
+		{ReservationPaid, ReservationCancelled},
+		{ReservationPaid, ReservationRefunded},
+		{ReservationCheckedIn, ReservationCompleted},
+		{ReservationCancelled, ReservationRefunded},
+	}
+	isLegal := map[[2]string]bool{}
+	for _, e := range legal {
+		isLegal[[2]string{e.from, e.to}] = true
+	}
+
+	for _, from := range ReservationStatuses() {
+		for _, to := range ReservationStatuses() {
+			t.Run(from+"->"+to, func(t *testing.T) {
+				err := ValidateTransition(from, to)
+				if from == to || isLegal[[2]string{from, to}] {
+					if err != nil {
+						t.Errorf("legal transition rejected: %v", err)
+					}
+					return
+				}
+				var invalid *InvalidTransitionError
+				if !errors.As(err, &invalid) {
+					t.Fatalf("got %v, want an *InvalidTransitionError", err)
+				}
+				if invalid.From != from || invalid.To != to {
+					t.Errorf("error names %s -> %s", invalid.From, invalid.To)
+				}
+			})
+		}
+	}
+
+	tests := []struct {
+		name     string
+		from, to string
+		invalid  bool
+		wantErr  error
+	}{
+		{"expired booking cannot be paid", ReservationExpired, ReservationPaid, true, nil},
+		{"paid booking cannot go back to pending", ReservationPaid, ReservationPending, true, nil},
+		{"completed booking is terminal", ReservationCompleted, ReservationCancelled, true, nil},
+		{"check-in requires payment", ReservationPending, ReservationCheckedIn, true, nil},
+		{"typo", ReservationPending, "payed", false, ErrUnknownStatus},
+		{"empty status", ReservationPending, "", false, ErrUnknownStatus},
+		{"typo is unknown even from itself", "payed", "payed", false, ErrUnknownStatus},
+		{"repeated status is a no-op", ReservationPaid, ReservationPaid, false, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateTransition(tt.from, tt.to)
+			var invalid *InvalidTransitionError
+			if tt.invalid {
+				if !errors.As(err, &invalid) {
+					t.Errorf("got %v, want an *InvalidTransitionError", err)
+				}
+				return
+			}
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("got %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	err := &InvalidTransitionError{From: ReservationPaid, To: ReservationPending}
+	want := []string{ReservationCheckedIn, ReservationNoShow, ReservationCancelled, ReservationRefunded}
+	if got := err.Allowed(); !reflect.DeepEqual(got, want) {
+		t.Errorf("allowed from paid: %v, want %v", got, want)
+	}
+	if err.Error() != "cannot change reservation status from paid to pending" {
+		t.Errorf("message is %q", err.Error())
+	}
+}
+
+func TestHoldsSlot(t *testing.T) {
+	for _, status := range ReservationStatuses() {
+		want := status != ReservationCancelled && status != ReservationExpired && status != ReservationNoShow && status != ReservationRefunded
+		if got := HoldsSlot(status); got != want {
+			t.Errorf("HoldsSlot(%s) = %v, want %v", status, got, want)
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestCreateReservationConcurrentSameSlot(t *testing.T) {
+	requireDB(t)
+	court, slot := testCourtSlot(t)
+	date := time.Now().AddDate(0, 0, 1).Format("2006-01-02")
+
+	const n = 20
+	var (
+		wg    sync.WaitGroup
+		start = make(chan struct{})
+		errs  = make([]error, n)
+	)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			r := &Reservation{
+				Id:            uuid.New().String(),
+				CourtId:       court.Id,
+				TimeslotId:    slot.Id,
+				BookingDate:   date,
+				CustomerName:  fmt.Sprintf("Race Tester %d", i),
+				CustomerEmail: fmt.Sprintf("race%d@example.com", i),
+				CustomerPhone: "+6281234567890",
+				TotalPrice:    court.PricePerHour,
+				Status:        ReservationPending,
+				ExpiredAt:     time.Now().Add(30 * time.Minute),
+			}
+			// Release every goroutine at once to maximise contention
+			<-start
+			errs[i] = CreateReservation(r)
+		}(i)
+	}
+	close(start)
+	wg.Wait()
+
+	var booked, rejected int
+	for _, err := range errs {
+		switch {
+		case err == nil:
+			booked++
+		case errors.Is(err, ErrSlotAlreadyBooked):
+			rejected++
+		default:
+			t.Errorf("unexpected error: %v", err)
+		}
+	}
+	if booked != 1 || rejected != n-1 {
+		t.Fatalf("booked %d, rejected %d; want 1 and %d", booked, rejected, n-1)
+	}
+
+	available, err := CheckAvailability(court.Id, slot.Id, date)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if available {
+		t.Error("slot is still available after a successful booking")
+	}
+}
+
+func TestExpireOldReservations(t *testing.T) {
+	requireDB(t)
+	court, slot := testCourtSlot(t)
+	r := &Reservation{
+		Id:            uuid.New().String(),
+		CourtId:       court.Id,
+		TimeslotId:    slot.Id,
+		BookingDate:   time.Now().AddDate(0, 0, 1).Format("2006-01-02"),
+		CustomerName:  "Expiry Tester",
+		CustomerEmail: "expiry@example.com",
+		CustomerPhone: "+6281234567890",
+		TotalPrice:    court.PricePerHour,
+		Status:        ReservationPending,
+		ExpiredAt:     time.Now().Add(-time.Minute),
+	}
+	if err := CreateReservation(r); err != nil {
+		t.Fatal(err)
+	}
+
+	// A run stopped by the scheduler leaves the reservation for the next one
+	stopped, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := ExpireOldReservations(stopped); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := GetReservationById(r.Id); got.Status != ReservationPending {
+		t.Errorf("cancelled run moved the reservation to %s", got.Status)
+	}
+
+	if err := ExpireOldReservations(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := GetReservationById(r.Id); got.Status != ReservationExpired {
+		t.Errorf("reservation is %s, want expired", got.Status)
+	}
+}
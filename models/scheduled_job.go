@@ -0,0 +1,80 @@
+package models
+
+import (
+	"time"
+
+	"github.com/beego/beego/v2/client/orm"
+)
+
+// ScheduledJob is a background job and the outcome of its latest run
+type ScheduledJob struct {
+	Name           string     `orm:"column(name);pk;size(64)" json:"name"`
+	Schedule       string     `orm:"column(schedule);size(100)" json:"schedule"`
+	LastStartedAt  *time.Time `orm:"column(last_started_at);type(datetime);null" json:"last_started_at"`
+	LastFinishedAt *time.Time `orm:"column(last_finished_at);type(datetime);null" json:"last_finished_at"`
+	LastDurationMs int64      `orm:"column(last_duration_ms);default(0)" json:"last_duration_ms"`
+	LastSuccessAt  *time.Time `orm:"column(last_success_at);type(datetime);null" json:"last_success_at"`
+	LastError      string     `orm:"column(last_error);type(text);null" json:"last_error,omitempty"`
+	LastErrorAt    *time.Time `orm:"column(last_error_at);type(datetime);null" json:"last_error_at,omitempty"`
+	NextRunAt      *time.Time `orm:"column(next_run_at);type(datetime);null" json:"next_run_at"`
+	RunCount       int64      `orm:"column(run_count);default(0)" json:"run_count"`
+	FailureCount   int64      `orm:"column(failure_count);default(0)" json:"failure_count"`
+	RunBy          string     `orm:"column(run_by);size(255)" json:"run_by"`
+	CreatedAt      time.Time  `orm:"column(created_at);auto_now_add;type(datetime)" json:"created_at"`
+	UpdatedAt      time.Time  `orm:"column(updated_at);auto_now;type(datetime)" json:"updated_at"`
+}
+
+func (j *ScheduledJob) TableName() string {
+	return "scheduled_jobs"
+}
+
+func init() {
+	registerModels(new(ScheduledJob))
+}
+
+// UpsertScheduledJob creates the row of a registered job, or updates its schedule
+func UpsertScheduledJob(name, schedule string) error {
+	o := orm.NewOrm()
+	_, err := o.Raw(`INSERT INTO scheduled_jobs (name, schedule, created_at, updated_at) VALUES (?, ?, now(), now())
+		ON CONFLICT (name) DO UPDATE SET schedule = EXCLUDED.schedule`, name, schedule).Exec()
+	return err
+}
+
+// StartScheduledJobRun records that instance started a run of the job
+func StartScheduledJobRun(name, instance string, startedAt time.Time) error {
+	o := orm.NewOrm()
+	_, err := o.Raw("UPDATE scheduled_jobs SET last_started_at = ?, run_by = ?, next_run_at = NULL WHERE name = ?",
+		startedAt, instance, name).Exec()
+	return err
+}
+
+// FinishScheduledJobRun records the outcome of a run; an empty runErr is a success
+func FinishScheduledJobRun(name string, finishedAt time.Time, duration time.Duration, runErr string, nextRunAt time.Time) error {
+	o := orm.NewOrm()
+	var err error
+	if runErr == "" {
+		_, err = o.Raw(`UPDATE scheduled_jobs SET last_finished_at = ?, last_duration_ms = ?, last_success_at = ?, last_error = NULL,
+			next_run_at = ?, run_count = run_count + 1 WHERE name = ?`,
+			finishedAt, duration.Milliseconds(), finishedAt, nextRunAt, name).Exec()
+	} else {
+		_, err = o.Raw(`UPDATE scheduled_jobs SET last_finished_at = ?, last_duration_ms = ?, last_error = ?, last_error_at = ?,
+			next_run_at = ?, run_count = run_count + 1, failure_count = failure_count + 1 WHERE name = ?`,
+			finishedAt, duration.Milliseconds(), runErr, finishedAt, nextRunAt, name).Exec()
+	}
+	return err
+}
+
+// SetScheduledJobNextRun records when the job will run next
+func SetScheduledJobNextRun(name string, nextRunAt time.Time) error {
+	o := orm.NewOrm()
+	_, err := o.Raw("UPDATE scheduled_jobs SET next_run_at = ? WHERE name = ?", nextRunAt, name).Exec()
+	return err
+}
+
+// GetScheduledJobs lists every job by name
+func GetScheduledJobs() ([]*ScheduledJob, error) {
+	o := orm.NewOrm()
+	var list []*ScheduledJob
+	_, err := o.QueryTable(new(ScheduledJob)).OrderBy("name").All(&list)
+	return list, err
+}
@@ -1,9 +1,15 @@
 package models
 
 import (
+	"errors"
+	"time"
+
 	"github.com/beego/beego/v2/client/orm"
 )
 
+// ErrTimeslotInUse is returned when deleting a timeslot that reservations still reference
+var ErrTimeslotInUse = errors.New("timeslot is referenced by reservations")
+
 type Timeslot struct {
 	Id        int    `orm:"column(id);auto;pk" json:"id"`
 	StartTime string `orm:"column(start_time);size(10)" json:"start_time"`
@@ -15,8 +21,12 @@ func (t *Timeslot) TableName() string {
 	return "timeslots"
 }
 
+func (t *Timeslot) TableIndex() [][]string {
+	return [][]string{{"is_active"}}
+}
+
 func init() {
-	orm.RegisterModel(new(Timeslot))
+	registerModels(new(Timeslot))
 }
 
 // GetTimeslotById returns a timeslot by id
@@ -53,3 +63,59 @@ func GetAvailableTimeslots(courtId int, bookingDate string) ([]*Timeslot, error)
 		ORDER BY t.id`, courtId, bookingDate).QueryRows(&slots)
 	return slots, err
 }
+
+// CreateTimeslot inserts a new timeslot and sets its generated id
+func CreateTimeslot(t *Timeslot) error {
+	o := orm.NewOrm()
+	// Use RETURNING instead of LastInsertId, which the Postgres driver does not support
+	return o.Raw(`INSERT INTO timeslots (start_time, end_time, is_active, created_at, updated_at)
+		VALUES (?, ?, ?, now(), now()) RETURNING id`, t.StartTime, t.EndTime, t.IsActive).QueryRow(&t.Id)
+}
+
+// UpdateTimeslot saves the given columns of a timeslot (all columns when none are given)
+func UpdateTimeslot(t *Timeslot, cols ...string) error {
+	o := orm.NewOrm()
+	_, err := o.Update(t, cols...)
+	return err
+}
+
+// FindOverlappingActiveTimeslot returns an active timeslot (other than excludeId) whose range
+// overlaps [startTime, endTime), or nil when there is none. Times are zero-padded HH:MM:SS so
+// string comparison matches time order.
+func FindOverlappingActiveTimeslot(startTime string, endTime string, excludeId int) (*Timeslot, error) {
+	o := orm.NewOrm()
+	var slots []*Timeslot
+	_, err := o.Raw(`SELECT id, start_time, end_time, is_active FROM timeslots
+		WHERE is_active = true AND id <> ? AND start_time < ? AND end_time > ?
+		ORDER BY start_time LIMIT 1`, excludeId, endTime, startTime).QueryRows(&slots)
+	if err != nil || len(slots) == 0 {
+		return nil, err
+	}
+	return slots[0], nil
+}
+
+// CountFutureReservationsByTimeslot counts slot-holding reservation items from today onwards that use the timeslot
+func CountFutureReservationsByTimeslot(timeslotId int) (int64, error) {
+	o := orm.NewOrm()
+	today := time.Now().Format("2006-01-02")
+	return o.QueryTable(new(ReservationItem)).Filter("timeslot_id", timeslotId).Filter("booking_date__gte", today).Filter("holds_slot", true).Count()
+}
+
+// DeleteTimeslot removes a timeslot. It returns ErrTimeslotInUse when future reservations use
+// it, or when any reservation still references it (reservations.timeslot_id is ON DELETE RESTRICT).
+func DeleteTimeslot(id int) error {
+	cnt, err := CountFutureReservationsByTimeslot(id)
+	if err != nil {
+		return err
+	}
+	if cnt > 0 {
+		return ErrTimeslotInUse
+	}
+
+	o := orm.NewOrm()
+	_, err = o.Delete(&Timeslot{Id: id})
+	if isForeignKeyViolation(err) {
+		return ErrTimeslotInUse
+	}
+	return err
+}
@@ -20,15 +20,30 @@ func (t *TimeslotAvailability) TableName() string {
 	return "timeslot_availabilities"
 }
 
+func (t *TimeslotAvailability) TableUnique() [][]string {
+	return [][]string{{"court_id", "timeslot_id", "booking_date"}}
+}
+
+func (t *TimeslotAvailability) TableReferences() map[string]string {
+	return map[string]string{
+		"court_id":    "courts(id)",
+		"timeslot_id": "timeslots(id)",
+	}
+}
+
 func init() {
-	orm.RegisterModel(new(TimeslotAvailability))
+	registerModels(new(TimeslotAvailability))
 }
 
 // MarkTimeslotUnavailable marks a timeslot as unavailable (is_active=false) for a given court and date
 func MarkTimeslotUnavailable(courtId int, timeslotId int, bookingDate string) error {
-	o := orm.NewOrm()
+	return markTimeslotUnavailable(orm.NewOrm(), courtId, timeslotId, bookingDate)
+}
+
+// markTimeslotUnavailable performs the upsert on the given executor so it can join a transaction
+func markTimeslotUnavailable(q orm.QueryExecutor, courtId int, timeslotId int, bookingDate string) error {
 	// Upsert: insert or update to set is_active = false
-	_, err := o.Raw(`INSERT INTO timeslot_availabilities (court_id, timeslot_id, booking_date, is_active, created_at, updated_at)
+	_, err := q.Raw(`INSERT INTO timeslot_availabilities (court_id, timeslot_id, booking_date, is_active, created_at, updated_at)
         VALUES (?, ?, ?, false, now(), now())
         ON CONFLICT (court_id, timeslot_id, booking_date) DO UPDATE SET is_active = false, updated_at = now()`, courtId, timeslotId, bookingDate).Exec()
 	return err
@@ -36,9 +51,13 @@ func MarkTimeslotUnavailable(courtId int, timeslotId int, bookingDate string) er
 
 // MarkTimeslotAvailable marks timeslot as available (is_active=true) for a given court and date
 func MarkTimeslotAvailable(courtId int, timeslotId int, bookingDate string) error {
-	o := orm.NewOrm()
+	return markTimeslotAvailable(orm.NewOrm(), courtId, timeslotId, bookingDate)
+}
+
+// markTimeslotAvailable performs the upsert on the given executor so it can join a transaction
+func markTimeslotAvailable(q orm.QueryExecutor, courtId int, timeslotId int, bookingDate string) error {
 	// Upsert to set is_active = true
-	_, err := o.Raw(`INSERT INTO timeslot_availabilities (court_id, timeslot_id, booking_date, is_active, created_at, updated_at)
+	_, err := q.Raw(`INSERT INTO timeslot_availabilities (court_id, timeslot_id, booking_date, is_active, created_at, updated_at)
 		VALUES (?, ?, ?, true, now(), now())
 		ON CONFLICT (court_id, timeslot_id, booking_date) DO UPDATE SET is_active = true, updated_at = now()`, courtId, timeslotId, bookingDate).Exec()
 	return err
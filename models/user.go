@@ -0,0 +1,80 @@
+package models
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/beego/beego/v2/client/orm"
+)
+
+// User roles carried in the JWT role claim
+const (
+	RoleCustomer = "customer"
+	RoleStaff    = "staff"
+	RoleAdmin    = "admin"
+)
+
+// ErrEmailTaken is returned when registering an email that already has an account
+var ErrEmailTaken = errors.New("email is already registered")
+
+type User struct {
+	Id           string    `orm:"column(id);pk;size(36)" json:"id"`
+	Name         string    `orm:"column(name);size(255)" json:"name"`
+	Email        string    `orm:"column(email);size(255)" json:"email"`
+	PasswordHash string    `orm:"column(password_hash);size(255)" json:"-"`
+	Role         string    `orm:"column(role);size(20);default(customer)" json:"role"`
+	IsActive     bool      `orm:"column(is_active);default(true)" json:"is_active"`
+	CreatedAt    time.Time `orm:"column(created_at);auto_now_add;type(datetime)" json:"created_at"`
+	UpdatedAt    time.Time `orm:"column(updated_at);auto_now;type(datetime)" json:"updated_at"`
+}
+
+func (u *User) TableName() string {
+	return "users"
+}
+
+func init() {
+	registerModels(new(User))
+}
+
+// IsValidRole reports whether role is one of the known roles
+func IsValidRole(role string) bool {
+	switch role {
+	case RoleCustomer, RoleStaff, RoleAdmin:
+		return true
+	}
+	return false
+}
+
+// CreateUser inserts a new user record
+func CreateUser(u *User) error {
+	o := orm.NewOrm()
+	// Use raw insert to avoid LastInsertId issues on Postgres drivers
+	_, err := o.Raw(`INSERT INTO users (id, name, email, password_hash, role, is_active, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, now(), now())`, u.Id, u.Name, strings.ToLower(u.Email), u.PasswordHash, u.Role, u.IsActive).Exec()
+	if isUniqueViolation(err, "idx_users_email") {
+		return ErrEmailTaken
+	}
+	return err
+}
+
+// GetUserById returns a user by id
+func GetUserById(id string) (*User, error) {
+	o := orm.NewOrm()
+	u := &User{Id: id}
+	if err := o.Read(u); err != nil {
+		return nil, err
+	}
+	return u, nil
+}
+
+// GetUserByEmail returns a user by (case-insensitive) email
+func GetUserByEmail(email string) (*User, error) {
+	o := orm.NewOrm()
+	u := &User{}
+	err := o.QueryTable(new(User)).Filter("email", strings.ToLower(email)).One(u)
+	if err != nil {
+		return nil, err
+	}
+	return u, nil
+}
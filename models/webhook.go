@@ -0,0 +1,304 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/beego/beego/v2/client/orm"
+)
+
+// ErrWebhookEndpointInactive is returned when replaying a delivery to a deactivated endpoint
+var ErrWebhookEndpointInactive = errors.New("webhook endpoint is inactive")
+
+// Webhook delivery statuses
+const (
+	WebhookPending   = "pending"
+	WebhookDelivered = "delivered"
+	WebhookDead      = "dead"
+)
+
+// WebhookEndpoint is a partner URL that receives domain events
+type WebhookEndpoint struct {
+	Id          int    `orm:"column(id);auto;pk" json:"id"`
+	Url         string `orm:"column(url);size(2048)" json:"url"`
+	Description string `orm:"column(description);size(255)" json:"description"`
+	// Secret signs every request; it is only shown when created or rotated
+	Secret string `orm:"column(secret);size(100)" json:"-"`
+	// EventTypes is a comma-separated list of the events to send; empty sends all
+	EventTypes string    `orm:"column(event_types);type(text)" json:"-"`
+	IsActive   bool      `orm:"column(is_active);default(true)" json:"is_active"`
+	CreatedAt  time.Time `orm:"column(created_at);auto_now_add;type(datetime)" json:"created_at"`
+	UpdatedAt  time.Time `orm:"column(updated_at);auto_now;type(datetime)" json:"updated_at"`
+
+	EventTypeList []string `orm:"-" json:"event_types"`
+}
+
+func (e *WebhookEndpoint) TableName() string {
+	return "webhook_endpoints"
+}
+
+// WebhookDelivery is one domain event on its way to one endpoint
+type WebhookDelivery struct {
+	Id             int64      `orm:"column(id);auto;pk" json:"id"`
+	EndpointId     int        `orm:"column(endpoint_id)" json:"endpoint_id"`
+	OutboxEventId  int64      `orm:"column(outbox_event_id)" json:"outbox_event_id"`
+	EventType      string     `orm:"column(event_type);size(64)" json:"event_type"`
+	Payload        string     `orm:"column(payload);type(text)" json:"payload"`
+	Status         string     `orm:"column(status);size(20);default(pending)" json:"status"`
+	Attempts       int        `orm:"column(attempts);default(0)" json:"attempts"`
+	NextAttemptAt  time.Time  `orm:"column(next_attempt_at);type(datetime)" json:"next_attempt_at"`
+	LastStatusCode int        `orm:"column(last_status_code);default(0)" json:"last_status_code"`
+	LastError      string     `orm:"column(last_error);type(text);null" json:"last_error,omitempty"`
+	DeliveredAt    *time.Time `orm:"column(delivered_at);type(datetime);null" json:"delivered_at,omitempty"`
+	CreatedAt      time.Time  `orm:"column(created_at);auto_now_add;type(datetime)" json:"created_at"`
+	UpdatedAt      time.Time  `orm:"column(updated_at);auto_now;type(datetime)" json:"updated_at"`
+
+	AttemptLog []*WebhookAttempt `orm:"-" json:"attempt_log,omitempty"`
+}
+
+func (d *WebhookDelivery) TableName() string {
+	return "webhook_deliveries"
+}
+
+func (d *WebhookDelivery) TableIndex() [][]string {
+	return [][]string{{"status", "next_attempt_at"}}
+}
+
+func (d *WebhookDelivery) TableUnique() [][]string {
+	return [][]string{{"endpoint_id", "outbox_event_id"}}
+}
+
+func (d *WebhookDelivery) TableReferences() map[string]string {
+	return map[string]string{"endpoint_id": "webhook_endpoints(id)"}
+}
+
+// WebhookAttempt is one HTTP request made for a delivery
+type WebhookAttempt struct {
+	Id           int64     `orm:"column(id);auto;pk" json:"id"`
+	DeliveryId   int64     `orm:"column(delivery_id)" json:"delivery_id"`
+	Attempt      int       `orm:"column(attempt)" json:"attempt"`
+	StatusCode   int       `orm:"column(status_code);default(0)" json:"status_code"`
+	Error        string    `orm:"column(error);type(text);null" json:"error,omitempty"`
+	ResponseBody string    `orm:"column(response_body);type(text);null" json:"response_body,omitempty"`
+	DurationMs   int       `orm:"column(duration_ms);default(0)" json:"duration_ms"`
+	CreatedAt    time.Time `orm:"column(created_at);auto_now_add;type(datetime)" json:"created_at"`
+}
+
+func (a *WebhookAttempt) TableName() string {
+	return "webhook_attempts"
+}
+
+func (a *WebhookAttempt) TableIndex() [][]string {
+	return [][]string{{"delivery_id"}}
+}
+
+func (a *WebhookAttempt) TableReferences() map[string]string {
+	return map[string]string{"delivery_id": "webhook_deliveries(id)"}
+}
+
+func init() {
+	registerModels(new(WebhookEndpoint), new(WebhookDelivery), new(WebhookAttempt))
+}
+
+// Receives reports whether the endpoint subscribed to eventType
+func (e *WebhookEndpoint) Receives(eventType string) bool {
+	if len(e.EventTypeList) == 0 {
+		return true
+	}
+	for _, t := range e.EventTypeList {
+		if t == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// load fills EventTypeList from the stored column
+func (e *WebhookEndpoint) load() *WebhookEndpoint {
+	e.EventTypeList = strings.FieldsFunc(e.EventTypes, func(r rune) bool { return r == ',' || r == ' ' })
+	return e
+}
+
+// CreateWebhookEndpoint inserts an endpoint
+func CreateWebhookEndpoint(e *WebhookEndpoint) error {
+	e.EventTypes = strings.Join(e.EventTypeList, ",")
+	o := orm.NewOrm()
+	return o.Raw(`INSERT INTO webhook_endpoints (url, description, secret, event_types, is_active, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, now(), now()) RETURNING id, created_at, updated_at`,
+		e.Url, e.Description, e.Secret, e.EventTypes, e.IsActive).QueryRow(&e.Id, &e.CreatedAt, &e.UpdatedAt)
+}
+
+// UpdateWebhookEndpoint saves the editable columns of an endpoint, including its secret
+func UpdateWebhookEndpoint(e *WebhookEndpoint) error {
+	e.EventTypes = strings.Join(e.EventTypeList, ",")
+	o := orm.NewOrm()
+	_, err := o.Update(e, "url", "description", "secret", "event_types", "is_active")
+	return err
+}
+
+// DeleteWebhookEndpoint removes an endpoint with its deliveries and attempts
+func DeleteWebhookEndpoint(id int) error {
+	o := orm.NewOrm()
+	_, err := o.Delete(&WebhookEndpoint{Id: id})
+	return err
+}
+
+// GetWebhookEndpointById returns an endpoint
+func GetWebhookEndpointById(id int) (*WebhookEndpoint, error) {
+	o := orm.NewOrm()
+	e := &WebhookEndpoint{Id: id}
+	if err := o.Read(e); err != nil {
+		return nil, err
+	}
+	return e.load(), nil
+}
+
+// GetWebhookEndpoints lists endpoints; activeOnly leaves out deactivated ones
+func GetWebhookEndpoints(activeOnly bool) ([]*WebhookEndpoint, error) {
+	o := orm.NewOrm()
+	qs := o.QueryTable(new(WebhookEndpoint))
+	if activeOnly {
+		qs = qs.Filter("is_active", true)
+	}
+	var list []*WebhookEndpoint
+	if _, err := qs.OrderBy("id").All(&list); err != nil {
+		return nil, err
+	}
+	for _, e := range list {
+		e.load()
+	}
+	return list, nil
+}
+
+// CreateWebhookDeliveries queues payload, the body of event, for each endpoint. Endpoints
+// that already have a delivery of the event are skipped, so fanning an event out again
+// creates no duplicates. It returns how many deliveries were created.
+func CreateWebhookDeliveries(event *OutboxEvent, endpointIds []int, payload string) (int, error) {
+	created := 0
+	o := orm.NewOrm()
+	err := o.DoTx(func(ctx context.Context, txOrm orm.TxOrmer) error {
+		created = 0
+		for _, endpointId := range endpointIds {
+			res, err := txOrm.Raw(`INSERT INTO webhook_deliveries (endpoint_id, outbox_event_id, event_type, payload, status, attempts, next_attempt_at, last_status_code, created_at, updated_at)
+				VALUES (?, ?, ?, ?, ?, 0, now(), 0, now(), now())
+				ON CONFLICT (endpoint_id, outbox_event_id) DO NOTHING`, endpointId, event.Id, event.EventType, payload, WebhookPending).Exec()
+			if err != nil {
+				return err
+			}
+			if n, _ := res.RowsAffected(); n > 0 {
+				created++
+			}
+		}
+		return nil
+	})
+	return created, err
+}
+
+// ClaimWebhookDeliveries leases up to limit due pending deliveries to active endpoints for
+// lease and counts the attempt, like ClaimOutboxEvents. Deliveries to deactivated endpoints
+// wait without using up attempts.
+func ClaimWebhookDeliveries(limit int, lease time.Duration) ([]*WebhookDelivery, error) {
+	o := orm.NewOrm()
+	var ids []int64
+	if _, err := o.Raw(`UPDATE webhook_deliveries SET attempts = attempts + 1, next_attempt_at = ?, updated_at = now()
+		WHERE id IN (SELECT d.id FROM webhook_deliveries d JOIN webhook_endpoints e ON e.id = d.endpoint_id
+			WHERE d.status = ? AND d.next_attempt_at <= now() AND e.is_active
+			ORDER BY d.id LIMIT ? FOR UPDATE OF d SKIP LOCKED)
+		RETURNING id`, time.Now().Add(lease), WebhookPending, limit).QueryRows(&ids); err != nil {
+		return nil, err
+	}
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	var list []*WebhookDelivery
+	_, err := o.QueryTable(new(WebhookDelivery)).Filter("id__in", ids).OrderBy("id").All(&list)
+	return list, err
+}
+
+// RecordWebhookAttempt logs attempt and moves its delivery to status. A pending delivery is
+// retried at nextAttemptAt.
+func RecordWebhookAttempt(attempt *WebhookAttempt, status string, nextAttemptAt time.Time) error {
+	deliveredAt := "delivered_at"
+	if status == WebhookDelivered {
+		deliveredAt = "now()"
+	}
+	o := orm.NewOrm()
+	return o.DoTx(func(ctx context.Context, txOrm orm.TxOrmer) error {
+		if err := txOrm.Raw(`INSERT INTO webhook_attempts (delivery_id, attempt, status_code, error, response_body, duration_ms, created_at)
+			VALUES (?, ?, ?, ?, ?, ?, now()) RETURNING id`, attempt.DeliveryId, attempt.Attempt, attempt.StatusCode,
+			nullString(attempt.Error), nullString(attempt.ResponseBody), attempt.DurationMs).QueryRow(&attempt.Id); err != nil {
+			return err
+		}
+		_, err := txOrm.Raw(`UPDATE webhook_deliveries SET status = ?, last_status_code = ?, last_error = ?, next_attempt_at = ?,
+				delivered_at = `+deliveredAt+`, updated_at = now()
+			WHERE id = ?`, status, attempt.StatusCode, nullString(attempt.Error), nextAttemptAt, attempt.DeliveryId).Exec()
+		return err
+	})
+}
+
+// ReplayWebhookDelivery sends a delivery again now with a fresh set of attempts, whatever its
+// status. The body is the one originally queued.
+func ReplayWebhookDelivery(id int64) (*WebhookDelivery, error) {
+	d, err := GetWebhookDeliveryById(id)
+	if err != nil {
+		return nil, err
+	}
+	endpoint, err := GetWebhookEndpointById(d.EndpointId)
+	if err != nil {
+		return nil, err
+	}
+	if !endpoint.IsActive {
+		return d, ErrWebhookEndpointInactive
+	}
+	o := orm.NewOrm()
+	if _, err := o.Raw("UPDATE webhook_deliveries SET status = ?, attempts = 0, next_attempt_at = now(), updated_at = now() WHERE id = ?",
+		WebhookPending, id).Exec(); err != nil {
+		return nil, err
+	}
+	return GetWebhookDeliveryById(id)
+}
+
+// GetWebhookDeliveryById returns a delivery with its attempts, oldest first
+func GetWebhookDeliveryById(id int64) (*WebhookDelivery, error) {
+	o := orm.NewOrm()
+	d := &WebhookDelivery{Id: id}
+	if err := o.Read(d); err != nil {
+		return nil, err
+	}
+	if _, err := o.QueryTable(new(WebhookAttempt)).Filter("delivery_id", id).OrderBy("id").All(&d.AttemptLog); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// GetWebhookDeliveries lists deliveries, newest first, optionally filtered by endpoint (0 for
+// all), status and event type
+func GetWebhookDeliveries(endpointId int, status, eventType string, limit int) ([]*WebhookDelivery, error) {
+	o := orm.NewOrm()
+	qs := o.QueryTable(new(WebhookDelivery))
+	if endpointId != 0 {
+		qs = qs.Filter("endpoint_id", endpointId)
+	}
+	if status != "" {
+		qs = qs.Filter("status", status)
+	}
+	if eventType != "" {
+		qs = qs.Filter("event_type", eventType)
+	}
+	var list []*WebhookDelivery
+	_, err := qs.OrderBy("-id").Limit(limit).All(&list)
+	return list, err
+}
+
+// DeleteDeliveredWebhookDeliveries deletes deliveries delivered before t, with their
+// attempts, and returns how many
+func DeleteDeliveredWebhookDeliveries(before time.Time) (int64, error) {
+	o := orm.NewOrm()
+	res, err := o.Raw("DELETE FROM webhook_deliveries WHERE status = ? AND delivered_at < ?", WebhookDelivered, before).Exec()
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
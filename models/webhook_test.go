@@ -0,0 +1,17 @@
+package models
+
+import "testing"
+
+func TestWebhookEndpointReceives(t *testing.T) {
+	all := &WebhookEndpoint{}
+	if !all.Receives(EventSeriesCreated) {
+		t.Error("endpoint without a filter does not receive every event")
+	}
+	some := &WebhookEndpoint{EventTypeList: []string{EventReservationPaid, EventPaymentRefunded}}
+	if !some.Receives(EventPaymentRefunded) {
+		t.Error("filter holds back a listed event")
+	}
+	if some.Receives(EventReservationCreated) {
+		t.Error("filter lets an unlisted event through")
+	}
+}
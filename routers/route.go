@@ -2,6 +2,8 @@ package routers
 
 import (
 	"badminton-reservation-api/controllers"
+	"badminton-reservation-api/middleware"
+	"badminton-reservation-api/models"
 
 	"github.com/beego/beego/v2/server/web"
 )
@@ -9,9 +11,19 @@ import (
 func init() {
 	// API v1 namespace
 	ns := web.NewNamespace("/api/v1",
+		// Auth routes
+		web.NSRouter("/auth/register", &controllers.AuthController{}, "post:Register"),
+		web.NSRouter("/auth/login", &controllers.AuthController{}, "post:Login"),
+		web.NSRouter("/auth/refresh", &controllers.AuthController{}, "post:Refresh"),
+		web.NSRouter("/auth/me", &controllers.AuthController{}, "get:Me"),
+		web.NSRouter("/auth/me/notification-preferences", &controllers.NotificationPreferenceController{}, "get:GetPreferences;put:UpdatePreferences"),
+
 		// Date routes
 		web.NSRouter("/dates", &controllers.DateController{}, "get:GetAvailableDates"),
 
+		// Availability routes
+		web.NSRouter("/availability", &controllers.AvailabilityController{}, "get:GetAvailability"),
+
 		// Timeslot routes
 		web.NSRouter("/timeslots", &controllers.TimeslotController{}, "get:GetAvailableTimeslots"),
 		web.NSRouter("/timeslots/all", &controllers.TimeslotController{}, "get:GetAllTimeslots"),
@@ -24,16 +36,86 @@ func init() {
 		web.NSRouter("/reservations", &controllers.ReservationController{}, "post:CreateReservation"),
 		web.NSRouter("/reservations/:id", &controllers.ReservationController{}, "get:GetReservationById"),
 		web.NSRouter("/reservations/:id/status", &controllers.ReservationController{}, "post:UpdateStatus"),
+		web.NSRouter("/reservations/:id/cancel", &controllers.ReservationController{}, "post:CancelReservation"),
 		web.NSRouter("/reservations/customer", &controllers.ReservationController{}, "get:GetReservationsByEmail"),
 
+		// Recurring reservation series routes
+		web.NSRouter("/series", &controllers.SeriesController{}, "post:CreateSeries"),
+		web.NSRouter("/series/:id", &controllers.SeriesController{}, "get:GetSeries"),
+		web.NSRouter("/series/:id/cancel", &controllers.SeriesController{}, "post:CancelSeries"),
+
+		// Admin court routes
+		web.NSRouter("/admin/courts", &controllers.AdminCourtController{}, "get:ListCourts;post:CreateCourt"),
+		web.NSRouter("/admin/courts/:id", &controllers.AdminCourtController{}, "get:GetCourt;put:UpdateCourt"),
+		web.NSRouter("/admin/courts/:id/price", &controllers.AdminCourtController{}, "put:UpdatePrice"),
+		web.NSRouter("/admin/courts/:id/activate", &controllers.AdminCourtController{}, "post:Activate"),
+		web.NSRouter("/admin/courts/:id/deactivate", &controllers.AdminCourtController{}, "post:Deactivate"),
+		web.NSRouter("/admin/courts/:id/maintenance", &controllers.AdminCourtController{}, "post:Maintenance"),
+
+		// Admin timeslot routes
+		web.NSRouter("/admin/timeslots", &controllers.AdminTimeslotController{}, "get:ListTimeslots;post:CreateTimeslot"),
+		web.NSRouter("/admin/timeslots/:id", &controllers.AdminTimeslotController{}, "put:UpdateTimeslot;delete:DeleteTimeslot"),
+		web.NSRouter("/admin/timeslots/:id/activate", &controllers.AdminTimeslotController{}, "post:Activate"),
+		web.NSRouter("/admin/timeslots/:id/deactivate", &controllers.AdminTimeslotController{}, "post:Deactivate"),
+
+		// Admin pricing rule routes
+		web.NSRouter("/admin/pricing-rules", &controllers.AdminPricingController{}, "get:ListRules;post:CreateRule"),
+		web.NSRouter("/admin/pricing-rules/:id", &controllers.AdminPricingController{}, "put:UpdateRule;delete:DeleteRule"),
+
+		// Admin promo code routes
+		web.NSRouter("/admin/promo-codes", &controllers.AdminPromoController{}, "get:ListPromoCodes;post:CreatePromoCode"),
+		web.NSRouter("/admin/promo-codes/:id", &controllers.AdminPromoController{}, "put:UpdatePromoCode;delete:DeletePromoCode"),
+
+		// Admin payment routes
+		web.NSRouter("/admin/payments/reconcile", &controllers.AdminPaymentController{}, "post:Reconcile"),
+		web.NSRouter("/admin/payments/:id/refund", &controllers.AdminPaymentController{}, "post:Refund"),
+		web.NSRouter("/admin/payments/:id/refunds", &controllers.AdminPaymentController{}, "get:ListRefunds"),
+		web.NSRouter("/admin/payment-events", &controllers.AdminPaymentController{}, "get:ListEvents"),
+		web.NSRouter("/admin/payment-events/:id/replay", &controllers.AdminPaymentController{}, "post:ReplayEvent"),
+		web.NSRouter("/admin/payment-events/:id/review", &controllers.AdminPaymentController{}, "post:ReviewEvent"),
+
+		// Admin background job routes
+		web.NSRouter("/admin/jobs", &controllers.AdminJobController{}, "get:ListJobs"),
+
+		// Admin outbox routes
+		web.NSRouter("/admin/outbox", &controllers.AdminOutboxController{}, "get:ListEvents"),
+		web.NSRouter("/admin/outbox/:id/retry", &controllers.AdminOutboxController{}, "post:RetryEvent"),
+
+		// Admin notification routes
+		web.NSRouter("/admin/notifications", &controllers.AdminNotificationController{}, "get:ListNotifications"),
+
+		// Admin webhook routes
+		web.NSRouter("/admin/webhooks", &controllers.AdminWebhookController{}, "get:ListEndpoints;post:CreateEndpoint"),
+		web.NSRouter("/admin/webhooks/:id", &controllers.AdminWebhookController{}, "get:GetEndpoint;put:UpdateEndpoint;delete:DeleteEndpoint"),
+		web.NSRouter("/admin/webhooks/:id/rotate-secret", &controllers.AdminWebhookController{}, "post:RotateSecret"),
+		web.NSRouter("/admin/webhook-deliveries", &controllers.AdminWebhookController{}, "get:ListDeliveries"),
+		web.NSRouter("/admin/webhook-deliveries/:id", &controllers.AdminWebhookController{}, "get:GetDelivery"),
+		web.NSRouter("/admin/webhook-deliveries/:id/replay", &controllers.AdminWebhookController{}, "post:ReplayDelivery"),
+
 		// Payment routes
 		web.NSRouter("/payments/process", &controllers.PaymentController{}, "post:ProcessPayment"),
 		web.NSRouter("/payments/callback", &controllers.PaymentController{}, "post:PaymentCallback"),
+		web.NSRouter("/payments/callback/:gateway", &controllers.PaymentController{}, "post:PaymentCallback"),
 		web.NSRouter("/payments/:id", &controllers.PaymentController{}, "get:GetPaymentStatus"),
 	)
 
 	web.AddNamespace(ns)
 
+	// Route access policies. Filters run at BeforeExec so CORS (BeforeRouter) is applied first.
+	web.InsertFilter("/api/v1/auth/me", web.BeforeExec, middleware.RequireRoles())
+	web.InsertFilter("/api/v1/auth/me/notification-preferences", web.BeforeExec, middleware.RequireRoles())
+	web.InsertFilter("/api/v1/reservations", web.BeforeExec, middleware.OptionalAuth())
+	web.InsertFilter("/api/v1/reservations/:id/status", web.BeforeExec, middleware.RequireRoles(models.RoleStaff, models.RoleAdmin))
+	web.InsertFilter("/api/v1/reservations/:id/cancel", web.BeforeExec, middleware.OptionalAuth())
+	web.InsertFilter("/api/v1/series", web.BeforeExec, middleware.RequireRoles())
+	web.InsertFilter("/api/v1/series/:id", web.BeforeExec, middleware.RequireRoles())
+	web.InsertFilter("/api/v1/series/:id/cancel", web.BeforeExec, middleware.RequireRoles())
+	web.InsertFilter("/api/v1/admin/*", web.BeforeExec, middleware.RequireRoles(models.RoleAdmin))
+
+	// Retried creates with the same Idempotency-Key return the original response
+	middleware.RegisterIdempotency("/api/v1/reservations")
+	middleware.RegisterIdempotency("/api/v1/payments/process")
+
 	// Health check endpoint
 	web.Router("/health", &controllers.HealthController{}, "get:Get")
 
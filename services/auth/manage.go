@@ -0,0 +1,32 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+)
+
+// ManageToken returns the signed token for a reservation's manage link, letting a customer
+// without an account act on that one reservation (e.g. cancel it)
+func ManageToken(reservationId string) (string, error) {
+	key, err := secret()
+	if err != nil {
+		return "", err
+	}
+	return signManage(key, reservationId), nil
+}
+
+// VerifyManageToken reports whether token is the manage-link token of reservationId
+func VerifyManageToken(reservationId string, token string) bool {
+	key, err := secret()
+	if err != nil || token == "" {
+		return false
+	}
+	return hmac.Equal([]byte(signManage(key, reservationId)), []byte(token))
+}
+
+func signManage(key []byte, reservationId string) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte("manage:" + reservationId))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,47 @@
+package auth
+
+import "testing"
+
+func TestManageToken(t *testing.T) {
+	t.Setenv("JWT_SECRET", testSecret)
+	token, err := ManageToken("res-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !VerifyManageToken("res-1", token) {
+		t.Fatal("manage token rejected for its own reservation")
+	}
+
+	tampered := []byte(token)
+	tampered[0] ^= 1
+	other, err := ManageToken("res-2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name          string
+		reservationId string
+		token         string
+	}{
+		{"tampered token", "res-1", string(tampered)},
+		{"truncated token", "res-1", token[:len(token)-1]},
+		{"token of another reservation", "res-1", other},
+		{"empty token", "res-1", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if VerifyManageToken(tt.reservationId, tt.token) {
+				t.Error("token accepted")
+			}
+		})
+	}
+
+	t.Setenv("JWT_SECRET", "another-secret-key-with-at-least-32-chars")
+	if VerifyManageToken("res-1", token) {
+		t.Error("token accepted after the secret changed")
+	}
+	t.Setenv("JWT_SECRET", "")
+	if _, err := ManageToken("res-1"); err == nil {
+		t.Error("manage token issued without a secret")
+	}
+}
@@ -0,0 +1,139 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"badminton-reservation-api/utils"
+
+	"github.com/golang-jwt/jwt/v5"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Token types carried in the token_type claim so a refresh token cannot be used as an access token
+const (
+	TokenTypeAccess  = "access"
+	TokenTypeRefresh = "refresh"
+)
+
+// ErrInvalidToken is returned for malformed, expired or wrongly-typed tokens
+var ErrInvalidToken = errors.New("invalid or expired token")
+
+// Claims are the JWT claims issued by the API
+type Claims struct {
+	Email     string `json:"email"`
+	Role      string `json:"role"`
+	TokenType string `json:"token_type"`
+	jwt.RegisteredClaims
+}
+
+// UserId returns the subject (user id) of the token
+func (c *Claims) UserId() string {
+	return c.Subject
+}
+
+// TokenPair is returned by login and refresh
+type TokenPair struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int64  `json:"expires_in"`
+}
+
+// secret returns the signing key from JWT_SECRET
+func secret() ([]byte, error) {
+	s := os.Getenv("JWT_SECRET")
+	if len(s) < 32 {
+		return nil, fmt.Errorf("JWT_SECRET must be at least 32 characters")
+	}
+	return []byte(s), nil
+}
+
+// CheckSecret reports a missing or too short JWT_SECRET so the API can refuse to start
+// instead of rejecting every bearer token
+func CheckSecret() error {
+	_, err := secret()
+	return err
+}
+
+// AccessTokenTTL is the lifetime of access tokens (JWT_EXPIRATION, default 24h)
+func AccessTokenTTL() time.Duration {
+	return utils.EnvDuration("JWT_EXPIRATION", 24*time.Hour)
+}
+
+// RefreshTokenTTL is the lifetime of refresh tokens (JWT_REFRESH_EXPIRATION, default 7 days)
+func RefreshTokenTTL() time.Duration {
+	return utils.EnvDuration("JWT_REFRESH_EXPIRATION", 7*24*time.Hour)
+}
+
+func sign(userId, email, role, tokenType string, ttl time.Duration) (string, error) {
+	key, err := secret()
+	if err != nil {
+		return "", err
+	}
+	now := time.Now()
+	claims := Claims{
+		Email:     email,
+		Role:      role,
+		TokenType: tokenType,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   userId,
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
+		},
+	}
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(key)
+}
+
+// GenerateTokenPair issues a new access and refresh token for the given user
+func GenerateTokenPair(userId, email, role string) (*TokenPair, error) {
+	access, err := sign(userId, email, role, TokenTypeAccess, AccessTokenTTL())
+	if err != nil {
+		return nil, err
+	}
+	refresh, err := sign(userId, email, role, TokenTypeRefresh, RefreshTokenTTL())
+	if err != nil {
+		return nil, err
+	}
+	return &TokenPair{
+		AccessToken:  access,
+		RefreshToken: refresh,
+		TokenType:    "Bearer",
+		ExpiresIn:    int64(AccessTokenTTL().Seconds()),
+	}, nil
+}
+
+// ParseToken validates the signature and expiry of a token and checks its type
+func ParseToken(tokenString string, tokenType string) (*Claims, error) {
+	key, err := secret()
+	if err != nil {
+		return nil, err
+	}
+	claims := &Claims{}
+	_, err = jwt.ParseWithClaims(tokenString, claims, func(t *jwt.Token) (interface{}, error) {
+		return key, nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}), jwt.WithExpirationRequired())
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+	if claims.TokenType != tokenType || claims.Subject == "" {
+		return nil, ErrInvalidToken
+	}
+	return claims, nil
+}
+
+// HashPassword hashes a plaintext password with bcrypt
+func HashPassword(password string) (string, error) {
+	h, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+	return string(h), nil
+}
+
+// CheckPassword compares a plaintext password against a bcrypt hash
+func CheckPassword(hash, password string) bool {
+	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil
+}
@@ -0,0 +1,127 @@
+package auth
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const testSecret = "test-secret-key-with-at-least-32-chars"
+
+func TestParseToken(t *testing.T) {
+	t.Setenv("JWT_SECRET", testSecret)
+	pair, err := GenerateTokenPair("user-1", "budi@example.com", "staff")
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims, err := ParseToken(pair.AccessToken, TokenTypeAccess)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if claims.UserId() != "user-1" || claims.Email != "budi@example.com" || claims.Role != "staff" {
+		t.Errorf("claims are %+v", claims)
+	}
+	if _, err := ParseToken(pair.RefreshToken, TokenTypeRefresh); err != nil {
+		t.Errorf("refresh token rejected as a refresh token: %v", err)
+	}
+
+	signed := func(method jwt.SigningMethod, key interface{}, claims Claims) string {
+		t.Helper()
+		s, err := jwt.NewWithClaims(method, claims).SignedString(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return s
+	}
+	valid := func(tokenType string) Claims {
+		return Claims{TokenType: tokenType, RegisteredClaims: jwt.RegisteredClaims{
+			Subject: "user-1", ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+		}}
+	}
+	expired := valid(TokenTypeAccess)
+	expired.ExpiresAt = jwt.NewNumericDate(time.Now().Add(-time.Minute))
+	noExpiry := valid(TokenTypeAccess)
+	noExpiry.ExpiresAt = nil
+	noSubject := valid(TokenTypeAccess)
+	noSubject.Subject = ""
+
+	tests := []struct {
+		name  string
+		token string
+	}{
+		{"refresh token used as an access token", pair.RefreshToken},
+		{"HS512 with the right key", signed(jwt.SigningMethodHS512, []byte(testSecret), valid(TokenTypeAccess))},
+		{"unsigned token", signed(jwt.SigningMethodNone, jwt.UnsafeAllowNoneSignatureType, valid(TokenTypeAccess))},
+		{"signed with another key", signed(jwt.SigningMethodHS256, []byte(strings.Repeat("x", 32)), valid(TokenTypeAccess))},
+		{"expired", signed(jwt.SigningMethodHS256, []byte(testSecret), expired)},
+		{"no expiry", signed(jwt.SigningMethodHS256, []byte(testSecret), noExpiry)},
+		{"no subject", signed(jwt.SigningMethodHS256, []byte(testSecret), noSubject)},
+		{"tampered signature", pair.AccessToken[:len(pair.AccessToken)-4] + "AAAA"},
+		{"garbage", "not.a.token"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ParseToken(tt.token, TokenTypeAccess); !errors.Is(err, ErrInvalidToken) {
+				t.Errorf("got %v, want ErrInvalidToken", err)
+			}
+		})
+	}
+	if _, err := ParseToken(pair.AccessToken, TokenTypeRefresh); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("access token used as a refresh token: got %v", err)
+	}
+}
+
+func TestTokenTTL(t *testing.T) {
+	t.Setenv("JWT_SECRET", testSecret)
+	t.Setenv("JWT_EXPIRATION", "15m")
+	t.Setenv("JWT_REFRESH_EXPIRATION", "not-a-duration")
+	if got := AccessTokenTTL(); got != 15*time.Minute {
+		t.Errorf("access TTL is %s, want 15m", got)
+	}
+	if got := RefreshTokenTTL(); got != 7*24*time.Hour {
+		t.Errorf("invalid refresh TTL gives %s, want the 7 day default", got)
+	}
+	pair, err := GenerateTokenPair("user-1", "budi@example.com", "customer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pair.ExpiresIn != 900 {
+		t.Errorf("expires_in is %d, want 900", pair.ExpiresIn)
+	}
+	claims, err := ParseToken(pair.AccessToken, TokenTypeAccess)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if left := time.Until(claims.ExpiresAt.Time); left > 15*time.Minute || left < 14*time.Minute {
+		t.Errorf("access token expires in %s", left)
+	}
+}
+
+func TestCheckSecret(t *testing.T) {
+	for _, s := range []string{"", "short-secret"} {
+		t.Setenv("JWT_SECRET", s)
+		if CheckSecret() == nil {
+			t.Errorf("JWT_SECRET %q accepted", s)
+		}
+		if _, err := GenerateTokenPair("user-1", "budi@example.com", "customer"); err == nil {
+			t.Errorf("tokens signed with JWT_SECRET %q", s)
+		}
+	}
+	t.Setenv("JWT_SECRET", testSecret)
+	if err := CheckSecret(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestPassword(t *testing.T) {
+	hash, err := HashPassword("rahasia123")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !CheckPassword(hash, "rahasia123") || CheckPassword(hash, "rahasia124") {
+		t.Error("password check does not match the hashed password only")
+	}
+}
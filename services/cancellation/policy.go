@@ -0,0 +1,76 @@
+package cancellation
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultPolicy refunds everything up to 24 hours before the game and half of it until the
+// game starts; cancelling after the start refunds nothing.
+const DefaultPolicy = "24:100,0:50"
+
+// Tier refunds Percent of the paid amount when the booking is cancelled at least
+// MinHoursBefore hours before the game starts
+type Tier struct {
+	MinHoursBefore float64 `json:"min_hours_before"`
+	Percent        float64 `json:"percent"`
+}
+
+// Policy is a list of refund tiers ordered from the earliest cancellation to the latest.
+// A cancellation that matches no tier is not refunded.
+type Policy struct {
+	Tiers []Tier `json:"tiers"`
+}
+
+// ParsePolicy parses "hours:percent" pairs separated by commas, e.g. "24:100,0:50"
+func ParsePolicy(s string) (Policy, error) {
+	var p Policy
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		fields := strings.SplitN(part, ":", 2)
+		if len(fields) != 2 {
+			return Policy{}, fmt.Errorf("invalid cancellation tier %q, expected hours:percent", part)
+		}
+		hours, err := strconv.ParseFloat(strings.TrimSpace(fields[0]), 64)
+		if err != nil {
+			return Policy{}, fmt.Errorf("invalid hours in cancellation tier %q", part)
+		}
+		percent, err := strconv.ParseFloat(strings.TrimSpace(fields[1]), 64)
+		if err != nil || percent < 0 || percent > 100 {
+			return Policy{}, fmt.Errorf("invalid percent in cancellation tier %q", part)
+		}
+		p.Tiers = append(p.Tiers, Tier{MinHoursBefore: hours, Percent: percent})
+	}
+	sort.Slice(p.Tiers, func(i, j int) bool { return p.Tiers[i].MinHoursBefore > p.Tiers[j].MinHoursBefore })
+	return p, nil
+}
+
+// PolicyFromEnv reads CANCELLATION_POLICY, falling back to DefaultPolicy when unset or invalid
+func PolicyFromEnv() Policy {
+	if v := os.Getenv("CANCELLATION_POLICY"); v != "" {
+		if p, err := ParsePolicy(v); err == nil {
+			return p
+		}
+	}
+	p, _ := ParsePolicy(DefaultPolicy)
+	return p
+}
+
+// RefundPercent returns the share of the paid amount refunded when cancelling at now a game
+// that starts at start
+func (p Policy) RefundPercent(start time.Time, now time.Time) float64 {
+	hoursBefore := start.Sub(now).Hours()
+	for _, t := range p.Tiers {
+		if hoursBefore >= t.MinHoursBefore {
+			return t.Percent
+		}
+	}
+	return 0
+}
@@ -0,0 +1,66 @@
+package cancellation
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParsePolicy(t *testing.T) {
+	p, err := ParsePolicy(" 0:50, 48:100 ,24:75,")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Tier{{48, 100}, {24, 75}, {0, 50}}
+	if !reflect.DeepEqual(p.Tiers, want) {
+		t.Errorf("tiers are %v, want %v ordered from the earliest cancellation", p.Tiers, want)
+	}
+
+	for _, s := range []string{"24", "24:100;0:50", "day:100", "24:half", "24:150", "24:-10", "24:100,0"} {
+		if _, err := ParsePolicy(s); err == nil {
+			t.Errorf("malformed policy %q accepted", s)
+		}
+	}
+}
+
+func TestRefundPercent(t *testing.T) {
+	p, err := ParsePolicy(DefaultPolicy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Date(2026, 10, 20, 19, 0, 0, 0, time.Local)
+	tests := []struct {
+		name   string
+		before time.Duration
+		want   float64
+	}{
+		{"a week ahead", 7 * 24 * time.Hour, 100},
+		{"exactly 24 hours ahead", 24 * time.Hour, 100},
+		{"just inside 24 hours", 24*time.Hour - time.Second, 50},
+		{"exactly at the start", 0, 50},
+		{"after the start", -time.Second, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := p.RefundPercent(start, start.Add(-tt.before)); got != tt.want {
+				t.Errorf("refund is %v%%, want %v%%", got, tt.want)
+			}
+		})
+	}
+
+	if got := (Policy{}).RefundPercent(start, start.Add(-48*time.Hour)); got != 0 {
+		t.Errorf("an empty policy refunds %v%%", got)
+	}
+}
+
+func TestPolicyFromEnv(t *testing.T) {
+	t.Setenv("CANCELLATION_POLICY", "12:80")
+	if got := PolicyFromEnv(); !reflect.DeepEqual(got.Tiers, []Tier{{12, 80}}) {
+		t.Errorf("configured policy is %v", got.Tiers)
+	}
+	t.Setenv("CANCELLATION_POLICY", "12:eighty")
+	def, _ := ParsePolicy(DefaultPolicy)
+	if got := PolicyFromEnv(); !reflect.DeepEqual(got, def) {
+		t.Errorf("invalid policy gives %v, want the default", got.Tiers)
+	}
+}
@@ -0,0 +1,119 @@
+package notification
+
+import (
+	"badminton-reservation-api/models"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// Recipient is who a notification is for; each channel picks the address it needs
+type Recipient struct {
+	Name  string
+	Email string
+	Phone string // E.164
+}
+
+// Notifier delivers rendered messages over one channel
+type Notifier interface {
+	// Channel is models.ChannelEmail, models.ChannelSMS or models.ChannelWhatsApp
+	Channel() string
+	// Address returns where to reach to over this channel, or "" when it cannot
+	Address(to Recipient) string
+	// Notify sends msg to address
+	Notify(ctx context.Context, address string, msg *Message) error
+}
+
+// EmailNotifier sends notifications as email through Mailer
+type EmailNotifier struct {
+	Mailer Mailer
+}
+
+func (e *EmailNotifier) Channel() string { return models.ChannelEmail }
+
+func (e *EmailNotifier) Address(to Recipient) string { return to.Email }
+
+func (e *EmailNotifier) Notify(ctx context.Context, address string, msg *Message) error {
+	return e.Mailer.Send(ctx, address, msg)
+}
+
+// HTTPNotifier sends SMS or WhatsApp messages through a provider's HTTP API. Each message
+// is POSTed to URL as
+//
+//	{"channel": "whatsapp", "from": "<Sender>", "to": "+6281234567890", "message": "..."}
+//
+// with "Authorization: Bearer <Token>" when Token is set; any 2xx response means the
+// provider accepted it. SMS carries Message.Short, WhatsApp Message.Text.
+type HTTPNotifier struct {
+	Name   string // models.ChannelSMS or models.ChannelWhatsApp
+	URL    string
+	Token  string
+	Sender string
+	Client *http.Client
+}
+
+// HTTPFromEnv configures an HTTPNotifier for channel (sms or whatsapp) from
+// <CHANNEL>_PROVIDER_URL, <CHANNEL>_PROVIDER_TOKEN and <CHANNEL>_SENDER, e.g.
+// WHATSAPP_PROVIDER_URL. It returns nil when the URL is not set.
+func HTTPFromEnv(channel string) *HTTPNotifier {
+	prefix := strings.ToUpper(channel) + "_"
+	url := os.Getenv(prefix + "PROVIDER_URL")
+	if url == "" {
+		return nil
+	}
+	return &HTTPNotifier{
+		Name:   channel,
+		URL:    url,
+		Token:  os.Getenv(prefix + "PROVIDER_TOKEN"),
+		Sender: os.Getenv(prefix + "SENDER"),
+		Client: &http.Client{Timeout: 15 * time.Second},
+	}
+}
+
+func (h *HTTPNotifier) Channel() string { return h.Name }
+
+func (h *HTTPNotifier) Address(to Recipient) string { return to.Phone }
+
+func (h *HTTPNotifier) Notify(ctx context.Context, address string, msg *Message) error {
+	text := msg.Text
+	if h.Name == models.ChannelSMS {
+		text = msg.Short
+	}
+	body, err := json.Marshal(map[string]string{
+		"channel": h.Name,
+		"from":    h.Sender,
+		"to":      address,
+		"message": text,
+	})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, h.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if h.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+h.Token)
+	}
+	client := h.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		detail, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("%s provider returned %d: %s", h.Name, resp.StatusCode, strings.TrimSpace(string(detail)))
+	}
+	return nil
+}
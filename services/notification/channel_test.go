@@ -0,0 +1,67 @@
+package notification
+
+import (
+	"badminton-reservation-api/models"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestHTTPNotifier(t *testing.T) {
+	t.Setenv("CURRENCY", "IDR")
+	type request struct {
+		auth string
+		body map[string]string
+	}
+	var got []request
+	status := http.StatusAccepted
+	provider := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]string
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		got = append(got, request{auth: r.Header.Get("Authorization"), body: body})
+		w.WriteHeader(status)
+		fmt.Fprint(w, `{"error":"quota exceeded"}`)
+	}))
+	defer provider.Close()
+
+	msg := mustRender(t, models.NotifyGameReminder, "id", testBooking())
+	to := Recipient{Name: "Budi", Email: "budi@example.com", Phone: "+6281234567890"}
+	sms := &HTTPNotifier{Name: models.ChannelSMS, URL: provider.URL, Token: "sms-key", Sender: "GORSEHAT"}
+	whatsapp := &HTTPNotifier{Name: models.ChannelWhatsApp, URL: provider.URL, Sender: "+6221555000"}
+	if sms.Address(to) != "+6281234567890" || whatsapp.Address(to) != "+6281234567890" {
+		t.Errorf("phone channels address %q and %q", sms.Address(to), whatsapp.Address(to))
+	}
+
+	if err := sms.Notify(context.Background(), sms.Address(to), msg); err != nil {
+		t.Fatal(err)
+	}
+	if err := whatsapp.Notify(context.Background(), whatsapp.Address(to), msg); err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 {
+		t.Fatalf("provider received %d requests, want 2", len(got))
+	}
+	if want := map[string]string{"channel": "sms", "from": "GORSEHAT", "to": "+6281234567890", "message": msg.Short}; !reflect.DeepEqual(got[0].body, want) {
+		t.Errorf("sms body %v, want %v", got[0].body, want)
+	}
+	if got[0].auth != "Bearer sms-key" {
+		t.Errorf("sms authorization %q", got[0].auth)
+	}
+	if got[1].body["message"] != msg.Text || got[1].body["channel"] != "whatsapp" {
+		t.Errorf("whatsapp does not carry the full text: %v", got[1].body)
+	}
+	if got[1].auth != "" {
+		t.Errorf("no token sent authorization %q", got[1].auth)
+	}
+
+	status = http.StatusPaymentRequired
+	err := sms.Notify(context.Background(), sms.Address(to), msg)
+	if err == nil || !strings.Contains(err.Error(), "402") || !strings.Contains(err.Error(), "quota exceeded") {
+		t.Errorf("provider error: got %v", err)
+	}
+}
@@ -0,0 +1,41 @@
+package notification
+
+import (
+	"badminton-reservation-api/models"
+	"context"
+	"encoding/json"
+)
+
+// eventKinds is the notification sent for each domain event
+var eventKinds = map[string]string{
+	models.EventReservationCreated:   models.NotifyReservationCreated,
+	models.EventReservationPaid:      models.NotifyReservationPaid,
+	models.EventReservationCancelled: models.NotifyReservationCancelled,
+	models.EventReservationExpired:   models.NotifyReservationExpired,
+}
+
+// EventTypes are the domain events HandleEvent sends notifications for
+func EventTypes() []string {
+	types := make([]string, 0, len(eventKinds))
+	for t := range eventKinds {
+		types = append(types, t)
+	}
+	return types
+}
+
+// HandleEvent is the outbox handler sending lifecycle notifications. Occurrences created as
+// part of a series get no confirmation of their own.
+func (n *Service) HandleEvent(ctx context.Context, event *models.OutboxEvent) error {
+	kind, ok := eventKinds[event.EventType]
+	if !ok {
+		return nil
+	}
+	var payload models.ReservationPayload
+	if err := json.Unmarshal([]byte(event.Payload), &payload); err != nil {
+		return err
+	}
+	if event.EventType == models.EventReservationCreated && payload.SeriesId != "" {
+		return nil
+	}
+	return n.Notify(ctx, kind, event.AggregateId, event.Id)
+}
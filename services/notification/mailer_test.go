@@ -0,0 +1,102 @@
+package notification
+
+import (
+	"badminton-reservation-api/models"
+	"badminton-reservation-api/services/notification/smtptest"
+	"context"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/mail"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSMTPMailer(t *testing.T) {
+	t.Setenv("CURRENCY", "IDR")
+	server, err := smtptest.NewServer("127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+
+	mailer := &SMTPMailer{
+		Host: server.Host, Port: server.Port, Username: "mailer", Password: "secret",
+		From: "GOR Sehat <no-reply@gorsehat.id>", TLS: TLSNone, Timeout: 5 * time.Second,
+	}
+	msg := mustRender(t, models.NotifyReservationPaid, "id", testBooking())
+	msg.Subject += "\r\nBcc: victim@example.com"
+	email := &EmailNotifier{Mailer: mailer}
+	to := Recipient{Name: "Budi", Email: "budi@example.com", Phone: "+6281234567890"}
+	if got := email.Address(to); got != "budi@example.com" {
+		t.Errorf("email channel addresses %q", got)
+	}
+	if err := email.Notify(context.Background(), email.Address(to), msg); err != nil {
+		t.Fatal(err)
+	}
+
+	received := server.Messages()
+	if len(received) != 1 {
+		t.Fatalf("stand-in received %d messages, want 1", len(received))
+	}
+	m := received[0]
+	if m.From != "no-reply@gorsehat.id" || len(m.To) != 1 || m.To[0] != "budi@example.com" {
+		t.Errorf("envelope is from %q to %v, want the bare addresses", m.From, m.To)
+	}
+	if !m.Auth {
+		t.Error("mailer did not authenticate with a username set")
+	}
+
+	parsed, err := mail.ReadMessage(strings.NewReader(m.Data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	subject, _ := new(mime.WordDecoder).DecodeHeader(parsed.Header.Get("Subject"))
+	if !strings.HasPrefix(subject, "Bukti pembayaran reservasi 3F2C9A1E") {
+		t.Errorf("subject is %q", subject)
+	}
+	if parsed.Header.Get("Bcc") != "" {
+		t.Error("a line break in the subject added a Bcc header")
+	}
+	if id := parsed.Header.Get("Message-ID"); !strings.HasSuffix(id, "@gorsehat.id>") {
+		t.Errorf("Message-ID is %q", id)
+	}
+
+	mediaType, params, _ := mime.ParseMediaType(parsed.Header.Get("Content-Type"))
+	if mediaType != "multipart/alternative" {
+		t.Fatalf("message is %s, want multipart/alternative", mediaType)
+	}
+	parts := map[string]string{}
+	mr := multipart.NewReader(parsed.Body, params["boundary"])
+	for {
+		part, err := mr.NextPart()
+		if err != nil {
+			break
+		}
+		body, _ := io.ReadAll(part)
+		ct, _, _ := mime.ParseMediaType(part.Header.Get("Content-Type"))
+		parts[ct] = string(body)
+	}
+	if plain := parts["text/plain"]; !strings.Contains(plain, "19:00–20:00, Court A") || !strings.Contains(plain, "Rp270.000") {
+		t.Errorf("text part did not survive quoted-printable:\n%s", plain)
+	}
+	if html := parts["text/html"]; !strings.Contains(html, "<html") || !strings.Contains(html, "Budi &lt;Santoso&gt;") {
+		t.Error("html part is missing")
+	}
+
+	server.RejectRecipients = true
+	if err := mailer.Send(context.Background(), "budi@example.com", msg); err == nil || !strings.Contains(err.Error(), "550") {
+		t.Errorf("rejected recipient: got %v, want a 550 error", err)
+	}
+
+	down := &SMTPMailer{Host: "127.0.0.1", Port: "1", From: "no-reply@gorsehat.id", TLS: TLSNone, Timeout: time.Second}
+	if down.Send(context.Background(), "budi@example.com", msg) == nil {
+		t.Error("unreachable server accepted the message")
+	}
+	bad := *mailer
+	bad.From = "not an address"
+	if bad.Send(context.Background(), "budi@example.com", msg) == nil {
+		t.Error("invalid sender accepted")
+	}
+}
@@ -0,0 +1,255 @@
+// Package notification tells customers about their bookings: confirmation, payment
+// reminder, receipt, cancellation, expiry and a reminder before the game. Lifecycle
+// notifications are driven by the domain events in the outbox, reminders by scheduled jobs.
+// A notification is rendered once and sent over every channel the customer prefers (email,
+// SMS, WhatsApp); preferences only apply to bookings made while signed in to the account
+// that set them. Every attempt is logged in notification_logs and a kind is sent at most
+// once per reservation and channel.
+package notification
+
+import (
+	"badminton-reservation-api/models"
+	"badminton-reservation-api/services/auth"
+	"badminton-reservation-api/utils"
+	"context"
+	"errors"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/beego/beego/v2/core/logs"
+)
+
+// Service renders notifications and sends them over Channels
+type Service struct {
+	// Channels are the configured channels by name
+	Channels map[string]Notifier
+	// DefaultChannels is used for customers without preferences, or whose preferred
+	// channels are not configured
+	DefaultChannels []string
+	Language        string
+	Brand           string
+	// ManageURL is the customer's manage link with {id} and {token} placeholders; empty
+	// leaves the link out
+	ManageURL string
+}
+
+// FromEnv returns a Service with the channels that are configured: email (see SMTPFromEnv),
+// sms and whatsapp (see HTTPFromEnv). Customers without preferences are notified over
+// NOTIFICATION_CHANNELS (comma-separated, default email), in NOTIFICATION_LANGUAGE (id or
+// en, default id), signed NOTIFICATION_BRAND, linking to NOTIFICATION_MANAGE_URL.
+// It returns nil when no channel is configured.
+func FromEnv() *Service {
+	channels := map[string]Notifier{}
+	if m := SMTPFromEnv(); m != nil {
+		channels[models.ChannelEmail] = &EmailNotifier{Mailer: m}
+	}
+	for _, name := range []string{models.ChannelSMS, models.ChannelWhatsApp} {
+		if h := HTTPFromEnv(name); h != nil {
+			channels[name] = h
+		}
+	}
+	if len(channels) == 0 {
+		return nil
+	}
+	brand := os.Getenv("NOTIFICATION_BRAND")
+	if brand == "" {
+		brand = "Badminton Reservation"
+	}
+	return &Service{
+		Channels:        channels,
+		DefaultChannels: DefaultChannels(),
+		Language:        NormalizeLanguage(os.Getenv("NOTIFICATION_LANGUAGE")),
+		Brand:           brand,
+		ManageURL:       os.Getenv("NOTIFICATION_MANAGE_URL"),
+	}
+}
+
+// DefaultChannels are the channels for customers without preferences
+// (NOTIFICATION_CHANNELS, default email)
+func DefaultChannels() []string {
+	if channels := models.SplitChannels(os.Getenv("NOTIFICATION_CHANNELS")); len(channels) > 0 {
+		return channels
+	}
+	return []string{models.ChannelEmail}
+}
+
+// ChannelNames lists the configured channels
+func (n *Service) ChannelNames() []string {
+	names := make([]string, 0, len(n.Channels))
+	for _, name := range []string{models.ChannelEmail, models.ChannelSMS, models.ChannelWhatsApp} {
+		if _, ok := n.Channels[name]; ok {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// Route returns the channels to notify a customer with pref (nil for none) over: the
+// preferred channels that are configured, else the configured default channels
+func (n *Service) Route(pref *models.NotificationPreference) []string {
+	if pref != nil {
+		if channels := n.configured(pref.ChannelList); len(channels) > 0 {
+			return channels
+		}
+	}
+	return n.configured(n.DefaultChannels)
+}
+
+func (n *Service) configured(channels []string) []string {
+	var list []string
+	seen := map[string]bool{}
+	for _, name := range channels {
+		if _, ok := n.Channels[name]; ok && !seen[name] {
+			seen[name] = true
+			list = append(list, name)
+		}
+	}
+	return list
+}
+
+// Notify sends the kind notification about a reservation over the customer's channels,
+// skipping channels it was already sent over. eventId is the outbox event that triggered
+// it, or 0.
+func (n *Service) Notify(ctx context.Context, kind, reservationId string, eventId int64) error {
+	r, err := models.GetReservationById(reservationId)
+	if err != nil {
+		return err
+	}
+	_, err = n.notify(ctx, kind, r, eventId)
+	return err
+}
+
+// notify sends kind about r and reports whether it was sent over any channel now
+func (n *Service) notify(ctx context.Context, kind string, r *models.Reservation, eventId int64) (bool, error) {
+	pref, err := models.GetReservationNotificationPreference(r)
+	if err != nil {
+		return false, err
+	}
+	to := Recipient{Name: r.CustomerName, Email: r.CustomerEmail}
+	to.Phone, _ = utils.NormalizePhone(r.CustomerPhone, utils.PhoneCallingCode())
+	lang := n.Language
+	if pref != nil {
+		if pref.Phone != "" {
+			to.Phone = pref.Phone
+		}
+		if pref.Language != "" {
+			lang = NormalizeLanguage(pref.Language)
+		}
+	}
+
+	var msg *Message
+	sentAny := false
+	var errs []error
+	for _, name := range n.Route(pref) {
+		channel := n.Channels[name]
+		address := channel.Address(to)
+		if address == "" {
+			continue
+		}
+		sent, err := models.HasSentNotification(r.Id, kind, name)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if sent {
+			continue
+		}
+		if msg == nil {
+			b, err := n.booking(r)
+			if err != nil {
+				return false, err
+			}
+			if msg, err = Render(kind, lang, n.Brand, b); err != nil {
+				return false, err
+			}
+		}
+
+		sendErr := channel.Notify(ctx, address, msg)
+		entry := &models.NotificationLog{
+			ReservationId: r.Id,
+			Kind:          kind,
+			Channel:       name,
+			Recipient:     address,
+			Language:      lang,
+			Subject:       msg.Subject,
+			Status:        models.NotificationSent,
+		}
+		if eventId != 0 {
+			entry.OutboxEventId = &eventId
+		}
+		if sendErr != nil {
+			entry.Status = models.NotificationFailed
+			entry.Error = sendErr.Error()
+			errs = append(errs, sendErr)
+		} else {
+			sentAny = true
+		}
+		if err := models.CreateNotificationLog(entry); err != nil {
+			logs.Error("Notification: could not log", kind, "over", name, "for reservation", r.Id, ":", err)
+		}
+	}
+	return sentAny, errors.Join(errs...)
+}
+
+// booking collects what the templates show about r
+func (n *Service) booking(r *models.Reservation) (*Booking, error) {
+	b := &Booking{
+		ReservationId:      r.Id,
+		CustomerName:       r.CustomerName,
+		Status:             r.Status,
+		Total:              r.TotalPrice,
+		Discount:           r.DiscountAmount,
+		PromoCode:          r.PromoCode,
+		Refund:             r.RefundAmount,
+		CancellationReason: r.CancellationReason,
+		PaymentDeadline:    r.ExpiredAt,
+	}
+
+	items := r.Items
+	if len(items) == 0 {
+		items = []*models.ReservationItem{{CourtId: r.CourtId, TimeslotId: r.TimeslotId, BookingDate: r.BookingDate}}
+	}
+	courts := map[int]string{}
+	for _, item := range items {
+		if _, ok := courts[item.CourtId]; !ok {
+			court, err := models.GetCourtById(item.CourtId)
+			if err != nil {
+				return nil, err
+			}
+			courts[item.CourtId] = court.Name
+		}
+		slot, err := models.GetTimeslotById(item.TimeslotId)
+		if err != nil {
+			return nil, err
+		}
+		b.Slots = append(b.Slots, Slot{Court: courts[item.CourtId], Date: item.BookingDate, Start: clock(slot.StartTime), End: clock(slot.EndTime)})
+		start, err := time.ParseInLocation("2006-01-02 15:04:05", item.BookingDate+" "+slot.StartTime, time.Local)
+		if err == nil && (b.StartsAt.IsZero() || start.Before(b.StartsAt)) {
+			b.StartsAt = start
+		}
+	}
+
+	if p, err := models.GetPaymentByReservationId(r.Id); err == nil && p.Status == models.PaymentPending {
+		b.PaymentURL = p.PaymentUrl
+	} else if r.SeriesId != "" {
+		if p, err := models.GetPaymentBySeriesId(r.SeriesId); err == nil && p.Status == models.PaymentPending {
+			b.PaymentURL = p.PaymentUrl
+		}
+	}
+	if n.ManageURL != "" {
+		if token, err := auth.ManageToken(r.Id); err == nil {
+			b.ManageURL = strings.NewReplacer("{id}", url.PathEscape(r.Id), "{token}", url.QueryEscape(token)).Replace(n.ManageURL)
+		}
+	}
+	return b, nil
+}
+
+// clock turns a timeslot time such as "19:00:00" into "19:00"
+func clock(t string) string {
+	if len(t) > 5 {
+		return t[:5]
+	}
+	return t
+}
@@ -0,0 +1,38 @@
+package notification
+
+import (
+	"badminton-reservation-api/models"
+	"reflect"
+	"testing"
+)
+
+func TestRoute(t *testing.T) {
+	service := &Service{
+		Channels:        map[string]Notifier{models.ChannelEmail: &EmailNotifier{}, models.ChannelWhatsApp: &HTTPNotifier{Name: models.ChannelWhatsApp}},
+		DefaultChannels: []string{models.ChannelEmail},
+	}
+	tests := []struct {
+		name      string
+		preferred []string
+		want      []string
+	}{
+		{"no preferences get the defaults", nil, []string{"email"}},
+		{"preferred channels in order", []string{"whatsapp", "email"}, []string{"whatsapp", "email"}},
+		{"unconfigured preferred channels are skipped", []string{"sms", "whatsapp"}, []string{"whatsapp"}},
+		{"only unconfigured preferences fall back to the defaults", []string{"sms"}, []string{"email"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var pref *models.NotificationPreference
+			if tt.preferred != nil {
+				pref = &models.NotificationPreference{ChannelList: tt.preferred}
+			}
+			if got := service.Route(pref); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("routed to %v, want %v", got, tt.want)
+			}
+		})
+	}
+	if got := service.ChannelNames(); !reflect.DeepEqual(got, []string{"email", "whatsapp"}) {
+		t.Errorf("configured channels listed as %v", got)
+	}
+}
@@ -0,0 +1,72 @@
+package notification
+
+import (
+	"badminton-reservation-api/models"
+	"context"
+	"errors"
+	"time"
+
+	"github.com/beego/beego/v2/core/logs"
+)
+
+// SendPaymentReminders reminds customers whose unpaid reservation expires within before and
+// returns how many were sent.
+// Occurrences of a prepaid series are skipped; they are paid through the series.
+func (n *Service) SendPaymentReminders(ctx context.Context, before time.Duration) (int, error) {
+	list, err := models.GetReservationsAwaitingPayment(time.Now().Add(before))
+	if err != nil {
+		return 0, err
+	}
+	prepaid := map[string]bool{}
+	sent := 0
+	var errs []error
+	for _, r := range list {
+		if r.SeriesId != "" {
+			if _, ok := prepaid[r.SeriesId]; !ok {
+				series, err := models.GetReservationSeriesById(r.SeriesId)
+				prepaid[r.SeriesId] = err == nil && series.PaymentMode == models.SeriesPaymentPrepaid
+			}
+			if prepaid[r.SeriesId] {
+				continue
+			}
+		}
+		ok, err := n.notify(ctx, models.NotifyPaymentReminder, r, 0)
+		if err != nil {
+			logs.Error("Notification: payment reminder for reservation", r.Id, "failed:", err)
+			errs = append(errs, err)
+		} else if ok {
+			sent++
+		}
+	}
+	return sent, errors.Join(errs...)
+}
+
+// SendGameReminders reminds customers whose paid game starts within before and returns how
+// many were sent
+func (n *Service) SendGameReminders(ctx context.Context, before time.Duration) (int, error) {
+	now := time.Now()
+	list, err := models.GetPaidReservationsBetween(now.Format("2006-01-02"), now.Add(before).Format("2006-01-02"))
+	if err != nil {
+		return 0, err
+	}
+	sent := 0
+	var errs []error
+	for _, r := range list {
+		b, err := n.booking(r)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if !b.StartsAt.After(now) || b.StartsAt.After(now.Add(before)) {
+			continue
+		}
+		ok, err := n.notify(ctx, models.NotifyGameReminder, r, 0)
+		if err != nil {
+			logs.Error("Notification: game reminder for reservation", r.Id, "failed:", err)
+			errs = append(errs, err)
+		} else if ok {
+			sent++
+		}
+	}
+	return sent, errors.Join(errs...)
+}
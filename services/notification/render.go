@@ -0,0 +1,259 @@
+package notification
+
+import (
+	"badminton-reservation-api/models"
+	"bytes"
+	"embed"
+	"fmt"
+	htmltemplate "html/template"
+	"strings"
+	"sync"
+	texttemplate "text/template"
+	"time"
+)
+
+//go:embed templates
+var templateFS embed.FS
+
+// Supported languages; DefaultLanguage is used for anything else
+const (
+	LanguageIndonesian = "id"
+	LanguageEnglish    = "en"
+	DefaultLanguage    = LanguageIndonesian
+)
+
+// Kinds lists the notifications that have templates
+func Kinds() []string {
+	return []string{
+		models.NotifyReservationCreated, models.NotifyPaymentReminder, models.NotifyReservationPaid,
+		models.NotifyReservationCancelled, models.NotifyReservationExpired, models.NotifyGameReminder,
+	}
+}
+
+// Slot is one court/timeslot of a booking as shown to the customer
+type Slot struct {
+	Court string
+	Date  string // YYYY-MM-DD
+	Start string // HH:MM
+	End   string // HH:MM
+}
+
+// Booking is everything the templates show about a reservation
+type Booking struct {
+	ReservationId      string
+	CustomerName       string
+	Status             string
+	Slots              []Slot
+	Total              models.Money
+	Discount           models.Money
+	PromoCode          string
+	Refund             models.Money
+	CancellationReason string
+	PaymentURL         string
+	ManageURL          string
+	PaymentDeadline    time.Time
+	StartsAt           time.Time
+}
+
+// Message is a rendered notification. Every channel sends the same rendering: email uses
+// Subject, Text and HTML, WhatsApp the Text and SMS the one-line Short.
+type Message struct {
+	Subject string
+	Text    string
+	HTML    string
+	Short   string
+}
+
+type view struct {
+	*Booking
+	Brand string
+}
+
+type compiled struct {
+	text *texttemplate.Template
+	html *htmltemplate.Template
+}
+
+var (
+	compiledMu sync.Mutex
+	compiledBy = map[string]*compiled{}
+)
+
+// Render renders the kind notification about b in lang. brand is the business name shown
+// to the customer.
+func Render(kind, lang, brand string, b *Booking) (*Message, error) {
+	lang = NormalizeLanguage(lang)
+	t, err := templates(kind, lang)
+	if err != nil {
+		return nil, err
+	}
+	v := view{Booking: b, Brand: brand}
+
+	var subject, text, html, short bytes.Buffer
+	if err := t.text.ExecuteTemplate(&subject, "subject", v); err != nil {
+		return nil, fmt.Errorf("render %s/%s subject: %w", lang, kind, err)
+	}
+	if err := t.text.ExecuteTemplate(&text, "text", v); err != nil {
+		return nil, fmt.Errorf("render %s/%s text: %w", lang, kind, err)
+	}
+	if err := t.text.ExecuteTemplate(&short, "short", v); err != nil {
+		return nil, fmt.Errorf("render %s/%s short: %w", lang, kind, err)
+	}
+	if err := t.html.ExecuteTemplate(&html, "layout", v); err != nil {
+		return nil, fmt.Errorf("render %s/%s html: %w", lang, kind, err)
+	}
+	return &Message{
+		Subject: strings.Join(strings.Fields(subject.String()), " "),
+		Text:    strings.TrimSpace(text.String()) + "\n",
+		HTML:    html.String(),
+		Short:   strings.Join(strings.Fields(short.String()), " "),
+	}, nil
+}
+
+// NormalizeLanguage maps lang to a supported language
+func NormalizeLanguage(lang string) string {
+	switch strings.ToLower(strings.TrimSpace(lang)) {
+	case LanguageEnglish:
+		return LanguageEnglish
+	case LanguageIndonesian:
+		return LanguageIndonesian
+	}
+	return DefaultLanguage
+}
+
+// templates parses the text and HTML templates of kind in lang once
+func templates(kind, lang string) (*compiled, error) {
+	compiledMu.Lock()
+	defer compiledMu.Unlock()
+	key := lang + "/" + kind
+	if t, ok := compiledBy[key]; ok {
+		return t, nil
+	}
+
+	funcs := formatFuncs(lang)
+	dir := "templates/" + lang + "/"
+	text, err := texttemplate.New(kind+".txt").Funcs(funcs).ParseFS(templateFS, dir+kind+".txt")
+	if err != nil {
+		return nil, fmt.Errorf("unknown notification %s/%s: %w", lang, kind, err)
+	}
+	html, err := htmltemplate.New("layout.html").Funcs(funcs).ParseFS(templateFS, dir+"layout.html", dir+kind+".html")
+	if err != nil {
+		return nil, fmt.Errorf("unknown notification %s/%s: %w", lang, kind, err)
+	}
+	t := &compiled{text: text, html: html}
+	compiledBy[key] = t
+	return t, nil
+}
+
+var (
+	monthNames = map[string][]string{
+		LanguageIndonesian: {"Januari", "Februari", "Maret", "April", "Mei", "Juni", "Juli", "Agustus", "September", "Oktober", "November", "Desember"},
+		LanguageEnglish:    {"January", "February", "March", "April", "May", "June", "July", "August", "September", "October", "November", "December"},
+	}
+	dayNames = map[string][]string{
+		LanguageIndonesian: {"Minggu", "Senin", "Selasa", "Rabu", "Kamis", "Jumat", "Sabtu"},
+		LanguageEnglish:    {"Sunday", "Monday", "Tuesday", "Wednesday", "Thursday", "Friday", "Saturday"},
+	}
+)
+
+// formatFuncs are the template helpers, formatting for lang:
+//
+//	money    150000.00 IDR -> "Rp150.000" (id) or "IDR 150,000" (en)
+//	date     "2026-10-20" or a time -> "Selasa, 20 Oktober 2026"
+//	datetime a time -> "Selasa, 20 Oktober 2026 19:00"
+//	ref      a reservation id -> its first 8 characters in upper case
+//	slots    the slots on one line, back-to-back slots merged ->
+//	         "Selasa, 20 Oktober 2026 19:00–21:00 Court A, 19:00–20:00 Court B"
+//	dict     "URL" .PaymentURL "Label" "Pay now" -> a map, to pass several values to a template
+func formatFuncs(lang string) map[string]interface{} {
+	formatDate := func(t time.Time) string {
+		return fmt.Sprintf("%s, %d %s %d", dayNames[lang][t.Weekday()], t.Day(), monthNames[lang][t.Month()-1], t.Year())
+	}
+	date := func(v interface{}) string {
+		switch d := v.(type) {
+		case time.Time:
+			return formatDate(d)
+		case string:
+			if t, err := time.Parse("2006-01-02", d); err == nil {
+				return formatDate(t)
+			}
+			return d
+		}
+		return fmt.Sprint(v)
+	}
+	return map[string]interface{}{
+		"money":    func(m models.Money) string { return FormatMoney(m, lang) },
+		"date":     date,
+		"datetime": func(t time.Time) string { return formatDate(t) + " " + t.Format("15:04") },
+		"ref": func(id string) string {
+			if len(id) > 8 {
+				id = id[:8]
+			}
+			return strings.ToUpper(id)
+		},
+		"slots": func(slots []Slot) string {
+			var merged []Slot
+			for _, slot := range slots {
+				if n := len(merged); n > 0 && merged[n-1].Court == slot.Court && merged[n-1].Date == slot.Date && merged[n-1].End == slot.Start {
+					merged[n-1].End = slot.End
+					continue
+				}
+				merged = append(merged, slot)
+			}
+			parts := make([]string, len(merged))
+			for i, slot := range merged {
+				parts[i] = slot.Start + "–" + slot.End + " " + slot.Court
+				if i == 0 || slot.Date != merged[i-1].Date {
+					parts[i] = date(slot.Date) + " " + parts[i]
+				}
+			}
+			return strings.Join(parts, ", ")
+		},
+		"dict": func(pairs ...interface{}) map[string]interface{} {
+			m := map[string]interface{}{}
+			for i := 0; i+1 < len(pairs); i += 2 {
+				m[fmt.Sprint(pairs[i])] = pairs[i+1]
+			}
+			return m
+		},
+	}
+}
+
+// FormatMoney formats m for a customer: thousands grouped with "." and decimal "," in
+// Indonesian, "," and "." in English; zero decimals are dropped. Rupiah shows as "Rp" in
+// Indonesian, every other amount with its currency code.
+func FormatMoney(m models.Money, lang string) string {
+	s := m.String()
+	negative := strings.HasPrefix(s, "-")
+	s = strings.TrimPrefix(s, "-")
+	whole, frac, _ := strings.Cut(s, ".")
+	if strings.Trim(frac, "0") == "" {
+		frac = ""
+	}
+
+	group, point := ",", "."
+	if lang == LanguageIndonesian {
+		group, point = ".", ","
+	}
+	var b strings.Builder
+	for i, r := range whole {
+		if i > 0 && (len(whole)-i)%3 == 0 {
+			b.WriteString(group)
+		}
+		b.WriteRune(r)
+	}
+	number := b.String()
+	if frac != "" {
+		number += point + frac
+	}
+
+	code := m.CurrencyCode()
+	prefix := code + " "
+	if code == "IDR" && lang == LanguageIndonesian {
+		prefix = "Rp"
+	}
+	if negative {
+		prefix = "-" + prefix
+	}
+	return prefix + number
+}
@@ -0,0 +1,165 @@
+package notification
+
+import (
+	"badminton-reservation-api/models"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testBooking() *Booking {
+	return &Booking{
+		ReservationId: "3f2c9a1e-7b4d-4c1a-9e2f-5d6c7b8a9f01",
+		CustomerName:  "Budi <Santoso>",
+		Status:        models.ReservationPending,
+		Slots: []Slot{
+			{Court: "Court A", Date: "2026-10-20", Start: "19:00", End: "20:00"},
+			{Court: "Court A", Date: "2026-10-20", Start: "20:00", End: "21:00"},
+		},
+		Total:           models.Whole(270000),
+		Discount:        models.Whole(30000),
+		PromoCode:       "HEMAT10",
+		PaymentURL:      "https://pay.example.com/checkout/abc",
+		ManageURL:       "https://example.com/manage/3f2c?token=t",
+		PaymentDeadline: time.Date(2026, 10, 20, 18, 30, 0, 0, time.Local),
+		StartsAt:        time.Date(2026, 10, 20, 19, 0, 0, 0, time.Local),
+	}
+}
+
+func mustRender(t *testing.T, kind, lang string, b *Booking) *Message {
+	t.Helper()
+	msg, err := Render(kind, lang, "GOR Sehat", b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return msg
+}
+
+func TestFormatMoney(t *testing.T) {
+	t.Setenv("CURRENCY", "IDR")
+	tests := []struct {
+		m          models.Money
+		lang, want string
+	}{
+		{models.Whole(150000), "id", "Rp150.000"},
+		{models.Whole(150000), "en", "IDR 150,000"},
+		{models.Whole(1250000), "id", "Rp1.250.000"},
+		{models.NewMoney(1250, "USD"), "en", "USD 12.50"},
+		{models.NewMoney(1250, "USD"), "id", "USD 12,50"},
+		{models.Whole(500), "id", "Rp500"},
+		{models.Whole(-75000), "id", "-Rp75.000"},
+	}
+	for _, tt := range tests {
+		if got := FormatMoney(tt.m, tt.lang); got != tt.want {
+			t.Errorf("%s in %s is %s, want %s", tt.m, tt.lang, got, tt.want)
+		}
+	}
+}
+
+func TestNormalizeLanguage(t *testing.T) {
+	if got := NormalizeLanguage("fr"); got != "id" {
+		t.Errorf("unknown language is %s, want the Indonesian fallback", got)
+	}
+	if got := NormalizeLanguage(" EN "); got != "en" {
+		t.Errorf("\" EN \" is %s, want en", got)
+	}
+}
+
+func TestRenderEveryKind(t *testing.T) {
+	t.Setenv("CURRENCY", "IDR")
+	for _, lang := range []string{"id", "en"} {
+		for _, kind := range Kinds() {
+			t.Run(lang+"/"+kind, func(t *testing.T) {
+				msg := mustRender(t, kind, lang, testBooking())
+				if msg.Subject == "" || strings.ContainsAny(msg.Subject, "\r\n") {
+					t.Errorf("subject %q is not one line", msg.Subject)
+				}
+				if !strings.Contains(msg.Text, "Budi <Santoso>") || !strings.Contains(msg.Text, "19:00–20:00, Court A") {
+					t.Errorf("text does not greet the customer and list the slots:\n%s", msg.Text)
+				}
+				if !strings.Contains(msg.HTML, "Budi &lt;Santoso&gt;") || strings.Contains(msg.HTML, "<Santoso>") {
+					t.Error("html does not escape customer data")
+				}
+				if !strings.Contains(msg.HTML, "GOR Sehat") || !strings.Contains(msg.HTML, "3F2C9A1E") {
+					t.Error("html lacks the brand or booking code")
+				}
+				if strings.Contains(msg.Text, "{{") || strings.Contains(msg.Text, "<no value>") {
+					t.Errorf("text has template residue:\n%s", msg.Text)
+				}
+				if msg.Short == "" || len(msg.Short) > 306 || strings.Contains(msg.Short, "<no value>") {
+					t.Errorf("short text does not fit two SMS (%d bytes): %s", len(msg.Short), msg.Short)
+				}
+				if !strings.Contains(msg.Short, "19:00–21:00 Court A") || !strings.Contains(msg.Short, "3F2C9A1E") {
+					t.Errorf("short text does not merge back-to-back slots: %s", msg.Short)
+				}
+			})
+		}
+	}
+}
+
+func TestRenderContent(t *testing.T) {
+	t.Setenv("CURRENCY", "IDR")
+	paid := testBooking()
+	paid.Status = models.ReservationPaid
+	cancelled := testBooking()
+	cancelled.Status, cancelled.CancellationReason = models.ReservationCancelled, "Hujan deras"
+	refunded := testBooking()
+	refunded.Status, refunded.CancellationReason, refunded.Refund = models.ReservationCancelled, "Hujan deras", models.Whole(135000)
+	split := testBooking()
+	split.Slots = append(split.Slots, Slot{Court: "Court B", Date: "2026-10-21", Start: "07:00", End: "08:00"})
+
+	tests := []struct {
+		name       string
+		kind, lang string
+		booking    *Booking
+		field      func(*Message) string
+		want       []string
+		wantNot    []string
+	}{
+		{"confirmation shows the Indonesian date", models.NotifyReservationCreated, "id", testBooking(), text,
+			[]string{"Selasa, 20 Oktober 2026"}, nil},
+		{"confirmation shows the payment deadline and link", models.NotifyReservationCreated, "id", testBooking(), text,
+			[]string{"Selasa, 20 Oktober 2026 18:30", "https://pay.example.com/checkout/abc"}, nil},
+		{"confirmation shows the promo discount", models.NotifyReservationCreated, "id", testBooking(), text,
+			[]string{"Diskon (HEMAT10): Rp30.000", "Total: Rp270.000"}, nil},
+		{"confirmation of a paid booking asks for no payment", models.NotifyReservationCreated, "en", paid, text,
+			nil, []string{"complete the payment"}},
+		{"receipt shows the English date and amount", models.NotifyReservationPaid, "en", paid, text,
+			[]string{"Tuesday, 20 October 2026", "Total paid: IDR 270,000"}, nil},
+		{"unpaid cancellation mentions no refund", models.NotifyReservationCancelled, "id", cancelled, text,
+			[]string{"Alasan: Hujan deras"}, []string{"Refund"}},
+		{"paid cancellation mentions the refund", models.NotifyReservationCancelled, "id", refunded, text,
+			[]string{"Refund sebesar Rp135.000"}, nil},
+		{"short text lists separate slots", models.NotifyGameReminder, "en", split, short,
+			[]string{"Tuesday, 20 October 2026 19:00–21:00 Court A, Wednesday, 21 October 2026 07:00–08:00 Court B"}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.field(mustRender(t, tt.kind, tt.lang, tt.booking))
+			for _, want := range tt.want {
+				if !strings.Contains(got, want) {
+					t.Errorf("missing %q in:\n%s", want, got)
+				}
+			}
+			for _, unwanted := range tt.wantNot {
+				if strings.Contains(got, unwanted) {
+					t.Errorf("unexpected %q in:\n%s", unwanted, got)
+				}
+			}
+		})
+	}
+
+	if msg := mustRender(t, models.NotifyGameReminder, "en", paid); msg.Subject != "Reminder: you play Tuesday, 20 October 2026 19:00" {
+		t.Errorf("game reminder subject is %q", msg.Subject)
+	}
+	want := "GOR Sehat: reservasi 3F2C9A1E diterima, Selasa, 20 Oktober 2026 19:00–21:00 Court A. Total Rp270.000. Bayar sebelum Selasa, 20 Oktober 2026 18:30: https://pay.example.com/checkout/abc"
+	if msg := mustRender(t, models.NotifyReservationCreated, "id", testBooking()); msg.Short != want {
+		t.Errorf("short confirmation is %q, want %q", msg.Short, want)
+	}
+	if _, err := Render("birthday", "en", "GOR Sehat", paid); err == nil {
+		t.Error("unknown kind rendered")
+	}
+}
+
+func text(m *Message) string  { return m.Text }
+func short(m *Message) string { return m.Short }
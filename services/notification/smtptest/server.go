@@ -0,0 +1,152 @@
+// Package smtptest is a local SMTP stand-in that accepts every message and keeps it in
+// memory, for checking email delivery without a real mail server.
+package smtptest
+
+import (
+	"bufio"
+	"net"
+	"strings"
+	"sync"
+)
+
+// Received is a message accepted by the server
+type Received struct {
+	From string
+	To   []string
+	Auth bool
+	Data string
+}
+
+// Server is a plain-text SMTP server on a local port
+type Server struct {
+	Host string
+	Port string
+	// RejectRecipients makes the server refuse every recipient with 550
+	RejectRecipients bool
+	// OnMessage, when set, is called for every accepted message
+	OnMessage func(Received)
+
+	listener net.Listener
+	mu       sync.Mutex
+	messages []Received
+	wg       sync.WaitGroup
+}
+
+// NewServer starts a server on addr, e.g. "127.0.0.1:0" for a free port
+func NewServer(addr string) (*Server, error) {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	host, port, _ := net.SplitHostPort(l.Addr().String())
+	s := &Server{Host: host, Port: port, listener: l}
+	s.wg.Add(1)
+	go s.serve()
+	return s, nil
+}
+
+// Messages returns the messages accepted so far
+func (s *Server) Messages() []Received {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Received(nil), s.messages...)
+}
+
+// Close stops the server
+func (s *Server) Close() error {
+	err := s.listener.Close()
+	s.wg.Wait()
+	return err
+}
+
+func (s *Server) serve() {
+	defer s.wg.Done()
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			s.handle(conn)
+		}()
+	}
+}
+
+// handle speaks just enough SMTP for net/smtp: EHLO, AUTH PLAIN, MAIL, RCPT, DATA, RSET, QUIT
+func (s *Server) handle(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	reply := func(line string) { conn.Write([]byte(line + "\r\n")) }
+
+	reply("220 smtptest ready")
+	var msg Received
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		line = strings.TrimRight(line, "\r\n")
+		verb := strings.ToUpper(strings.SplitN(line, " ", 2)[0])
+		switch verb {
+		case "EHLO", "HELO":
+			reply("250-smtptest")
+			reply("250 AUTH PLAIN")
+		case "AUTH":
+			msg.Auth = true
+			reply("235 authenticated")
+		case "MAIL":
+			msg.From = address(line)
+			reply("250 ok")
+		case "RCPT":
+			if s.RejectRecipients {
+				reply("550 mailbox unavailable")
+				continue
+			}
+			msg.To = append(msg.To, address(line))
+			reply("250 ok")
+		case "DATA":
+			reply("354 end with <CRLF>.<CRLF>")
+			var data strings.Builder
+			for {
+				l, err := r.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if l == ".\r\n" {
+					break
+				}
+				data.WriteString(strings.TrimPrefix(l, "."))
+			}
+			msg.Data = data.String()
+			s.mu.Lock()
+			s.messages = append(s.messages, msg)
+			s.mu.Unlock()
+			if s.OnMessage != nil {
+				s.OnMessage(msg)
+			}
+			msg = Received{Auth: msg.Auth}
+			reply("250 queued")
+		case "RSET":
+			msg = Received{Auth: msg.Auth}
+			reply("250 ok")
+		case "NOOP":
+			reply("250 ok")
+		case "QUIT":
+			reply("221 bye")
+			return
+		default:
+			reply("502 command not implemented")
+		}
+	}
+}
+
+// address extracts the address from "MAIL FROM:<a@b>" or "RCPT TO:<a@b>"
+func address(line string) string {
+	start, end := strings.Index(line, "<"), strings.LastIndex(line, ">")
+	if start < 0 || end < start {
+		return ""
+	}
+	return line[start+1 : end]
+}
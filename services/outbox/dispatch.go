@@ -0,0 +1,67 @@
+package outbox
+
+import (
+	"badminton-reservation-api/models"
+	"badminton-reservation-api/utils"
+	"context"
+	"time"
+
+	"github.com/beego/beego/v2/core/logs"
+)
+
+// maxBatches bounds one dispatch run so a large backlog does not hold the job forever
+const maxBatches = 20
+
+// DispatchResult summarizes one dispatch run
+type DispatchResult struct {
+	Claimed   int `json:"claimed"`
+	Delivered int `json:"delivered"`
+	Retrying  int `json:"retrying"`
+	Dead      int `json:"dead"`
+}
+
+// Dispatch delivers due pending events in batches of OUTBOX_BATCH_SIZE (default 100) until
+// none are left or ctx is done. Each claimed event is leased for OUTBOX_LEASE (default 5m):
+// should this process die mid-delivery, the event is delivered again after the lease.
+func Dispatch(ctx context.Context) (*DispatchResult, error) {
+	batch := utils.EnvInt("OUTBOX_BATCH_SIZE", 100)
+	lease := utils.EnvDuration("OUTBOX_LEASE", 5*time.Minute)
+	maxAttempts := MaxAttempts()
+
+	result := &DispatchResult{}
+	for i := 0; i < maxBatches && ctx.Err() == nil; i++ {
+		events, err := models.ClaimOutboxEvents(batch, lease)
+		if err != nil {
+			return result, err
+		}
+		result.Claimed += len(events)
+		for _, event := range events {
+			if err := dispatchEvent(ctx, event, maxAttempts, result); err != nil {
+				return result, err
+			}
+		}
+		if len(events) < batch {
+			break
+		}
+	}
+	return result, nil
+}
+
+// dispatchEvent delivers one claimed event and records the outcome
+func dispatchEvent(ctx context.Context, event *models.OutboxEvent, maxAttempts int, result *DispatchResult) error {
+	handled, deliveryErr := Deliver(ctx, event)
+	if deliveryErr == nil {
+		result.Delivered++
+		return models.MarkOutboxEventDelivered(event.Id, handled)
+	}
+
+	dead := event.Attempts >= maxAttempts
+	if dead {
+		result.Dead++
+		logs.Error("Outbox event", event.Id, event.EventType, "is dead after", event.Attempts, "attempts:", deliveryErr)
+	} else {
+		result.Retrying++
+		logs.Warn("Outbox event", event.Id, event.EventType, "attempt", event.Attempts, "failed:", deliveryErr)
+	}
+	return models.MarkOutboxEventFailed(event.Id, handled, deliveryErr.Error(), time.Now().Add(Backoff(event.Attempts)), dead)
+}
@@ -0,0 +1,130 @@
+// Package outbox delivers the domain events that models write to the outbox_events table
+// to the handlers subscribed in this process. Delivery is at least once: an event is
+// retried with backoff until every subscribed handler has processed it, so handlers must
+// tolerate seeing an event more than once (the event id identifies it). A handler that
+// succeeded is not called again when the event is retried for another handler.
+package outbox
+
+import (
+	"badminton-reservation-api/models"
+	"badminton-reservation-api/utils"
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Handler processes one event. Returning an error retries the event for this handler later.
+type Handler func(ctx context.Context, event *models.OutboxEvent) error
+
+type subscription struct {
+	handle     Handler
+	eventTypes map[string]bool
+}
+
+var (
+	subscriptionsMu sync.RWMutex
+	subscriptions   = map[string]*subscription{}
+)
+
+// Subscribe registers handle under name for the given event types, or for every event when
+// none are given. The name is recorded on the events the handler processed, so it must stay
+// the same across releases and cannot contain commas. Subscribing again under a name
+// replaces the handler.
+func Subscribe(name string, handle Handler, eventTypes ...string) error {
+	if name == "" || strings.Contains(name, ",") {
+		return fmt.Errorf("invalid outbox handler name %q", name)
+	}
+	sub := &subscription{handle: handle}
+	if len(eventTypes) > 0 {
+		sub.eventTypes = map[string]bool{}
+		for _, t := range eventTypes {
+			sub.eventTypes[t] = true
+		}
+	}
+	subscriptionsMu.Lock()
+	defer subscriptionsMu.Unlock()
+	subscriptions[name] = sub
+	return nil
+}
+
+// Unsubscribe removes the handler registered under name
+func Unsubscribe(name string) {
+	subscriptionsMu.Lock()
+	defer subscriptionsMu.Unlock()
+	delete(subscriptions, name)
+}
+
+// Handlers returns the subscribed handler names in order
+func Handlers() []string {
+	subscriptionsMu.RLock()
+	defer subscriptionsMu.RUnlock()
+	names := make([]string, 0, len(subscriptions))
+	for name := range subscriptions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Deliver runs every handler subscribed to the event that has not processed it yet, in name
+// order. A failing handler does not stop the others. It returns the handlers that have now
+// processed the event, including earlier ones, and the failures joined into one error.
+func Deliver(ctx context.Context, event *models.OutboxEvent) ([]string, error) {
+	handled := event.Handled()
+	done := map[string]bool{}
+	for _, name := range handled {
+		done[name] = true
+	}
+
+	subscriptionsMu.RLock()
+	pending := map[string]Handler{}
+	for name, sub := range subscriptions {
+		if done[name] || (sub.eventTypes != nil && !sub.eventTypes[event.EventType]) {
+			continue
+		}
+		pending[name] = sub.handle
+	}
+	subscriptionsMu.RUnlock()
+
+	names := make([]string, 0, len(pending))
+	for name := range pending {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var errs []error
+	for _, name := range names {
+		if err := safeHandle(ctx, pending[name], event); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", name, err))
+			continue
+		}
+		handled = append(handled, name)
+	}
+	return handled, errors.Join(errs...)
+}
+
+// safeHandle runs a handler, turning a panic into an error so it is retried like a failure
+func safeHandle(ctx context.Context, handle Handler, event *models.OutboxEvent) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return handle(ctx, event)
+}
+
+// Backoff is the wait after the given failed attempt (1 is the first): 10s doubling per
+// attempt, at most an hour
+func Backoff(attempt int) time.Duration {
+	return utils.Backoff(attempt, 10*time.Second, time.Hour)
+}
+
+// MaxAttempts is how many deliveries an event gets before it is dead (OUTBOX_MAX_ATTEMPTS,
+// default 10, retried over about an hour and a half)
+func MaxAttempts() int {
+	return utils.EnvInt("OUTBOX_MAX_ATTEMPTS", 10)
+}
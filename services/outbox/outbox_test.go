@@ -0,0 +1,98 @@
+package outbox
+
+import (
+	"badminton-reservation-api/models"
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSubscribeRefusesBadNames(t *testing.T) {
+	noop := func(context.Context, *models.OutboxEvent) error { return nil }
+	for _, name := range []string{"", "a,b"} {
+		if Subscribe(name, noop) == nil {
+			t.Errorf("handler name %q was accepted", name)
+		}
+	}
+}
+
+func TestDeliver(t *testing.T) {
+	calls := map[string]int{}
+	failing := true
+	record := func(name string, err func() error) Handler {
+		return func(ctx context.Context, e *models.OutboxEvent) error {
+			calls[name]++
+			return err()
+		}
+	}
+	ok := func() error { return nil }
+	_ = Subscribe("audit", record("audit", ok))
+	_ = Subscribe("mailer", record("mailer", func() error {
+		if failing {
+			return errors.New("smtp down")
+		}
+		return nil
+	}), models.EventReservationPaid, models.EventReservationCancelled)
+	_ = Subscribe("crm", record("crm", func() error { panic("nil map") }), models.EventPaymentSucceeded)
+	t.Cleanup(func() {
+		for _, name := range []string{"audit", "mailer", "crm"} {
+			Unsubscribe(name)
+		}
+	})
+	if got := Handlers(); !reflect.DeepEqual(got, []string{"audit", "crm", "mailer"}) {
+		t.Errorf("handlers listed as %v", got)
+	}
+
+	created := &models.OutboxEvent{Id: 1, EventType: models.EventReservationCreated}
+	handled, err := Deliver(context.Background(), created)
+	if err != nil || !reflect.DeepEqual(handled, []string{"audit"}) || calls["mailer"] != 0 {
+		t.Errorf("unsubscribed event type reached %v, err %v", handled, err)
+	}
+
+	paid := &models.OutboxEvent{Id: 2, EventType: models.EventReservationPaid}
+	handled, err = Deliver(context.Background(), paid)
+	if err == nil || !strings.Contains(err.Error(), "mailer: smtp down") {
+		t.Errorf("failing handler not reported by name: %v", err)
+	}
+	if !reflect.DeepEqual(handled, []string{"audit"}) {
+		t.Errorf("other handlers did not run: %v", handled)
+	}
+
+	// A retry skips the handlers that already succeeded
+	paid.CompletedHandlers = strings.Join(handled, ",")
+	failing = false
+	handled, err = Deliver(context.Background(), paid)
+	if err != nil || calls["audit"] != 2 || calls["mailer"] != 2 {
+		t.Errorf("retry calls %v, err %v", calls, err)
+	}
+	if !reflect.DeepEqual(handled, []string{"audit", "mailer"}) {
+		t.Errorf("retry reports %v done", handled)
+	}
+
+	succeeded := &models.OutboxEvent{Id: 3, EventType: models.EventPaymentSucceeded}
+	handled, err = Deliver(context.Background(), succeeded)
+	if err == nil || !strings.Contains(err.Error(), "crm: panic: nil map") {
+		t.Errorf("panicking handler did not fail the delivery: %v", err)
+	}
+	if !reflect.DeepEqual(handled, []string{"audit"}) {
+		t.Errorf("panic stopped other handlers: %v", handled)
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	for attempt, want := range map[int]time.Duration{1: 10 * time.Second, 2: 20 * time.Second, 4: 80 * time.Second, 9: 2560 * time.Second, 10: time.Hour, 50: time.Hour} {
+		if got := Backoff(attempt); got != want {
+			t.Errorf("backoff after attempt %d is %s, want %s", attempt, got, want)
+		}
+	}
+	var total time.Duration
+	for attempt := 1; attempt < MaxAttempts(); attempt++ {
+		total += Backoff(attempt)
+	}
+	if total <= time.Hour || total >= 2*time.Hour {
+		t.Errorf("default retries span %s, want about an hour and a half", total)
+	}
+}
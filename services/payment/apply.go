@@ -0,0 +1,215 @@
+package payment
+
+import (
+	"badminton-reservation-api/models"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/beego/beego/v2/core/logs"
+)
+
+// ErrPaymentNotFound is returned when an event's order does not belong to a payment of its gateway
+var ErrPaymentNotFound = errors.New("payment not found for order")
+
+// ErrPaymentMismatch is returned when a verified notification does not match the stored
+// payment (amount or currency). Such events are not applied.
+var ErrPaymentMismatch = errors.New("notification does not match the payment")
+
+// reservationStatusFor maps a payment status to the status its reservations should move to
+func reservationStatusFor(paymentStatus string) string {
+	switch paymentStatus {
+	case models.PaymentSuccess:
+		return models.ReservationPaid
+	case models.PaymentFailed:
+		return models.ReservationCancelled
+	case models.PaymentExpired:
+		return models.ReservationExpired
+	default:
+		return models.ReservationWaitingPayment
+	}
+}
+
+// ProcessEvent logs a verified gateway update in payment_events and applies it. A
+// redelivered notification (same order, gateway status and status code) whose first copy was
+// applied, found stale or flagged suspicious is not applied again: the stored event is
+// returned with duplicate set. One whose first copy failed (e.g. it arrived before the
+// payment row was written) or never finished is applied again on the stored event, so the
+// gateway's retries are not lost.
+func ProcessEvent(gatewayName string, update *StatusUpdate, source string) (*ProcessedEvent, error) {
+	payload, _ := json.Marshal(update.Raw)
+	event := &models.PaymentEvent{
+		DedupeKey:         models.PaymentEventDedupeKey(update.OrderId, update.GatewayStatus, update.StatusCode),
+		Gateway:           gatewayName,
+		OrderId:           update.OrderId,
+		TransactionStatus: update.GatewayStatus,
+		StatusCode:        update.StatusCode,
+		PaymentStatus:     update.Status,
+		Amount:            update.Amount,
+		Currency:          update.Currency,
+		TransactionId:     update.TransactionId,
+		Source:            source,
+		Payload:           string(payload),
+	}
+	stored, err := models.RecordPaymentEvent(event)
+	if err != nil {
+		return nil, err
+	}
+	if !stored && event.Finished() {
+		return &ProcessedEvent{Event: event, Duplicate: true}, nil
+	}
+
+	outcome, paymentId, review, err := applyEvent(gatewayName, update)
+	errText := review
+	if err != nil {
+		errText = err.Error()
+	}
+	if ferr := models.FinishPaymentEvent(event.Id, paymentId, outcome, errText, review != ""); ferr != nil {
+		logs.Error("Error storing payment event outcome:", ferr)
+	}
+	if outcome == models.EventSuspicious || review != "" {
+		logs.Warn("Payment event", event.Id, "flagged for review:", errText)
+	}
+	event.Outcome = outcome
+	event.PaymentId = paymentId
+	event.Error = errText
+	event.NeedsReview = event.NeedsReview || outcome == models.EventSuspicious || review != ""
+	return &ProcessedEvent{Event: event}, err
+}
+
+// ReplayEvent applies a stored event again, e.g. after fixing the cause of an error outcome.
+// Replays still go through the forward-only status rule, so replaying an old event is harmless.
+func ReplayEvent(event *models.PaymentEvent) (*models.PaymentEvent, error) {
+	var raw map[string]interface{}
+	_ = json.Unmarshal([]byte(event.Payload), &raw)
+	update := &StatusUpdate{
+		OrderId:       event.OrderId,
+		TransactionId: event.TransactionId,
+		Status:        event.PaymentStatus,
+		GatewayStatus: event.TransactionStatus,
+		StatusCode:    event.StatusCode,
+		Amount:        event.Amount,
+		Currency:      event.Currency,
+		Raw:           raw,
+	}
+
+	outcome, paymentId, review, err := applyEvent(event.Gateway, update)
+	errText := review
+	if err != nil {
+		errText = err.Error()
+	}
+	if merr := models.MarkPaymentEventReplayed(event.Id, paymentId, outcome, errText, review != ""); merr != nil {
+		return nil, merr
+	}
+	event.Outcome = outcome
+	event.PaymentId = paymentId
+	event.Error = errText
+	event.NeedsReview = event.NeedsReview || outcome == models.EventSuspicious || review != ""
+	event.ReplayCount++
+	return event, err
+}
+
+// ProcessedEvent is the result of ProcessEvent
+type ProcessedEvent struct {
+	Event     *models.PaymentEvent
+	Duplicate bool
+}
+
+// applyEvent finds the payment of the update's order, checks the update against it and
+// applies the update. review explains why an applied update needs an admin's attention.
+func applyEvent(gatewayName string, update *StatusUpdate) (outcome string, paymentId string, review string, err error) {
+	paymentRecord, err := models.GetPaymentByOrderId(update.OrderId)
+	if err != nil || paymentRecord.PaymentGateway != gatewayName {
+		return models.EventError, "", "", fmt.Errorf("%w %s", ErrPaymentNotFound, update.OrderId)
+	}
+	if err := MatchPayment(paymentRecord, update); err != nil {
+		return models.EventSuspicious, paymentRecord.Id, "", err
+	}
+	applied, review, err := ApplyStatusUpdate(paymentRecord, update)
+	if err != nil {
+		return models.EventError, paymentRecord.Id, "", err
+	}
+	if !applied {
+		return models.EventStale, paymentRecord.Id, "", nil
+	}
+	return models.EventApplied, paymentRecord.Id, review, nil
+}
+
+// MatchPayment checks that a verified update describes the stored payment: the reported
+// amount and currency must equal what was billed. A valid signature only proves the gateway
+// sent the update, not that the customer paid what we asked for. The payment itself was found
+// by the update's order id, so the order needs no further check. Updates without an amount
+// (the reconciler's synthetic expiry) are not checked.
+func MatchPayment(paymentRecord *models.Payment, update *StatusUpdate) error {
+	currency := paymentRecord.Amount.CurrencyCode()
+	if update.Currency != "" && !strings.EqualFold(update.Currency, currency) {
+		return fmt.Errorf("%w: currency %s, expected %s", ErrPaymentMismatch, update.Currency, currency)
+	}
+	if update.Amount != "" {
+		amount, err := models.ParseMoney(update.Amount, currency)
+		if err != nil || amount.Amount != paymentRecord.Amount.Amount {
+			return fmt.Errorf("%w: amount %s, expected %s", ErrPaymentMismatch, update.Amount, paymentRecord.Amount.String())
+		}
+	}
+	return nil
+}
+
+// ApplyStatusUpdate records a gateway status update on the payment and moves the
+// reservations it covers accordingly, in one transaction. The payment status only moves
+// forward (pending → failed/expired → success → refunded), so a late or out-of-order
+// notification is ignored and reported as not applied. Other reservation changes that the
+// state machine refuses are logged and ignored, but a settlement whose reservations can no
+// longer be marked paid, e.g. one that arrives after the reservation expired, charged the
+// customer for a booking they do not hold: it is returned as review, and a payment for a
+// single reservation is refunded in full.
+func ApplyStatusUpdate(paymentRecord *models.Payment, update *StatusUpdate) (applied bool, review string, err error) {
+	// Refunds are recorded when issued through the refunds API; their notifications need no action
+	if update.Status == models.PaymentRefunded || update.Status == models.PaymentPartiallyRefunded {
+		logs.Info("Acknowledging refund notification for order:", update.OrderId)
+		return false, "", nil
+	}
+
+	notificationJSON, _ := json.Marshal(update.Raw)
+	advanced, skipped, err := models.AdvancePaymentStatus(paymentRecord.Id, update.Status, update.TransactionId, string(notificationJSON), reservationStatusFor(update.Status))
+	if err != nil {
+		return false, "", err
+	}
+	if !advanced {
+		logs.Info("Ignoring stale payment update for order", update.OrderId, ":", update.Status)
+		return false, "", nil
+	}
+	paymentRecord.Status = update.Status
+	if update.Status == models.PaymentSuccess && len(skipped) > 0 {
+		return true, refundUnbookedSettlement(paymentRecord, skipped), nil
+	}
+	for _, transitionErr := range skipped {
+		// e.g. an occurrence cancelled before payment; keep the current status
+		logs.Warn("Ignoring reservation status change from payment update:", transitionErr)
+	}
+	return true, "", nil
+}
+
+// refundUnbookedSettlement handles a settled payment whose reservations stayed in the
+// statuses listed in skipped and returns the note stored on the event for review. A payment
+// for one reservation is refunded in full; a series payment may still cover occurrences that
+// were booked, so what to refund is left to the admin.
+func refundUnbookedSettlement(paymentRecord *models.Payment, skipped []*models.InvalidTransitionError) string {
+	states := make([]string, 0, len(skipped))
+	for _, transitionErr := range skipped {
+		states = append(states, transitionErr.From)
+	}
+	review := fmt.Sprintf("payment settled but %d reservation(s) could not be marked paid (%s)", len(skipped), strings.Join(states, ", "))
+	if paymentRecord.SeriesId != "" {
+		return review + "; refund the occurrences that were not booked"
+	}
+
+	refund, err := IssueRefund(paymentRecord, "", paymentRecord.Amount, "Payment settled after the reservation "+skipped[0].From)
+	if err != nil {
+		logs.Error("Refunding unbooked payment", paymentRecord.Id, ":", err)
+		if refund == nil {
+			return review + "; automatic refund failed: " + err.Error()
+		}
+	}
+	return fmt.Sprintf("%s; refund %s issued automatically (%s)", review, refund.Id, refund.Status)
+}
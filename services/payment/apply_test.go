@@ -0,0 +1,49 @@
+package payment
+
+import (
+	"testing"
+
+	"badminton-reservation-api/models"
+)
+
+func TestSettlementAfterExpiryIsRefunded(t *testing.T) {
+	requireDB(t)
+	gw := midtransGateway(t)
+	r, p := testPayment(t, "midtrans", models.ReservationWaitingPayment, models.PaymentPending, "PAID-")
+
+	expired := &StatusUpdate{OrderId: p.OrderId, Status: models.PaymentExpired, GatewayStatus: "reconciler_expired"}
+	if _, err := ProcessEvent("midtrans", expired, models.EventSourceReconciler); err != nil {
+		t.Fatal(err)
+	}
+	assertPaymentState(t, p, r, models.PaymentExpired, models.ReservationExpired)
+
+	// The customer completed the checkout just before it closed; the settlement arrives late
+	settled, err := gw.QueryStatus(p.OrderId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := ProcessEvent("midtrans", settled, models.EventSourceWebhook)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Event.Outcome != models.EventApplied || !result.Event.NeedsReview {
+		t.Errorf("late settlement: outcome %s, needs review %v; want applied and flagged", result.Event.Outcome, result.Event.NeedsReview)
+	}
+	stored, err := models.GetPaymentEventById(result.Event.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !stored.NeedsReview || stored.Error == "" {
+		t.Errorf("stored event needs review %v with note %q, want it flagged with a note", stored.NeedsReview, stored.Error)
+	}
+
+	refunds, err := models.GetRefundsByPaymentId(p.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(refunds) != 1 || refunds[0].Status != models.RefundSucceeded || refunds[0].Amount.Amount != p.Amount.Amount {
+		t.Fatalf("refunds %+v, want one succeeded refund of the full amount", refunds)
+	}
+	// The slot was released on expiry and stays released
+	assertPaymentState(t, p, r, models.PaymentRefunded, models.ReservationExpired)
+}
@@ -0,0 +1,120 @@
+package payment
+
+import (
+	"badminton-reservation-api/models"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// DefaultGateway is used when PAYMENT_GATEWAY is not set
+const DefaultGateway = "midtrans"
+
+// ErrTransactionNotFound is returned by QueryStatus when the gateway has no transaction for
+// the order, e.g. because the customer never opened the checkout
+var ErrTransactionNotFound = errors.New("transaction not found at gateway")
+
+// ErrRefundNotFound is returned by QueryRefund when the gateway never received the refund,
+// e.g. because the request timed out before reaching it
+var ErrRefundNotFound = errors.New("refund not found at gateway")
+
+// Transaction is the checkout created at the gateway for a payment
+type Transaction struct {
+	Token       string `json:"token"`
+	RedirectUrl string `json:"redirect_url"`
+}
+
+// StatusUpdate is a gateway's view of a payment, from a notification or a status query.
+// Status is one of the models.Payment* statuses.
+type StatusUpdate struct {
+	OrderId       string
+	TransactionId string
+	Status        string
+	// GatewayStatus and StatusCode are the gateway's own values (e.g. settlement / 200);
+	// together with OrderId they identify a notification for deduplication
+	GatewayStatus string
+	StatusCode    string
+	// Amount is the gross amount as reported by the gateway, and Currency its ISO code when the
+	// gateway sends one; both are checked against the stored payment before it is updated
+	Amount   string
+	Currency string
+	// Raw is the gateway payload, stored on the payment for auditing
+	Raw map[string]interface{}
+}
+
+// RefundResult is the gateway outcome of a refund request. Status is one of the
+// models.Refund* statuses; pending means the gateway accepted the refund but has not
+// completed it yet.
+type RefundResult struct {
+	Status          string
+	GatewayRefundId string
+	Message         string
+	Raw             string
+}
+
+// PaymentGateway is implemented by every payment provider
+type PaymentGateway interface {
+	// Name is the registry key, stored in payments.payment_gateway
+	Name() string
+	// CreateTransaction opens a checkout for the reservation and fills payment.OrderId and
+	// payment.PaymentUrl (and TransactionId when the gateway assigns one up front)
+	CreateTransaction(reservation *models.Reservation, payment *models.Payment) (*Transaction, error)
+	// VerifyNotification authenticates a webhook and extracts the payment update
+	VerifyNotification(header http.Header, payload map[string]interface{}) (*StatusUpdate, error)
+	// QueryStatus asks the gateway for the current state of an order
+	QueryStatus(orderId string) (*StatusUpdate, error)
+	// Refund returns amount of a settled payment. refundKey identifies the refund at the
+	// gateway and is kept for every attempt of the same refund (see IssueRefund).
+	Refund(payment *models.Payment, refundKey string, amount models.Money, reason string) (*RefundResult, error)
+	// QueryRefund asks the gateway for the current state of the refund sent with refundKey
+	QueryRefund(payment *models.Payment, refundKey string) (*RefundResult, error)
+}
+
+var (
+	registryMu sync.RWMutex
+	registry   = map[string]func() PaymentGateway{}
+)
+
+// Register makes a gateway available under name. The factory is called for every use so
+// implementations pick up their configuration from the environment at that time.
+func Register(name string, factory func() PaymentGateway) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	registry[name] = factory
+}
+
+// Get returns the gateway registered under name
+func Get(name string) (PaymentGateway, error) {
+	registryMu.RLock()
+	factory, ok := registry[strings.ToLower(name)]
+	registryMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown payment gateway %q", name)
+	}
+	return factory(), nil
+}
+
+// Default returns the gateway configured for this deployment (PAYMENT_GATEWAY, default midtrans)
+func Default() (PaymentGateway, error) {
+	name := os.Getenv("PAYMENT_GATEWAY")
+	if name == "" {
+		name = DefaultGateway
+	}
+	return Get(name)
+}
+
+// Names returns the registered gateway names in order
+func Names() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,385 @@
+package payment
+
+import (
+	"badminton-reservation-api/models"
+	"crypto/sha512"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+const (
+	midtransKey   = "SB-FAKE-SERVER-KEY"
+	xenditKey     = "xnd_development_fake"
+	xenditToken   = "fake-callback-token"
+	paidOrder     = "RES-PAID-1"
+	deniedOrder   = "DENY-1"
+	paidInvoiceId = "inv-paid-1"
+	sentRefundKey = "RF-sent"
+)
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+// fakeMidtrans imitates Snap transaction creation and the Core API status/refund endpoints.
+// Orders named paidOrder or containing -PAID- are settled, orders containing -PENDING- await
+// payment and any other order is unknown.
+func fakeMidtrans(w http.ResponseWriter, r *http.Request) {
+	if user, _, ok := r.BasicAuth(); !ok || user != midtransKey {
+		writeJSON(w, 401, map[string]string{"status_code": "401", "status_message": "Unknown Merchant server_key/id"})
+		return
+	}
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	switch {
+	case r.Method == http.MethodPost && r.URL.Path == "/snap/v1/transactions":
+		writeJSON(w, 201, map[string]string{"token": "snap-token", "redirect_url": "https://fake.midtrans/snap/snap-token"})
+	case r.Method == http.MethodGet && len(parts) == 3 && parts[2] == "status":
+		if strings.Contains(parts[1], "-PENDING-") {
+			writeJSON(w, 200, map[string]string{"status_code": "201", "order_id": parts[1], "transaction_status": "pending", "transaction_id": "TRX-2", "gross_amount": "150000.00"})
+			return
+		}
+		if parts[1] != paidOrder && !strings.Contains(parts[1], "-PAID-") {
+			writeJSON(w, 200, map[string]string{"status_code": "404", "status_message": "Transaction doesn't exist."})
+			return
+		}
+		writeJSON(w, 200, map[string]interface{}{"status_code": "200", "order_id": parts[1], "transaction_status": "settlement", "transaction_id": "TRX-1", "gross_amount": "150000.00", "fraud_status": "accept",
+			"refunds": []map[string]interface{}{{"refund_chargeback_id": 4242, "refund_amount": "50000.00", "refund_key": sentRefundKey}}})
+	case r.Method == http.MethodPost && len(parts) == 3 && parts[2] == "refund":
+		var body struct {
+			RefundKey string `json:"refund_key"`
+			Amount    int64  `json:"amount"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		if strings.HasPrefix(parts[1], "DENY") {
+			writeJSON(w, 200, map[string]string{"status_code": "412", "status_message": "Merchant cannot modify the status of the transaction"})
+			return
+		}
+		writeJSON(w, 200, map[string]interface{}{"status_code": "200", "status_message": "Success, refund request is approved", "order_id": parts[1], "refund_chargeback_id": 4242, "refund_amount": fmt.Sprintf("%d.00", body.Amount), "refund_key": body.RefundKey})
+	default:
+		writeJSON(w, 404, map[string]string{"status_code": "404"})
+	}
+}
+
+// fakeXendit imitates the invoice and refund endpoints
+func fakeXendit(w http.ResponseWriter, r *http.Request) {
+	if user, _, ok := r.BasicAuth(); !ok || user != xenditKey {
+		writeJSON(w, 401, map[string]string{"error_code": "INVALID_API_KEY", "message": "API key is invalid"})
+		return
+	}
+	switch {
+	case r.Method == http.MethodPost && r.URL.Path == "/v2/invoices":
+		var body map[string]interface{}
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		writeJSON(w, 200, map[string]interface{}{"id": "inv-new", "external_id": body["external_id"], "status": "PENDING", "amount": body["amount"], "invoice_url": "https://fake.xendit/web/inv-new"})
+	case r.Method == http.MethodGet && r.URL.Path == "/v2/invoices":
+		if r.URL.Query().Get("external_id") != paidOrder {
+			writeJSON(w, 200, []interface{}{})
+			return
+		}
+		writeJSON(w, 200, []map[string]interface{}{{"id": paidInvoiceId, "external_id": paidOrder, "status": "PAID", "amount": 150000}})
+	case r.Method == http.MethodPost && r.URL.Path == "/refunds":
+		var body map[string]interface{}
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		if body["invoice_id"] != paidInvoiceId {
+			writeJSON(w, 400, map[string]string{"error_code": "REFUND_NOT_SUPPORTED", "message": "Invoice is not refundable"})
+			return
+		}
+		if r.Header.Get("Idempotency-key") == "" {
+			writeJSON(w, 400, map[string]string{"error_code": "API_VALIDATION_ERROR", "message": "missing idempotency key"})
+			return
+		}
+		writeJSON(w, 200, map[string]interface{}{"id": "rfd-1", "status": "SUCCEEDED", "amount": body["amount"]})
+	case r.Method == http.MethodGet && r.URL.Path == "/refunds":
+		if r.URL.Query().Get("invoice_id") != paidInvoiceId {
+			writeJSON(w, 200, map[string]interface{}{"data": []interface{}{}})
+			return
+		}
+		writeJSON(w, 200, map[string]interface{}{"data": []map[string]interface{}{
+			{"id": "rfd-1", "reference_id": sentRefundKey, "status": "SUCCEEDED", "amount": 50000},
+			{"id": "rfd-2", "reference_id": "RF-slow", "status": "PENDING", "amount": 10000},
+		}})
+	default:
+		writeJSON(w, 404, map[string]string{"error_code": "NOT_FOUND"})
+	}
+}
+
+func testReservation() *models.Reservation {
+	return &models.Reservation{
+		Id:            "0a1b2c3d-0000-0000-0000-000000000000",
+		CourtId:       1,
+		BookingDate:   "2030-01-01",
+		CustomerName:  "Test Customer",
+		CustomerEmail: "customer@example.com",
+		CustomerPhone: "081234567890",
+		TotalPrice:    models.Whole(150000),
+	}
+}
+
+// midtransGateway points the midtrans gateway at a local fake of the Snap and Core APIs
+func midtransGateway(t *testing.T) PaymentGateway {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(fakeMidtrans))
+	t.Cleanup(server.Close)
+	t.Setenv("MIDTRANS_MOCK", "false")
+	t.Setenv("MIDTRANS_SERVER_KEY", midtransKey)
+	t.Setenv("MIDTRANS_API_BASE_URL", server.URL)
+	t.Setenv("MIDTRANS_SNAP_BASE_URL", server.URL)
+	gw, err := Get("midtrans")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return gw
+}
+
+// xenditGateway points the xendit gateway at a local fake of the invoice and refund APIs
+func xenditGateway(t *testing.T) PaymentGateway {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(fakeXendit))
+	t.Cleanup(server.Close)
+	t.Setenv("XENDIT_MOCK", "false")
+	t.Setenv("XENDIT_SECRET_KEY", xenditKey)
+	t.Setenv("XENDIT_CALLBACK_TOKEN", xenditToken)
+	t.Setenv("XENDIT_API_BASE_URL", server.URL)
+	gw, err := Get("xendit")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return gw
+}
+
+func TestMidtransCreateTransaction(t *testing.T) {
+	gw := midtransGateway(t)
+	p := &models.Payment{ExpiredAt: time.Now().Add(30 * time.Minute)}
+	tx, err := gw.CreateTransaction(testReservation(), p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tx.Token != "snap-token" || p.OrderId == "" || p.PaymentUrl != tx.RedirectUrl {
+		t.Errorf("got transaction %+v, payment order %q url %q", tx, p.OrderId, p.PaymentUrl)
+	}
+}
+
+func TestMidtransVerifyNotification(t *testing.T) {
+	gw := midtransGateway(t)
+	sig := sha512.Sum512([]byte(paidOrder + "200" + "150000.00" + midtransKey))
+	payload := map[string]interface{}{"order_id": paidOrder, "status_code": "200", "gross_amount": "150000.00", "transaction_status": "settlement", "transaction_id": "TRX-1", "signature_key": hex.EncodeToString(sig[:])}
+
+	update, err := gw.VerifyNotification(http.Header{}, payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if update.Status != models.PaymentSuccess || update.OrderId != paidOrder {
+		t.Errorf("got %+v", update)
+	}
+
+	payload["signature_key"] = "forged"
+	if _, err := gw.VerifyNotification(http.Header{}, payload); err == nil {
+		t.Error("forged notification was accepted")
+	}
+}
+
+func TestMidtransQueryStatus(t *testing.T) {
+	gw := midtransGateway(t)
+	status, err := gw.QueryStatus(paidOrder)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.Status != models.PaymentSuccess || status.TransactionId != "TRX-1" {
+		t.Errorf("got %+v", status)
+	}
+	if _, err := gw.QueryStatus("RES-UNKNOWN"); err == nil {
+		t.Error("unknown order did not return an error")
+	}
+}
+
+func TestMidtransRefund(t *testing.T) {
+	gw := midtransGateway(t)
+	for _, tc := range []struct {
+		name    string
+		orderId string
+		amount  models.Money
+		status  string
+	}{
+		{"full", paidOrder, models.Whole(150000), models.RefundSucceeded},
+		{"partial", paidOrder, models.Whole(37500), models.RefundSucceeded},
+		{"refused", deniedOrder, models.Whole(1000), models.RefundFailed},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			result, err := gw.Refund(&models.Payment{OrderId: tc.orderId}, "RF-"+tc.name, tc.amount, "cancelled")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if result.Status != tc.status {
+				t.Errorf("status %s, want %s", result.Status, tc.status)
+			}
+			if tc.status == models.RefundSucceeded && result.GatewayRefundId != "4242" {
+				t.Errorf("gateway refund id %q, want 4242", result.GatewayRefundId)
+			}
+		})
+	}
+
+	if _, err := gw.Refund(&models.Payment{OrderId: paidOrder}, "RF-sen", models.NewMoney(100050, ""), ""); err == nil {
+		t.Error("refund with minor units was accepted")
+	}
+
+	t.Setenv("MIDTRANS_API_BASE_URL", "http://127.0.0.1:1")
+	if _, err := gw.Refund(&models.Payment{OrderId: paidOrder}, "RF-down", models.Whole(1000), ""); err == nil {
+		t.Error("unreachable gateway did not return an error")
+	}
+}
+
+func TestXenditCreateInvoice(t *testing.T) {
+	gw := xenditGateway(t)
+	p := &models.Payment{ExpiredAt: time.Now().Add(30 * time.Minute)}
+	tx, err := gw.CreateTransaction(testReservation(), p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.TransactionId != "inv-new" || tx.RedirectUrl != "https://fake.xendit/web/inv-new" {
+		t.Errorf("got transaction %+v, payment transaction %q", tx, p.TransactionId)
+	}
+}
+
+func TestXenditVerifyNotification(t *testing.T) {
+	gw := xenditGateway(t)
+	payload := map[string]interface{}{"id": paidInvoiceId, "external_id": paidOrder, "status": "PAID", "amount": 150000}
+	header := http.Header{}
+	header.Set("X-Callback-Token", xenditToken)
+
+	update, err := gw.VerifyNotification(header, payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if update.Status != models.PaymentSuccess || update.OrderId != paidOrder || update.Amount != "150000" {
+		t.Errorf("got %+v", update)
+	}
+
+	header.Set("X-Callback-Token", "forged")
+	if _, err := gw.VerifyNotification(header, payload); err == nil {
+		t.Error("forged callback was accepted")
+	}
+
+	payload["status"] = "EXPIRED"
+	header.Set("X-Callback-Token", xenditToken)
+	update, err = gw.VerifyNotification(header, payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if update.Status != models.PaymentExpired {
+		t.Errorf("expired invoice gave status %s", update.Status)
+	}
+}
+
+func TestXenditQueryStatus(t *testing.T) {
+	gw := xenditGateway(t)
+	status, err := gw.QueryStatus(paidOrder)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.Status != models.PaymentSuccess || status.TransactionId != paidInvoiceId {
+		t.Errorf("got %+v", status)
+	}
+	if _, err := gw.QueryStatus("RES-UNKNOWN"); err == nil {
+		t.Error("unknown order did not return an error")
+	}
+}
+
+func TestXenditRefund(t *testing.T) {
+	gw := xenditGateway(t)
+	refund, err := gw.Refund(&models.Payment{TransactionId: paidInvoiceId}, "RF-x1", models.Whole(50000), "cancelled")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if refund.Status != models.RefundSucceeded || refund.GatewayRefundId != "rfd-1" {
+		t.Errorf("got %+v", refund)
+	}
+
+	refused, err := gw.Refund(&models.Payment{TransactionId: "inv-other"}, "RF-x2", models.Whole(50000), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if refused.Status != models.RefundFailed || refused.Message == "" {
+		t.Errorf("got %+v", refused)
+	}
+}
+
+func TestMidtransQueryRefund(t *testing.T) {
+	gw := midtransGateway(t)
+	result, err := gw.QueryRefund(&models.Payment{OrderId: paidOrder}, sentRefundKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Status != models.RefundSucceeded || result.GatewayRefundId != "4242" {
+		t.Errorf("got %+v", result)
+	}
+	if _, err := gw.QueryRefund(&models.Payment{OrderId: paidOrder}, "RF-never-sent"); !errors.Is(err, ErrRefundNotFound) {
+		t.Errorf("unknown refund key: got %v, want ErrRefundNotFound", err)
+	}
+	if _, err := gw.QueryRefund(&models.Payment{OrderId: "RES-UNKNOWN"}, sentRefundKey); !errors.Is(err, ErrRefundNotFound) {
+		t.Errorf("unknown order: got %v, want ErrRefundNotFound", err)
+	}
+
+	t.Setenv("MIDTRANS_API_BASE_URL", "http://127.0.0.1:1")
+	if _, err := gw.QueryRefund(&models.Payment{OrderId: paidOrder}, sentRefundKey); err == nil || errors.Is(err, ErrRefundNotFound) {
+		t.Errorf("unreachable gateway: got %v, want a transport error", err)
+	}
+}
+
+func TestXenditQueryRefund(t *testing.T) {
+	gw := xenditGateway(t)
+	paid := &models.Payment{TransactionId: paidInvoiceId}
+	for _, tc := range []struct {
+		key, status, id string
+	}{
+		{sentRefundKey, models.RefundSucceeded, "rfd-1"},
+		{"RF-slow", models.RefundPending, "rfd-2"},
+	} {
+		result, err := gw.QueryRefund(paid, tc.key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result.Status != tc.status || result.GatewayRefundId != tc.id {
+			t.Errorf("%s: got %+v", tc.key, result)
+		}
+	}
+	if _, err := gw.QueryRefund(paid, "RF-never-sent"); !errors.Is(err, ErrRefundNotFound) {
+		t.Errorf("unknown refund key: got %v, want ErrRefundNotFound", err)
+	}
+}
+
+func TestMatchPayment(t *testing.T) {
+	stored := &models.Payment{ReservationId: testReservation().Id, Amount: models.Whole(150000)}
+	orderId := "RES-0a1b2c3d-1700000000"
+	for _, tc := range []struct {
+		name   string
+		update StatusUpdate
+		ok     bool
+	}{
+		{"matching midtrans update", StatusUpdate{OrderId: orderId, Amount: "150000.00", Currency: "IDR"}, true},
+		{"matching xendit update", StatusUpdate{OrderId: orderId, Amount: "150000"}, true},
+		{"update without amount", StatusUpdate{OrderId: orderId}, true},
+		{"different amount", StatusUpdate{OrderId: orderId, Amount: "1500.00", Currency: "IDR"}, false},
+		{"amount off by one sen", StatusUpdate{OrderId: orderId, Amount: "150000.01"}, false},
+		{"unparseable amount", StatusUpdate{OrderId: orderId, Amount: "150.000,00"}, false},
+		{"different currency", StatusUpdate{OrderId: orderId, Amount: "150000.00", Currency: "USD"}, false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			err := MatchPayment(stored, &tc.update)
+			if tc.ok && err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+			if !tc.ok && !errors.Is(err, ErrPaymentMismatch) {
+				t.Errorf("got %v, want ErrPaymentMismatch", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,97 @@
+package payment
+
+import (
+	"badminton-reservation-api/models"
+	"fmt"
+	"strconv"
+)
+
+// maxItemNameLength is the shortest item name limit of the supported gateways (Midtrans: 50)
+const maxItemNameLength = 50
+
+// lineItem is one billed slot (or a discount, with a negative Price), converted to each
+// gateway's item format
+type lineItem struct {
+	Id       string
+	Name     string
+	Price    int64
+	Discount bool
+}
+
+// describeItems lists each reserved slot as a line item. Reservations without loaded items
+// fall back to a single line for the whole booking. Prices are in whole currency units;
+// an amount with minor units fails rather than being billed truncated.
+func describeItems(reservation *models.Reservation) ([]lineItem, error) {
+	if len(reservation.Items) == 0 {
+		total, err := reservation.TotalPrice.WholeUnits()
+		if err != nil {
+			return nil, err
+		}
+		return []lineItem{{
+			Id:    strconv.Itoa(reservation.CourtId),
+			Name:  fmt.Sprintf("Court Booking - %s", reservation.BookingDate),
+			Price: total,
+		}}, nil
+	}
+
+	courtNames := map[int]string{}
+	lines := make([]lineItem, 0, len(reservation.Items))
+	for _, item := range reservation.Items {
+		name, ok := courtNames[item.CourtId]
+		if !ok {
+			name = fmt.Sprintf("Court %d", item.CourtId)
+			if court, err := models.GetCourtById(item.CourtId); err == nil {
+				name = court.Name
+			}
+			courtNames[item.CourtId] = name
+		}
+		label := fmt.Sprintf("%s %s", name, item.BookingDate)
+		if slot, err := models.GetTimeslotById(item.TimeslotId); err == nil {
+			label = fmt.Sprintf("%s %s %s-%s", name, item.BookingDate, shortTime(slot.StartTime), shortTime(slot.EndTime))
+		}
+		label = truncateItemName(label)
+
+		price, err := item.Price.WholeUnits()
+		if err != nil {
+			return nil, err
+		}
+		lines = append(lines, lineItem{
+			Id:    fmt.Sprintf("C%d-T%d-%s", item.CourtId, item.TimeslotId, item.BookingDate),
+			Name:  label,
+			Price: price,
+		})
+	}
+
+	// A redeemed promo code is billed as a negative line so the items add up to the total
+	if reservation.DiscountAmount.Amount > 0 {
+		discount, err := reservation.DiscountAmount.WholeUnits()
+		if err != nil {
+			return nil, err
+		}
+		label := truncateItemName("Discount " + reservation.PromoCode)
+		lines = append(lines, lineItem{
+			Id:       "PROMO-" + reservation.PromoCode,
+			Name:     label,
+			Price:    -discount,
+			Discount: true,
+		})
+	}
+	return lines, nil
+}
+
+// shortTime trims HH:MM:SS to HH:MM
+func shortTime(t string) string {
+	if len(t) >= 5 {
+		return t[:5]
+	}
+	return t
+}
+
+// truncateItemName cuts name to maxItemNameLength characters without splitting a multi-byte character
+func truncateItemName(name string) string {
+	runes := []rune(name)
+	if len(runes) <= maxItemNameLength {
+		return name
+	}
+	return string(runes[:maxItemNameLength])
+}
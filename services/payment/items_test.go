@@ -0,0 +1,30 @@
+package payment
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestTruncateItemName(t *testing.T) {
+	tests := []struct {
+		name, in, want string
+	}{
+		{"short name is kept", "Court A 2026-10-20 19:00-20:00", "Court A 2026-10-20 19:00-20:00"},
+		{"exactly the limit", strings.Repeat("a", 50), strings.Repeat("a", 50)},
+		{"long ASCII name", strings.Repeat("a", 60), strings.Repeat("a", 50)},
+		{"multi-byte characters are counted once", strings.Repeat("é", 50), strings.Repeat("é", 50)},
+		{"cut on a character boundary", "Lapangan Ünggulan " + strings.Repeat("🏸", 40), "Lapangan Ünggulan " + strings.Repeat("🏸", 32)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := truncateItemName(tt.in)
+			if got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+			if !utf8.ValidString(got) {
+				t.Errorf("%q is not valid UTF-8", got)
+			}
+		})
+	}
+}
@@ -0,0 +1,113 @@
+package payment
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"badminton-reservation-api/database"
+	"badminton-reservation-api/database/migrations"
+	"badminton-reservation-api/models"
+
+	"github.com/beego/beego/v2/client/orm"
+	"github.com/google/uuid"
+)
+
+// testDB is set when TEST_DATABASE_URL points at a Postgres database the tests may write to.
+// Migrations are applied once on start; tests that need the database call requireDB.
+var testDB bool
+
+func TestMain(m *testing.M) {
+	if dataSource := os.Getenv("TEST_DATABASE_URL"); dataSource != "" {
+		if err := openTestDB(dataSource); err != nil {
+			fmt.Fprintln(os.Stderr, "test database:", err)
+			os.Exit(1)
+		}
+		testDB = true
+	}
+	os.Exit(m.Run())
+}
+
+func openTestDB(dataSource string) error {
+	if err := orm.RegisterDriver("postgres", orm.DRPostgres); err != nil {
+		return err
+	}
+	if err := orm.RegisterDataBase("default", "postgres", dataSource); err != nil {
+		return err
+	}
+	list, err := database.LoadMigrations(migrations.FS)
+	if err != nil {
+		return err
+	}
+	db, err := orm.GetDB("default")
+	if err != nil {
+		return err
+	}
+	_, err = database.NewMigrator(db, list).Up(context.Background())
+	return err
+}
+
+// requireDB skips t unless TEST_DATABASE_URL is set
+func requireDB(t *testing.T) {
+	t.Helper()
+	if !testDB {
+		t.Skip("TEST_DATABASE_URL is not set")
+	}
+}
+
+// testPayment books a court of its own for tomorrow in reservationStatus and stores a
+// 150000 payment for it through gateway in paymentStatus. The order id carries orderTag for the
+// gateway fakes (e.g. PAID- for an order fakeMidtrans reports as settled). Everything is
+// removed when t ends.
+func testPayment(t *testing.T, gateway, reservationStatus, paymentStatus, orderTag string) (*models.Reservation, *models.Payment) {
+	t.Helper()
+	court := &models.Court{Name: "Test " + t.Name(), PricePerHour: models.Whole(150000)}
+	if err := models.CreateCourt(court); err != nil {
+		t.Fatal(err)
+	}
+	slot := &models.Timeslot{StartTime: "23:00:00", EndTime: "23:59:00"}
+	if err := models.CreateTimeslot(slot); err != nil {
+		t.Fatal(err)
+	}
+	r := &models.Reservation{
+		Id:            uuid.New().String(),
+		CourtId:       court.Id,
+		TimeslotId:    slot.Id,
+		BookingDate:   time.Now().AddDate(0, 0, 1).Format("2006-01-02"),
+		CustomerName:  "Payment Tester",
+		CustomerEmail: "payment@example.com",
+		CustomerPhone: "+6281234567890",
+		TotalPrice:    court.PricePerHour,
+		Status:        reservationStatus,
+		ExpiredAt:     time.Now().Add(30 * time.Minute),
+	}
+	p := &models.Payment{
+		Id:             uuid.New().String(),
+		ReservationId:  r.Id,
+		OrderId:        "RES-" + r.Id[:8] + "-" + orderTag + uuid.New().String()[:8],
+		Amount:         r.TotalPrice,
+		PaymentGateway: gateway,
+		Status:         paymentStatus,
+		TransactionId:  "inv-" + r.Id[:8],
+		ExpiredAt:      r.ExpiredAt,
+	}
+	t.Cleanup(func() {
+		o := orm.NewOrm()
+		o.Raw("DELETE FROM payment_events WHERE order_id = ?", p.OrderId).Exec()
+		o.Raw("DELETE FROM refunds WHERE payment_id = ?", p.Id).Exec()
+		o.Raw("DELETE FROM payments WHERE id = ?", p.Id).Exec()
+		o.Raw("DELETE FROM reservations WHERE id = ?", r.Id).Exec()
+		o.Raw("DELETE FROM timeslot_availabilities WHERE court_id = ?", court.Id).Exec()
+		o.Raw("DELETE FROM timeslots WHERE id = ?", slot.Id).Exec()
+		o.Raw("DELETE FROM courts WHERE id = ?", court.Id).Exec()
+	})
+	if err := models.CreateReservation(r); err != nil {
+		t.Fatal(err)
+	}
+	if err := models.CreatePayment(p); err != nil {
+		t.Fatal(err)
+	}
+	return r, p
+}
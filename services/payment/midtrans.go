@@ -3,11 +3,15 @@ package payment
 import (
 	"badminton-reservation-api/models"
 	"crypto/sha512"
+	"crypto/subtle"
 	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"io"
+	"net/http"
+	"net/url"
 	"os"
-	"strconv"
+	"strings"
 	"time"
 
 	"github.com/midtrans/midtrans-go"
@@ -18,9 +22,8 @@ type MidtransService struct {
 	Client snap.Client
 }
 
-type MidtransResponse struct {
-	Token       string `json:"token"`
-	RedirectUrl string `json:"redirect_url"`
+func init() {
+	Register("midtrans", func() PaymentGateway { return NewMidtransService() })
 }
 
 // NewMidtransService creates a new Midtrans service instance
@@ -33,13 +36,38 @@ func NewMidtransService() *MidtransService {
 		client.New(os.Getenv("MIDTRANS_SERVER_KEY"), midtrans.Production)
 	}
 
+	// Point Snap at another host (e.g. a local fake) when MIDTRANS_SNAP_BASE_URL is set
+	if base := os.Getenv("MIDTRANS_SNAP_BASE_URL"); base != "" {
+		client.HttpClient = &rebasedHttpClient{
+			inner: client.HttpClient,
+			from:  client.Env.SnapURL(),
+			to:    strings.TrimRight(base, "/"),
+		}
+	}
+
 	return &MidtransService{
 		Client: client,
 	}
 }
 
+// rebasedHttpClient rewrites the host of Midtrans SDK requests
+type rebasedHttpClient struct {
+	inner midtrans.HttpClient
+	from  string
+	to    string
+}
+
+func (c *rebasedHttpClient) Call(method string, url string, apiKey *string, options *midtrans.ConfigOptions, body io.Reader, result interface{}) *midtrans.Error {
+	return c.inner.Call(method, strings.Replace(url, c.from, c.to, 1), apiKey, options, body, result)
+}
+
+// Name implements PaymentGateway
+func (s *MidtransService) Name() string {
+	return "midtrans"
+}
+
 // CreateTransaction creates a Snap transaction for payment
-func (s *MidtransService) CreateTransaction(reservation *models.Reservation, payment *models.Payment) (*MidtransResponse, error) {
+func (s *MidtransService) CreateTransaction(reservation *models.Reservation, payment *models.Payment) (*Transaction, error) {
 	// Support a mock mode for local testing without Midtrans API key
 	if os.Getenv("MIDTRANS_MOCK") == "true" {
 		orderId := fmt.Sprintf("MOCK-%s-%d", reservation.Id[:8], time.Now().Unix())
@@ -50,13 +78,23 @@ func (s *MidtransService) CreateTransaction(reservation *models.Reservation, pay
 		payment.OrderId = orderId
 		payment.PaymentUrl = mockUrl
 
-		response := &MidtransResponse{
+		response := &Transaction{
 			Token:       mockToken,
 			RedirectUrl: mockUrl,
 		}
 		return response, nil
 	}
 
+	// Snap takes whole rupiah
+	grossAmount, err := reservation.TotalPrice.WholeUnits()
+	if err != nil {
+		return nil, err
+	}
+	items, err := buildItemDetails(reservation)
+	if err != nil {
+		return nil, err
+	}
+
 	// Generate unique order ID
 	orderId := fmt.Sprintf("RES-%s-%d", reservation.Id[:8], time.Now().Unix())
 
@@ -64,38 +102,32 @@ func (s *MidtransService) CreateTransaction(reservation *models.Reservation, pay
 	req := &snap.Request{
 		TransactionDetails: midtrans.TransactionDetails{
 			OrderID:  orderId,
-			GrossAmt: int64(reservation.TotalPrice),
+			GrossAmt: grossAmount,
 		},
 		CustomerDetail: &midtrans.CustomerDetails{
 			FName: reservation.CustomerName,
 			Email: reservation.CustomerEmail,
 			Phone: reservation.CustomerPhone,
 		},
-		Items: &[]midtrans.ItemDetails{
-			{
-				ID:    strconv.Itoa(reservation.CourtId),
-				Name:  fmt.Sprintf("Court Booking - %s", reservation.BookingDate),
-				Price: int64(reservation.TotalPrice),
-				Qty:   1,
-			},
-		},
+		Items:           items,
+		Expiry:          snapExpiry(payment.ExpiredAt),
 		EnabledPayments: snap.AllSnapPaymentType,
 		Callbacks: &snap.Callbacks{
 			Finish: os.Getenv("APP_URL") + "/payment/finish",
 		},
 	}
 
-	// Create transaction
-	snapResp, err := s.Client.CreateTransaction(req)
-	if err != nil {
-		return nil, err
+	// Create transaction; the client returns a *midtrans.Error, so compare before widening to error
+	snapResp, snapErr := s.Client.CreateTransaction(req)
+	if snapErr != nil {
+		return nil, snapErr
 	}
 
 	// Update payment with order_id and payment_url
 	payment.OrderId = orderId
 	payment.PaymentUrl = snapResp.RedirectURL
 
-	response := &MidtransResponse{
+	response := &Transaction{
 		Token:       snapResp.Token,
 		RedirectUrl: snapResp.RedirectURL,
 	}
@@ -103,12 +135,48 @@ func (s *MidtransService) CreateTransaction(reservation *models.Reservation, pay
 	return response, nil
 }
 
+// snapExpiry makes the Snap checkout expire together with the payment, so a customer cannot
+// pay for a reservation that has already been released. Returns nil (Midtrans default) when
+// the payment has no expiry.
+func snapExpiry(expiredAt time.Time) *snap.ExpiryDetails {
+	if expiredAt.IsZero() {
+		return nil
+	}
+	minutes := int64(time.Until(expiredAt).Minutes())
+	if minutes < 1 {
+		minutes = 1
+	}
+	return &snap.ExpiryDetails{
+		StartTime: time.Now().Format("2006-01-02 15:04:05 -0700"),
+		Unit:      "minute",
+		Duration:  minutes,
+	}
+}
+
+// buildItemDetails lists each reserved slot as a Snap line item
+func buildItemDetails(reservation *models.Reservation) (*[]midtrans.ItemDetails, error) {
+	lines, err := describeItems(reservation)
+	if err != nil {
+		return nil, err
+	}
+	items := make([]midtrans.ItemDetails, 0, len(lines))
+	for _, line := range lines {
+		items = append(items, midtrans.ItemDetails{
+			ID:    line.Id,
+			Name:  line.Name,
+			Price: line.Price,
+			Qty:   1,
+		})
+	}
+	return &items, nil
+}
+
 // VerifySignature verifies the signature from Midtrans notification
 func (s *MidtransService) VerifySignature(orderId, statusCode, grossAmount, serverKey, signatureKey string) bool {
 	// Midtrans signature: SHA512(order_id+status_code+gross_amount+ServerKey)
 	h := sha512.Sum512([]byte(orderId + statusCode + grossAmount + serverKey))
 	expectedSignature := hex.EncodeToString(h[:])
-	return expectedSignature == signatureKey
+	return subtle.ConstantTimeCompare([]byte(expectedSignature), []byte(signatureKey)) == 1
 }
 
 // TransactionStatusResponse represents the response from Midtrans transaction status API
@@ -116,6 +184,7 @@ type TransactionStatusResponse struct {
 	TransactionStatus string `json:"transaction_status"`
 	OrderID           string `json:"order_id"`
 	GrossAmount       string `json:"gross_amount"`
+	Currency          string `json:"currency"`
 	PaymentType       string `json:"payment_type"`
 	TransactionID     string `json:"transaction_id"`
 	FraudStatus       string `json:"fraud_status"`
@@ -123,6 +192,52 @@ type TransactionStatusResponse struct {
 	SignatureKey      string `json:"signature_key"`
 }
 
+// VerifyNotification implements PaymentGateway: the payload must carry a valid signature_key
+func (s *MidtransService) VerifyNotification(header http.Header, payload map[string]interface{}) (*StatusUpdate, error) {
+	statusResp, err := s.ParseNotification(payload)
+	if err != nil {
+		return nil, err
+	}
+	return &StatusUpdate{
+		OrderId:       statusResp.OrderID,
+		TransactionId: statusResp.TransactionID,
+		Status:        GetPaymentStatus(statusResp.TransactionStatus, statusResp.FraudStatus),
+		GatewayStatus: statusResp.TransactionStatus,
+		StatusCode:    statusResp.StatusCode,
+		Amount:        statusResp.GrossAmount,
+		Currency:      statusResp.Currency,
+		Raw:           payload,
+	}, nil
+}
+
+// QueryStatus implements PaymentGateway using the Core API transaction status endpoint
+func (s *MidtransService) QueryStatus(orderId string) (*StatusUpdate, error) {
+	endpoint := fmt.Sprintf("%s/v2/%s/status", apiBaseURL(), url.PathEscape(orderId))
+	var payload map[string]interface{}
+	if _, err := s.call(http.MethodGet, endpoint, nil, &payload); err != nil {
+		return nil, err
+	}
+
+	var statusResp TransactionStatusResponse
+	raw, _ := json.Marshal(payload)
+	if err := json.Unmarshal(raw, &statusResp); err != nil {
+		return nil, err
+	}
+	if statusResp.StatusCode == "404" {
+		return nil, fmt.Errorf("midtrans: order %s: %w", orderId, ErrTransactionNotFound)
+	}
+	return &StatusUpdate{
+		OrderId:       orderId,
+		TransactionId: statusResp.TransactionID,
+		Status:        GetPaymentStatus(statusResp.TransactionStatus, statusResp.FraudStatus),
+		GatewayStatus: statusResp.TransactionStatus,
+		StatusCode:    statusResp.StatusCode,
+		Amount:        statusResp.GrossAmount,
+		Currency:      statusResp.Currency,
+		Raw:           payload,
+	}, nil
+}
+
 // ParseNotification parses and validates the notification from Midtrans
 func (s *MidtransService) ParseNotification(notificationPayload map[string]interface{}) (*TransactionStatusResponse, error) {
 	// Convert map to JSON
@@ -166,8 +281,14 @@ func GetPaymentStatus(transactionStatus string, fraudStatus string) string {
 		return "success"
 	} else if transactionStatus == "pending" {
 		return "pending"
-	} else if transactionStatus == "deny" || transactionStatus == "expire" || transactionStatus == "cancel" {
+	} else if transactionStatus == "deny" || transactionStatus == "cancel" {
 		return "failed"
+	} else if transactionStatus == "expire" {
+		return models.PaymentExpired
+	} else if transactionStatus == "refund" {
+		return models.PaymentRefunded
+	} else if transactionStatus == "partial_refund" {
+		return models.PaymentPartiallyRefunded
 	}
 	return "pending"
 }
@@ -0,0 +1,121 @@
+package payment
+
+import (
+	"badminton-reservation-api/models"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// Midtrans Core API base URLs; MIDTRANS_API_BASE_URL overrides them (e.g. a local fake)
+const (
+	midtransSandboxAPIURL    = "https://api.sandbox.midtrans.com"
+	midtransProductionAPIURL = "https://api.midtrans.com"
+)
+
+// apiBaseURL returns the Midtrans Core API base URL for the configured environment
+func apiBaseURL() string {
+	if v := os.Getenv("MIDTRANS_API_BASE_URL"); v != "" {
+		return strings.TrimRight(v, "/")
+	}
+	if os.Getenv("MIDTRANS_IS_PRODUCTION") == "true" {
+		return midtransProductionAPIURL
+	}
+	return midtransSandboxAPIURL
+}
+
+// call performs a Core API request authenticated with the server key
+func (s *MidtransService) call(method string, endpoint string, body interface{}, out interface{}) ([]byte, error) {
+	header := http.Header{}
+	header.Set("Authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte(os.Getenv("MIDTRANS_SERVER_KEY")+":")))
+	raw, err := doJSON(method, endpoint, header, body, out)
+	if err != nil {
+		return raw, fmt.Errorf("midtrans: %w", err)
+	}
+	return raw, nil
+}
+
+// midtransRefundResponse is the Core API refund response
+type midtransRefundResponse struct {
+	StatusCode         string `json:"status_code"`
+	StatusMessage      string `json:"status_message"`
+	TransactionID      string `json:"transaction_id"`
+	OrderID            string `json:"order_id"`
+	RefundChargebackID int64  `json:"refund_chargeback_id"`
+	RefundAmount       string `json:"refund_amount"`
+	RefundKey          string `json:"refund_key"`
+}
+
+// Refund implements PaymentGateway. A non-nil error means the gateway could not be reached or
+// answered garbage; a reachable gateway refusing the refund is reported as a failed result.
+func (s *MidtransService) Refund(payment *models.Payment, refundKey string, amount models.Money, reason string) (*RefundResult, error) {
+	if os.Getenv("MIDTRANS_MOCK") == "true" {
+		return &RefundResult{
+			Status:          models.RefundSucceeded,
+			GatewayRefundId: fmt.Sprintf("%d", time.Now().Unix()),
+			Message:         "Success, refund request is approved",
+			Raw:             "{}",
+		}, nil
+	}
+
+	refundAmount, err := amount.WholeUnits()
+	if err != nil {
+		return nil, err
+	}
+	endpoint := fmt.Sprintf("%s/v2/%s/refund", apiBaseURL(), url.PathEscape(payment.OrderId))
+	var resp midtransRefundResponse
+	raw, err := s.call(http.MethodPost, endpoint, map[string]interface{}{
+		"refund_key": refundKey,
+		"amount":     refundAmount,
+		"reason":     reason,
+	}, &resp)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &RefundResult{Status: models.RefundFailed, Message: resp.StatusMessage, Raw: string(raw)}
+	if resp.StatusCode == "200" {
+		result.Status = models.RefundSucceeded
+		result.GatewayRefundId = fmt.Sprintf("%d", resp.RefundChargebackID)
+	}
+	return result, nil
+}
+
+// midtransRefundStatus is the part of the transaction status that lists the order's refunds
+type midtransRefundStatus struct {
+	StatusCode string `json:"status_code"`
+	Refunds    []struct {
+		RefundChargebackID int64  `json:"refund_chargeback_id"`
+		RefundAmount       string `json:"refund_amount"`
+		RefundKey          string `json:"refund_key"`
+	} `json:"refunds"`
+}
+
+// QueryRefund implements PaymentGateway through the transaction status, which lists every
+// refund Midtrans approved for the order with its refund key
+func (s *MidtransService) QueryRefund(payment *models.Payment, refundKey string) (*RefundResult, error) {
+	if os.Getenv("MIDTRANS_MOCK") == "true" {
+		return nil, fmt.Errorf("midtrans: refund %s: %w", refundKey, ErrRefundNotFound)
+	}
+
+	endpoint := fmt.Sprintf("%s/v2/%s/status", apiBaseURL(), url.PathEscape(payment.OrderId))
+	var resp midtransRefundStatus
+	raw, err := s.call(http.MethodGet, endpoint, nil, &resp)
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range resp.Refunds {
+		if r.RefundKey == refundKey {
+			return &RefundResult{
+				Status:          models.RefundSucceeded,
+				GatewayRefundId: fmt.Sprintf("%d", r.RefundChargebackID),
+				Raw:             string(raw),
+			}, nil
+		}
+	}
+	return nil, fmt.Errorf("midtrans: refund %s: %w", refundKey, ErrRefundNotFound)
+}
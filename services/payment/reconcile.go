@@ -0,0 +1,137 @@
+package payment
+
+import (
+	"badminton-reservation-api/models"
+	"badminton-reservation-api/utils"
+	"context"
+	"errors"
+	"time"
+
+	"github.com/beego/beego/v2/core/logs"
+)
+
+// ReconcileResult summarizes one reconciliation run
+type ReconcileResult struct {
+	Checked int `json:"checked"`
+	Updated int `json:"updated"`
+	Expired int `json:"expired"`
+	Failed  int `json:"failed"`
+}
+
+// ReconcileInterval is how often the reconciler runs (RECONCILE_INTERVAL, default 5m)
+func ReconcileInterval() time.Duration {
+	return utils.EnvDuration("RECONCILE_INTERVAL", 5*time.Minute)
+}
+
+// ReconcilePendingPayments recovers from lost webhooks. Every payment still pending
+// RECONCILE_AFTER (default 10m) after creation is looked up at its gateway and the result
+// recorded and applied exactly like a webhook. Payments whose checkout has expired (ExpiredAt plus
+// RECONCILE_EXPIRY_GRACE, default 5m) and that the gateway still reports as pending, or does
+// not know at all, are expired together with their reservations. The run stops early once ctx
+// is done; the payments left are picked up by the next run.
+func ReconcilePendingPayments(ctx context.Context) (*ReconcileResult, error) {
+	now := time.Now()
+	after := utils.EnvDuration("RECONCILE_AFTER", 10*time.Minute)
+	grace := utils.EnvDuration("RECONCILE_EXPIRY_GRACE", 5*time.Minute)
+
+	payments, err := models.GetPendingPaymentsCreatedBefore(now.Add(-after))
+	if err != nil {
+		return nil, err
+	}
+
+	result := &ReconcileResult{}
+	for _, p := range payments {
+		if ctx.Err() != nil {
+			break
+		}
+		result.Checked++
+
+		gateway, err := Get(p.PaymentGateway)
+		if err != nil {
+			logs.Error("Reconcile: payment", p.Id, ":", err)
+			result.Failed++
+			continue
+		}
+
+		update, err := gateway.QueryStatus(p.OrderId)
+		if err != nil && !errors.Is(err, ErrTransactionNotFound) {
+			// Gateway unreachable: try again next run rather than guessing
+			logs.Warn("Reconcile: could not query order", p.OrderId, ":", err)
+			result.Failed++
+			continue
+		}
+
+		if err == nil && update.Status != models.PaymentPending {
+			if _, err := ProcessEvent(p.PaymentGateway, update, models.EventSourceReconciler); err != nil {
+				logs.Error("Reconcile: applying status for order", p.OrderId, ":", err)
+				result.Failed++
+				continue
+			}
+			result.Updated++
+			continue
+		}
+
+		if p.ExpiredAt.IsZero() || now.Before(p.ExpiredAt.Add(grace)) {
+			continue
+		}
+		expired := &StatusUpdate{
+			OrderId:       p.OrderId,
+			Status:        models.PaymentExpired,
+			GatewayStatus: "reconciler_expired",
+			Raw:           map[string]interface{}{"source": "reconciler", "reason": "checkout expired without payment"},
+		}
+		if _, err := ProcessEvent(p.PaymentGateway, expired, models.EventSourceReconciler); err != nil {
+			logs.Error("Reconcile: expiring order", p.OrderId, ":", err)
+			result.Failed++
+			continue
+		}
+		result.Expired++
+	}
+	return result, nil
+}
+
+// ReconcilePendingRefunds settles refunds still pending RECONCILE_AFTER (default 10m) after
+// they were requested, because the gateway could not be reached or had not completed them.
+// Each one is looked up at its gateway by refund key and completed with the gateway's outcome;
+// a refund the gateway never received is sent again under the same key. The run stops early
+// once ctx is done.
+func ReconcilePendingRefunds(ctx context.Context) (*ReconcileResult, error) {
+	after := utils.EnvDuration("RECONCILE_AFTER", 10*time.Minute)
+	refunds, err := models.GetPendingRefundsCreatedBefore(time.Now().Add(-after))
+	if err != nil {
+		return nil, err
+	}
+
+	result := &ReconcileResult{}
+	for _, r := range refunds {
+		if ctx.Err() != nil {
+			break
+		}
+		result.Checked++
+
+		p, err := models.GetPaymentById(r.PaymentId)
+		if err != nil {
+			logs.Error("Reconcile: refund", r.Id, ":", err)
+			result.Failed++
+			continue
+		}
+		gateway, err := Get(p.PaymentGateway)
+		if err != nil {
+			logs.Error("Reconcile: refund", r.Id, ":", err)
+			result.Failed++
+			continue
+		}
+
+		settled, err := settleRefund(gateway, p, r)
+		if err != nil {
+			// Left pending: try again next run
+			logs.Warn("Reconcile: could not settle refund", r.Id, ":", err)
+			result.Failed++
+			continue
+		}
+		if settled.Status != models.RefundPending {
+			result.Updated++
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,95 @@
+package payment
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"badminton-reservation-api/models"
+
+	"github.com/beego/beego/v2/client/orm"
+)
+
+// expirePaymentAt moves the checkout expiry of a stored payment
+func expirePaymentAt(t *testing.T, p *models.Payment, at time.Time) {
+	t.Helper()
+	if _, err := orm.NewOrm().Raw("UPDATE payments SET expired_at = ? WHERE id = ?", at, p.Id).Exec(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReconcilePendingPayments(t *testing.T) {
+	requireDB(t)
+	midtransGateway(t)
+	t.Setenv("RECONCILE_AFTER", "1ns")
+	t.Setenv("RECONCILE_EXPIRY_GRACE", "5m")
+
+	settledRes, settled := testPayment(t, "midtrans", models.ReservationWaitingPayment, models.PaymentPending, "PAID-")
+	lostRes, lost := testPayment(t, "midtrans", models.ReservationWaitingPayment, models.PaymentPending, "")
+	expirePaymentAt(t, lost, time.Now().Add(-10*time.Minute))
+	graceRes, grace := testPayment(t, "midtrans", models.ReservationWaitingPayment, models.PaymentPending, "")
+	expirePaymentAt(t, grace, time.Now().Add(-time.Minute))
+	waitingRes, waiting := testPayment(t, "midtrans", models.ReservationWaitingPayment, models.PaymentPending, "PENDING-")
+	expirePaymentAt(t, waiting, time.Now().Add(-10*time.Minute))
+
+	result, err := ReconcilePendingPayments(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Pending payments left by other tests are reconciled too, so only lower bounds hold
+	if result.Checked < 4 || result.Updated < 1 || result.Expired < 2 {
+		t.Errorf("result %+v, want at least 4 checked, 1 updated and 2 expired", result)
+	}
+
+	tests := []struct {
+		name                         string
+		reservation                  *models.Reservation
+		payment                      *models.Payment
+		wantPayment, wantReservation string
+	}{
+		{"settled at the gateway", settledRes, settled, models.PaymentSuccess, models.ReservationPaid},
+		{"unknown at the gateway past expiry and grace", lostRes, lost, models.PaymentExpired, models.ReservationExpired},
+		{"unknown at the gateway inside the grace period", graceRes, grace, models.PaymentPending, models.ReservationWaitingPayment},
+		{"still pending at the gateway past expiry and grace", waitingRes, waiting, models.PaymentExpired, models.ReservationExpired},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assertPaymentState(t, tt.payment, tt.reservation, tt.wantPayment, tt.wantReservation)
+		})
+	}
+}
+
+func TestReconcileUnreachableGateway(t *testing.T) {
+	requireDB(t)
+	midtransGateway(t)
+	t.Setenv("RECONCILE_AFTER", "1ns")
+	t.Setenv("MIDTRANS_API_BASE_URL", "http://127.0.0.1:1")
+
+	r, p := testPayment(t, "midtrans", models.ReservationWaitingPayment, models.PaymentPending, "PAID-")
+	expirePaymentAt(t, p, time.Now().Add(-time.Hour))
+
+	result, err := ReconcilePendingPayments(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Failed < 1 {
+		t.Errorf("result %+v, want the unreachable gateway counted as failed", result)
+	}
+	// Even a long expired checkout is not expired while the gateway cannot confirm it was not paid
+	assertPaymentState(t, p, r, models.PaymentPending, models.ReservationWaitingPayment)
+}
+
+func assertPaymentState(t *testing.T, p *models.Payment, r *models.Reservation, wantPayment, wantReservation string) {
+	t.Helper()
+	stored, err := models.GetPaymentById(p.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reservation, err := models.GetReservationById(r.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.Status != wantPayment || reservation.Status != wantReservation {
+		t.Errorf("payment %s and reservation %s, want %s and %s", stored.Status, reservation.Status, wantPayment, wantReservation)
+	}
+}
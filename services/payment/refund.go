@@ -0,0 +1,91 @@
+package payment
+
+import (
+	"badminton-reservation-api/models"
+	"errors"
+
+	"github.com/beego/beego/v2/client/orm"
+	"github.com/google/uuid"
+)
+
+// IssueRefund refunds amount of a settled payment through the gateway that took it and
+// records the outcome. reservationId is the reservation being refunded, which for a prepaid
+// series payment is the cancelled occurrence; empty means the payment's own reservation. The refund is stored as pending before calling the gateway so the
+// refundable amount can never be exceeded by concurrent requests. A refund of the same
+// reservation and amount that is still pending is retried rather than started again: the
+// gateway is asked about it by its refund key and it is only sent again, under that key, when
+// the gateway never received it. When the gateway cannot be reached the refund stays pending
+// and is returned together with the error; ReconcilePendingRefunds settles it later.
+func IssueRefund(paymentRecord *models.Payment, reservationId string, amount models.Money, reason string) (*models.Refund, error) {
+	gateway, err := Get(paymentRecord.PaymentGateway)
+	if err != nil {
+		return nil, err
+	}
+	if reservationId == "" {
+		reservationId = paymentRecord.ReservationId
+	}
+
+	pending, err := models.GetPendingRefund(paymentRecord.Id, reservationId, amount)
+	if err == nil {
+		return settleRefund(gateway, paymentRecord, pending)
+	}
+	if !errors.Is(err, orm.ErrNoRows) {
+		return nil, err
+	}
+
+	refund := &models.Refund{
+		Id:            uuid.New().String(),
+		PaymentId:     paymentRecord.Id,
+		ReservationId: reservationId,
+		Amount:        amount,
+		Reason:        reason,
+		RefundKey:     "RF-" + uuid.New().String(),
+	}
+	if err := models.CreateRefund(refund); err != nil {
+		return nil, err
+	}
+	return sendRefund(gateway, paymentRecord, refund)
+}
+
+// settleRefund looks a pending refund up at the gateway and stores its outcome, sending it
+// again under the same refund key when the gateway never received it
+func settleRefund(gateway PaymentGateway, paymentRecord *models.Payment, refund *models.Refund) (*models.Refund, error) {
+	result, err := gateway.QueryRefund(paymentRecord, refund.RefundKey)
+	if errors.Is(err, ErrRefundNotFound) {
+		return sendRefund(gateway, paymentRecord, refund)
+	}
+	if err != nil {
+		return refund, err
+	}
+	return storeRefundResult(refund, result)
+}
+
+// sendRefund asks the gateway to pay out a pending refund and stores the answer. When the
+// gateway cannot be reached the error is kept on the refund, which stays pending.
+func sendRefund(gateway PaymentGateway, paymentRecord *models.Payment, refund *models.Refund) (*models.Refund, error) {
+	result, err := gateway.Refund(paymentRecord, refund.RefundKey, refund.Amount, refund.Reason)
+	if err != nil {
+		if rerr := models.RecordRefundAttempt(refund.Id, "", err.Error()); rerr != nil {
+			return nil, rerr
+		}
+		refund.GatewayResponse = err.Error()
+		return refund, err
+	}
+	return storeRefundResult(refund, result)
+}
+
+// storeRefundResult completes the refund with the gateway outcome, or records the answer when
+// the gateway accepted the refund but has not completed it yet
+func storeRefundResult(refund *models.Refund, result *RefundResult) (*models.Refund, error) {
+	if result.Status == models.RefundPending {
+		if err := models.RecordRefundAttempt(refund.Id, result.GatewayRefundId, result.Raw); err != nil {
+			return nil, err
+		}
+		if result.GatewayRefundId != "" {
+			refund.GatewayRefundId = result.GatewayRefundId
+		}
+		refund.GatewayResponse = result.Raw
+		return refund, nil
+	}
+	return models.CompleteRefund(refund.Id, result.Status, result.GatewayRefundId, result.Raw)
+}
@@ -0,0 +1,194 @@
+package payment
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"badminton-reservation-api/models"
+)
+
+// refundingMidtrans is a Midtrans fake that pays each refund key out once, as the gateway
+// does, lists the refunds in the transaction status, and can answer after the client gave up
+type refundingMidtrans struct {
+	mu      sync.Mutex
+	refunds []map[string]interface{}
+	delay   time.Duration
+}
+
+func (f *refundingMidtrans) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if len(parts) != 3 {
+		writeJSON(w, 404, map[string]string{"status_code": "404"})
+		return
+	}
+	f.mu.Lock()
+	switch parts[2] {
+	case "refund":
+		var body struct {
+			RefundKey string `json:"refund_key"`
+			Amount    int64  `json:"amount"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		if f.find(body.RefundKey) == nil {
+			f.refunds = append(f.refunds, map[string]interface{}{"refund_chargeback_id": len(f.refunds) + 1, "refund_amount": body.Amount, "refund_key": body.RefundKey})
+		}
+		refund, delay := f.find(body.RefundKey), f.delay
+		f.mu.Unlock()
+		time.Sleep(delay)
+		writeJSON(w, 200, map[string]interface{}{"status_code": "200", "status_message": "Success, refund request is approved", "refund_chargeback_id": refund["refund_chargeback_id"], "refund_key": body.RefundKey})
+	case "status":
+		refunds := append([]map[string]interface{}(nil), f.refunds...)
+		f.mu.Unlock()
+		writeJSON(w, 200, map[string]interface{}{"status_code": "200", "order_id": parts[1], "transaction_status": "settlement", "refunds": refunds})
+	default:
+		f.mu.Unlock()
+		writeJSON(w, 404, map[string]string{"status_code": "404"})
+	}
+}
+
+func (f *refundingMidtrans) find(key string) map[string]interface{} {
+	for _, r := range f.refunds {
+		if r["refund_key"] == key {
+			return r
+		}
+	}
+	return nil
+}
+
+// payouts is the number of refunds the fake paid out
+func (f *refundingMidtrans) payouts() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.refunds)
+}
+
+func (f *refundingMidtrans) setDelay(d time.Duration) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.delay = d
+}
+
+// startRefundingMidtrans points the midtrans gateway at a refundingMidtrans, returned with its
+// URL, and gives up on gateway calls after 100ms
+func startRefundingMidtrans(t *testing.T) (*refundingMidtrans, string) {
+	t.Helper()
+	fake := &refundingMidtrans{}
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+	t.Setenv("MIDTRANS_MOCK", "false")
+	t.Setenv("MIDTRANS_SERVER_KEY", midtransKey)
+	t.Setenv("MIDTRANS_API_BASE_URL", server.URL)
+
+	client := httpClient
+	httpClient = &http.Client{Timeout: 100 * time.Millisecond}
+	t.Cleanup(func() { httpClient = client })
+	return fake, server.URL
+}
+
+func TestIssueRefundRetryAfterTimeout(t *testing.T) {
+	requireDB(t)
+	fake, _ := startRefundingMidtrans(t)
+	_, p := testPayment(t, "midtrans", models.ReservationPaid, models.PaymentSuccess, "")
+
+	// The gateway pays the refund out but answers after the client timed out
+	fake.setDelay(time.Second)
+	refund, err := IssueRefund(p, "", models.Whole(50000), "rain")
+	if err == nil {
+		t.Fatal("timed out refund returned no error")
+	}
+	if refund == nil || refund.Status != models.RefundPending {
+		t.Fatalf("timed out refund is %+v, want pending", refund)
+	}
+	if refundable, err := models.RefundableAmount(p.Id); err != nil || refundable.Amount != models.Whole(100000).Amount {
+		t.Errorf("refundable after a pending refund is %s (%v), want 100000", refundable, err)
+	}
+
+	fake.setDelay(0)
+	retry, err := IssueRefund(p, "", models.Whole(50000), "rain")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if retry.Id != refund.Id || retry.RefundKey != refund.RefundKey {
+		t.Errorf("retry created refund %s (%s), want %s (%s)", retry.Id, retry.RefundKey, refund.Id, refund.RefundKey)
+	}
+	if retry.Status != models.RefundSucceeded {
+		t.Errorf("retried refund is %s, want succeeded", retry.Status)
+	}
+	if n := fake.payouts(); n != 1 {
+		t.Errorf("gateway paid out %d refunds, want 1", n)
+	}
+
+	stored, err := models.GetPaymentById(p.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.Status != models.PaymentPartiallyRefunded || stored.RefundedAmount.Amount != models.Whole(50000).Amount {
+		t.Errorf("payment is %s with %s refunded, want partially_refunded with 50000", stored.Status, stored.RefundedAmount)
+	}
+}
+
+func TestReconcilePendingRefunds(t *testing.T) {
+	requireDB(t)
+	fake, url := startRefundingMidtrans(t)
+	_, p := testPayment(t, "midtrans", models.ReservationPaid, models.PaymentSuccess, "")
+	t.Setenv("RECONCILE_AFTER", "1ns")
+
+	// Never reached the gateway
+	t.Setenv("MIDTRANS_API_BASE_URL", "http://127.0.0.1:1")
+	unsent, err := IssueRefund(p, "", models.Whole(30000), "rain")
+	if err == nil || unsent.Status != models.RefundPending {
+		t.Fatalf("unreachable gateway: refund %+v, error %v", unsent, err)
+	}
+	if _, err := ReconcilePendingRefunds(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if still := refundStatus(t, p.Id, unsent.Id); still != models.RefundPending {
+		t.Errorf("refund is %s while the gateway is still down, want pending", still)
+	}
+
+	// Reached the gateway, which answered too late
+	fake.setDelay(time.Second)
+	t.Setenv("MIDTRANS_API_BASE_URL", url)
+	late, err := IssueRefund(p, "", models.Whole(20000), "rain")
+	if err == nil || late.Status != models.RefundPending {
+		t.Fatalf("timed out refund %+v, error %v", late, err)
+	}
+
+	fake.setDelay(0)
+	result, err := ReconcilePendingRefunds(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Updated < 2 {
+		t.Errorf("reconciler completed %d refunds, want at least 2", result.Updated)
+	}
+	for _, id := range []string{unsent.Id, late.Id} {
+		if status := refundStatus(t, p.Id, id); status != models.RefundSucceeded {
+			t.Errorf("refund %s is %s, want succeeded", id, status)
+		}
+	}
+	if n := fake.payouts(); n != 2 {
+		t.Errorf("gateway paid out %d refunds, want 2", n)
+	}
+}
+
+func refundStatus(t *testing.T, paymentId, refundId string) string {
+	t.Helper()
+	refunds, err := models.GetRefundsByPaymentId(paymentId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, r := range refunds {
+		if r.Id == refundId {
+			return r.Status
+		}
+	}
+	t.Fatalf("refund %s not found", refundId)
+	return ""
+}
@@ -0,0 +1,294 @@
+package payment
+
+import (
+	"badminton-reservation-api/models"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// xenditAPIURL is the Xendit API base URL; XENDIT_API_BASE_URL overrides it (e.g. a local fake)
+const xenditAPIURL = "https://api.xendit.co"
+
+// XenditService takes payments through Xendit invoices (hosted checkout pages)
+type XenditService struct {
+	SecretKey     string
+	CallbackToken string
+	BaseURL       string
+}
+
+func init() {
+	Register("xendit", func() PaymentGateway { return NewXenditService() })
+}
+
+// NewXenditService creates a Xendit gateway from XENDIT_SECRET_KEY, XENDIT_CALLBACK_TOKEN
+// and XENDIT_API_BASE_URL
+func NewXenditService() *XenditService {
+	base := os.Getenv("XENDIT_API_BASE_URL")
+	if base == "" {
+		base = xenditAPIURL
+	}
+	return &XenditService{
+		SecretKey:     os.Getenv("XENDIT_SECRET_KEY"),
+		CallbackToken: os.Getenv("XENDIT_CALLBACK_TOKEN"),
+		BaseURL:       strings.TrimRight(base, "/"),
+	}
+}
+
+// Name implements PaymentGateway
+func (s *XenditService) Name() string {
+	return "xendit"
+}
+
+// xenditInvoice is the subset of the Xendit invoice object we use
+type xenditInvoice struct {
+	Id         string      `json:"id"`
+	ExternalId string      `json:"external_id"`
+	Status     string      `json:"status"`
+	Amount     json.Number `json:"amount"`
+	Currency   string      `json:"currency"`
+	InvoiceUrl string      `json:"invoice_url"`
+	ErrorCode  string      `json:"error_code"`
+	Message    string      `json:"message"`
+}
+
+// call performs an API request authenticated with the secret key
+func (s *XenditService) call(method string, endpoint string, header http.Header, body interface{}, out interface{}) ([]byte, error) {
+	if header == nil {
+		header = http.Header{}
+	}
+	header.Set("Authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte(s.SecretKey+":")))
+	raw, err := doJSON(method, s.BaseURL+endpoint, header, body, out)
+	if err != nil {
+		return raw, fmt.Errorf("xendit: %w", err)
+	}
+	return raw, nil
+}
+
+// CreateTransaction implements PaymentGateway by creating an invoice that expires with the reservation
+func (s *XenditService) CreateTransaction(reservation *models.Reservation, payment *models.Payment) (*Transaction, error) {
+	orderId := fmt.Sprintf("RES-%s-%d", reservation.Id[:8], time.Now().Unix())
+
+	if os.Getenv("XENDIT_MOCK") == "true" {
+		payment.OrderId = orderId
+		payment.TransactionId = "mock-invoice-" + reservation.Id[:8]
+		payment.PaymentUrl = fmt.Sprintf("https://mock-pay.example.com/invoice/%s", orderId)
+		return &Transaction{RedirectUrl: payment.PaymentUrl}, nil
+	}
+
+	total, err := reservation.TotalPrice.WholeUnits()
+	if err != nil {
+		return nil, err
+	}
+	lines, err := describeItems(reservation)
+	if err != nil {
+		return nil, err
+	}
+	items := make([]map[string]interface{}, 0)
+	fees := make([]map[string]interface{}, 0)
+	for _, line := range lines {
+		// Invoice items must be positive; discounts go in as negative fees
+		if line.Discount {
+			fees = append(fees, map[string]interface{}{"type": line.Name, "value": line.Price})
+			continue
+		}
+		items = append(items, map[string]interface{}{
+			"name":         line.Name,
+			"quantity":     1,
+			"price":        line.Price,
+			"reference_id": line.Id,
+		})
+	}
+
+	duration := int64(time.Until(payment.ExpiredAt).Seconds())
+	if duration < 60 {
+		duration = 60
+	}
+
+	req := map[string]interface{}{
+		"external_id":          orderId,
+		"amount":               total,
+		"payer_email":          reservation.CustomerEmail,
+		"description":          fmt.Sprintf("Court booking %s", reservation.BookingDate),
+		"invoice_duration":     duration,
+		"currency":             reservation.TotalPrice.CurrencyCode(),
+		"success_redirect_url": os.Getenv("APP_URL") + "/payment/finish",
+		"customer": map[string]interface{}{
+			"given_names":   reservation.CustomerName,
+			"email":         reservation.CustomerEmail,
+			"mobile_number": reservation.CustomerPhone,
+		},
+		"items": items,
+	}
+	if len(fees) > 0 {
+		req["fees"] = fees
+	}
+
+	var invoice xenditInvoice
+	if _, err := s.call(http.MethodPost, "/v2/invoices", nil, req, &invoice); err != nil {
+		return nil, err
+	}
+	if invoice.Id == "" {
+		return nil, fmt.Errorf("xendit: invoice not created: %s %s", invoice.ErrorCode, invoice.Message)
+	}
+
+	payment.OrderId = orderId
+	payment.TransactionId = invoice.Id
+	payment.PaymentUrl = invoice.InvoiceUrl
+	return &Transaction{RedirectUrl: invoice.InvoiceUrl}, nil
+}
+
+// xenditStatus maps a Xendit invoice status to a payment status
+func xenditStatus(status string) string {
+	switch strings.ToUpper(status) {
+	case "PAID", "SETTLED":
+		return models.PaymentSuccess
+	case "EXPIRED":
+		return models.PaymentExpired
+	default:
+		return models.PaymentPending
+	}
+}
+
+// VerifyNotification implements PaymentGateway. Xendit authenticates invoice callbacks with
+// the X-Callback-Token header, which must match XENDIT_CALLBACK_TOKEN.
+func (s *XenditService) VerifyNotification(header http.Header, payload map[string]interface{}) (*StatusUpdate, error) {
+	if s.CallbackToken == "" {
+		return nil, fmt.Errorf("XENDIT_CALLBACK_TOKEN is not configured")
+	}
+	token := header.Get("X-Callback-Token")
+	if subtle.ConstantTimeCompare([]byte(token), []byte(s.CallbackToken)) != 1 {
+		return nil, fmt.Errorf("invalid callback token")
+	}
+
+	raw, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+	var invoice xenditInvoice
+	if err := json.Unmarshal(raw, &invoice); err != nil {
+		return nil, err
+	}
+	if invoice.ExternalId == "" {
+		return nil, fmt.Errorf("callback has no external_id")
+	}
+	return &StatusUpdate{
+		OrderId:       invoice.ExternalId,
+		TransactionId: invoice.Id,
+		Status:        xenditStatus(invoice.Status),
+		GatewayStatus: invoice.Status,
+		Amount:        invoice.Amount.String(),
+		Currency:      invoice.Currency,
+		Raw:           payload,
+	}, nil
+}
+
+// QueryStatus implements PaymentGateway by looking the invoice up by external_id
+func (s *XenditService) QueryStatus(orderId string) (*StatusUpdate, error) {
+	var invoices []xenditInvoice
+	raw, err := s.call(http.MethodGet, "/v2/invoices?external_id="+url.QueryEscape(orderId), nil, nil, &invoices)
+	if err != nil {
+		return nil, err
+	}
+	if len(invoices) == 0 {
+		return nil, fmt.Errorf("xendit: invoice %s: %w", orderId, ErrTransactionNotFound)
+	}
+	invoice := invoices[len(invoices)-1]
+
+	var payload map[string]interface{}
+	var list []map[string]interface{}
+	if json.Unmarshal(raw, &list) == nil && len(list) > 0 {
+		payload = list[len(list)-1]
+	}
+	return &StatusUpdate{
+		OrderId:       orderId,
+		TransactionId: invoice.Id,
+		Status:        xenditStatus(invoice.Status),
+		GatewayStatus: invoice.Status,
+		Amount:        invoice.Amount.String(),
+		Currency:      invoice.Currency,
+		Raw:           payload,
+	}, nil
+}
+
+// xenditRefund is the Xendit refund object
+type xenditRefund struct {
+	Id          string `json:"id"`
+	ReferenceId string `json:"reference_id"`
+	Status      string `json:"status"`
+	FailureCode string `json:"failure_code"`
+	ErrorCode   string `json:"error_code"`
+	Message     string `json:"message"`
+}
+
+// Refund implements PaymentGateway through the Refunds API against the paid invoice.
+// The refund key is sent as both reference_id and Idempotency-key.
+func (s *XenditService) Refund(payment *models.Payment, refundKey string, amount models.Money, reason string) (*RefundResult, error) {
+	if os.Getenv("XENDIT_MOCK") == "true" {
+		return &RefundResult{Status: models.RefundSucceeded, GatewayRefundId: "mock-refund-" + refundKey, Raw: "{}"}, nil
+	}
+
+	refundAmount, err := amount.WholeUnits()
+	if err != nil {
+		return nil, err
+	}
+	header := http.Header{}
+	header.Set("Idempotency-key", refundKey)
+	var resp xenditRefund
+	raw, err := s.call(http.MethodPost, "/refunds", header, map[string]interface{}{
+		"invoice_id":   payment.TransactionId,
+		"reference_id": refundKey,
+		"amount":       refundAmount,
+		"reason":       "REQUESTED_BY_CUSTOMER",
+		"metadata":     map[string]string{"reason": reason},
+	}, &resp)
+	if err != nil {
+		return nil, err
+	}
+	return xenditRefundResult(resp, raw), nil
+}
+
+// QueryRefund implements PaymentGateway by listing the refunds of the paid invoice and
+// matching the refund key sent as reference_id
+func (s *XenditService) QueryRefund(payment *models.Payment, refundKey string) (*RefundResult, error) {
+	if os.Getenv("XENDIT_MOCK") == "true" {
+		return nil, fmt.Errorf("xendit: refund %s: %w", refundKey, ErrRefundNotFound)
+	}
+
+	var resp struct {
+		Data []json.RawMessage `json:"data"`
+	}
+	if _, err := s.call(http.MethodGet, "/refunds?invoice_id="+url.QueryEscape(payment.TransactionId), nil, nil, &resp); err != nil {
+		return nil, err
+	}
+	for _, raw := range resp.Data {
+		var refund xenditRefund
+		if json.Unmarshal(raw, &refund) == nil && refund.ReferenceId == refundKey {
+			return xenditRefundResult(refund, raw), nil
+		}
+	}
+	return nil, fmt.Errorf("xendit: refund %s: %w", refundKey, ErrRefundNotFound)
+}
+
+// xenditRefundResult maps a Xendit refund object to the refund outcome
+func xenditRefundResult(resp xenditRefund, raw []byte) *RefundResult {
+	result := &RefundResult{GatewayRefundId: resp.Id, Message: resp.Message, Raw: string(raw)}
+	switch strings.ToUpper(resp.Status) {
+	case "SUCCEEDED":
+		result.Status = models.RefundSucceeded
+	case "PENDING":
+		result.Status = models.RefundPending
+	default:
+		result.Status = models.RefundFailed
+		if result.Message == "" {
+			result.Message = resp.FailureCode
+		}
+	}
+	return result
+}
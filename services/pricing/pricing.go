@@ -0,0 +1,193 @@
+// Package pricing computes slot prices from a court's base price and the pricing rules.
+// The calculator is pure: callers load the rules (models.GetActivePricingRules) once and pass them in.
+package pricing
+
+import (
+	"badminton-reservation-api/models"
+	"badminton-reservation-api/utils"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Slot is one bookable court/timeslot on a date
+type Slot struct {
+	CourtId     int
+	BasePrice   models.Money
+	BookingDate string // YYYY-MM-DD
+	StartTime   string // HH:MM:SS
+}
+
+// Quote is the price of a slot and the rule that set it (RuleId 0 when the base price applies)
+type Quote struct {
+	BasePrice models.Money `json:"base_price"`
+	Price     models.Money `json:"price"`
+	RuleId    int          `json:"rule_id,omitempty"`
+	RuleName  string       `json:"rule_name,omitempty"`
+}
+
+// Calculate prices slot with the first matching rule in priority order (highest priority,
+// then lowest id). rules need not be sorted. Adjusted prices are rounded to whole currency
+// units because the gateways bill whole rupiah. It fails only when a rule amount is in a
+// different currency than the base price.
+func Calculate(slot Slot, rules []*models.PricingRule) (Quote, error) {
+	quote := Quote{BasePrice: slot.BasePrice, Price: slot.BasePrice}
+	date, err := time.Parse("2006-01-02", slot.BookingDate)
+	if err != nil {
+		return quote, nil
+	}
+
+	var best *models.PricingRule
+	for _, r := range rules {
+		if !r.IsActive || !matches(r, slot, date.Weekday()) {
+			continue
+		}
+		if best == nil || r.Priority > best.Priority || (r.Priority == best.Priority && r.Id < best.Id) {
+			best = r
+		}
+	}
+	if best == nil {
+		return quote, nil
+	}
+
+	quote.RuleId = best.Id
+	quote.RuleName = best.Name
+	switch best.Adjustment {
+	case models.PricingFixed:
+		quote.Price = best.Amount
+	case models.PricingMultiplier:
+		quote.Price = slot.BasePrice.Mul(best.Multiplier)
+	case models.PricingSurcharge:
+		if quote.Price, err = slot.BasePrice.Add(best.Amount); err != nil {
+			return quote, fmt.Errorf("pricing rule %d: %w", best.Id, err)
+		}
+	}
+	quote.Price = quote.Price.RoundWhole()
+	return quote, nil
+}
+
+// matches reports whether r applies to slot. Zero-padded HH:MM:SS and YYYY-MM-DD strings
+// compare in time order.
+func matches(r *models.PricingRule, slot Slot, weekday time.Weekday) bool {
+	if r.CourtId != nil && *r.CourtId != slot.CourtId {
+		return false
+	}
+	if r.Weekdays != "" {
+		days, err := ParseWeekdays(r.Weekdays)
+		if err != nil || !days[weekday] {
+			return false
+		}
+	}
+	if r.StartTime != "" && slot.StartTime < r.StartTime {
+		return false
+	}
+	if r.EndTime != "" && slot.StartTime >= r.EndTime {
+		return false
+	}
+	if r.StartDate != "" && slot.BookingDate < r.StartDate {
+		return false
+	}
+	if r.EndDate != "" && slot.BookingDate > r.EndDate {
+		return false
+	}
+	return true
+}
+
+// ParseWeekdays parses a comma-separated weekday list (0 = Sunday .. 6 = Saturday)
+func ParseWeekdays(s string) (map[time.Weekday]bool, error) {
+	days := map[time.Weekday]bool{}
+	for _, part := range strings.Split(s, ",") {
+		d, err := strconv.Atoi(strings.TrimSpace(part))
+		if err != nil || d < 0 || d > 6 {
+			return nil, fmt.Errorf("invalid weekday %q: use 0 (Sunday) to 6 (Saturday)", strings.TrimSpace(part))
+		}
+		days[time.Weekday(d)] = true
+	}
+	return days, nil
+}
+
+// NormalizeWeekdays returns the weekday list sorted and without duplicates, e.g. "6, 0" → "0,6"
+func NormalizeWeekdays(s string) (string, error) {
+	if strings.TrimSpace(s) == "" {
+		return "", nil
+	}
+	days, err := ParseWeekdays(s)
+	if err != nil {
+		return "", err
+	}
+	list := make([]int, 0, len(days))
+	for d := range days {
+		list = append(list, int(d))
+	}
+	sort.Ints(list)
+	parts := make([]string, len(list))
+	for i, d := range list {
+		parts[i] = strconv.Itoa(d)
+	}
+	return strings.Join(parts, ","), nil
+}
+
+// Validate checks a rule before it is saved and normalizes its weekday list
+func Validate(r *models.PricingRule) error {
+	if strings.TrimSpace(r.Name) == "" {
+		return errors.New("name is required")
+	}
+	if !models.IsValidPricingAdjustment(r.Adjustment) {
+		return errors.New("adjustment must be fixed, multiplier or surcharge")
+	}
+	if r.Amount.Amount < 0 || r.Multiplier < 0 {
+		return errors.New("amount and multiplier must not be negative")
+	}
+	// Only the column the adjustment uses is kept, so a stale one cannot leak into prices
+	if r.Adjustment == models.PricingMultiplier {
+		r.Amount = models.Money{}
+	} else {
+		r.Multiplier = 1
+	}
+	weekdays, err := NormalizeWeekdays(r.Weekdays)
+	if err != nil {
+		return err
+	}
+	r.Weekdays = weekdays
+	for _, t := range []string{r.StartTime, r.EndTime} {
+		if t != "" && !utils.ValidateTime(t) {
+			return errors.New("start_time and end_time must use HH:MM:SS format")
+		}
+	}
+	if r.StartTime != "" && r.EndTime != "" && r.EndTime <= r.StartTime {
+		return errors.New("end_time must be after start_time")
+	}
+	for _, d := range []string{r.StartDate, r.EndDate} {
+		if d != "" && !utils.ValidateDate(d) {
+			return errors.New("start_date and end_date must use YYYY-MM-DD format")
+		}
+	}
+	if r.StartDate != "" && r.EndDate != "" && r.EndDate < r.StartDate {
+		return errors.New("end_date must not be before start_date")
+	}
+	return nil
+}
+
+// PriceGrid sets the price of every slot in an availability grid
+func PriceGrid(grid *models.AvailabilityGrid, rules []*models.PricingRule) error {
+	for _, court := range grid.Courts {
+		for _, date := range court.Dates {
+			for _, s := range date.Slots {
+				quote, err := Calculate(Slot{
+					CourtId:     court.Id,
+					BasePrice:   court.PricePerHour,
+					BookingDate: date.Date,
+					StartTime:   s.StartTime,
+				}, rules)
+				if err != nil {
+					return err
+				}
+				s.Price = quote.Price
+			}
+		}
+	}
+	return nil
+}
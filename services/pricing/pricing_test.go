@@ -0,0 +1,74 @@
+package pricing
+
+import (
+	"badminton-reservation-api/models"
+	"errors"
+	"testing"
+)
+
+func TestCalculate(t *testing.T) {
+	t.Setenv("CURRENCY", "IDR")
+	fixed, err := models.ParseMoney("99999.99", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	surcharge, err := models.ParseMoney("20000.5", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rules := []*models.PricingRule{
+		{Id: 1, Name: "peak", Adjustment: models.PricingMultiplier, Multiplier: 1.15, StartTime: "18:00:00", Priority: 1, IsActive: true},
+		{Id: 2, Name: "weekend", Adjustment: models.PricingSurcharge, Amount: surcharge, Weekdays: "0,6", Priority: 2, IsActive: true},
+		{Id: 3, Name: "holiday", Adjustment: models.PricingFixed, Amount: fixed, StartDate: "2030-01-01", EndDate: "2030-01-01", Priority: 3, IsActive: true},
+	}
+
+	tests := []struct {
+		name      string
+		date      string
+		startTime string
+		want      string
+		rule      int
+	}{
+		{"base price without a rule", "2030-01-02", "08:00:00", "50001.00", 0},
+		{"multiplier 50001 × 1.15 = 57501.15 rounds to 57501", "2030-01-02", "19:00:00", "57501.00", 1},
+		{"surcharge 50001 + 20000.5 rounds half up to 70002", "2030-01-05", "08:00:00", "70002.00", 2},
+		{"fixed 99999.99 rounds to 100000", "2030-01-01", "08:00:00", "100000.00", 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			quote, err := Calculate(Slot{CourtId: 1, BasePrice: models.Whole(50001), BookingDate: tt.date, StartTime: tt.startTime}, rules)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if quote.Price.String() != tt.want || quote.RuleId != tt.rule {
+				t.Errorf("price %s by rule %d, want %s by rule %d", quote.Price, quote.RuleId, tt.want, tt.rule)
+			}
+		})
+	}
+
+	t.Run("surcharge in another currency", func(t *testing.T) {
+		rules := []*models.PricingRule{{Id: 1, Adjustment: models.PricingSurcharge, Amount: models.NewMoney(100, "USD"), IsActive: true}}
+		_, err := Calculate(Slot{CourtId: 1, BasePrice: models.Whole(50000), BookingDate: "2030-01-02", StartTime: "08:00:00"}, rules)
+		if !errors.Is(err, models.ErrCurrencyMismatch) {
+			t.Errorf("got %v, want ErrCurrencyMismatch", err)
+		}
+	})
+}
+
+func TestValidateKeepsOnlyTheUsedColumn(t *testing.T) {
+	r := &models.PricingRule{Name: "peak", Adjustment: models.PricingMultiplier, Multiplier: 1.5, Amount: models.Whole(10000)}
+	if err := Validate(r); err != nil {
+		t.Fatal(err)
+	}
+	if !r.Amount.IsZero() || r.Multiplier != 1.5 {
+		t.Errorf("multiplier rule kept amount %s, multiplier %v", r.Amount, r.Multiplier)
+	}
+
+	r = &models.PricingRule{Name: "weekend", Adjustment: models.PricingSurcharge, Multiplier: 1.5, Amount: models.Whole(10000)}
+	if err := Validate(r); err != nil {
+		t.Fatal(err)
+	}
+	if r.Amount.String() != "10000.00" || r.Multiplier != 1 {
+		t.Errorf("surcharge rule kept amount %s, multiplier %v", r.Amount, r.Multiplier)
+	}
+}
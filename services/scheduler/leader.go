@@ -0,0 +1,61 @@
+package scheduler
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/beego/beego/v2/core/logs"
+)
+
+// schedulerLockId is the Postgres advisory lock held by the instance that runs the jobs
+const schedulerLockId = 72602
+
+// leaderLock elects one leader among the instances sharing a database. The leader holds a
+// session advisory lock on a connection of its own; Postgres releases the lock when that
+// connection ends, so a crashed leader is replaced at the next check of another instance.
+type leaderLock struct {
+	db   *sql.DB
+	conn *sql.Conn
+}
+
+// hold reports whether this instance is the leader, trying to become leader if it is not
+func (l *leaderLock) hold(ctx context.Context) bool {
+	if l.conn != nil {
+		var one int
+		err := l.conn.QueryRowContext(ctx, "SELECT 1").Scan(&one)
+		if err == nil {
+			return true
+		}
+		logs.Warn("Scheduler lost its lock connection, giving up leadership:", err)
+		l.conn.Close()
+		l.conn = nil
+	}
+
+	conn, err := l.db.Conn(ctx)
+	if err != nil {
+		logs.Error("Scheduler could not get a connection for its lock:", err)
+		return false
+	}
+	var locked bool
+	if err := conn.QueryRowContext(ctx, "SELECT pg_try_advisory_lock($1)", schedulerLockId).Scan(&locked); err != nil || !locked {
+		if err != nil {
+			logs.Error("Scheduler could not try its lock:", err)
+		}
+		conn.Close()
+		return false
+	}
+	l.conn = conn
+	return true
+}
+
+// release gives up leadership
+func (l *leaderLock) release() {
+	if l.conn == nil {
+		return
+	}
+	if _, err := l.conn.ExecContext(context.Background(), "SELECT pg_advisory_unlock($1)", schedulerLockId); err != nil {
+		logs.Warn("Scheduler could not release its lock:", err)
+	}
+	l.conn.Close()
+	l.conn = nil
+}
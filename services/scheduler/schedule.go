@@ -0,0 +1,175 @@
+package scheduler
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schedule decides when a job runs next
+type Schedule interface {
+	// Next returns the first run time after t, or the zero time if there is none
+	Next(t time.Time) time.Time
+	String() string
+}
+
+// Every runs a job at a fixed interval
+func Every(d time.Duration) Schedule {
+	return interval(d)
+}
+
+type interval time.Duration
+
+func (i interval) Next(t time.Time) time.Time {
+	return t.Add(time.Duration(i))
+}
+
+func (i interval) String() string {
+	return "@every " + time.Duration(i).String()
+}
+
+// cronDescriptors are the shorthand cron specs
+var cronDescriptors = map[string]string{
+	"@hourly":   "0 * * * *",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@weekly":   "0 0 * * 0",
+	"@monthly":  "0 0 1 * *",
+}
+
+// ParseSchedule parses a job schedule: a Go duration ("5m") or "@every 5m" for a fixed
+// interval, a five-field cron expression ("*/10 6-22 * * 1-5": minute, hour, day of month,
+// month, day of week with 0 = Sunday), or one of @hourly, @daily, @weekly and @monthly.
+// Cron schedules use the server's local time zone.
+func ParseSchedule(spec string) (Schedule, error) {
+	spec = strings.TrimSpace(spec)
+	if strings.HasPrefix(spec, "@every ") || !strings.ContainsAny(spec, " *@") {
+		d, err := time.ParseDuration(strings.TrimSpace(strings.TrimPrefix(spec, "@every ")))
+		if err != nil || d < time.Second {
+			return nil, fmt.Errorf("invalid interval %q: use a duration of at least 1s, e.g. 5m", spec)
+		}
+		return Every(d), nil
+	}
+	if expr, ok := cronDescriptors[spec]; ok {
+		return parseCron(spec, expr)
+	}
+	return parseCron(spec, spec)
+}
+
+// cron is a parsed cron expression; each field is a bit set of the allowed values
+type cron struct {
+	spec                          string
+	minute, hour, dom, month, dow uint64
+	domRestricted, dowRestricted  bool
+}
+
+func parseCron(spec, expr string) (Schedule, error) {
+	fields := strings.Fields(expr)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("invalid cron expression %q: want 5 fields (minute hour day month weekday)", spec)
+	}
+	c := &cron{spec: spec}
+	var err error
+	for i, f := range []struct {
+		set      *uint64
+		min, max int
+	}{{&c.minute, 0, 59}, {&c.hour, 0, 23}, {&c.dom, 1, 31}, {&c.month, 1, 12}, {&c.dow, 0, 7}} {
+		if *f.set, err = parseCronField(fields[i], f.min, f.max); err != nil {
+			return nil, fmt.Errorf("invalid cron expression %q: %w", spec, err)
+		}
+	}
+	// 7 is Sunday too
+	if c.dow&(1<<7) != 0 {
+		c.dow |= 1
+	}
+	c.domRestricted = !strings.HasPrefix(fields[2], "*")
+	c.dowRestricted = !strings.HasPrefix(fields[4], "*")
+	return c, nil
+}
+
+// parseCronField parses a comma-separated list of *, n, a-b, each optionally with /step
+func parseCronField(field string, min, max int) (uint64, error) {
+	var set uint64
+	for _, part := range strings.Split(field, ",") {
+		step := 1
+		if slash := strings.Index(part, "/"); slash >= 0 {
+			n, err := strconv.Atoi(part[slash+1:])
+			if err != nil || n <= 0 {
+				return 0, fmt.Errorf("invalid step in %q", part)
+			}
+			step = n
+			part = part[:slash]
+		}
+
+		lo, hi := min, max
+		switch {
+		case part == "*":
+		case strings.Contains(part, "-"):
+			bounds := strings.SplitN(part, "-", 2)
+			a, errA := strconv.Atoi(bounds[0])
+			b, errB := strconv.Atoi(bounds[1])
+			if errA != nil || errB != nil {
+				return 0, fmt.Errorf("invalid range %q", part)
+			}
+			lo, hi = a, b
+		default:
+			n, err := strconv.Atoi(part)
+			if err != nil {
+				return 0, fmt.Errorf("invalid value %q", part)
+			}
+			lo = n
+			if step == 1 {
+				hi = n
+			}
+		}
+		if lo < min || hi > max || lo > hi {
+			return 0, fmt.Errorf("%q is outside %d-%d", part, min, max)
+		}
+		for v := lo; v <= hi; v += step {
+			set |= 1 << uint(v)
+		}
+	}
+	return set, nil
+}
+
+func (c *cron) has(set uint64, v int) bool {
+	return set&(1<<uint(v)) != 0
+}
+
+// dayMatches follows cron: when both day of month and day of week are restricted, either
+// may match
+func (c *cron) dayMatches(t time.Time) bool {
+	dom := c.has(c.dom, t.Day())
+	dow := c.has(c.dow, int(t.Weekday()))
+	if c.domRestricted && c.dowRestricted {
+		return dom || dow
+	}
+	return dom && dow
+}
+
+func (c *cron) Next(t time.Time) time.Time {
+	loc := t.Location()
+	t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), 0, 0, loc).Add(time.Minute)
+	// Expressions such as "0 0 30 2 *" never match; give up after five years
+	limit := t.AddDate(5, 0, 0)
+	for t.Before(limit) {
+		switch {
+		case !c.has(c.month, int(t.Month())):
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, loc)
+		case !c.dayMatches(t):
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, loc)
+		case !c.has(c.hour, t.Hour()):
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, loc)
+		case !c.has(c.minute, t.Minute()):
+			t = t.Add(time.Minute)
+		default:
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+func (c *cron) String() string {
+	return c.spec
+}
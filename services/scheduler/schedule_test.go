@@ -0,0 +1,60 @@
+package scheduler
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+var jakarta = time.FixedZone("WIB", 7*3600)
+
+func TestScheduleNext(t *testing.T) {
+	tests := []struct {
+		spec, from, want string
+	}{
+		{"*/15 * * * *", "2026-10-16 10:07", "2026-10-16 10:15"},
+		{"*/15 * * * *", "2026-10-16 10:45", "2026-10-16 11:00"},
+		{"0 9 * * 1-5", "2026-10-16 10:00", "2026-10-19 09:00"}, // Friday → Monday
+		{"30 2 * * 7", "2026-10-16 10:00", "2026-10-18 02:30"},  // 7 is Sunday
+		{"0 0 1 * *", "2026-10-16 10:00", "2026-11-01 00:00"},
+		{"0 0 13 * 5", "2026-10-10 00:00", "2026-10-13 00:00"}, // day of month or Friday
+		{"5,10 22-23 * 12 *", "2026-10-16 10:00", "2026-12-01 22:05"},
+		{"@daily", "2026-12-31 23:59", "2027-01-01 00:00"},
+		{"@hourly", "2026-10-16 10:00", "2026-10-16 11:00"},
+		{"5m", "2026-10-16 10:00", "2026-10-16 10:05"},
+		{"@every 90s", "2026-10-16 10:00", "2026-10-16 10:01"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.spec+" after "+tt.from, func(t *testing.T) {
+			s, err := ParseSchedule(tt.spec)
+			if err != nil {
+				t.Fatal(err)
+			}
+			from, err := time.ParseInLocation("2006-01-02 15:04", tt.from, jakarta)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := s.Next(from).Truncate(time.Minute).Format("2006-01-02 15:04"); got != tt.want {
+				t.Errorf("next run %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseScheduleRefusesBadSpecs(t *testing.T) {
+	for _, spec := range []string{"5x", "1ms", "* * *", "60 * * * *", "*/0 * * * *", "5-1 * * * *", "* * * * mon", "@yearly"} {
+		if _, err := ParseSchedule(spec); err == nil {
+			t.Errorf("%q was accepted", spec)
+		}
+	}
+}
+
+func TestRegisterRefusesScheduleThatNeverFires(t *testing.T) {
+	never, err := ParseSchedule("0 0 30 2 *")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := New(nil).Register("never", never, func(context.Context) error { return nil }); err == nil {
+		t.Error("schedule that never fires was accepted")
+	}
+}
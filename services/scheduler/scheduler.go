@@ -0,0 +1,404 @@
+// Package scheduler runs named background jobs on interval or cron schedules. When several
+// instances share a database only one of them, the holder of a Postgres advisory lock, runs
+// jobs; the others take over when it stops or loses its connection.
+package scheduler
+
+import (
+	"badminton-reservation-api/models"
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/beego/beego/v2/core/logs"
+)
+
+// JobFunc is the work of a job. ctx is cancelled when shutdown gives up waiting for it.
+type JobFunc func(ctx context.Context) error
+
+// JobStatus is a job and the outcome of its latest run
+type JobStatus struct {
+	Name           string     `json:"name"`
+	Schedule       string     `json:"schedule"`
+	Running        bool       `json:"running"`
+	LastStartedAt  *time.Time `json:"last_started_at"`
+	LastFinishedAt *time.Time `json:"last_finished_at"`
+	LastDurationMs int64      `json:"last_duration_ms"`
+	LastSuccessAt  *time.Time `json:"last_success_at"`
+	LastError      string     `json:"last_error,omitempty"`
+	LastErrorAt    *time.Time `json:"last_error_at,omitempty"`
+	NextRunAt      *time.Time `json:"next_run_at"`
+	RunCount       int64      `json:"run_count"`
+	FailureCount   int64      `json:"failure_count"`
+	RunBy          string     `json:"run_by"`
+}
+
+type job struct {
+	schedule Schedule
+	run      JobFunc
+	next     time.Time
+	status   JobStatus
+}
+
+// Scheduler runs registered jobs while it is the leader. With a database, leadership goes
+// through an advisory lock and every run is recorded in scheduled_jobs so any instance can
+// report it; without one (a single instance, or tools) it always leads and keeps status in
+// memory.
+type Scheduler struct {
+	lock        *leaderLock
+	instance    string
+	leaderCheck time.Duration
+	tick        time.Duration
+
+	mu      sync.Mutex
+	jobs    []*job
+	leader  bool
+	started bool
+
+	stop       context.CancelFunc
+	cancelRuns context.CancelFunc
+	done       chan struct{}
+	runs       sync.WaitGroup
+}
+
+var (
+	currentMu sync.Mutex
+	current   *Scheduler
+)
+
+// Current returns the scheduler started by this process, or nil
+func Current() *Scheduler {
+	currentMu.Lock()
+	defer currentMu.Unlock()
+	return current
+}
+
+// New creates a scheduler. db may be nil to run without leader election and persistence.
+func New(db *sql.DB) *Scheduler {
+	host, _ := os.Hostname()
+	s := &Scheduler{
+		instance:    fmt.Sprintf("%s:%d", host, os.Getpid()),
+		leaderCheck: 15 * time.Second,
+		tick:        time.Second,
+	}
+	if db != nil {
+		s.lock = &leaderLock{db: db}
+	}
+	return s
+}
+
+// Register adds a job. Jobs must be registered before Start and names must be unique.
+func (s *Scheduler) Register(name string, schedule Schedule, run JobFunc) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.started {
+		return fmt.Errorf("job %s registered after the scheduler started", name)
+	}
+	for _, j := range s.jobs {
+		if j.status.Name == name {
+			return fmt.Errorf("job %s is already registered", name)
+		}
+	}
+	if schedule.Next(time.Now()).IsZero() {
+		return fmt.Errorf("schedule %s of job %s never fires", schedule, name)
+	}
+	s.jobs = append(s.jobs, &job{schedule: schedule, run: run, status: JobStatus{Name: name, Schedule: schedule.String()}})
+	return nil
+}
+
+// Instance identifies this process (host:pid) in job status
+func (s *Scheduler) Instance() string {
+	return s.instance
+}
+
+// Leader reports whether this instance currently runs the jobs
+func (s *Scheduler) Leader() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.leader
+}
+
+// Start begins running jobs in the background until Stop
+func (s *Scheduler) Start() {
+	s.mu.Lock()
+	if s.started {
+		s.mu.Unlock()
+		return
+	}
+	s.started = true
+	jobs := append([]*job(nil), s.jobs...)
+	s.mu.Unlock()
+
+	if s.lock != nil {
+		for _, j := range jobs {
+			if err := models.UpsertScheduledJob(j.status.Name, j.status.Schedule); err != nil {
+				logs.Error("Scheduler could not record job", j.status.Name, ":", err)
+			}
+		}
+	}
+
+	ctx, stop := context.WithCancel(context.Background())
+	runCtx, cancelRuns := context.WithCancel(context.Background())
+	s.stop, s.cancelRuns = stop, cancelRuns
+	s.done = make(chan struct{})
+	go s.loop(ctx, runCtx)
+
+	currentMu.Lock()
+	current = s
+	currentMu.Unlock()
+	logs.Info("Scheduler started with", len(jobs), "job(s) on instance", s.instance)
+}
+
+// Stop stops starting jobs, waits up to timeout for running ones and gives up leadership.
+// Jobs still running after timeout have their context cancelled and are reported in the
+// error.
+func (s *Scheduler) Stop(timeout time.Duration) error {
+	if s.stop == nil {
+		return nil
+	}
+	s.stop()
+	<-s.done
+
+	finished := make(chan struct{})
+	go func() {
+		s.runs.Wait()
+		close(finished)
+	}()
+	var err error
+	select {
+	case <-finished:
+	case <-time.After(timeout):
+		var running []string
+		for _, status := range s.localStatus() {
+			if status.Running {
+				running = append(running, status.Name)
+			}
+		}
+		err = fmt.Errorf("jobs still running after %s: %v", timeout, running)
+	}
+	s.cancelRuns()
+
+	if s.lock != nil {
+		s.lock.release()
+	}
+	s.mu.Lock()
+	s.leader = false
+	s.mu.Unlock()
+	return err
+}
+
+func (s *Scheduler) loop(ctx, runCtx context.Context) {
+	defer close(s.done)
+	ticker := time.NewTicker(s.tick)
+	defer ticker.Stop()
+
+	var nextCheck time.Time
+	for {
+		now := time.Now()
+		if !now.Before(nextCheck) {
+			s.elect(ctx, now)
+			nextCheck = now.Add(s.leaderCheck)
+		}
+		s.launchDue(runCtx, now)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// elect checks leadership and plans the next runs when this instance becomes leader
+func (s *Scheduler) elect(ctx context.Context, now time.Time) {
+	leader := s.lock == nil || s.lock.hold(ctx)
+	s.mu.Lock()
+	was := s.leader
+	s.leader = leader
+	s.mu.Unlock()
+
+	switch {
+	case leader && !was:
+		if s.lock != nil {
+			logs.Info("Scheduler: instance", s.instance, "is now running jobs")
+		}
+		s.plan(now)
+	case !leader && was:
+		logs.Warn("Scheduler: instance", s.instance, "is no longer running jobs")
+	}
+}
+
+// plan schedules every job after the latest recorded run, possibly by another instance, so
+// a new leader neither repeats a fresh run nor skips one that was due. Overdue jobs run now.
+func (s *Scheduler) plan(now time.Time) {
+	lastStarted := map[string]time.Time{}
+	if s.lock != nil {
+		rows, err := models.GetScheduledJobs()
+		if err != nil {
+			logs.Error("Scheduler could not load job history:", err)
+		}
+		for _, row := range rows {
+			if row.LastStartedAt != nil {
+				lastStarted[row.Name] = *row.LastStartedAt
+			}
+		}
+	}
+
+	s.mu.Lock()
+	planned := map[string]time.Time{}
+	for _, j := range s.jobs {
+		if j.status.LastStartedAt != nil && j.status.LastStartedAt.After(lastStarted[j.status.Name]) {
+			lastStarted[j.status.Name] = *j.status.LastStartedAt
+		}
+		next := j.schedule.Next(now)
+		if last, ok := lastStarted[j.status.Name]; ok {
+			if afterLast := j.schedule.Next(last); !afterLast.IsZero() && afterLast.Before(next) {
+				next = afterLast
+			}
+		}
+		if next.Before(now) {
+			next = now
+		}
+		j.next = next
+		j.status.NextRunAt = &next
+		planned[j.status.Name] = next
+	}
+	s.mu.Unlock()
+
+	if s.lock != nil {
+		for name, next := range planned {
+			if err := models.SetScheduledJobNextRun(name, next); err != nil {
+				logs.Error("Scheduler could not record next run of", name, ":", err)
+			}
+		}
+	}
+}
+
+// launchDue starts every due job that is not already running
+func (s *Scheduler) launchDue(runCtx context.Context, now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.leader {
+		return
+	}
+	for _, j := range s.jobs {
+		if j.status.Running || now.Before(j.next) {
+			continue
+		}
+		started := now
+		j.status.Running = true
+		j.status.LastStartedAt = &started
+		j.status.RunBy = s.instance
+		j.next = j.schedule.Next(now)
+		next := j.next
+		j.status.NextRunAt = &next
+
+		s.runs.Add(1)
+		go s.run(runCtx, j, started)
+	}
+}
+
+func (s *Scheduler) run(ctx context.Context, j *job, started time.Time) {
+	defer s.runs.Done()
+	name := j.status.Name
+	if s.lock != nil {
+		if err := models.StartScheduledJobRun(name, s.instance, started); err != nil {
+			logs.Error("Scheduler could not record start of", name, ":", err)
+		}
+	}
+
+	err := safeRun(ctx, j.run)
+	finished := time.Now()
+	duration := finished.Sub(started)
+
+	s.mu.Lock()
+	j.status.Running = false
+	j.status.LastFinishedAt = &finished
+	j.status.LastDurationMs = duration.Milliseconds()
+	j.status.RunCount++
+	errText := ""
+	if err != nil {
+		errText = err.Error()
+		j.status.LastError = errText
+		j.status.LastErrorAt = &finished
+		j.status.FailureCount++
+	} else {
+		j.status.LastError = ""
+		j.status.LastSuccessAt = &finished
+	}
+	next := j.next
+	s.mu.Unlock()
+
+	if err != nil {
+		logs.Error("Job", name, "failed:", err)
+	} else {
+		logs.Info("Job", name, "completed in", duration.Round(time.Millisecond))
+	}
+	if s.lock != nil {
+		if err := models.FinishScheduledJobRun(name, finished, duration, errText, next); err != nil {
+			logs.Error("Scheduler could not record outcome of", name, ":", err)
+		}
+	}
+}
+
+// safeRun runs a job, turning a panic into an error so one bad run does not stop the scheduler
+func safeRun(ctx context.Context, run JobFunc) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return run(ctx)
+}
+
+// Status lists the registered jobs. With a database it reports the latest recorded runs,
+// which may have been made by another instance.
+func (s *Scheduler) Status() ([]JobStatus, error) {
+	local := s.localStatus()
+	if s.lock == nil {
+		return local, nil
+	}
+	rows, err := models.GetScheduledJobs()
+	if err != nil {
+		return local, err
+	}
+	byName := map[string]*models.ScheduledJob{}
+	for _, row := range rows {
+		byName[row.Name] = row
+	}
+	for i, status := range local {
+		row := byName[status.Name]
+		if row == nil {
+			continue
+		}
+		local[i] = JobStatus{
+			Name:           status.Name,
+			Schedule:       status.Schedule,
+			Running:        row.LastStartedAt != nil && (row.LastFinishedAt == nil || row.LastFinishedAt.Before(*row.LastStartedAt)),
+			LastStartedAt:  row.LastStartedAt,
+			LastFinishedAt: row.LastFinishedAt,
+			LastDurationMs: row.LastDurationMs,
+			LastSuccessAt:  row.LastSuccessAt,
+			LastError:      row.LastError,
+			LastErrorAt:    row.LastErrorAt,
+			NextRunAt:      row.NextRunAt,
+			RunCount:       row.RunCount,
+			FailureCount:   row.FailureCount,
+			RunBy:          row.RunBy,
+		}
+	}
+	return local, nil
+}
+
+// localStatus is what this instance itself knows about its jobs
+func (s *Scheduler) localStatus() []JobStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	list := make([]JobStatus, 0, len(s.jobs))
+	for _, j := range s.jobs {
+		list = append(list, j.status)
+	}
+	return list
+}
@@ -0,0 +1,110 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func statusOf(t *testing.T, s *Scheduler, name string) JobStatus {
+	t.Helper()
+	list, err := s.Status()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, st := range list {
+		if st.Name == name {
+			return st
+		}
+	}
+	t.Fatalf("no status for job %s", name)
+	return JobStatus{}
+}
+
+// The scheduler ticks once per second, so these tests take a few seconds
+func TestSchedulerRuns(t *testing.T) {
+	if testing.Short() {
+		t.Skip("waits for scheduler ticks")
+	}
+	s := New(nil)
+	var okRuns int32
+	var slowFinished atomic.Bool
+	every := Every(time.Second)
+	_ = s.Register("ok", every, func(context.Context) error { atomic.AddInt32(&okRuns, 1); return nil })
+	_ = s.Register("fail", every, func(context.Context) error { return errors.New("boom") })
+	_ = s.Register("panic", every, func(context.Context) error { panic("oops") })
+	_ = s.Register("slow", every, func(ctx context.Context) error {
+		select {
+		case <-time.After(1500 * time.Millisecond):
+			slowFinished.Store(true)
+		case <-ctx.Done():
+		}
+		return nil
+	})
+	if s.Register("ok", every, nil) == nil {
+		t.Error("duplicate job name was accepted")
+	}
+
+	s.Start()
+	if Current() != s {
+		t.Error("started scheduler is not current")
+	}
+	if s.Register("late", every, nil) == nil {
+		t.Error("job registered after start was accepted")
+	}
+	time.Sleep(2300 * time.Millisecond)
+
+	if !s.Leader() {
+		t.Error("without a database the scheduler should lead")
+	}
+	if ok := statusOf(t, s, "ok"); ok.RunCount < 2 || ok.LastError != "" || ok.LastSuccessAt == nil || ok.NextRunAt == nil {
+		t.Errorf("ok job did not run on schedule: %+v", ok)
+	}
+	if fail := statusOf(t, s, "fail"); fail.FailureCount < 1 || fail.LastError != "boom" || fail.LastErrorAt == nil || fail.LastSuccessAt != nil {
+		t.Errorf("failed run not recorded: %+v", fail)
+	}
+	if p := statusOf(t, s, "panic"); p.FailureCount < 1 || !strings.Contains(p.LastError, "oops") {
+		t.Errorf("panic not recorded as an error: %+v", p)
+	}
+	if slow := statusOf(t, s, "slow"); !slow.Running {
+		t.Errorf("slow job is not running: %+v", slow)
+	}
+
+	if err := s.Stop(5 * time.Second); err != nil || !slowFinished.Load() {
+		t.Errorf("stop did not wait for the running job: %v", err)
+	}
+	if s.Leader() {
+		t.Error("stopped scheduler still leads")
+	}
+	runs := atomic.LoadInt32(&okRuns)
+	time.Sleep(1200 * time.Millisecond)
+	if got := atomic.LoadInt32(&okRuns); got != runs {
+		t.Errorf("%d runs after stop", got-runs)
+	}
+}
+
+func TestSchedulerStopTimeout(t *testing.T) {
+	if testing.Short() {
+		t.Skip("waits for scheduler ticks")
+	}
+	s := New(nil)
+	var cancelled atomic.Bool
+	_ = s.Register("stuck", Every(time.Second), func(ctx context.Context) error {
+		<-ctx.Done()
+		cancelled.Store(true)
+		return ctx.Err()
+	})
+	s.Start()
+	time.Sleep(1300 * time.Millisecond)
+
+	if err := s.Stop(200 * time.Millisecond); err == nil || !strings.Contains(err.Error(), "stuck") {
+		t.Errorf("stop should report the job still running after the timeout, got %v", err)
+	}
+	time.Sleep(50 * time.Millisecond)
+	if !cancelled.Load() {
+		t.Error("timed out job did not have its context cancelled")
+	}
+}
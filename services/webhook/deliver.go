@@ -0,0 +1,151 @@
+package webhook
+
+import (
+	"badminton-reservation-api/models"
+	"badminton-reservation-api/utils"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/beego/beego/v2/client/orm"
+	"github.com/beego/beego/v2/core/logs"
+)
+
+// maxBatches bounds one delivery run so a large backlog does not hold the job forever
+const maxBatches = 20
+
+// maxResponseBody is how much of an endpoint's response is kept in the attempt log
+const maxResponseBody = 1024
+
+// DeliveryResult summarizes one delivery run
+type DeliveryResult struct {
+	Claimed   int `json:"claimed"`
+	Delivered int `json:"delivered"`
+	Retrying  int `json:"retrying"`
+	Dead      int `json:"dead"`
+}
+
+// NewClient returns the HTTP client for endpoints: WEBHOOK_TIMEOUT (default 10s) per
+// request, and redirects are not followed, so a moved endpoint shows up as a failure
+func NewClient() *http.Client {
+	return &http.Client{
+		Timeout: utils.EnvDuration("WEBHOOK_TIMEOUT", 10*time.Second),
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+// Deliver sends due deliveries in batches of WEBHOOK_BATCH_SIZE (default 20) until none are
+// left or ctx is done. Each claimed delivery is leased for WEBHOOK_LEASE (default 5m), which
+// must cover a batch of WEBHOOK_TIMEOUT requests: should this process die mid-batch, the
+// deliveries are sent again after the lease.
+func Deliver(ctx context.Context) (*DeliveryResult, error) {
+	batch := utils.EnvInt("WEBHOOK_BATCH_SIZE", 20)
+	lease := utils.EnvDuration("WEBHOOK_LEASE", 5*time.Minute)
+	maxAttempts := MaxAttempts()
+	client := NewClient()
+
+	result := &DeliveryResult{}
+	endpoints := map[int]*models.WebhookEndpoint{}
+	for i := 0; i < maxBatches && ctx.Err() == nil; i++ {
+		deliveries, err := models.ClaimWebhookDeliveries(batch, lease)
+		if err != nil {
+			return result, err
+		}
+		result.Claimed += len(deliveries)
+		for _, d := range deliveries {
+			endpoint, ok := endpoints[d.EndpointId]
+			if !ok {
+				endpoint, err = models.GetWebhookEndpointById(d.EndpointId)
+				if errors.Is(err, orm.ErrNoRows) {
+					continue // deleted mid-run; its deliveries went with it
+				}
+				if err != nil {
+					return result, err
+				}
+				endpoints[d.EndpointId] = endpoint
+			}
+			if err := deliver(ctx, client, endpoint, d, maxAttempts, result); err != nil {
+				return result, err
+			}
+		}
+		if len(deliveries) < batch {
+			break
+		}
+	}
+	return result, nil
+}
+
+// deliver sends one claimed delivery and records the attempt
+func deliver(ctx context.Context, client *http.Client, endpoint *models.WebhookEndpoint, d *models.WebhookDelivery, maxAttempts int, result *DeliveryResult) error {
+	attempt := Send(ctx, client, endpoint, d)
+	status, next := Outcome(attempt, maxAttempts)
+	switch status {
+	case models.WebhookDelivered:
+		result.Delivered++
+	case models.WebhookDead:
+		result.Dead++
+		logs.Error("Webhook delivery", d.Id, d.EventType, "to", endpoint.Url, "is dead after", d.Attempts, "attempts:", attempt.Error)
+	default:
+		result.Retrying++
+		logs.Warn("Webhook delivery", d.Id, d.EventType, "to", endpoint.Url, "attempt", d.Attempts, "failed:", attempt.Error)
+	}
+	return models.RecordWebhookAttempt(attempt, status, next)
+}
+
+// Outcome is the status a delivery moves to after attempt, and when a pending one is retried
+func Outcome(attempt *models.WebhookAttempt, maxAttempts int) (string, time.Time) {
+	if attempt.Error == "" {
+		return models.WebhookDelivered, time.Now()
+	}
+	if attempt.Attempt >= maxAttempts {
+		return models.WebhookDead, time.Now()
+	}
+	return models.WebhookPending, time.Now().Add(Backoff(attempt.Attempt))
+}
+
+// Send POSTs a delivery to endpoint, signed with its secret, and returns the attempt. Any
+// 2xx response is a success; the attempt's Error is set otherwise.
+func Send(ctx context.Context, client *http.Client, endpoint *models.WebhookEndpoint, d *models.WebhookDelivery) *models.WebhookAttempt {
+	attempt := &models.WebhookAttempt{DeliveryId: d.Id, Attempt: d.Attempts}
+	body := []byte(d.Payload)
+	timestamp := time.Now().Unix()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint.Url, bytes.NewReader(body))
+	if err != nil {
+		attempt.Error = err.Error()
+		return attempt
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "badminton-reservation-webhooks/1.0")
+	req.Header.Set(HeaderId, strconv.FormatInt(d.Id, 10))
+	req.Header.Set(HeaderEvent, d.EventType)
+	req.Header.Set(HeaderTimestamp, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(HeaderSignature, Sign(endpoint.Secret, timestamp, body))
+
+	started := time.Now()
+	resp, err := client.Do(req)
+	attempt.DurationMs = int(time.Since(started).Milliseconds())
+	if err != nil {
+		attempt.Error = err.Error()
+		return attempt
+	}
+	defer resp.Body.Close()
+
+	attempt.StatusCode = resp.StatusCode
+	respBody, _ := io.ReadAll(io.LimitReader(resp.Body, maxResponseBody))
+	// Postgres text holds neither invalid UTF-8 nor NUL bytes
+	attempt.ResponseBody = strings.ReplaceAll(strings.ToValidUTF8(string(respBody), string(utf8.RuneError)), "\x00", "")
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		attempt.Error = fmt.Sprintf("endpoint returned %d", resp.StatusCode)
+	}
+	return attempt
+}
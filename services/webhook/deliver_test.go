@@ -0,0 +1,116 @@
+package webhook
+
+import (
+	"badminton-reservation-api/models"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSend(t *testing.T) {
+	type received struct {
+		header http.Header
+		body   []byte
+	}
+	var got []received
+	reply := func(w http.ResponseWriter) { w.WriteHeader(http.StatusNoContent) }
+	partner := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		got = append(got, received{header: r.Header.Clone(), body: body})
+		reply(w)
+	}))
+	defer partner.Close()
+
+	envelope, err := json.Marshal(&Envelope{
+		Id: 42, Type: models.EventReservationPaid, AggregateType: models.AggregateReservation,
+		AggregateId: "3f2c9a1e-7b4d-4c1a-9e2f-5d6c7b8a9f01", CreatedAt: time.Now(),
+		Data: json.RawMessage(`{"reservation_id":"3f2c9a1e-7b4d-4c1a-9e2f-5d6c7b8a9f01","status":"paid"}`),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	endpoint := &models.WebhookEndpoint{Id: 1, Url: partner.URL + "/hooks", Secret: "whsec_partner", IsActive: true}
+	delivery := &models.WebhookDelivery{Id: 7, EndpointId: 1, OutboxEventId: 42, EventType: models.EventReservationPaid, Payload: string(envelope), Attempts: 1}
+	client := NewClient()
+
+	attempt := Send(context.Background(), client, endpoint, delivery)
+	if attempt.Error != "" || attempt.StatusCode != 204 || attempt.DeliveryId != 7 || attempt.Attempt != 1 {
+		t.Errorf("2xx response is not a success: %+v", attempt)
+	}
+	if len(got) != 1 {
+		t.Fatalf("partner received %d requests, want 1", len(got))
+	}
+	h := got[0].header
+	if string(got[0].body) != delivery.Payload {
+		t.Errorf("body is %s, want the queued envelope", got[0].body)
+	}
+	if h.Get(HeaderId) != "7" || h.Get(HeaderEvent) != "ReservationPaid" || h.Get("Content-Type") != "application/json" {
+		t.Errorf("headers do not name the delivery and event: %v", h)
+	}
+	if !Verify("whsec_partner", h.Get(HeaderTimestamp), got[0].body, h.Get(HeaderSignature), time.Minute) {
+		t.Errorf("signature %s does not verify with the endpoint secret", h.Get(HeaderSignature))
+	}
+	var env map[string]interface{}
+	if err := json.Unmarshal(got[0].body, &env); err != nil {
+		t.Fatal(err)
+	}
+	data, _ := env["data"].(map[string]interface{})
+	if env["id"] != float64(42) || env["type"] != "ReservationPaid" || env["aggregate_type"] != "reservation" || data["status"] != "paid" {
+		t.Errorf("envelope is %v", env)
+	}
+
+	reply = func(w http.ResponseWriter) {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte("database down\x00\xff"))
+	}
+	attempt = Send(context.Background(), client, endpoint, delivery)
+	if attempt.Error != "endpoint returned 500" || attempt.StatusCode != 500 {
+		t.Errorf("5xx response is not a failure: %+v", attempt)
+	}
+	if attempt.ResponseBody != "database down�" {
+		t.Errorf("response body kept as %q, want clean text", attempt.ResponseBody)
+	}
+
+	reply = func(w http.ResponseWriter) {
+		w.Header().Set("Location", "https://example.com/moved")
+		w.WriteHeader(http.StatusMovedPermanently)
+	}
+	calls := len(got)
+	attempt = Send(context.Background(), client, endpoint, delivery)
+	if attempt.StatusCode != 301 || attempt.Error == "" || len(got) != calls+1 {
+		t.Errorf("redirect was followed or accepted: %+v", attempt)
+	}
+
+	reply = func(w http.ResponseWriter) {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(strings.Repeat("x", 5000)))
+	}
+	attempt = Send(context.Background(), client, endpoint, delivery)
+	if attempt.Error != "" || len(attempt.ResponseBody) != 1024 {
+		t.Errorf("long response kept as %d bytes, want 1024", len(attempt.ResponseBody))
+	}
+
+	attempt = Send(context.Background(), client, &models.WebhookEndpoint{Url: "http://127.0.0.1:1", Secret: "s"}, delivery)
+	if attempt.Error == "" || attempt.StatusCode != 0 {
+		t.Errorf("unreachable endpoint is not a failure: %+v", attempt)
+	}
+}
+
+func TestSendTimeout(t *testing.T) {
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(500 * time.Millisecond)
+	}))
+	defer slow.Close()
+	t.Setenv("WEBHOOK_TIMEOUT", "100ms")
+
+	delivery := &models.WebhookDelivery{Id: 7, Payload: "{}", Attempts: 1}
+	attempt := Send(context.Background(), NewClient(), &models.WebhookEndpoint{Url: slow.URL, Secret: "s"}, delivery)
+	if attempt.Error == "" || attempt.StatusCode != 0 {
+		t.Errorf("slow endpoint did not time out: %+v", attempt)
+	}
+}
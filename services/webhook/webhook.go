@@ -0,0 +1,121 @@
+// Package webhook sends booking and payment domain events to partner endpoints registered
+// by admins. An outbox handler fans every event out into one delivery per subscribed
+// endpoint; the deliver_webhooks job POSTs due deliveries, retrying failures with
+// exponential backoff until WEBHOOK_MAX_ATTEMPTS, after which the delivery is dead until an
+// admin replays it. Every request is signed with the endpoint's secret.
+//
+// A request carries the headers
+//
+//	X-Webhook-Id:        delivery id, the same on every retry and replay
+//	X-Webhook-Event:     event type, e.g. ReservationPaid
+//	X-Webhook-Timestamp: unix seconds when the request was signed
+//	X-Webhook-Signature: sha256=<hex HMAC-SHA256 of "<timestamp>.<body>" keyed by the secret>
+//
+// and an Envelope as body. Receivers should check the signature with Verify, reject stale
+// timestamps, and use the envelope id to ignore events they have already seen.
+package webhook
+
+import (
+	"badminton-reservation-api/models"
+	"badminton-reservation-api/utils"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"strconv"
+	"time"
+)
+
+// Request headers
+const (
+	HeaderId        = "X-Webhook-Id"
+	HeaderEvent     = "X-Webhook-Event"
+	HeaderTimestamp = "X-Webhook-Timestamp"
+	HeaderSignature = "X-Webhook-Signature"
+)
+
+// Envelope is the body of every webhook request
+type Envelope struct {
+	// Id is the domain event id; an event has the same id at every endpoint
+	Id            int64           `json:"id"`
+	Type          string          `json:"type"`
+	AggregateType string          `json:"aggregate_type"`
+	AggregateId   string          `json:"aggregate_id"`
+	CreatedAt     time.Time       `json:"created_at"`
+	Data          json.RawMessage `json:"data"`
+}
+
+// NewSecret returns a random signing secret for an endpoint
+func NewSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return "whsec_" + hex.EncodeToString(b), nil
+}
+
+// Sign returns the X-Webhook-Signature value for body sent at timestamp (unix seconds)
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify reports whether signature was made by secret for body at timestamp, and timestamp
+// is no further than tolerance from now. Receivers in Go can use it as is.
+func Verify(secret, timestamp string, body []byte, signature string, tolerance time.Duration) bool {
+	ts, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return false
+	}
+	age := time.Since(time.Unix(ts, 0))
+	if age > tolerance || age < -tolerance {
+		return false
+	}
+	return hmac.Equal([]byte(Sign(secret, ts, body)), []byte(signature))
+}
+
+// Fanout is the outbox handler queuing event for every active endpoint that subscribed to it
+func Fanout(ctx context.Context, event *models.OutboxEvent) error {
+	endpoints, err := models.GetWebhookEndpoints(true)
+	if err != nil {
+		return err
+	}
+	var ids []int
+	for _, e := range endpoints {
+		if e.Receives(event.EventType) {
+			ids = append(ids, e.Id)
+		}
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+	body, err := json.Marshal(&Envelope{
+		Id:            event.Id,
+		Type:          event.EventType,
+		AggregateType: event.AggregateType,
+		AggregateId:   event.AggregateId,
+		CreatedAt:     event.CreatedAt,
+		Data:          json.RawMessage(event.Payload),
+	})
+	if err != nil {
+		return err
+	}
+	_, err = models.CreateWebhookDeliveries(event, ids, string(body))
+	return err
+}
+
+// Backoff is how long to wait after the attempt-th failed attempt: 30s doubling up to 6h
+func Backoff(attempt int) time.Duration {
+	return utils.Backoff(attempt, 30*time.Second, 6*time.Hour)
+}
+
+// MaxAttempts is how many attempts a delivery gets before it is dead (WEBHOOK_MAX_ATTEMPTS,
+// default 12, retried over about 14 hours)
+func MaxAttempts() int {
+	return utils.EnvInt("WEBHOOK_MAX_ATTEMPTS", 12)
+}
@@ -0,0 +1,90 @@
+package webhook
+
+import (
+	"badminton-reservation-api/models"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSign(t *testing.T) {
+	want := "sha256=2f441ba4b3b2d50d28a9ab9d9fd8880376ecd1eb5d0435401553f5d8d0a5dcf8"
+	if got := Sign("whsec_test", 1700000000, []byte(`{"id":1}`)); got != want {
+		t.Errorf("signature is %s, want HMAC-SHA256 of timestamp.body %s", got, want)
+	}
+}
+
+func TestVerify(t *testing.T) {
+	body := []byte(`{"id":1}`)
+	now := time.Now().Unix()
+	ts := strconv.FormatInt(now, 10)
+	fresh := Sign("whsec_test", now, body)
+	if !Verify("whsec_test", ts, body, fresh, 5*time.Minute) {
+		t.Error("fresh signature does not verify")
+	}
+
+	old, future := now-600, now+600
+	refused := []struct {
+		name, secret, timestamp, signature string
+		body                               []byte
+	}{
+		{"tampered body", "whsec_test", ts, fresh, []byte(`{"id":2}`)},
+		{"wrong secret", "whsec_other", ts, fresh, body},
+		{"shifted timestamp", "whsec_test", strconv.FormatInt(now+1, 10), fresh, body},
+		{"stale timestamp", "whsec_test", strconv.FormatInt(old, 10), Sign("whsec_test", old, body), body},
+		{"future timestamp", "whsec_test", strconv.FormatInt(future, 10), Sign("whsec_test", future, body), body},
+		{"malformed timestamp", "whsec_test", "yesterday", fresh, body},
+	}
+	for _, tt := range refused {
+		if Verify(tt.secret, tt.timestamp, tt.body, tt.signature, 5*time.Minute) {
+			t.Errorf("%s verifies", tt.name)
+		}
+	}
+}
+
+func TestNewSecret(t *testing.T) {
+	a, err := NewSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := NewSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a == b || !strings.HasPrefix(a, "whsec_") || len(a) != 70 {
+		t.Errorf("secrets %q and %q are not random 32-byte keys", a, b)
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	for i, want := range []time.Duration{30 * time.Second, time.Minute, 2 * time.Minute, 4 * time.Minute} {
+		if got := Backoff(i + 1); got != want {
+			t.Errorf("backoff after attempt %d is %s, want %s", i+1, got, want)
+		}
+	}
+	if Backoff(11) != 6*time.Hour || Backoff(40) != 6*time.Hour {
+		t.Errorf("backoff is not capped at 6h: %s", Backoff(40))
+	}
+
+	var total time.Duration
+	for i := 1; i < MaxAttempts(); i++ {
+		total += Backoff(i)
+	}
+	if MaxAttempts() != 12 || total <= 13*time.Hour || total >= 15*time.Hour {
+		t.Errorf("%d default attempts span %s, want 12 over about 14 hours", MaxAttempts(), total)
+	}
+}
+
+func TestOutcome(t *testing.T) {
+	if status, _ := Outcome(&models.WebhookAttempt{Attempt: 1, StatusCode: 204}, 12); status != models.WebhookDelivered {
+		t.Errorf("2xx attempt is %s, want delivered", status)
+	}
+	status, next := Outcome(&models.WebhookAttempt{Attempt: 3, StatusCode: 500, Error: "endpoint returned 500"}, 12)
+	if wait := time.Until(next); status != models.WebhookPending || wait <= 110*time.Second || wait > 2*time.Minute {
+		t.Errorf("failed attempt is %s, retried in %s; want pending after the 2m backoff", status, wait)
+	}
+	if status, _ := Outcome(&models.WebhookAttempt{Attempt: 12, Error: "connection refused"}, 12); status != models.WebhookDead {
+		t.Errorf("last allowed attempt is %s, want dead", status)
+	}
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"badminton-reservation-api/services/notification/smtptest"
+	"flag"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/mail"
+	"os"
+	"os/signal"
+	"strings"
+)
+
+// Local SMTP stand-in for development: accepts every email the API sends and prints it.
+// Point the API at it with SMTP_HOST=127.0.0.1 SMTP_PORT=1025 SMTP_TLS=none.
+// Usage:
+//  go run tools/smtp_standin/main.go -addr 127.0.0.1:1025
+
+func main() {
+	addr := flag.String("addr", "127.0.0.1:1025", "address to listen on")
+	flag.Parse()
+
+	s, err := smtptest.NewServer(*addr)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "listen:", err)
+		os.Exit(1)
+	}
+	s.OnMessage = func(m smtptest.Received) {
+		subject, text := summarize(m.Data)
+		fmt.Printf("---\nFrom: %s\nTo: %s\nSubject: %s\n\n%s\n", m.From, strings.Join(m.To, ", "), subject, text)
+	}
+	fmt.Printf("SMTP stand-in listening on %s:%s (Ctrl+C to stop)\n", s.Host, s.Port)
+
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, os.Interrupt)
+	<-stop
+	s.Close()
+}
+
+// summarize returns the decoded subject and plain-text part of a message, or the raw
+// message when it is not multipart
+func summarize(data string) (string, string) {
+	msg, err := mail.ReadMessage(strings.NewReader(data))
+	if err != nil {
+		return "", data
+	}
+	subject, _ := new(mime.WordDecoder).DecodeHeader(msg.Header.Get("Subject"))
+	_, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	if err != nil || params["boundary"] == "" {
+		return subject, data
+	}
+	mr := multipart.NewReader(msg.Body, params["boundary"])
+	for {
+		part, err := mr.NextPart()
+		if err != nil {
+			return subject, data
+		}
+		if strings.HasPrefix(part.Header.Get("Content-Type"), "text/plain") {
+			// multipart.Reader decodes quoted-printable parts itself
+			body, _ := io.ReadAll(part)
+			return subject, string(body)
+		}
+	}
+}
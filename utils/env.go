@@ -0,0 +1,37 @@
+package utils
+
+import (
+	"os"
+	"strconv"
+	"time"
+)
+
+// EnvInt reads a positive integer from env, falling back to def when it is unset or invalid
+func EnvInt(key string, def int) int {
+	if n, err := strconv.Atoi(os.Getenv(key)); err == nil && n > 0 {
+		return n
+	}
+	return def
+}
+
+// EnvDuration reads a positive Go duration (e.g. 24h) from env, falling back to def when it is
+// unset or invalid
+func EnvDuration(key string, def time.Duration) time.Duration {
+	if d, err := time.ParseDuration(os.Getenv(key)); err == nil && d > 0 {
+		return d
+	}
+	return def
+}
+
+// Backoff is the wait after the attempt-th failed attempt (1 is the first): base doubling per
+// attempt, at most max
+func Backoff(attempt int, base, max time.Duration) time.Duration {
+	d := base
+	for i := 1; i < attempt && d < max; i++ {
+		d *= 2
+	}
+	if d > max {
+		d = max
+	}
+	return d
+}
@@ -0,0 +1,36 @@
+package utils
+
+import (
+	"testing"
+	"time"
+)
+
+func TestEnvInt(t *testing.T) {
+	for value, want := range map[string]int{"": 5, "12": 12, "0": 5, "-3": 5, "ten": 5} {
+		t.Setenv("TEST_ENV_INT", value)
+		if got := EnvInt("TEST_ENV_INT", 5); got != want {
+			t.Errorf("%q read as %d, want %d", value, got, want)
+		}
+	}
+}
+
+func TestEnvDuration(t *testing.T) {
+	for value, want := range map[string]time.Duration{"": time.Minute, "90s": 90 * time.Second, "0s": time.Minute, "-1h": time.Minute, "10": time.Minute} {
+		t.Setenv("TEST_ENV_DURATION", value)
+		if got := EnvDuration("TEST_ENV_DURATION", time.Minute); got != want {
+			t.Errorf("%q read as %s, want %s", value, got, want)
+		}
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	want := []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 5 * time.Second, 5 * time.Second}
+	for i, w := range want {
+		if got := Backoff(i+1, time.Second, 5*time.Second); got != w {
+			t.Errorf("attempt %d waits %s, want %s", i+1, got, w)
+		}
+	}
+	if got := Backoff(1000, time.Second, 5*time.Second); got != 5*time.Second {
+		t.Errorf("attempt 1000 waits %s, want the 5s cap", got)
+	}
+}
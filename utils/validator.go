@@ -2,6 +2,7 @@ package utils
 
 import (
 	"errors"
+	"os"
 	"regexp"
 	"strings"
 	"time"
@@ -27,15 +28,53 @@ func ValidateEmail(email string) bool {
 	return re.MatchString(email)
 }
 
-// ValidatePhone performs a basic phone number validation (digits, spaces, +, -, parentheses)
-func ValidatePhone(phone string) bool {
-	if strings.TrimSpace(phone) == "" {
-		return false
+// ErrInvalidPhone is returned by NormalizePhone for anything that is not a phone number
+var ErrInvalidPhone = errors.New("invalid phone number")
+
+// PhoneCallingCode is the country calling code for numbers given without one
+// (PHONE_COUNTRY_CODE, default 62 for Indonesia)
+func PhoneCallingCode() string {
+	if code := strings.TrimPrefix(strings.TrimSpace(os.Getenv("PHONE_COUNTRY_CODE")), "+"); code != "" {
+		return code
+	}
+	return "62"
+}
+
+// NormalizePhone returns phone in E.164 form, e.g. "0812-3456-7890" -> "+6281234567890".
+// Spaces, dashes, dots and parentheses are dropped and a leading 00 counts as +. A number
+// without a country code gets callingCode, replacing its trunk 0; a trunk 0 written after
+// callingCode ("+62 0812...") is dropped as well. The result must have 8 to 15 digits.
+func NormalizePhone(phone, callingCode string) (string, error) {
+	digits := strings.Map(func(r rune) rune {
+		switch r {
+		case ' ', '-', '.', '(', ')':
+			return -1
+		}
+		return r
+	}, strings.TrimSpace(phone))
+
+	switch {
+	case strings.HasPrefix(digits, "+"):
+		digits = digits[1:]
+	case strings.HasPrefix(digits, "00"):
+		digits = digits[2:]
+	case strings.HasPrefix(digits, "0"):
+		digits = callingCode + digits[1:]
+	case !strings.HasPrefix(digits, callingCode):
+		digits = callingCode + digits
+	}
+	if rest := strings.TrimPrefix(digits, callingCode+"0"); rest != digits {
+		digits = callingCode + rest
 	}
-	re := regexp.MustCompile(`^[0-9()+\-\s]{6,20}$`)
-	return re.MatchString(phone)
+
+	if !phoneDigits.MatchString(digits) {
+		return "", ErrInvalidPhone
+	}
+	return "+" + digits, nil
 }
 
+var phoneDigits = regexp.MustCompile(`^[1-9][0-9]{7,14}$`)
+
 // ValidateDate checks whether a date string matches YYYY-MM-DD
 func ValidateDate(dateStr string) bool {
 	if strings.TrimSpace(dateStr) == "" {
@@ -45,6 +84,15 @@ func ValidateDate(dateStr string) bool {
 	return err == nil
 }
 
+// ValidateTime checks whether a time string matches HH:MM:SS (24-hour clock)
+func ValidateTime(timeStr string) bool {
+	if len(timeStr) != len("15:04:05") {
+		return false
+	}
+	_, err := time.Parse("15:04:05", timeStr)
+	return err == nil
+}
+
 // ValidateDateRange verifies that the given date is not before today and within 'days' days ahead
 func ValidateDateRange(dateStr string, days int) (bool, error) {
 	if !ValidateDate(dateStr) {
@@ -0,0 +1,25 @@
+package utils
+
+import "testing"
+
+func TestNormalizePhone(t *testing.T) {
+	for in, want := range map[string]string{
+		"0812-3456-7890":       "+6281234567890",
+		"+62 812 3456 7890":    "+6281234567890",
+		"62812345678":          "+62812345678",
+		"812 3456 7890":        "+6281234567890",
+		"+62 (0)812-3456-7890": "+6281234567890",
+		"0062 812 3456 7890":   "+6281234567890",
+		"+1 (415) 555-0100":    "+14155550100",
+		"(021) 555-0100":       "+62215550100",
+	} {
+		if got, err := NormalizePhone(in, "62"); err != nil || got != want {
+			t.Errorf("NormalizePhone(%q) = %q, %v; want %q", in, got, err, want)
+		}
+	}
+	for _, in := range []string{"", "12345", "0812-abc-7890", "+0812345678", "+1234567890123456"} {
+		if _, err := NormalizePhone(in, "62"); err != ErrInvalidPhone {
+			t.Errorf("NormalizePhone(%q): got %v, want ErrInvalidPhone", in, err)
+		}
+	}
+}